@@ -0,0 +1,161 @@
+package gominitrader
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BACKTEST_WINDOW is the number of candles handed to the strategy on every bar; it matches
+// the number of candles the MinitraderPool fetches for live minitraders.
+const BACKTEST_WINDOW = 200
+
+type Backtester struct {
+	Strategy             Strategy
+	StatefulStrategy     StatefulStrategy // used instead of Strategy and Window when set
+	InitialBalance       float64
+	InvestmentPercentage float64
+	StopLossPercentage   float64
+	ProfitPercentage     float64
+	Window               int
+}
+
+type BacktestExitReason string
+
+const (
+	STOP_LOSS   BacktestExitReason = "STOP_LOSS"
+	TAKE_PROFIT BacktestExitReason = "TAKE_PROFIT"
+	END_OF_DATA BacktestExitReason = "END_OF_DATA"
+)
+
+type BacktestTrade struct {
+	EntryTimestamp int64
+	EntryPrice     float64
+	ExitTimestamp  int64
+	ExitPrice      float64
+	ExitReason     BacktestExitReason
+	Size           float64
+	ProfitLoss     float64
+}
+
+type EquityPoint struct {
+	Timestamp int64
+	Equity    float64
+}
+
+type BacktestResult struct {
+	Trades       []BacktestTrade
+	EquityCurve  []EquityPoint
+	FinalBalance float64
+}
+
+func NewBacktester(initialBalance float64, investmentPercentage float64, stopLossPercentage float64, profitPercentage float64, strategy Strategy) *Backtester {
+	return &Backtester{
+		Strategy:             strategy,
+		InitialBalance:       initialBalance,
+		InvestmentPercentage: investmentPercentage,
+		StopLossPercentage:   stopLossPercentage,
+		ProfitPercentage:     profitPercentage,
+		Window:               BACKTEST_WINDOW,
+	}
+}
+
+func NewStatefulBacktester(initialBalance float64, investmentPercentage float64, stopLossPercentage float64, profitPercentage float64, strategy StatefulStrategy) *Backtester {
+	backtester := NewBacktester(initialBalance, investmentPercentage, stopLossPercentage, profitPercentage, nil)
+	backtester.StatefulStrategy = strategy
+	return backtester
+}
+
+// RunStore replays the candles of an epic and timeframe stored between from and to, both inclusive.
+func (backtester *Backtester) RunStore(store CandleStore, epic string, timeframe Timeframe, from int64, to int64) (BacktestResult, error) {
+	candles, err := store.Range(epic, timeframe, from, to)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+	if len(candles) == 0 {
+		return BacktestResult{}, errors.New(fmt.Sprintf("No %s %s candles stored between %d and %d", epic, timeframe, from, to))
+	}
+	return backtester.Run(candles)
+}
+
+// Run replays candles bar by bar. The strategy is reset and fed one candle at a time, so it never sees a
+// candle after the current bar; plain Strategy functions are handed the last `Window` candles. Once the
+// strategy is warmed up, its signals are handled with the same rules `Minitrader.Effect` uses.
+func (backtester *Backtester) Run(candles Candles) (BacktestResult, error) {
+	strategy := backtester.StatefulStrategy
+	if strategy == nil {
+		if backtester.Strategy == nil {
+			return BacktestResult{}, errors.New("Backtester Strategy cannot be nil")
+		}
+		if backtester.Window < 1 {
+			return BacktestResult{}, errors.New("Backtester Window must be greater than zero")
+		}
+		strategy = NewFuncStrategy(backtester.Strategy, backtester.Window)
+	}
+	if backtester.InvestmentPercentage <= 0 || backtester.InvestmentPercentage > 100 {
+		return BacktestResult{}, fmt.Errorf("Backtester InvestmentPercentage must be in (0, 100]; Current: %f", backtester.InvestmentPercentage)
+	}
+	warmUp := strategy.WarmUp()
+	if warmUp < 1 {
+		warmUp = 1
+	}
+	if len(candles) < warmUp {
+		return BacktestResult{}, fmt.Errorf("Not Enough Candles To Backtest; Need At Least %d, Got %d", warmUp, len(candles))
+	}
+	strategy.Reset()
+
+	result := BacktestResult{}
+	balance := backtester.InitialBalance
+	holding := false
+	var trade BacktestTrade
+
+	closeTrade := func(timestamp int64, price float64, reason BacktestExitReason) {
+		trade.ExitTimestamp = timestamp
+		trade.ExitPrice = price
+		trade.ExitReason = reason
+		trade.ProfitLoss = (price - trade.EntryPrice) * trade.Size
+		balance += trade.Size * price
+		result.Trades = append(result.Trades, trade)
+		holding = false
+	}
+
+	for i, candle := range candles {
+		signal, price := strategy.OnCandle(candle)
+		if i < warmUp-1 {
+			continue
+		}
+
+		// exits are checked before entries, like Minitrader.Effect does
+		if holding && hitsStopLoss(trade.EntryPrice, backtester.StopLossPercentage, price) {
+			closeTrade(candle.Timestamp, price, STOP_LOSS)
+		}
+		if holding && hitsTakeProfit(trade.EntryPrice, backtester.ProfitPercentage, price) {
+			closeTrade(candle.Timestamp, price, TAKE_PROFIT)
+		}
+		if !holding && signal == BUY && price > 0 {
+			amount := balance * backtester.InvestmentPercentage / 100
+			trade = BacktestTrade{
+				EntryTimestamp: candle.Timestamp,
+				EntryPrice:     price,
+				Size:           amount / price,
+			}
+			balance -= amount
+			holding = true
+		}
+
+		equity := balance
+		if holding {
+			equity += trade.Size * candle.Close.Bid
+		}
+		result.EquityCurve = append(result.EquityCurve, EquityPoint{Timestamp: candle.Timestamp, Equity: equity})
+	}
+
+	// close any open trade with the last known price
+	if holding {
+		last := candles[len(candles)-1]
+		closeTrade(last.Timestamp, last.Close.Bid, END_OF_DATA)
+		result.EquityCurve[len(result.EquityCurve)-1].Equity = balance
+	}
+	result.FinalBalance = balance
+
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package gominitrader
+
+import (
+	"math"
+	"testing"
+)
+
+func _TestCandles(closes ...float64) Candles {
+	candles := make(Candles, len(closes))
+	for i, price := range closes {
+		candles[i] = Candle{
+			Timestamp: int64(i) * 60,
+			Open:      BidAskPrice{Bid: price, Ask: price},
+			High:      BidAskPrice{Bid: price, Ask: price},
+			Low:       BidAskPrice{Bid: price, Ask: price},
+			Close:     BidAskPrice{Bid: price, Ask: price},
+		}
+	}
+	return candles
+}
+
+func TestBacktestNoLookAhead(t *testing.T) {
+	candles := _TestCandles(1, 2, 3, 4, 5, 6)
+	seen := 0
+	strategy := func(window Candles) (Signal, float64) {
+		last := window[len(window)-1]
+		if last.Timestamp != int64(seen+2)*60 {
+			t.Errorf("strategy saw candle %d on bar %d", last.Timestamp/60, seen+2)
+		}
+		if len(window) != 3 {
+			t.Errorf("expected window of 3 candles, got %d", len(window))
+		}
+		seen++
+		return NONE, last.Close.Bid
+	}
+
+	backtester := NewBacktester(1000, 100, 5, 5, strategy)
+	backtester.Window = 3
+	result, err := backtester.Run(candles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen != 4 {
+		t.Errorf("expected 4 evaluated bars, got %d", seen)
+	}
+	if len(result.EquityCurve) != 4 || result.FinalBalance != 1000 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestBacktestExits(t *testing.T) {
+	toleranceError := 1e-9
+	tests := []struct {
+		closes         []float64
+		expectedReason BacktestExitReason
+		expectedExit   float64
+	}{
+		{[]float64{100, 100, 101, 106}, TAKE_PROFIT, 106},
+		{[]float64{100, 100, 99, 94}, STOP_LOSS, 94},
+		{[]float64{100, 100, 101, 102}, END_OF_DATA, 102},
+	}
+
+	for i, test := range tests {
+		bought := false
+		strategy := func(window Candles) (Signal, float64) {
+			price := window[len(window)-1].Close.Bid
+			if !bought {
+				bought = true
+				return BUY, price
+			}
+			return NONE, price
+		}
+		backtester := NewBacktester(1000, 50, 5, 5, strategy)
+		backtester.Window = 2
+		result, err := backtester.Run(_TestCandles(test.closes...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Trades) != 1 {
+			t.Fatalf("Test case %d: expected 1 trade, got %d", i, len(result.Trades))
+		}
+		trade := result.Trades[0]
+		if trade.ExitReason != test.expectedReason || trade.ExitPrice != test.expectedExit {
+			t.Errorf("Test case %d: unexpected exit %s at %f", i, trade.ExitReason, trade.ExitPrice)
+		}
+		expectedBalance := 1000 + (test.expectedExit-100)*5
+		if math.Abs(result.FinalBalance-expectedBalance) > toleranceError {
+			t.Errorf("Test case %d: expected final balance %f, got %f", i, expectedBalance, result.FinalBalance)
+		}
+	}
+}
+
+func TestBacktestGPTStrategy(t *testing.T) {
+	closes := make([]float64, 300)
+	for i := range closes {
+		closes[i] = 20 + math.Sin(float64(i)/5)
+	}
+	result, err := NewBacktester(1000, 100, 2, 0.35, GPTStrategy).Run(_TestCandles(closes...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.EquityCurve) != 101 {
+		t.Errorf("expected 101 equity points, got %d", len(result.EquityCurve))
+	}
+}
+
+func TestBacktestStatefulStrategy(t *testing.T) {
+	strategy := &_TestRecordingStrategy{warmUp: 3}
+	strategy.seen = _TestCandles(100) // leftovers from a previous run are reset
+	backtester := NewStatefulBacktester(1000, 100, 5, 5, strategy)
+
+	result, err := backtester.Run(_TestCandles(100, 100, 100, 101, 106))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strategy.resets != 1 || len(strategy.seen) != 5 {
+		t.Errorf("expected 1 reset and 5 candles seen, got %d and %d", strategy.resets, len(strategy.seen))
+	}
+	if len(result.EquityCurve) != 3 || len(result.Trades) == 0 || result.Trades[0].EntryTimestamp != 120 {
+		t.Errorf("signals before warm up should be ignored: %+v", result)
+	}
+
+	if _, err := NewStatefulBacktester(1000, 100, 5, 5, &_TestRecordingStrategy{warmUp: 10}).Run(_TestCandles(1, 2)); err == nil {
+		t.Error("expected not enough candles error")
+	}
+}
@@ -0,0 +1,56 @@
+package gominitrader
+
+import (
+	"context"
+	"net/http"
+)
+
+// Broker is the set of operations a Minitrader and a MinitraderPool need from a broker.
+// CapitalClientAPI is the Capital.com implementation; simulators and test doubles can
+// implement it as well.
+type Broker interface {
+	// session
+	CreateNewSession(ctx context.Context) (NewSessionResponse, http.Header, error)
+
+	// accounts
+	GetAllAccounts(ctx context.Context) (AccountsResponse, error)
+	GetPreferredAccount(ctx context.Context) (AccountResponse, error)
+
+	// markets
+	GetMarketsDetails(ctx context.Context, epics []string) (MarketsDetailsResponse, error)
+	GetHistoricalPrices(ctx context.Context, epic string, resolution Timeframe, numberOfCandles int) (PricesResponse, error)
+
+	// working orders
+	CreateWorkingOrder(ctx context.Context, workingOrder CreateWorkingOrderBody) (WorkingOrderResponse, error)
+	UpdateWorkingOrder(ctx context.Context, dealId string, update UpdateWorkingOrderBody) (WorkingOrderResponse, error)
+	GetAllWorkingOrders(ctx context.Context) (WorkingOrdersResponse, error)
+	DeleteWorkingOrder(ctx context.Context, dealReference string) (WorkingOrderResponse, error)
+
+	// positions
+	GetPositions(ctx context.Context) (PositionsResponse, error)
+	GetPosition(ctx context.Context, dealId string) (PositionResponse, error)
+	CreatePosition(ctx context.Context, position CreatePositionBody) (DealReferenceResponse, error)
+	UpdatePosition(ctx context.Context, dealId string, update UpdatePositionBody) (DealReferenceResponse, error)
+	ClosePosition(ctx context.Context, dealId string) (DealReferenceResponse, error)
+
+	// confirmations
+	GetPositionOrderConfirmation(ctx context.Context, dealReference string) (PositionOrderConfirmationResponse, error)
+}
+
+var _ Broker = (*CapitalClientAPI)(nil)
+
+// KeepAliveBroker is implemented by brokers able to keep a session alive without logging in again.
+// A MinitraderPool pings it once the session has been created instead of creating new sessions.
+type KeepAliveBroker interface {
+	Ping(ctx context.Context) error
+}
+
+var _ KeepAliveBroker = (*CapitalClientAPI)(nil)
+
+// SessionRefresher is implemented by brokers renewing expired sessions by themselves. A MinitraderPool
+// registers a callback to publish a SessionRefreshed event when they do.
+type SessionRefresher interface {
+	OnSessionRefreshed(callback func())
+}
+
+var _ SessionRefresher = (*CapitalClientAPI)(nil)
@@ -0,0 +1,118 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// _TestBroker is an in-memory Broker used to exercise minitraders without a Capital.com session.
+type _TestBroker struct {
+	Account       AccountResponse
+	Prices        PricesResponse
+	Confirmation  PositionOrderConfirmationResponse
+	WorkingOrders []CreateWorkingOrderBody
+	Deleted       []string
+	Positions     []CreatePositionBody
+	Closed        []string
+
+	mutex     sync.Mutex
+	requested []int // number of candles of every GetHistoricalPrices call
+}
+
+func (broker *_TestBroker) CreateNewSession(ctx context.Context) (NewSessionResponse, http.Header, error) {
+	return NewSessionResponse{}, http.Header{}, nil
+}
+
+func (broker *_TestBroker) GetAllAccounts(ctx context.Context) (AccountsResponse, error) {
+	return AccountsResponse{Accounts: []AccountResponse{broker.Account}}, nil
+}
+
+func (broker *_TestBroker) GetPreferredAccount(ctx context.Context) (AccountResponse, error) {
+	return broker.Account, nil
+}
+
+func (broker *_TestBroker) GetMarketsDetails(ctx context.Context, epics []string) (MarketsDetailsResponse, error) {
+	return MarketsDetailsResponse{}, nil
+}
+
+func (broker *_TestBroker) GetHistoricalPrices(ctx context.Context, epic string, resolution Timeframe, numberOfCandles int) (PricesResponse, error) {
+	broker.mutex.Lock()
+	broker.requested = append(broker.requested, numberOfCandles)
+	broker.mutex.Unlock()
+	if len(broker.Prices.Prices) == 0 {
+		return PricesResponse{}, errors.New("no prices")
+	}
+	return broker.Prices, nil
+}
+
+func (broker *_TestBroker) requestedCandles() []int {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	return append([]int{}, broker.requested...)
+}
+
+func (broker *_TestBroker) CreateWorkingOrder(ctx context.Context, workingOrder CreateWorkingOrderBody) (WorkingOrderResponse, error) {
+	broker.WorkingOrders = append(broker.WorkingOrders, workingOrder)
+	return WorkingOrderResponse{DealReference: "o_test"}, nil
+}
+
+func (broker *_TestBroker) UpdateWorkingOrder(ctx context.Context, dealId string, update UpdateWorkingOrderBody) (WorkingOrderResponse, error) {
+	return WorkingOrderResponse{DealReference: "o_test"}, nil
+}
+
+func (broker *_TestBroker) GetAllWorkingOrders(ctx context.Context) (WorkingOrdersResponse, error) {
+	return WorkingOrdersResponse{}, nil
+}
+
+func (broker *_TestBroker) DeleteWorkingOrder(ctx context.Context, dealReference string) (WorkingOrderResponse, error) {
+	broker.Deleted = append(broker.Deleted, dealReference)
+	return WorkingOrderResponse{DealReference: dealReference}, nil
+}
+
+func (broker *_TestBroker) GetPositions(ctx context.Context) (PositionsResponse, error) {
+	return PositionsResponse{}, nil
+}
+
+func (broker *_TestBroker) GetPosition(ctx context.Context, dealId string) (PositionResponse, error) {
+	return PositionResponse{}, errors.New("no position")
+}
+
+func (broker *_TestBroker) CreatePosition(ctx context.Context, position CreatePositionBody) (DealReferenceResponse, error) {
+	broker.Positions = append(broker.Positions, position)
+	return DealReferenceResponse{DealReference: "p_test"}, nil
+}
+
+func (broker *_TestBroker) UpdatePosition(ctx context.Context, dealId string, update UpdatePositionBody) (DealReferenceResponse, error) {
+	return DealReferenceResponse{DealReference: "p_test"}, nil
+}
+
+func (broker *_TestBroker) ClosePosition(ctx context.Context, dealId string) (DealReferenceResponse, error) {
+	broker.Closed = append(broker.Closed, dealId)
+	return DealReferenceResponse{DealReference: "p_test"}, nil
+}
+
+func (broker *_TestBroker) GetPositionOrderConfirmation(ctx context.Context, dealReference string) (PositionOrderConfirmationResponse, error) {
+	return broker.Confirmation, nil
+}
+
+// _TestFailingBroker is a _TestBroker whose working orders are always rejected.
+type _TestFailingBroker struct {
+	_TestBroker
+}
+
+func (broker *_TestFailingBroker) CreateWorkingOrder(ctx context.Context, workingOrder CreateWorkingOrderBody) (WorkingOrderResponse, error) {
+	return WorkingOrderResponse{}, errors.New("order failed")
+}
+
+// _TestUnconfirmedBroker is a _TestBroker whose confirmations always fail.
+type _TestUnconfirmedBroker struct {
+	_TestBroker
+	tries int
+}
+
+func (broker *_TestUnconfirmedBroker) GetPositionOrderConfirmation(ctx context.Context, dealReference string) (PositionOrderConfirmationResponse, error) {
+	broker.tries++
+	return PositionOrderConfirmationResponse{}, errors.New("confirmation failed")
+}
@@ -1,7 +1,11 @@
 package gominitrader
 
 import (
+	"errors"
+	"math"
 	"time"
+
+	"github.com/menesesghz/go-minitrader/indicators"
 )
 
 type Candles []Candle
@@ -20,6 +24,28 @@ type BidAskPrice struct {
 	Ask float64
 }
 
+var _ indicators.Series = Candles{}
+
+func (candles Candles) Len() int {
+	return len(candles)
+}
+
+// Bar returns the candle at index i on one side, so Candles can be used with the indicators package.
+func (candles Candles) Bar(i int, source indicators.Source) indicators.OHLCV {
+	return candles[i].OHLCV(source)
+}
+
+// OHLCV returns the bid, ask or mid side of the candle.
+func (candle Candle) OHLCV(source indicators.Source) indicators.OHLCV {
+	return indicators.OHLCV{
+		Open:   indicators.Price(candle.Open.Bid, candle.Open.Ask, source),
+		High:   indicators.Price(candle.High.Bid, candle.High.Ask, source),
+		Low:    indicators.Price(candle.Low.Bid, candle.Low.Ask, source),
+		Close:  indicators.Price(candle.Close.Bid, candle.Close.Ask, source),
+		Volume: float64(candle.Volume),
+	}
+}
+
 func (candles *Candles) MarshalCapitalPrices(capitalPrices []CapitalPrice) error {
 	for index, capitalPrice := range capitalPrices {
 		// pushing empty candle
@@ -49,3 +75,45 @@ func (candles *Candles) MarshalCapitalPrices(capitalPrices []CapitalPrice) error
 	}
 	return nil
 }
+
+// Resample builds candles of the target timeframe from candles of a lower timeframe that divides it,
+// sorted oldest first. Bars are aligned to UTC; see ResampleIn.
+func (candles Candles) Resample(target Timeframe) (Candles, error) {
+	return candles.ResampleIn(target, time.UTC)
+}
+
+// ResampleIn is Resample with day and week bars starting at midnight in location, e.g. the session
+// timezone of the market. Open and close come from the first and last candle of each bar, high and low
+// are taken per side and volumes are summed. The first and last bars may be incomplete.
+func (candles Candles) ResampleIn(target Timeframe, location *time.Location) (Candles, error) {
+	duration, err := target.Duration()
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	resampled := Candles{}
+	for _, candle := range candles {
+		start := bucketStart(candle.Timestamp, duration, location)
+		last := len(resampled) - 1
+		if last >= 0 && start < resampled[last].Timestamp {
+			return nil, errors.New("Candles must be sorted from oldest to newest to be resampled")
+		}
+		if last < 0 || start > resampled[last].Timestamp {
+			candle.Timestamp = start
+			resampled = append(resampled, candle)
+			continue
+		}
+
+		bar := &resampled[last]
+		bar.High.Bid = math.Max(bar.High.Bid, candle.High.Bid)
+		bar.High.Ask = math.Max(bar.High.Ask, candle.High.Ask)
+		bar.Low.Bid = math.Min(bar.Low.Bid, candle.Low.Bid)
+		bar.Low.Ask = math.Min(bar.Low.Ask, candle.Low.Ask)
+		bar.Close = candle.Close
+		bar.Volume += candle.Volume
+	}
+	return resampled, nil
+}
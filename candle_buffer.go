@@ -0,0 +1,96 @@
+package gominitrader
+
+import (
+	"sync"
+)
+
+// CandleBuffer is a rolling window of the latest candles of an epic and timeframe. It is seeded once and
+// then kept up to date with the candles newer than its last one; the last candle is the bar still forming
+// and is replaced in place until a newer bar closes it.
+type CandleBuffer struct {
+	Capacity int
+
+	mutex   sync.Mutex
+	candles Candles
+}
+
+func NewCandleBuffer(capacity int) *CandleBuffer {
+	return &CandleBuffer{Capacity: capacity}
+}
+
+// Seed replaces the buffered candles, keeping the last Capacity ones.
+func (buffer *CandleBuffer) Seed(candles Candles) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	buffer.candles = append(Candles{}, candles...)
+	buffer.trim()
+}
+
+// Update merges sorted candles into the buffer: candles older than the last buffered one are ignored, the
+// forming bar is replaced and newer bars are appended, dropping the oldest ones.
+func (buffer *CandleBuffer) Update(candles Candles) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	buffer.update(func(buffered Candles) (Candles, error) {
+		for _, candle := range candles {
+			last := len(buffered) - 1
+			switch {
+			case last >= 0 && candle.Timestamp < buffered[last].Timestamp:
+			case last >= 0 && candle.Timestamp == buffered[last].Timestamp:
+				buffered[last] = candle
+			default:
+				buffered = append(buffered, candle)
+			}
+		}
+		return buffered, nil
+	})
+}
+
+// Apply updates the buffered candles with update, e.g. with a streamed price. The buffer is left as it was
+// if update fails.
+func (buffer *CandleBuffer) Apply(update func(candles Candles) (Candles, error)) error {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.update(func(buffered Candles) (Candles, error) {
+		return update(append(Candles{}, buffered...))
+	})
+}
+
+// Candles returns a copy of the buffered candles.
+func (buffer *CandleBuffer) Candles() Candles {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return append(Candles{}, buffer.candles...)
+}
+
+func (buffer *CandleBuffer) Len() int {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return len(buffer.candles)
+}
+
+// LastTimestamp is the timestamp of the forming bar, or 0 if the buffer has not been seeded.
+func (buffer *CandleBuffer) LastTimestamp() int64 {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	if len(buffer.candles) == 0 {
+		return 0
+	}
+	return buffer.candles[len(buffer.candles)-1].Timestamp
+}
+
+func (buffer *CandleBuffer) update(update func(Candles) (Candles, error)) error {
+	candles, err := update(buffer.candles)
+	if err != nil {
+		return err
+	}
+	buffer.candles = candles
+	buffer.trim()
+	return nil
+}
+
+func (buffer *CandleBuffer) trim() {
+	if buffer.Capacity > 0 && len(buffer.candles) > buffer.Capacity {
+		buffer.candles = append(Candles{}, buffer.candles[len(buffer.candles)-buffer.Capacity:]...)
+	}
+}
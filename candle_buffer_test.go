@@ -0,0 +1,231 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCandleBuffer(t *testing.T) {
+	buffer := NewCandleBuffer(3)
+	buffer.Update(_TestCandles(1, 2, 3, 4))
+
+	tests := []struct {
+		candles           Candles
+		expectedCloses    []float64
+		expectedTimestamp int64
+	}{
+		// forming bar at 180 is replaced in place
+		{_TestCandles(0, 0, 0, 4.5)[3:], []float64{2, 3, 4.5}, 180},
+		// older candles are ignored
+		{_TestCandles(0, 9)[1:], []float64{2, 3, 4.5}, 180},
+		// the final version of the forming bar and two new bars
+		{_TestCandles(0, 0, 0, 4.6, 5, 6)[3:], []float64{4.6, 5, 6}, 300},
+	}
+	for i, test := range tests {
+		buffer.Update(test.candles)
+		candles := buffer.Candles()
+		if len(candles) != len(test.expectedCloses) || buffer.LastTimestamp() != test.expectedTimestamp {
+			t.Fatalf("Test case %d: unexpected candles %+v", i, candles)
+		}
+		for j, candle := range candles {
+			if candle.Close.Bid != test.expectedCloses[j] {
+				t.Errorf("Test case %d: expected close %f on candle %d, got %f", i, test.expectedCloses[j], j, candle.Close.Bid)
+			}
+		}
+	}
+
+	// failed updates leave the buffer untouched
+	err := buffer.Apply(func(candles Candles) (Candles, error) {
+		candles[0].Close.Bid = 100
+		return candles, errors.New("failed")
+	})
+	if err == nil || buffer.Candles()[0].Close.Bid != 4.6 {
+		t.Errorf("expected a failed update to be discarded")
+	}
+}
+
+func TestMinitraderPoolIncrementalCandles(t *testing.T) {
+	now := time.Now()
+	broker := &_TestBroker{Account: AccountResponse{Preferred: true}}
+	broker.Prices = PricesResponse{Prices: GenerateCapitalPrices(now, MINUTE_15, 200, 19.5, 0.01)}
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	pool, _ := NewMinitraderPool(broker, minitrader)
+	buffer := pool.buffers["USDMXN"+string(MINUTE_15)]
+
+	tests := []struct {
+		now             time.Time
+		expectedCandles int
+	}{
+		{now, 200},                         // empty buffer
+		{now, 1},                           // same bar; only the forming one
+		{now.Add(time.Minute * 15), 2},     // the forming bar closed and a new one opened
+		{now.Add(time.Hour * 24 * 30), 10}, // too far behind; capped, updateBuffer seeds it again
+	}
+	for i, test := range tests {
+		if numberOfCandles := pool.candlesSince(buffer, MINUTE_15, test.now); numberOfCandles != test.expectedCandles {
+			t.Errorf("Test case %d: expected to fetch %d candles, got %d", i, test.expectedCandles, numberOfCandles)
+		}
+		if i == 0 {
+			var candles Candles
+			candles.MarshalCapitalPrices(broker.Prices.Prices)
+			buffer.Seed(candles)
+		}
+	}
+
+	buffer.Seed(nil)
+	go pool.UpdateMinitradersData(context.Background(), time.Millisecond*10)
+	for i := 0; i < 3; i++ {
+		select {
+		case candles := <-minitrader.candlesChannel:
+			if len(candles) != 200 {
+				t.Errorf("Test case %d: expected 200 candles, got %d", i, len(candles))
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Test case %d: candles not received", i)
+		}
+	}
+	requested := broker.requestedCandles()
+	if len(requested) < 3 || requested[0] != 200 || requested[1] > 2 || requested[2] > 2 {
+		t.Errorf("expected a full window and then only new candles, got %v", requested)
+	}
+}
+
+func TestMinitraderPoolUpdateBuffer(t *testing.T) {
+	now := time.Now()
+	closedAt := now.Add(-time.Hour * 48)
+	tests := []struct {
+		buffered          []CapitalPrice
+		prices            []CapitalPrice
+		expectedRequested []int
+		expectedTimestamp int64
+	}{
+		// empty buffer; seeded with a full window
+		{nil, GenerateCapitalPrices(now, MINUTE_15, 200, 19.5, 0.01), []int{200}, now.Unix() / 900 * 900},
+		// market closed over the weekend; the last bars fetched are the buffered ones
+		{GenerateCapitalPrices(closedAt, MINUTE_15, 200, 19.5, 0.01), GenerateCapitalPrices(closedAt, MINUTE_15, 10, 19.5, 0.01), []int{10}, closedAt.Unix() / 900 * 900},
+		// the candles fetched do not reach back to the buffer; seeded again
+		{GenerateCapitalPrices(closedAt, MINUTE_15, 200, 19.5, 0.01), GenerateCapitalPrices(now, MINUTE_15, 10, 19.5, 0.01), []int{10, 200}, now.Unix() / 900 * 900},
+	}
+	for i, test := range tests {
+		broker := &_TestBroker{Prices: PricesResponse{Prices: test.prices}}
+		pool, _ := NewMinitraderPool(broker, NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy))
+		buffer := pool.buffers["USDMXN"+string(MINUTE_15)]
+		var buffered Candles
+		buffered.MarshalCapitalPrices(test.buffered)
+		buffer.Seed(buffered)
+
+		if err := pool.updateBuffer(context.Background(), buffer, "USDMXN", MINUTE_15); err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		if requested := broker.requestedCandles(); fmt.Sprint(requested) != fmt.Sprint(test.expectedRequested) {
+			t.Errorf("Test case %d: expected to request %v candles, got %v", i, test.expectedRequested, requested)
+		}
+		if buffer.LastTimestamp() != test.expectedTimestamp {
+			t.Errorf("Test case %d: expected the last candle at %d, got %d", i, test.expectedTimestamp, buffer.LastTimestamp())
+		}
+	}
+}
+
+func TestMinitraderPoolCandlesUpdateError(t *testing.T) {
+	broker := &_TestBroker{Account: AccountResponse{Preferred: true}}
+	minitraders := []*Minitrader{
+		NewMinitrader("USDMXN", 50, 5, 0.5, MINUTE_15, GPTStrategy),
+		NewMinitrader("USDJPY", 50, 5, 0.5, MINUTE_15, GPTStrategy),
+	}
+	pool, err := NewMinitraderPool(broker, minitraders...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a single tick
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pool.UpdateMinitradersData(ctx, time.Second)
+	for i, minitrader := range minitraders {
+		if minitrader.Status() != ERROR_ON_UPDATE_CANDLES_DATA {
+			t.Errorf("Test case %d: expected status %s, got %s", i, ERROR_ON_UPDATE_CANDLES_DATA, minitrader.Status())
+		}
+	}
+	if requested := broker.requestedCandles(); len(requested) != 2 {
+		t.Errorf("expected every epic to be fetched after a failure, got %v", requested)
+	}
+
+	broker.Prices = PricesResponse{Prices: GenerateCapitalPrices(time.Now(), MINUTE_15, 200, 19.5, 0.01)}
+	pool.UpdateMinitradersData(ctx, time.Second)
+	for i, minitrader := range minitraders {
+		if minitrader.Status() != RUNNING {
+			t.Errorf("Test case %d: expected status %s, got %s", i, RUNNING, minitrader.Status())
+		}
+	}
+}
+
+func TestMinitraderBarClose(t *testing.T) {
+	strategy := &_TestRecordingStrategy{warmUp: 1}
+	minitrader := NewStatefulMinitrader("USDMXN", 100, 5, 0.5, MINUTE, strategy)
+	minitrader.Evaluation = BAR_CLOSE
+	closedBars := []int64{}
+	minitrader.OnBarClosed = func(minitrader *Minitrader, candle Candle) {
+		closedBars = append(closedBars, candle.Timestamp)
+	}
+
+	tests := []struct {
+		candles        Candles
+		expectedSignal Signal
+		expectedPrice  float64
+		expectedClosed int
+	}{
+		{_TestCandles(1, 2, 3), NONE, 3, 0},           // the forming bar at 120 is not evaluated
+		{_TestCandles(1, 2, 3.5), NONE, 3.5, 0},       // still forming
+		{_TestCandles(1, 2, 3.6, 4), BUY, 3.6, 1},     // 120 closed
+		{_TestCandles(1, 2, 3.6, 4, 5, 6), BUY, 5, 3}, // 180 and 240 closed
+	}
+	for i, test := range tests {
+		signal, price := minitrader.onCandles(test.candles)
+		if signal != test.expectedSignal || price != test.expectedPrice {
+			t.Errorf("Test case %d: expected %s at %f, got %s at %f", i, test.expectedSignal, test.expectedPrice, signal, price)
+		}
+		if len(closedBars) != test.expectedClosed {
+			t.Errorf("Test case %d: expected %d closed bars, got %v", i, test.expectedClosed, closedBars)
+		}
+	}
+	for _, candle := range strategy.seen {
+		if candle.Timestamp == 120 && candle.Close.Bid != 3.6 {
+			t.Errorf("the strategy should only see the closed bar, got %+v", candle)
+		}
+	}
+}
+
+func TestMinitraderBarCloseHoldsPosition(t *testing.T) {
+	now := time.Now()
+	paper := NewPaperBroker(nil, 10000, "USD")
+	paper.UpdatePrice("USDMXN", 19.5, 19.5)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 5, MINUTE_15, GPTStrategy)
+	minitrader.Evaluation = BAR_CLOSE
+	pool, err := NewMinitraderPool(paper, minitrader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minitrader.broker = pool.Broker
+	response, _ := paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 1})
+	minitrader.setTrade(response.DealReference, "", 19.5, 1)
+	minitrader.transition(HOLDING, "test position")
+
+	// the forming bar, and then a new bar that closes it
+	for i, start := range []time.Time{now, now.Add(time.Minute * 15)} {
+		var candles Candles
+		candles.MarshalCapitalPrices(GenerateCapitalPrices(start, MINUTE_15, 300, 19.5, 0))
+		signal, price := minitrader.onCandles(pool.minitraderCandles(minitrader, MINUTE_15, candles))
+		if i == 1 && price == 0 {
+			t.Errorf("expected the closed bar to be evaluated, got %s at %f", signal, price)
+		}
+		if err := minitrader.Effect(context.Background(), signal, price); err != nil {
+			t.Fatal(err)
+		}
+		if minitrader.Status() != HOLDING {
+			t.Fatalf("Test case %d: expected the position to be held, got %s", i, minitrader.Status())
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package gominitrader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CandleStore persists candles by epic and timeframe, so history survives restarts and can go further back
+// than Capital.com allows to page. Candles are deduplicated by Timestamp; appending a candle with a stored
+// timestamp replaces it.
+type CandleStore interface {
+	Append(epic string, timeframe Timeframe, candles Candles) error
+	// Range returns the stored candles with from <= Timestamp <= to, oldest first.
+	Range(epic string, timeframe Timeframe, from int64, to int64) (Candles, error)
+	// Last returns up to numberOfCandles of the newest stored candles, oldest first.
+	Last(epic string, timeframe Timeframe, numberOfCandles int) (Candles, error)
+	Close() error
+}
+
+var CSV_CANDLES_HEADER = []string{
+	"timestamp", "volume",
+	"open_bid", "open_ask", "high_bid", "high_ask", "low_bid", "low_ask", "close_bid", "close_ask",
+}
+
+// CSVCandleStore keeps one CSV file per epic and timeframe in a directory. Candles newer than the stored
+// ones are appended to the file; older or repeated candles make the file be rewritten.
+type CSVCandleStore struct {
+	Directory string
+
+	mutex          sync.Mutex
+	lastTimestamps map[string]int64 // newest stored timestamp by file, loaded on first use
+}
+
+func NewCSVCandleStore(directory string) (*CSVCandleStore, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	return &CSVCandleStore{Directory: directory, lastTimestamps: make(map[string]int64)}, nil
+}
+
+func (store *CSVCandleStore) Append(epic string, timeframe Timeframe, candles Candles) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	path, err := store.path(epic, timeframe)
+	if err != nil {
+		return err
+	}
+	candles = sortedCandles(candles)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	lastTimestamp, ok := store.lastTimestamps[path]
+	if !ok {
+		stored, err := readCSVCandles(path)
+		if err != nil {
+			return err
+		}
+		lastTimestamp = -1
+		if len(stored) != 0 {
+			lastTimestamp = stored[len(stored)-1].Timestamp
+		}
+	}
+
+	if candles[0].Timestamp > lastTimestamp {
+		err = appendCSVCandles(path, candles)
+	} else {
+		var stored Candles
+		stored, err = readCSVCandles(path)
+		if err == nil {
+			err = writeCSVCandles(path, mergeCandles(stored, candles))
+		}
+	}
+	if err != nil {
+		delete(store.lastTimestamps, path)
+		return err
+	}
+	if last := candles[len(candles)-1].Timestamp; last > lastTimestamp {
+		lastTimestamp = last
+	}
+	store.lastTimestamps[path] = lastTimestamp
+	return nil
+}
+
+func (store *CSVCandleStore) Range(epic string, timeframe Timeframe, from int64, to int64) (Candles, error) {
+	path, err := store.path(epic, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	stored, err := readCSVCandles(path)
+	if err != nil {
+		return nil, err
+	}
+	return stored.between(from, to), nil
+}
+
+func (store *CSVCandleStore) Last(epic string, timeframe Timeframe, numberOfCandles int) (Candles, error) {
+	path, err := store.path(epic, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	stored, err := readCSVCandles(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > numberOfCandles {
+		stored = stored[len(stored)-numberOfCandles:]
+	}
+	return stored, nil
+}
+
+func (store *CSVCandleStore) Close() error {
+	return nil
+}
+
+func (store *CSVCandleStore) path(epic string, timeframe Timeframe) (string, error) {
+	if epic == "" || strings.ContainsAny(epic+string(timeframe), `/\`) {
+		return "", errors.New(fmt.Sprintf("Invalid Candle Store Key: %s %s", epic, timeframe))
+	}
+	return filepath.Join(store.Directory, epic+"_"+string(timeframe)+".csv"), nil
+}
+
+// readCSVCandles reads a file written by CSVCandleStore; a missing file has no candles.
+func readCSVCandles(path string) (Candles, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Candles{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	candles := Candles{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if line == 1 || scanner.Text() == "" {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != len(CSV_CANDLES_HEADER) {
+			return nil, errors.New(fmt.Sprintf("Unexpected number of fields on line %d of %s", line, path))
+		}
+		candle, err := parseCSVCandle(fields)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid candle on line %d of %s - %s", line, path, err))
+		}
+		candles = append(candles, candle)
+	}
+	return candles, scanner.Err()
+}
+
+func parseCSVCandle(fields []string) (candle Candle, err error) {
+	if candle.Timestamp, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return candle, err
+	}
+	if candle.Volume, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return candle, err
+	}
+	prices := []*float64{
+		&candle.Open.Bid, &candle.Open.Ask, &candle.High.Bid, &candle.High.Ask,
+		&candle.Low.Bid, &candle.Low.Ask, &candle.Close.Bid, &candle.Close.Ask,
+	}
+	for i, price := range prices {
+		if *price, err = strconv.ParseFloat(fields[i+2], 64); err != nil {
+			return candle, err
+		}
+	}
+	return candle, nil
+}
+
+func formatCSVCandle(candle Candle) string {
+	fields := []string{strconv.FormatInt(candle.Timestamp, 10), strconv.FormatInt(candle.Volume, 10)}
+	for _, price := range []float64{
+		candle.Open.Bid, candle.Open.Ask, candle.High.Bid, candle.High.Ask,
+		candle.Low.Bid, candle.Low.Ask, candle.Close.Bid, candle.Close.Ask,
+	} {
+		fields = append(fields, strconv.FormatFloat(price, 'f', -1, 64))
+	}
+	return strings.Join(fields, ",")
+}
+
+// appendCSVCandles appends candles to a file, writing the header first if the file is new.
+func appendCSVCandles(path string, candles Candles) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if info.Size() == 0 {
+		writer.WriteString(strings.Join(CSV_CANDLES_HEADER, ",") + "\n")
+	}
+	for _, candle := range candles {
+		writer.WriteString(formatCSVCandle(candle) + "\n")
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeCSVCandles replaces the file through a temporary one, so it is never left half written.
+func writeCSVCandles(path string, candles Candles) error {
+	temporaryPath := path + ".tmp"
+	os.Remove(temporaryPath)
+	if err := appendCSVCandles(temporaryPath, candles); err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, path)
+}
+
+// sortedCandles returns a copy of candles sorted by timestamp, keeping the last candle of each timestamp.
+func sortedCandles(candles Candles) Candles {
+	sorted := make(Candles, len(candles))
+	copy(sorted, candles)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp < sorted[j].Timestamp })
+	return mergeCandles(Candles{}, sorted)
+}
+
+// mergeCandles merges sorted candles into sorted stored ones; candles replace stored candles with the
+// same timestamp.
+func mergeCandles(stored Candles, candles Candles) Candles {
+	merged := make(Candles, 0, len(stored)+len(candles))
+	push := func(candle Candle) {
+		if last := len(merged) - 1; last >= 0 && merged[last].Timestamp == candle.Timestamp {
+			merged[last] = candle
+			return
+		}
+		merged = append(merged, candle)
+	}
+	i, j := 0, 0
+	for i < len(stored) || j < len(candles) {
+		if j == len(candles) || (i < len(stored) && stored[i].Timestamp <= candles[j].Timestamp) {
+			push(stored[i])
+			i++
+			continue
+		}
+		push(candles[j])
+		j++
+	}
+	return merged
+}
+
+// between returns the sorted candles with from <= Timestamp <= to.
+func (candles Candles) between(from int64, to int64) Candles {
+	start := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp >= from })
+	end := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp > to })
+	if start >= end {
+		return Candles{}
+	}
+	return append(Candles{}, candles[start:end]...)
+}
@@ -0,0 +1,295 @@
+package gominitrader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// KV_CANDLES_MAGIC starts every file written by KVCandleStore.
+const KV_CANDLES_MAGIC = "GMCANDL1"
+
+// kvCandleSize is the size of an encoded candle: timestamp, volume and eight prices.
+const kvCandleSize = 10 * 8
+
+// KVCandleStore keeps every epic and timeframe in a single append-only key-value file. Each record holds
+// a checksum, the "epic/timeframe" key and a candle; the newest record of a timestamp wins. The file is
+// indexed in memory on open, and a record torn by a crash is truncated away.
+type KVCandleStore struct {
+	Path string
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+	index map[string][]kvCandleEntry // sorted by timestamp
+}
+
+type kvCandleEntry struct {
+	timestamp int64
+	offset    int64 // offset of the encoded candle
+}
+
+func OpenKVCandleStore(path string) (*KVCandleStore, error) {
+	store := &KVCandleStore{Path: path}
+	if err := store.open(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *KVCandleStore) Append(epic string, timeframe Timeframe, candles Candles) error {
+	key, err := kvCandleKey(epic, timeframe)
+	if err != nil {
+		return err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.file == nil {
+		return errors.New("KVCandleStore is closed")
+	}
+
+	buffer := &bytes.Buffer{}
+	offsets := make([]int64, len(candles))
+	for i, candle := range candles {
+		offsets[i] = store.size + int64(buffer.Len()) + 4 + 2 + int64(len(key))
+		buffer.Write(encodeKVCandleRecord(key, candle))
+	}
+	if _, err := store.file.WriteAt(buffer.Bytes(), store.size); err != nil {
+		// drop whatever was partially written
+		store.file.Truncate(store.size)
+		return err
+	}
+	store.size += int64(buffer.Len())
+	for i, candle := range candles {
+		store.index[key] = insertKVCandleEntry(store.index[key], kvCandleEntry{candle.Timestamp, offsets[i]})
+	}
+	return nil
+}
+
+func (store *KVCandleStore) Range(epic string, timeframe Timeframe, from int64, to int64) (Candles, error) {
+	key, err := kvCandleKey(epic, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	entries := store.index[key]
+	start := sort.Search(len(entries), func(i int) bool { return entries[i].timestamp >= from })
+	end := sort.Search(len(entries), func(i int) bool { return entries[i].timestamp > to })
+	if start >= end {
+		return Candles{}, nil
+	}
+	return store.read(entries[start:end])
+}
+
+func (store *KVCandleStore) Last(epic string, timeframe Timeframe, numberOfCandles int) (Candles, error) {
+	key, err := kvCandleKey(epic, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	entries := store.index[key]
+	if len(entries) > numberOfCandles {
+		entries = entries[len(entries)-numberOfCandles:]
+	}
+	return store.read(entries)
+}
+
+// Compact rewrites the file with only the newest record of every timestamp.
+func (store *KVCandleStore) Compact() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.file == nil {
+		return errors.New("KVCandleStore is closed")
+	}
+
+	temporaryPath := store.Path + ".tmp"
+	temporary, err := os.Create(temporaryPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(temporary)
+	writer.WriteString(KV_CANDLES_MAGIC)
+	for key, entries := range store.index {
+		candles, err := store.read(entries)
+		if err != nil {
+			temporary.Close()
+			return err
+		}
+		for _, candle := range candles {
+			writer.Write(encodeKVCandleRecord(key, candle))
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		return err
+	}
+	temporary.Close()
+
+	store.file.Close()
+	store.file = nil
+	if err := os.Rename(temporaryPath, store.Path); err != nil {
+		return err
+	}
+	return store.open()
+}
+
+func (store *KVCandleStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.file == nil {
+		return nil
+	}
+	store.file.Sync()
+	err := store.file.Close()
+	store.file = nil
+	return err
+}
+
+// open opens or creates the file and rebuilds the index by replaying its records.
+func (store *KVCandleStore) open() error {
+	file, err := os.OpenFile(store.Path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	store.file = file
+	store.index = make(map[string][]kvCandleEntry)
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(KV_CANDLES_MAGIC))
+	n, err := io.ReadFull(reader, magic)
+	if n == 0 && err == io.EOF {
+		if _, err := file.WriteAt([]byte(KV_CANDLES_MAGIC), 0); err != nil {
+			file.Close()
+			return err
+		}
+		store.size = int64(len(KV_CANDLES_MAGIC))
+		return nil
+	}
+	if err != nil || string(magic) != KV_CANDLES_MAGIC {
+		file.Close()
+		return errors.New(fmt.Sprintf("%s is not a KVCandleStore file", store.Path))
+	}
+
+	offset := int64(len(KV_CANDLES_MAGIC))
+	for {
+		key, candle, size, err := readKVCandleRecord(reader)
+		if err != nil {
+			// io.EOF ends the file; anything else is a torn or corrupted tail
+			break
+		}
+		entry := kvCandleEntry{candle.Timestamp, offset + int64(size-kvCandleSize)}
+		store.index[key] = insertKVCandleEntry(store.index[key], entry)
+		offset += int64(size)
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return err
+	}
+	store.size = offset
+	return nil
+}
+
+func (store *KVCandleStore) read(entries []kvCandleEntry) (Candles, error) {
+	candles := make(Candles, len(entries))
+	data := make([]byte, kvCandleSize)
+	for i, entry := range entries {
+		if _, err := store.file.ReadAt(data, entry.offset); err != nil {
+			return nil, err
+		}
+		candles[i] = decodeKVCandle(data)
+	}
+	return candles, nil
+}
+
+func kvCandleKey(epic string, timeframe Timeframe) (string, error) {
+	key := epic + "/" + string(timeframe)
+	if epic == "" || len(key) > math.MaxUint16 {
+		return "", errors.New(fmt.Sprintf("Invalid Candle Store Key: %s %s", epic, timeframe))
+	}
+	return key, nil
+}
+
+// insertKVCandleEntry inserts entry in sorted entries, replacing the entry of the same timestamp.
+func insertKVCandleEntry(entries []kvCandleEntry, entry kvCandleEntry) []kvCandleEntry {
+	last := len(entries) - 1
+	if last < 0 || entries[last].timestamp < entry.timestamp {
+		return append(entries, entry)
+	}
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].timestamp >= entry.timestamp })
+	if entries[i].timestamp == entry.timestamp {
+		entries[i] = entry
+		return entries
+	}
+	entries = append(entries, kvCandleEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	return entries
+}
+
+// encodeKVCandleRecord encodes crc32 | key length | key | candle, little endian. The checksum covers
+// everything after it.
+func encodeKVCandleRecord(key string, candle Candle) []byte {
+	record := make([]byte, 4+2+len(key)+kvCandleSize)
+	binary.LittleEndian.PutUint16(record[4:], uint16(len(key)))
+	copy(record[6:], key)
+	encodeKVCandle(record[6+len(key):], candle)
+	binary.LittleEndian.PutUint32(record, crc32.ChecksumIEEE(record[4:]))
+	return record
+}
+
+func readKVCandleRecord(reader io.Reader) (key string, candle Candle, size int, err error) {
+	header := make([]byte, 6)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return key, candle, size, err
+	}
+	body := make([]byte, int(binary.LittleEndian.Uint16(header[4:]))+kvCandleSize)
+	if _, err = io.ReadFull(reader, body); err != nil {
+		return key, candle, size, err
+	}
+	checksum := crc32.NewIEEE()
+	checksum.Write(header[4:])
+	checksum.Write(body)
+	if checksum.Sum32() != binary.LittleEndian.Uint32(header) {
+		return key, candle, size, errors.New("KVCandleStore record checksum mismatch")
+	}
+	key = string(body[:len(body)-kvCandleSize])
+	return key, decodeKVCandle(body[len(key):]), len(header) + len(body), nil
+}
+
+func encodeKVCandle(data []byte, candle Candle) {
+	binary.LittleEndian.PutUint64(data, uint64(candle.Timestamp))
+	binary.LittleEndian.PutUint64(data[8:], uint64(candle.Volume))
+	for i, price := range []float64{
+		candle.Open.Bid, candle.Open.Ask, candle.High.Bid, candle.High.Ask,
+		candle.Low.Bid, candle.Low.Ask, candle.Close.Bid, candle.Close.Ask,
+	} {
+		binary.LittleEndian.PutUint64(data[16+8*i:], math.Float64bits(price))
+	}
+}
+
+func decodeKVCandle(data []byte) (candle Candle) {
+	candle.Timestamp = int64(binary.LittleEndian.Uint64(data))
+	candle.Volume = int64(binary.LittleEndian.Uint64(data[8:]))
+	prices := []*float64{
+		&candle.Open.Bid, &candle.Open.Ask, &candle.High.Bid, &candle.High.Ask,
+		&candle.Low.Bid, &candle.Low.Ask, &candle.Close.Bid, &candle.Close.Ask,
+	}
+	for i, price := range prices {
+		*price = math.Float64frombits(binary.LittleEndian.Uint64(data[16+8*i:]))
+	}
+	return candle
+}
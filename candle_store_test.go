@@ -0,0 +1,155 @@
+package gominitrader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCandleStores(t *testing.T) {
+	directory := t.TempDir()
+	openStores := []func() (CandleStore, error){
+		func() (CandleStore, error) { return NewCSVCandleStore(filepath.Join(directory, "csv")) },
+		func() (CandleStore, error) { return OpenKVCandleStore(filepath.Join(directory, "candles.kv")) },
+	}
+
+	for i, openStore := range openStores {
+		store, err := openStore()
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		// out of order and repeated timestamps; the last candle of a timestamp wins
+		if err := store.Append("USDMXN", MINUTE, _TestCandles(1, 2, 3)); err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		updates := _TestCandles(10, 20, 30, 40, 50)
+		if err := store.Append("USDMXN", MINUTE, Candles{updates[4], updates[1], updates[3]}); err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		if err := store.Append("USDMXN", MINUTE_5, _TestCandles(7)); err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		store.Close()
+
+		// candles survive reopening
+		store, err = openStore()
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		candles, err := store.Range("USDMXN", MINUTE, 0, 1000)
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		expectedCloses := []float64{1, 20, 3, 40, 50}
+		if len(candles) != len(expectedCloses) {
+			t.Fatalf("Test case %d: expected %d candles, got %d", i, len(expectedCloses), len(candles))
+		}
+		for j, candle := range candles {
+			if candle.Timestamp != int64(j*60) || candle.Close.Bid != expectedCloses[j] {
+				t.Errorf("Test case %d: unexpected candle %d %+v", i, j, candle)
+			}
+		}
+
+		candles, _ = store.Range("USDMXN", MINUTE, 60, 180)
+		if len(candles) != 3 || candles[0].Timestamp != 60 || candles[2].Timestamp != 180 {
+			t.Errorf("Test case %d: unexpected range %+v", i, candles)
+		}
+		candles, _ = store.Last("USDMXN", MINUTE, 2)
+		if len(candles) != 2 || candles[0].Close.Bid != 40 || candles[1].Close.Bid != 50 {
+			t.Errorf("Test case %d: unexpected last candles %+v", i, candles)
+		}
+		candles, _ = store.Last("USDMXN", MINUTE_5, 10)
+		if len(candles) != 1 || candles[0].Close.Bid != 7 {
+			t.Errorf("Test case %d: timeframes should be stored apart %+v", i, candles)
+		}
+		candles, _ = store.Last("USDCAD", MINUTE, 10)
+		if len(candles) != 0 {
+			t.Errorf("Test case %d: unexpected USDCAD candles %+v", i, candles)
+		}
+		store.Close()
+	}
+}
+
+func TestKVCandleStoreRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candles.kv")
+	store, err := OpenKVCandleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Append("USDJPY", HOUR, _TestCandles(1, 2, 3))
+	store.Append("USDJPY", HOUR, _TestCandles(4))
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	candles, _ := store.Range("USDJPY", HOUR, 0, 1000)
+	if len(candles) != 3 || candles[0].Close.Bid != 4 {
+		t.Fatalf("unexpected candles after compacting %+v", candles)
+	}
+	store.Close()
+
+	// a record torn by a crash is dropped
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-5)
+	store, err = OpenKVCandleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candles, _ = store.Range("USDJPY", HOUR, 0, 1000)
+	if len(candles) != 2 {
+		t.Errorf("expected 2 candles after recovery, got %d", len(candles))
+	}
+	if err := store.Append("USDJPY", HOUR, _TestCandles(4, 5, 6)[2:]); err != nil {
+		t.Fatal(err)
+	}
+	candles, _ = store.Range("USDJPY", HOUR, 0, 1000)
+	if len(candles) != 3 || candles[2].Close.Bid != 6 {
+		t.Errorf("unexpected candles after appending to a recovered file %+v", candles)
+	}
+	store.Close()
+
+	os.WriteFile(path, []byte("not a candle store"), 0644)
+	if _, err := OpenKVCandleStore(path); err == nil {
+		t.Errorf("expected an error opening a file of another format")
+	}
+}
+
+func TestMinitraderPoolCandleStore(t *testing.T) {
+	store, _ := NewCSVCandleStore(t.TempDir())
+	store.Append("USDMXN", MINUTE_15, _TestCandles(1, 2, 3))
+
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	pool, _ := NewMinitraderPool(capClient, minitrader)
+	pool.SetCandleStore(store)
+	go pool.UpdateMinitradersData(context.Background(), time.Minute)
+
+	// stored candles come first, then the API ones, whose closed candles are stored
+	expectedLengths := []int{3, 200}
+	for i, expectedLength := range expectedLengths {
+		select {
+		case candles := <-minitrader.candlesChannel:
+			if len(candles) != expectedLength {
+				t.Errorf("Test case %d: expected %d candles, got %d", i, expectedLength, len(candles))
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Test case %d: candles not received", i)
+		}
+	}
+
+	stored, _ := store.Last("USDMXN", MINUTE_15, 1000)
+	if len(stored) != 3+199 {
+		t.Errorf("expected the closed API candles to be stored, got %d candles", len(stored))
+	}
+	backtester := NewBacktester(1000, 100, 2, 0.35, GPTStrategy)
+	backtester.Window = 50
+	if _, err := backtester.RunStore(store, "USDMXN", MINUTE_15, stored[3].Timestamp, stored[len(stored)-1].Timestamp); err != nil {
+		t.Errorf("unexpected error backtesting from the store: %s", err)
+	}
+	if _, err := backtester.RunStore(store, "USDCAD", MINUTE_15, 0, 1); err == nil {
+		t.Errorf("expected an error backtesting without stored candles")
+	}
+}
@@ -1,13 +1,17 @@
 package gominitrader
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/menesesghz/go-minitrader/indicators"
 )
 
 func TestMarshalCapitalPrices(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
-	capitalPricesResponse, _ := capClient.GetHistoricalPrices("USDMXN", MINUTE_15, 250)
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
+	capitalPricesResponse, _ := capClient.GetHistoricalPrices(context.Background(), "USDMXN", MINUTE_15, 250)
 
 	var candles Candles
 	err := candles.MarshalCapitalPrices(capitalPricesResponse.Prices)
@@ -23,3 +27,168 @@ func TestMarshalCapitalPrices(t *testing.T) {
 
 	//t.Logf("Marshalled Candles: %v+\n", candles)
 }
+
+func TestCandlesBar(t *testing.T) {
+	candles := Candles{{
+		Volume: 7,
+		Open:   BidAskPrice{Bid: 1, Ask: 2},
+		High:   BidAskPrice{Bid: 3, Ask: 4},
+		Low:    BidAskPrice{Bid: 0.5, Ask: 1},
+		Close:  BidAskPrice{Bid: 2, Ask: 3},
+	}}
+	tests := []struct {
+		source   indicators.Source
+		expected indicators.OHLCV
+	}{
+		{indicators.BID, indicators.OHLCV{Open: 1, High: 3, Low: 0.5, Close: 2, Volume: 7}},
+		{indicators.ASK, indicators.OHLCV{Open: 2, High: 4, Low: 1, Close: 3, Volume: 7}},
+		{indicators.MID, indicators.OHLCV{Open: 1.5, High: 3.5, Low: 0.75, Close: 2.5, Volume: 7}},
+	}
+	for i, test := range tests {
+		if bar := candles.Bar(0, test.source); bar != test.expected {
+			t.Errorf("Test case %d: expected %+v, got %+v", i, test.expected, bar)
+		}
+	}
+}
+
+func TestTimeframeDuration(t *testing.T) {
+	tests := []struct {
+		timeframe        Timeframe
+		expectedDuration time.Duration
+		expectedNative   bool
+	}{
+		{MINUTE_15, time.Minute * 15, true},
+		{WEEK, time.Hour * 24 * 7, true},
+		{"MINUTE_3", time.Minute * 3, false},
+		{"HOUR_2", time.Hour * 2, false},
+		{"HOUR_12", time.Hour * 12, false},
+		{"DAY_2", time.Hour * 48, false},
+	}
+	for i, test := range tests {
+		duration, err := test.timeframe.Duration()
+		if err != nil || duration != test.expectedDuration || test.timeframe.Native() != test.expectedNative {
+			t.Errorf("Test case %d: unexpected duration %s (%v) for %s", i, duration, err, test.timeframe)
+		}
+		if timeframe, _ := NewTimeframe(test.expectedDuration); timeframe != test.timeframe {
+			t.Errorf("Test case %d: expected timeframe %s, got %s", i, test.timeframe, timeframe)
+		}
+	}
+	for _, timeframe := range []Timeframe{"", "HOUR_0", "HOUR_X", "SECOND_5", "HOUR_36", "MINUTE_1500"} {
+		if _, err := timeframe.Duration(); err == nil {
+			t.Errorf("expected %q to be invalid", timeframe)
+		}
+	}
+	for _, duration := range []time.Duration{time.Second * 90, time.Hour * 36} {
+		if _, err := NewTimeframe(duration); err == nil {
+			t.Errorf("expected %s to be invalid", duration)
+		}
+	}
+}
+
+func TestBaseTimeframe(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		location     *time.Location
+		timeframes   []Timeframe
+		expectedBase Timeframe
+	}{
+		{time.UTC, []Timeframe{MINUTE_15}, MINUTE_15},
+		{time.UTC, []Timeframe{MINUTE_15, HOUR, "HOUR_2"}, MINUTE_15},
+		{time.UTC, []Timeframe{"MINUTE_3"}, MINUTE},
+		{time.UTC, []Timeframe{"HOUR_12", DAY}, HOUR_4},
+		{time.UTC, []Timeframe{WEEK}, WEEK},
+		{time.UTC, []Timeframe{"WEEK_2"}, DAY},
+		{newYork, []Timeframe{DAY}, HOUR},
+		{time.FixedZone("CET", 3600), []Timeframe{"HOUR_12"}, HOUR},
+		{time.FixedZone("IST", 19800), []Timeframe{HOUR}, MINUTE_30},
+		{time.FixedZone("UTC", 0), []Timeframe{WEEK}, WEEK},
+	}
+	for i, test := range tests {
+		if base, _ := baseTimeframe(test.location, test.timeframes...); base != test.expectedBase {
+			t.Errorf("Test case %d: expected base %s, got %s", i, test.expectedBase, base)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	// 1 minute candles; the bid is the minute and the ask is one above it
+	minutes := func(start time.Time, n int) Candles {
+		candles := Candles{}
+		for i := 0; i < n; i++ {
+			price := float64(i)
+			candles = append(candles, Candle{
+				Volume:    1,
+				Timestamp: start.Add(time.Minute * time.Duration(i)).Unix(),
+				Open:      BidAskPrice{Bid: price, Ask: price + 1},
+				High:      BidAskPrice{Bid: price + 0.5, Ask: price + 1.5},
+				Low:       BidAskPrice{Bid: price - 0.5, Ask: price + 0.5},
+				Close:     BidAskPrice{Bid: price + 0.25, Ask: price + 1.25},
+			})
+		}
+		return candles
+	}
+
+	start := time.Date(2023, 3, 1, 10, 1, 0, 0, time.UTC)
+	resampled, err := minutes(start, 7).Resample("MINUTE_3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10:00 (incomplete: 10:01, 10:02), 10:03, 10:06 (incomplete: 10:06, 10:07)
+	if len(resampled) != 3 {
+		t.Fatalf("expected 3 bars, got %d", len(resampled))
+	}
+	bar := resampled[1]
+	expected := Candle{
+		Volume:    3,
+		Timestamp: time.Date(2023, 3, 1, 10, 3, 0, 0, time.UTC).Unix(),
+		Open:      BidAskPrice{Bid: 2, Ask: 3},
+		High:      BidAskPrice{Bid: 4.5, Ask: 5.5},
+		Low:       BidAskPrice{Bid: 1.5, Ask: 2.5},
+		Close:     BidAskPrice{Bid: 4.25, Ask: 5.25},
+	}
+	if bar != expected {
+		t.Errorf("expected %+v, got %+v", expected, bar)
+	}
+
+	// daily bars start at midnight of the session timezone
+	newYork, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		timeframe          Timeframe
+		location           *time.Location
+		start              time.Time
+		n                  int
+		expectedTimestamps []time.Time
+	}{
+		{"HOUR_2", time.UTC, time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), 300, []time.Time{
+			time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 3, 1, 2, 0, 0, 0, time.UTC), time.Date(2023, 3, 1, 4, 0, 0, 0, time.UTC),
+		}},
+		{DAY, newYork, time.Date(2023, 3, 2, 4, 58, 0, 0, time.UTC), 4, []time.Time{
+			time.Date(2023, 3, 1, 0, 0, 0, 0, newYork), time.Date(2023, 3, 2, 0, 0, 0, 0, newYork),
+		}},
+		{WEEK, time.UTC, time.Date(2023, 3, 5, 23, 59, 0, 0, time.UTC), 2, []time.Time{ // Sunday to Monday
+			time.Date(2023, 2, 27, 0, 0, 0, 0, time.UTC), time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+	for i, test := range tests {
+		resampled, err := minutes(test.start, test.n).ResampleIn(test.timeframe, test.location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resampled) != len(test.expectedTimestamps) {
+			t.Errorf("Test case %d: expected %d bars, got %d", i, len(test.expectedTimestamps), len(resampled))
+			continue
+		}
+		for j, timestamp := range test.expectedTimestamps {
+			if resampled[j].Timestamp != timestamp.Unix() {
+				t.Errorf("Test case %d: bar %d starts at %s, expected %s", i, j, time.Unix(resampled[j].Timestamp, 0).UTC(), timestamp.UTC())
+			}
+		}
+	}
+
+	if _, err := append(minutes(start.Add(time.Hour), 1), minutes(start, 1)...).Resample(HOUR); err == nil {
+		t.Error("unsorted candles should not be resampled")
+	}
+	if _, err := minutes(start, 1).Resample("HOURS"); err == nil {
+		t.Error("invalid timeframes should not be resampled")
+	}
+}
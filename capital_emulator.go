@@ -0,0 +1,719 @@
+package gominitrader
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// CapitalEmulator is an in-process fake of the Capital.com REST API built on `httptest`.
+// It authenticates like Capital.com does, serves scripted prices and market statuses, and
+// keeps working orders, positions and confirmations in a PaperBroker. Point a client at it
+// through `CapitalClientAPI.CapitalDomainName`, or use `NewClient()`.
+type CapitalEmulator struct {
+	Server *httptest.Server
+	URL    string
+	Paper  *PaperBroker
+
+	email          string
+	apiKey         string
+	apiKeyPassword string
+
+	mutex        sync.Mutex
+	privateKey   *rsa.PrivateKey
+	timestamp    int64
+	sessions     map[string]string // CST -> X-SECURITY-TOKEN
+	prices       map[string]map[Timeframe][]CapitalPrice
+	marketStatus map[string]MinitraderMarketStatus
+	failures     []emulatorFailure
+
+	streamingDisabled bool
+	streams           map[*emulatorStream]bool
+}
+
+// emulatorStream is a WebSocket client connected to the emulator `/connect` endpoint.
+type emulatorStream struct {
+	mutex      sync.Mutex
+	connection *websocket.Conn
+	quotes     map[string]bool
+	ohlc       map[string]bool // epic + resolution
+}
+
+type emulatorFailure struct {
+	method     string
+	path       string
+	statusCode int
+	errorCode  string
+}
+
+func NewCapitalEmulator(email string, apiKey string, apiKeyPassword string) (*CapitalEmulator, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		return nil, err
+	}
+	emulator := &CapitalEmulator{
+		Paper:          NewPaperBroker(nil, 100000, "USD"),
+		email:          email,
+		apiKey:         apiKey,
+		apiKeyPassword: apiKeyPassword,
+		privateKey:     privateKey,
+		sessions:       make(map[string]string),
+		prices:         make(map[string]map[Timeframe][]CapitalPrice),
+		marketStatus:   make(map[string]MinitraderMarketStatus),
+		streams:        make(map[*emulatorStream]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/session/encryptionKey", emulator.handleEncryptionKey)
+	mux.HandleFunc("/api/v1/session", emulator.handleSession)
+	mux.HandleFunc("/api/v1/watchlists", emulator.authenticated(emulator.handleWatchLists))
+	mux.HandleFunc("/api/v1/accounts", emulator.authenticated(emulator.handleAccounts))
+	mux.HandleFunc("/api/v1/markets", emulator.authenticated(emulator.handleMarkets))
+	mux.HandleFunc("/api/v1/prices/", emulator.authenticated(emulator.handlePrices))
+	mux.HandleFunc("/api/v1/positions", emulator.authenticated(emulator.handlePositions))
+	mux.HandleFunc("/api/v1/positions/", emulator.authenticated(emulator.handlePosition))
+	mux.HandleFunc("/api/v1/workingorders", emulator.authenticated(emulator.handleWorkingOrders))
+	mux.HandleFunc("/api/v1/workingorders/", emulator.authenticated(emulator.handleWorkingOrder))
+	mux.HandleFunc("/api/v1/confirms/", emulator.authenticated(emulator.handleConfirms))
+	mux.HandleFunc("/connect", emulator.handleStream)
+
+	emulator.Server = httptest.NewServer(emulator.withScriptedFailures(mux))
+	emulator.URL = emulator.Server.URL
+	return emulator, nil
+}
+
+// NewClient returns a CapitalClientAPI with the emulator credentials pointing at the emulator.
+func (emulator *CapitalEmulator) NewClient() (*CapitalClientAPI, error) {
+	client, err := NewCapitalClient(emulator.email, emulator.apiKey, emulator.apiKeyPassword, true)
+	if err != nil {
+		return client, err
+	}
+	client.CapitalDomainName = emulator.URL
+	return client, nil
+}
+
+func (emulator *CapitalEmulator) Close() {
+	emulator.DisconnectStreams()
+	emulator.Server.Close()
+}
+
+// SetPrices scripts the candles served by `/prices/{epic}` for a resolution. Prices must be sorted
+// from oldest to newest; the last close becomes the current bid/ask of the epic.
+func (emulator *CapitalEmulator) SetPrices(epic string, resolution Timeframe, prices []CapitalPrice) {
+	emulator.mutex.Lock()
+	if emulator.prices[epic] == nil {
+		emulator.prices[epic] = make(map[Timeframe][]CapitalPrice)
+	}
+	emulator.prices[epic][resolution] = prices
+	if _, ok := emulator.marketStatus[epic]; !ok {
+		emulator.marketStatus[epic] = TRADEABLE
+	}
+	emulator.mutex.Unlock()
+
+	if len(prices) != 0 {
+		last := prices[len(prices)-1]
+		emulator.Paper.UpdatePrice(epic, last.ClosePrice.Bid, last.ClosePrice.Ask)
+	}
+}
+
+// SetQuote sets the current bid/ask of an epic, filling any working order crossed by it.
+func (emulator *CapitalEmulator) SetQuote(epic string, bid float64, ask float64) {
+	emulator.mutex.Lock()
+	if _, ok := emulator.marketStatus[epic]; !ok {
+		emulator.marketStatus[epic] = TRADEABLE
+	}
+	emulator.mutex.Unlock()
+	emulator.Paper.UpdatePrice(epic, bid, ask)
+}
+
+func (emulator *CapitalEmulator) SetMarketStatus(epic string, status MinitraderMarketStatus) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.marketStatus[epic] = status
+}
+
+// FailNext makes the next request matching method and path prefix fail with statusCode and
+// a Capital.com style `{"errorCode": ...}` body. An empty method matches any method.
+func (emulator *CapitalEmulator) FailNext(method string, path string, statusCode int, errorCode string) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.failures = append(emulator.failures, emulatorFailure{
+		method:     method,
+		path:       path,
+		statusCode: statusCode,
+		errorCode:  errorCode,
+	})
+}
+
+// ExpireSessions invalidates every session token handed out so far.
+func (emulator *CapitalEmulator) ExpireSessions() {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.sessions = make(map[string]string)
+}
+
+// SetStreamingEnabled accepts or refuses new `/connect` WebSocket connections.
+func (emulator *CapitalEmulator) SetStreamingEnabled(enabled bool) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.streamingDisabled = !enabled
+}
+
+// DisconnectStreams drops every connected WebSocket client.
+func (emulator *CapitalEmulator) DisconnectStreams() {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	for stream := range emulator.streams {
+		stream.connection.Close()
+		delete(emulator.streams, stream)
+	}
+}
+
+// StreamsConnected returns the number of WebSocket clients with at least one subscription.
+func (emulator *CapitalEmulator) StreamsConnected() int {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	connected := 0
+	for stream := range emulator.streams {
+		stream.mutex.Lock()
+		if len(stream.quotes) != 0 {
+			connected++
+		}
+		stream.mutex.Unlock()
+	}
+	return connected
+}
+
+// PushQuote sets the current bid/ask of an epic and streams it to the clients subscribed to its quotes.
+func (emulator *CapitalEmulator) PushQuote(epic string, bid float64, ask float64, timestamp time.Time) {
+	emulator.SetQuote(epic, bid, ask)
+	emulator.broadcast(func(stream *emulatorStream) bool { return stream.quotes[epic] }, STREAM_QUOTE, streamQuotePayload{
+		Epic:      epic,
+		Bid:       bid,
+		Offer:     ask,
+		Timestamp: timestamp.UnixMilli(),
+	})
+}
+
+// PushOHLC streams a bar update to the clients subscribed to the epic and resolution of the event.
+func (emulator *CapitalEmulator) PushOHLC(event StreamOHLC) {
+	key := event.Epic + string(event.Resolution)
+	emulator.broadcast(func(stream *emulatorStream) bool { return stream.ohlc[key] }, STREAM_OHLC, streamOHLCPayload{
+		Epic:       event.Epic,
+		Resolution: event.Resolution,
+		PriceType:  event.PriceType,
+		Timestamp:  event.Timestamp,
+		Open:       event.Open,
+		High:       event.High,
+		Low:        event.Low,
+		Close:      event.Close,
+	})
+}
+
+func (emulator *CapitalEmulator) broadcast(subscribed func(*emulatorStream) bool, destination string, payload interface{}) {
+	body, _ := json.Marshal(payload)
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	for stream := range emulator.streams {
+		stream.mutex.Lock()
+		if subscribed(stream) {
+			stream.connection.WriteJSON(streamResponse{Status: "OK", Destination: destination, Payload: body})
+		}
+		stream.mutex.Unlock()
+	}
+}
+
+func (emulator *CapitalEmulator) handleStream(w http.ResponseWriter, r *http.Request) {
+	emulator.mutex.Lock()
+	disabled := emulator.streamingDisabled
+	emulator.mutex.Unlock()
+	if disabled {
+		writeEmulatorError(w, http.StatusServiceUnavailable, "error.streaming.unavailable")
+		return
+	}
+
+	upgrader := websocket.Upgrader{}
+	connection, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	stream := &emulatorStream{connection: connection, quotes: make(map[string]bool), ohlc: make(map[string]bool)}
+	emulator.mutex.Lock()
+	emulator.streams[stream] = true
+	emulator.mutex.Unlock()
+	defer func() {
+		emulator.mutex.Lock()
+		delete(emulator.streams, stream)
+		emulator.mutex.Unlock()
+		connection.Close()
+	}()
+
+	for {
+		var request struct {
+			Destination   string `json:"destination"`
+			CorrelationID string `json:"correlationId"`
+			CST           string `json:"cst"`
+			SecurityToken string `json:"securityToken"`
+			Payload       struct {
+				Epics       []string    `json:"epics"`
+				Resolutions []Timeframe `json:"resolutions"`
+			} `json:"payload"`
+		}
+		if err := connection.ReadJSON(&request); err != nil {
+			return
+		}
+
+		response := streamResponse{Status: "OK", Destination: request.Destination, CorrelationID: request.CorrelationID}
+		emulator.mutex.Lock()
+		expectedToken, ok := emulator.sessions[request.CST]
+		emulator.mutex.Unlock()
+		if !ok || expectedToken != request.SecurityToken {
+			response.Status = "ERROR"
+			response.Payload, _ = json.Marshal(map[string]string{"errorCode": "error.invalid.session.token"})
+		} else {
+			subscriptions := make(map[string]string)
+			stream.mutex.Lock()
+			switch request.Destination {
+			case STREAM_QUOTE_SUBSCRIBE:
+				for _, epic := range request.Payload.Epics {
+					stream.quotes[epic] = true
+					subscriptions[epic] = "PROCESSED"
+				}
+			case STREAM_OHLC_SUBSCRIBE:
+				for _, epic := range request.Payload.Epics {
+					for _, resolution := range request.Payload.Resolutions {
+						stream.ohlc[epic+string(resolution)] = true
+					}
+					subscriptions[epic] = "PROCESSED"
+				}
+			}
+			stream.mutex.Unlock()
+			response.Payload, _ = json.Marshal(map[string]interface{}{"subscriptions": subscriptions})
+		}
+
+		stream.mutex.Lock()
+		err := connection.WriteJSON(response)
+		stream.mutex.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (emulator *CapitalEmulator) withScriptedFailures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		emulator.mutex.Lock()
+		for i, failure := range emulator.failures {
+			if (failure.method == "" || failure.method == r.Method) && strings.HasPrefix(r.URL.Path, failure.path) {
+				emulator.failures = append(emulator.failures[:i], emulator.failures[i+1:]...)
+				emulator.mutex.Unlock()
+				writeEmulatorError(w, failure.statusCode, failure.errorCode)
+				return
+			}
+		}
+		emulator.mutex.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticated checks the CST and X-SECURITY-TOKEN headers set by AuthenticationTransport.
+func (emulator *CapitalEmulator) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cst, securityToken := r.Header.Get("CST"), r.Header.Get("X-SECURITY-TOKEN")
+		if cst == "" || securityToken == "" {
+			writeEmulatorError(w, http.StatusUnauthorized, "error.null.client.token")
+			return
+		}
+		emulator.mutex.Lock()
+		expectedToken, ok := emulator.sessions[cst]
+		emulator.mutex.Unlock()
+		if !ok || expectedToken != securityToken {
+			writeEmulatorError(w, http.StatusUnauthorized, "error.invalid.session.token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (emulator *CapitalEmulator) handleEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeEmulatorError(w, http.StatusMethodNotAllowed, "error.method.not-allowed")
+		return
+	}
+	if r.Header.Get("X-CAP-API-KEY") != emulator.apiKey {
+		writeEmulatorError(w, http.StatusUnauthorized, "error.invalid.api.key")
+		return
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&emulator.privateKey.PublicKey)
+	if err != nil {
+		writeEmulatorError(w, http.StatusInternalServerError, "error.internal")
+		return
+	}
+
+	emulator.mutex.Lock()
+	emulator.timestamp = time.Now().UnixMilli()
+	timestamp := emulator.timestamp
+	emulator.mutex.Unlock()
+
+	writeEmulatorJSON(w, map[string]interface{}{
+		"encryptionKey": base64.StdEncoding.EncodeToString(publicKey),
+		"timeStamp":     timestamp,
+	})
+}
+
+func (emulator *CapitalEmulator) handleSession(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		emulator.createSession(w, r)
+	case http.MethodGet:
+		emulator.authenticated(func(w http.ResponseWriter, r *http.Request) {
+			writeEmulatorJSON(w, map[string]interface{}{
+				"clientId":        "emulator",
+				"accountId":       "PAPER",
+				"timezoneOffset":  0,
+				"locale":          "en",
+				"currency":        emulator.Paper.Currency,
+				"streamEndpoint":  "",
+				"currentAccounts": []string{"PAPER"},
+			})
+		})(w, r)
+	case http.MethodDelete:
+		emulator.authenticated(func(w http.ResponseWriter, r *http.Request) {
+			emulator.mutex.Lock()
+			delete(emulator.sessions, r.Header.Get("CST"))
+			emulator.mutex.Unlock()
+			writeEmulatorJSON(w, map[string]string{"status": "SUCCESS"})
+		})(w, r)
+	default:
+		writeEmulatorError(w, http.StatusMethodNotAllowed, "error.method.not-allowed")
+	}
+}
+
+func (emulator *CapitalEmulator) createSession(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-CAP-API-KEY") != emulator.apiKey {
+		writeEmulatorError(w, http.StatusUnauthorized, "error.invalid.api.key")
+		return
+	}
+	var body NewSessionBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeEmulatorError(w, http.StatusBadRequest, "error.invalid.details")
+		return
+	}
+	if body.Identifier != emulator.email || !emulator.validPassword(body) {
+		writeEmulatorError(w, http.StatusUnauthorized, "error.invalid.details")
+		return
+	}
+
+	cst, securityToken := randomEmulatorToken(), randomEmulatorToken()
+	emulator.mutex.Lock()
+	emulator.sessions[cst] = securityToken
+	emulator.mutex.Unlock()
+
+	session, _, _ := emulator.Paper.CreateNewSession(r.Context())
+	session.ClientId = "emulator"
+	session.StreamingHost = "ws" + strings.TrimPrefix(emulator.URL, "http") + "/"
+	session.HasActiveDemoAccounts = true
+
+	w.Header().Set("CST", cst)
+	w.Header().Set("X-SECURITY-TOKEN", securityToken)
+	writeEmulatorJSON(w, session)
+}
+
+// validPassword reverses `CapitalClientAPI.GetEncryptedPassword`.
+func (emulator *CapitalEmulator) validPassword(body NewSessionBody) bool {
+	if !body.EncryptedPassword {
+		return body.Password == emulator.apiKeyPassword
+	}
+	cipher, err := base64.StdEncoding.DecodeString(body.Password)
+	if err != nil {
+		return false
+	}
+	encoded, err := rsa.DecryptPKCS1v15(rand.Reader, emulator.privateKey, cipher)
+	if err != nil {
+		return false
+	}
+	plain, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return false
+	}
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	return string(plain) == emulator.apiKeyPassword+"|"+strconv.FormatInt(emulator.timestamp, 10)
+}
+
+func (emulator *CapitalEmulator) handleWatchLists(w http.ResponseWriter, r *http.Request) {
+	writeEmulatorJSON(w, map[string]interface{}{"watchlists": []interface{}{}})
+}
+
+func (emulator *CapitalEmulator) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	accountsResponse, _ := emulator.Paper.GetAllAccounts(r.Context())
+	writeEmulatorJSON(w, accountsResponse)
+}
+
+func (emulator *CapitalEmulator) handleMarkets(w http.ResponseWriter, r *http.Request) {
+	epics := r.URL.Query()["epics"]
+	if len(epics) == 1 {
+		epics = strings.Split(epics[0], ",")
+	}
+	marketsDetailsResponse, _ := emulator.Paper.GetMarketsDetails(r.Context(), epics)
+
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	for i, detail := range marketsDetailsResponse.MarketDetails {
+		if status, ok := emulator.marketStatus[detail.Instrument.Epic]; ok {
+			marketsDetailsResponse.MarketDetails[i].Snapshot.MarketStatus = string(status)
+		}
+	}
+	writeEmulatorJSON(w, marketsDetailsResponse)
+}
+
+func (emulator *CapitalEmulator) handlePrices(w http.ResponseWriter, r *http.Request) {
+	epic := strings.TrimPrefix(r.URL.Path, "/api/v1/prices/")
+	values := r.URL.Query()
+	resolution := Timeframe(values.Get("resolution"))
+	if resolution == "" {
+		resolution = MINUTE
+	}
+	max := 10
+	if values.Get("max") != "" {
+		max, _ = strconv.Atoi(values.Get("max"))
+	}
+	if max < 1 || max > 1000 {
+		writeEmulatorError(w, http.StatusBadRequest, "error.invalid.max")
+		return
+	}
+
+	emulator.mutex.Lock()
+	prices, ok := emulator.prices[epic][resolution]
+	emulator.mutex.Unlock()
+	if !ok {
+		writeEmulatorError(w, http.StatusNotFound, "error.prices.not-found")
+		return
+	}
+
+	// prices are sorted and snapshot times share the same layout, so they compare as strings
+	if from := values.Get("from"); from != "" {
+		start := sort.Search(len(prices), func(i int) bool { return prices[i].SnapshotTimeUTC >= from })
+		prices = prices[start:]
+	}
+	if to := values.Get("to"); to != "" {
+		end := sort.Search(len(prices), func(i int) bool { return prices[i].SnapshotTimeUTC > to })
+		prices = prices[:end]
+	}
+	if len(prices) > max {
+		prices = prices[len(prices)-max:]
+	}
+	if len(prices) == 0 {
+		writeEmulatorError(w, http.StatusNotFound, "error.prices.not-found")
+		return
+	}
+	writeEmulatorJSON(w, PricesResponse{Prices: prices})
+}
+
+func (emulator *CapitalEmulator) handlePositions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		positionsResponse, _ := emulator.Paper.GetPositions(r.Context())
+		writeEmulatorJSON(w, positionsResponse)
+	case http.MethodPost:
+		var body CreatePositionBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeEmulatorError(w, http.StatusBadRequest, "error.invalid.details")
+			return
+		}
+		if ok, errorCode := emulator.tradeable(body.Epic); !ok {
+			writeEmulatorError(w, http.StatusBadRequest, errorCode)
+			return
+		}
+		dealReferenceResponse, err := emulator.Paper.CreatePosition(r.Context(), body)
+		if err != nil {
+			writeEmulatorError(w, http.StatusBadRequest, "error.invalid.details")
+			return
+		}
+		writeEmulatorJSON(w, dealReferenceResponse)
+	default:
+		writeEmulatorError(w, http.StatusMethodNotAllowed, "error.method.not-allowed")
+	}
+}
+
+func (emulator *CapitalEmulator) handlePosition(w http.ResponseWriter, r *http.Request) {
+	dealID := strings.TrimPrefix(r.URL.Path, "/api/v1/positions/")
+	switch r.Method {
+	case http.MethodGet:
+		positionResponse, err := emulator.Paper.GetPosition(r.Context(), dealID)
+		if err != nil {
+			writeEmulatorError(w, http.StatusNotFound, "error.not-found.dealId")
+			return
+		}
+		writeEmulatorJSON(w, positionResponse)
+	case http.MethodPut:
+		var body UpdatePositionBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeEmulatorError(w, http.StatusBadRequest, "error.invalid.details")
+			return
+		}
+		dealReferenceResponse, err := emulator.Paper.UpdatePosition(r.Context(), dealID, body)
+		if err != nil {
+			writeEmulatorError(w, http.StatusNotFound, "error.not-found.dealId")
+			return
+		}
+		writeEmulatorJSON(w, dealReferenceResponse)
+	case http.MethodDelete:
+		dealReferenceResponse, err := emulator.Paper.ClosePosition(r.Context(), dealID)
+		if err != nil {
+			writeEmulatorError(w, http.StatusNotFound, "error.not-found.dealId")
+			return
+		}
+		writeEmulatorJSON(w, dealReferenceResponse)
+	default:
+		writeEmulatorError(w, http.StatusMethodNotAllowed, "error.method.not-allowed")
+	}
+}
+
+func (emulator *CapitalEmulator) handleWorkingOrders(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		workingOrdersResponse, _ := emulator.Paper.GetAllWorkingOrders(r.Context())
+		writeEmulatorJSON(w, workingOrdersResponse)
+	case http.MethodPost:
+		var body CreateWorkingOrderBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeEmulatorError(w, http.StatusBadRequest, "error.invalid.details")
+			return
+		}
+		if ok, errorCode := emulator.tradeable(body.Epic); !ok {
+			writeEmulatorError(w, http.StatusBadRequest, errorCode)
+			return
+		}
+		workingOrderResponse, err := emulator.Paper.CreateWorkingOrder(r.Context(), body)
+		if err != nil {
+			writeEmulatorError(w, http.StatusBadRequest, "error.invalid.details")
+			return
+		}
+		writeEmulatorJSON(w, workingOrderResponse)
+	default:
+		writeEmulatorError(w, http.StatusMethodNotAllowed, "error.method.not-allowed")
+	}
+}
+
+func (emulator *CapitalEmulator) handleWorkingOrder(w http.ResponseWriter, r *http.Request) {
+	dealID := strings.TrimPrefix(r.URL.Path, "/api/v1/workingorders/")
+	switch r.Method {
+	case http.MethodPut:
+		var body UpdateWorkingOrderBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeEmulatorError(w, http.StatusBadRequest, "error.invalid.details")
+			return
+		}
+		workingOrderResponse, err := emulator.Paper.UpdateWorkingOrder(r.Context(), dealID, body)
+		if err != nil {
+			writeEmulatorError(w, http.StatusNotFound, "error.not-found.dealId")
+			return
+		}
+		writeEmulatorJSON(w, workingOrderResponse)
+	case http.MethodDelete:
+		workingOrderResponse, err := emulator.Paper.DeleteWorkingOrder(r.Context(), dealID)
+		if err != nil {
+			writeEmulatorError(w, http.StatusNotFound, "error.not-found.dealId")
+			return
+		}
+		writeEmulatorJSON(w, workingOrderResponse)
+	default:
+		writeEmulatorError(w, http.StatusMethodNotAllowed, "error.method.not-allowed")
+	}
+}
+
+func (emulator *CapitalEmulator) handleConfirms(w http.ResponseWriter, r *http.Request) {
+	dealReference := strings.TrimPrefix(r.URL.Path, "/api/v1/confirms/")
+	confirmation, err := emulator.Paper.GetPositionOrderConfirmation(r.Context(), dealReference)
+	if err != nil {
+		writeEmulatorError(w, http.StatusNotFound, "error.not-found.dealReference")
+		return
+	}
+	writeEmulatorJSON(w, confirmation)
+}
+
+// tradeable reports whether an epic is known to the emulator and its market is open, and the error code
+// Capital.com answers with otherwise.
+func (emulator *CapitalEmulator) tradeable(epic string) (bool, string) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	status, ok := emulator.marketStatus[epic]
+	if !ok {
+		return false, "error.invalid.epic"
+	}
+	if status == CLOSED {
+		return false, "error.market.closed"
+	}
+	return true, ""
+}
+
+func writeEmulatorJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeEmulatorError(w http.ResponseWriter, statusCode int, errorCode string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", randomEmulatorToken())
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"errorCode": errorCode})
+}
+
+func randomEmulatorToken() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// GenerateCapitalPrices builds numberOfCandles consecutive prices for a resolution ending at `end`,
+// following a deterministic wave around startPrice with the given spread. Useful to script an emulator.
+func GenerateCapitalPrices(end time.Time, resolution Timeframe, numberOfCandles int, startPrice float64, spread float64) []CapitalPrice {
+	step := time.Duration(TimeframeMinuteMap[resolution]) * time.Minute
+	start := end.UTC().Truncate(step).Add(-step * time.Duration(numberOfCandles-1))
+	prices := make([]CapitalPrice, numberOfCandles)
+	for i := range prices {
+		snapshot := start.Add(step * time.Duration(i))
+		open := startPrice * (1 + 0.01*wave(i))
+		close := startPrice * (1 + 0.01*wave(i+1))
+		high, low := open, close
+		if close > open {
+			high, low = close, open
+		}
+		high *= 1.0005
+		low *= 0.9995
+
+		price := CapitalPrice{
+			SnapshotTime:     snapshot.Format("2006-01-02T15:04:05"),
+			SnapshotTimeUTC:  snapshot.Format("2006-01-02T15:04:05"),
+			LastTradedVolume: 100 + i%50,
+		}
+		price.OpenPrice.Bid, price.OpenPrice.Ask = open, open+spread
+		price.ClosePrice.Bid, price.ClosePrice.Ask = close, close+spread
+		price.HighPrice.Bid, price.HighPrice.Ask = high, high+spread
+		price.LowPrice.Bid, price.LowPrice.Ask = low, low+spread
+		prices[i] = price
+	}
+	return prices
+}
+
+// wave is a cheap deterministic oscillation in [-1, 1] used to generate prices.
+func wave(i int) float64 {
+	x := float64(i%40) / 10
+	if x > 2 {
+		x = 4 - x
+	}
+	return x - 1
+}
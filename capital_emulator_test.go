@@ -0,0 +1,110 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEmulatorRejectsUnauthenticatedRequests(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+
+	tests := []struct {
+		cst, securityToken string
+		expectedErrorCode  string
+	}{
+		{"", "", "error.null.client.token"},
+		{"unknown", "unknown", "error.invalid.session.token"},
+	}
+	for i, test := range tests {
+		request, _ := http.NewRequest("GET", emulator.URL+"/api/v1/accounts", nil)
+		request.Header.Set("CST", test.cst)
+		request.Header.Set("X-SECURITY-TOKEN", test.securityToken)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Test case %d: expected 401, got %d", i, response.StatusCode)
+		}
+	}
+}
+
+func TestEmulatorRejectsWrongPassword(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := NewCapitalClient("test@minitrader.com", "test-api-key", "wrong-password", true)
+	capClient.CapitalDomainName = emulator.URL
+
+	_, _, err := capClient.CreateNewSession(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "error.invalid.details") {
+		t.Errorf("expected invalid details error, got %v", err)
+	}
+}
+
+func TestEmulatorExpiredSession(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+	capClient.transport.Authenticate = nil // no refreshing
+	emulator.ExpireSessions()
+
+	_, err := capClient.GetPositions(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "error.invalid.session.token") {
+		t.Errorf("expected invalid session error, got %v", err)
+	}
+}
+
+func TestEmulatorScriptedResponses(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+
+	emulator.SetMarketStatus("USDMXN", CLOSED)
+	marketsDetails, err := capClient.GetMarketsDetails(context.Background(), []string{"USDMXN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(marketsDetails.MarketDetails) != 1 || marketsDetails.MarketDetails[0].Snapshot.MarketStatus != string(CLOSED) {
+		t.Errorf("expected USDMXN to be CLOSED, got %+v", marketsDetails.MarketDetails)
+	}
+	// closed markets can not be traded
+	if _, err := capClient.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 1}); !errors.Is(err, ErrMarketClosed) {
+		t.Errorf("expected %v, got %v", ErrMarketClosed, err)
+	}
+	if _, err := capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19, Size: 1}); !errors.Is(err, ErrMarketClosed) {
+		t.Errorf("expected %v, got %v", ErrMarketClosed, err)
+	}
+	emulator.SetMarketStatus("USDMXN", TRADEABLE)
+
+	emulator.FailNext("POST", "/api/v1/workingorders", http.StatusBadRequest, "error.invalid.size.minvalue")
+	_, err = capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19, Size: 0.001})
+	if err == nil || !strings.Contains(err.Error(), "error.invalid.size.minvalue") {
+		t.Errorf("expected scripted error, got %v", err)
+	}
+	_, err = capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19, Size: 1})
+	if err != nil {
+		t.Errorf("scripted error should only fail once, got %v", err)
+	}
+}
+
+func TestEmulatorPricesPagination(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+
+	pricesResponse, err := capClient.GetHistoricalPrices(context.Background(), "EURUSD", MINUTE_5, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pricesResponse.Prices) != 250 {
+		t.Fatalf("expected 250 prices, got %d", len(pricesResponse.Prices))
+	}
+	for i := 1; i < len(pricesResponse.Prices); i++ {
+		if pricesResponse.Prices[i-1].SnapshotTimeUTC >= pricesResponse.Prices[i].SnapshotTimeUTC {
+			t.Fatalf("prices are not sorted or contain duplicates at %d", i)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package gominitrader
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -9,10 +10,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -25,6 +26,12 @@ type CapitalClientAPI struct {
 	CAPITAL_API_KEY_PASSWORD string
 	CapitalDomainName        string
 	HttpClient               *http.Client
+	Scheduler                *RequestScheduler // throttles requests; nil sends them right away
+	Metrics                  *Metrics          // records every request; nil records nothing
+
+	transport     *AuthenticationTransport
+	mutex         sync.Mutex
+	streamingHost string
 }
 
 type CapitalClientUnathenticated struct{}
@@ -48,24 +55,57 @@ func NewCapitalClient(capitalEmail string, capitalApiKey string, capitalApiKeyPa
 		capitalDomainName = CAPITAL_DEBUG_DOMAIN_NAME
 	}
 
-	return &CapitalClientAPI{
+	client = &CapitalClientAPI{
 		CAPITAL_EMAIL:            capitalEmail,
 		CAPITAL_API_KEY:          capitalApiKey,
 		CAPITAL_API_KEY_PASSWORD: capitalApiKeyPassword,
 		CapitalDomainName:        capitalDomainName,
-		HttpClient:               &http.Client{Transport: nil},
-	}, nil
+		Scheduler:                NewDefaultRequestScheduler(),
+	}
+	RedactSecret(fmt.Sprintf("%p CAPITAL_API_KEY", client), capitalApiKey)
+	RedactSecret(fmt.Sprintf("%p CAPITAL_API_KEY_PASSWORD", client), capitalApiKeyPassword)
+	// the transport logs in again by itself when the session expires
+	client.transport = &AuthenticationTransport{RoundTripper: http.DefaultTransport}
+	client.transport.Authenticate = func(ctx context.Context) (string, string, error) {
+		_, headerTokens, err := client.login(ctx)
+		return headerTokens.Get("CST"), headerTokens.Get("X-SECURITY-TOKEN"), err
+	}
+	client.HttpClient = &http.Client{Transport: client.transport}
+	return client, nil
+}
+
+// hasSession reports whether a session has been created.
+func (capClient *CapitalClientAPI) hasSession() bool {
+	cst, _ := capClient.transport.Tokens()
+	return cst != ""
+}
+
+// do sends a request through the scheduler of the client.
+func (capClient *CapitalClientAPI) do(request *http.Request) (response *http.Response, err error) {
+	start := time.Now()
+	if capClient.Scheduler == nil {
+		response, err = capClient.HttpClient.Do(request)
+	} else {
+		response, err = capClient.Scheduler.Do(capClient.HttpClient, request)
+	}
+	if capClient.Metrics != nil {
+		capClient.Metrics.observeRequest(request, response, err, time.Since(start))
+	}
+	return response, err
 }
 
-func (capClient *CapitalClientAPI) GetEncriptionKey() (EncriptionResponse, error) {
-	request, _ := http.NewRequest("GET", capClient.CapitalDomainName+"/api/v1/session/encryptionKey", nil)
+func (capClient *CapitalClientAPI) GetEncriptionKey(ctx context.Context) (EncriptionResponse, error) {
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/session/encryptionKey", nil)
 	request.Header.Add("X-CAP-API-KEY", capClient.CAPITAL_API_KEY)
 
-	response, err := capClient.HttpClient.Do(request)
+	response, err := capClient.do(request)
 	if err != nil {
 		return EncriptionResponse{}, err
 	}
 	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return EncriptionResponse{}, newCapitalAPIError(response)
+	}
 
 	var encription EncriptionResponse
 	decoder := json.NewDecoder(response.Body)
@@ -74,27 +114,44 @@ func (capClient *CapitalClientAPI) GetEncriptionKey() (EncriptionResponse, error
 	return encription, nil
 }
 
-func (capClient *CapitalClientAPI) GetWatchLists() (WatchListsResponse, error) {
-	if capClient.HttpClient.Transport == nil {
-		return WatchListsResponse{}, errors.New("A Session is needed; Run `capClient.CreateNewSession()` to authenticate")
+func (capClient *CapitalClientAPI) GetWatchLists(ctx context.Context) (WatchListsResponse, error) {
+	if !capClient.hasSession() {
+		return WatchListsResponse{}, &CapitalClientUnathenticated{}
 	}
 
-	request, _ := http.NewRequest("GET", capClient.CapitalDomainName+"/api/v1/watchlists", nil)
-	response, err := capClient.HttpClient.Do(request)
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/watchlists", nil)
+	response, err := capClient.do(request)
 	if err != nil {
 		return WatchListsResponse{}, err
 	}
 	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return WatchListsResponse{}, newCapitalAPIError(response)
+	}
 
 	var watchlists WatchListsResponse
-	decoder := json.NewDecoder(response.Body)
-	decoder.Decode(&watchlists)
+	if err := json.NewDecoder(response.Body).Decode(&watchlists); err != nil {
+		return WatchListsResponse{}, err
+	}
 
 	return watchlists, nil
 }
 
-func (capClient *CapitalClientAPI) CreateNewSession() (newSessionResponse NewSessionResponse, headerTokens http.Header, err error) {
-	encriptionResponse, err := capClient.GetEncriptionKey()
+func (capClient *CapitalClientAPI) CreateNewSession(ctx context.Context) (newSessionResponse NewSessionResponse, headerTokens http.Header, err error) {
+	newSessionResponse, headerTokens, err = capClient.login(ctx)
+	if err != nil {
+		return newSessionResponse, headerTokens, err
+	}
+
+	// update the transport to set new auth creds in header for new requests
+	capClient.transport.SetTokens(headerTokens.Get("CST"), headerTokens.Get("X-SECURITY-TOKEN"))
+
+	return newSessionResponse, headerTokens, nil
+}
+
+// login creates a session and returns its tokens, without handing them to the transport.
+func (capClient *CapitalClientAPI) login(ctx context.Context) (newSessionResponse NewSessionResponse, headerTokens http.Header, err error) {
+	encriptionResponse, err := capClient.GetEncriptionKey(ctx)
 	if err != nil {
 		return newSessionResponse, headerTokens, err
 	}
@@ -110,38 +167,54 @@ func (capClient *CapitalClientAPI) CreateNewSession() (newSessionResponse NewSes
 	}
 	jsonData, _ := json.Marshal(body)
 
-	request, _ := http.NewRequest("POST", capClient.CapitalDomainName+"/api/v1/session", bytes.NewBuffer(jsonData))
+	request, _ := http.NewRequestWithContext(ctx, "POST", capClient.CapitalDomainName+"/api/v1/session", bytes.NewBuffer(jsonData))
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("X-CAP-API-KEY", capClient.CAPITAL_API_KEY)
-	response, err := capClient.HttpClient.Do(request)
+	response, err := capClient.do(request)
 	if err != nil {
 		return newSessionResponse, headerTokens, err
 	}
 	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(response.Body)
-		return newSessionResponse, headerTokens, errors.New(fmt.Sprintf("Unexpected [%d] Status Code Response - %s", response.StatusCode, string(body)))
+		return newSessionResponse, headerTokens, newCapitalAPIError(response)
 	}
 
 	// set new session resposne
 	decoder := json.NewDecoder(response.Body)
 	decoder.Decode(&newSessionResponse)
+	capClient.mutex.Lock()
+	capClient.streamingHost = newSessionResponse.StreamingHost
+	capClient.mutex.Unlock()
 
 	// set header tokens
 	headerTokens = http.Header{}
 	headerTokens.Add("CST", response.Header.Get("CST"))
 	headerTokens.Add("X-SECURITY-TOKEN", response.Header.Get("X-SECURITY-TOKEN"))
 
-	// update http client transport to set new auth creds in header for new requests
-	capClient.HttpClient = &http.Client{
-		Transport: &AuthenticationTransport{
-			RoundTripper:     http.DefaultTransport,
-			X_SECURITY_TOKEN: response.Header.Get("X-SECURITY-TOKEN"),
-			CST:              response.Header.Get("CST"),
-		},
+	return newSessionResponse, headerTokens, nil
+}
+
+// OnSessionRefreshed registers a callback called every time an expired session is renewed.
+func (capClient *CapitalClientAPI) OnSessionRefreshed(callback func()) {
+	capClient.transport.OnRefresh(callback)
+}
+
+// Ping keeps the session alive by requesting its details; an expired session is renewed by the transport.
+func (capClient *CapitalClientAPI) Ping(ctx context.Context) error {
+	if !capClient.hasSession() {
+		return &CapitalClientUnathenticated{}
 	}
 
-	return newSessionResponse, headerTokens, nil
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/session", nil)
+	response, err := capClient.do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return newCapitalAPIError(response)
+	}
+	return nil
 }
 
 func (capClient *CapitalClientAPI) GetEncryptedPassword(encriptionResponse EncriptionResponse) (string, error) {
@@ -168,26 +241,31 @@ func (capClient *CapitalClientAPI) GetEncryptedPassword(encriptionResponse Encri
 	return output, nil
 }
 
-func (capClient *CapitalClientAPI) GetAllAccounts() (AccountsResponse, error) {
-	if capClient.HttpClient.Transport == nil {
+func (capClient *CapitalClientAPI) GetAllAccounts(ctx context.Context) (AccountsResponse, error) {
+	if !capClient.hasSession() {
 		return AccountsResponse{}, &CapitalClientUnathenticated{}
 	}
-	request, _ := http.NewRequest("GET", capClient.CapitalDomainName+"/api/v1/accounts", nil)
-	response, err := capClient.HttpClient.Do(request)
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/accounts", nil)
+	response, err := capClient.do(request)
 	if err != nil {
 		return AccountsResponse{}, err
 	}
 	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return AccountsResponse{}, newCapitalAPIError(response)
+	}
 
 	var accountsResponse AccountsResponse
 	decoder := json.NewDecoder(response.Body)
-	decoder.Decode(&accountsResponse)
+	if err := decoder.Decode(&accountsResponse); err != nil {
+		return AccountsResponse{}, err
+	}
 
 	return accountsResponse, nil
 }
 
-func (capClient *CapitalClientAPI) GetMarketsDetails(epics []string) (MarketsDetailsResponse, error) {
-	if capClient.HttpClient.Transport == nil {
+func (capClient *CapitalClientAPI) GetMarketsDetails(ctx context.Context, epics []string) (MarketsDetailsResponse, error) {
+	if !capClient.hasSession() {
 		return MarketsDetailsResponse{}, &CapitalClientUnathenticated{}
 	}
 
@@ -196,16 +274,15 @@ func (capClient *CapitalClientAPI) GetMarketsDetails(epics []string) (MarketsDet
 		values.Add("epics", epic)
 	}
 
-	request, _ := http.NewRequest("GET", capClient.CapitalDomainName+"/api/v1/markets", nil)
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/markets", nil)
 	request.URL.RawQuery = values.Encode()
-	response, err := capClient.HttpClient.Do(request)
+	response, err := capClient.do(request)
 	if err != nil {
 		return MarketsDetailsResponse{}, err
 	}
 	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(response.Body)
-		return MarketsDetailsResponse{}, errors.New(fmt.Sprintf("Unexpected [%d] Status Code Response - %s", response.StatusCode, string(body)))
+		return MarketsDetailsResponse{}, newCapitalAPIError(response)
 	}
 
 	var marketsResponse MarketsDetailsResponse
@@ -215,8 +292,8 @@ func (capClient *CapitalClientAPI) GetMarketsDetails(epics []string) (MarketsDet
 	return marketsResponse, nil
 }
 
-func (capClient *CapitalClientAPI) GetHistoricalPrices(epic string, resolution Timeframe, numberOfCandles int) (pricesResponse PricesResponse, err error) {
-	if capClient.HttpClient.Transport == nil {
+func (capClient *CapitalClientAPI) GetHistoricalPrices(ctx context.Context, epic string, resolution Timeframe, numberOfCandles int) (pricesResponse PricesResponse, err error) {
+	if !capClient.hasSession() {
 		return pricesResponse, &CapitalClientUnathenticated{}
 	}
 
@@ -229,7 +306,7 @@ func (capClient *CapitalClientAPI) GetHistoricalPrices(epic string, resolution T
 		}
 		numberOfCandles -= max
 
-		request, _ := http.NewRequest("GET", capClient.CapitalDomainName+"/api/v1/prices/"+epic, nil)
+		request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/prices/"+epic, nil)
 		values := request.URL.Query()
 		values.Set("max", strconv.Itoa(max))
 		values.Set("resolution", string(resolution))
@@ -238,18 +315,27 @@ func (capClient *CapitalClientAPI) GetHistoricalPrices(epic string, resolution T
 		}
 		request.URL.RawQuery = values.Encode()
 
-		response, err := capClient.HttpClient.Do(request)
+		response, err := capClient.do(request)
 		if err != nil {
 			return pricesResponse, err
 		}
+		if response.StatusCode == 404 && len(pricesResponse.Prices) != 0 {
+			// no more history before the oldest candle
+			response.Body.Close()
+			break
+		}
 		if response.StatusCode != 200 {
-			body, _ := ioutil.ReadAll(response.Body)
-			return pricesResponse, errors.New(fmt.Sprintf("Unexpected [%d] Status Code Response - %s", response.StatusCode, string(body)))
+			apiError := newCapitalAPIError(response)
+			response.Body.Close()
+			return pricesResponse, apiError
 		}
 		tempResponse := PricesResponse{}
 		decoder := json.NewDecoder(response.Body)
 		decoder.Decode(&tempResponse)
 		response.Body.Close()
+		if len(tempResponse.Prices) == 0 {
+			break
+		}
 
 		pricesResponse.Prices = append(tempResponse.Prices, pricesResponse.Prices...)
 		oldestParsedTime, _ := time.Parse("2006-01-02T15:04:05", pricesResponse.Prices[0].SnapshotTimeUTC)
@@ -260,20 +346,19 @@ func (capClient *CapitalClientAPI) GetHistoricalPrices(epic string, resolution T
 	return pricesResponse, nil
 }
 
-func (capClient *CapitalClientAPI) GetPositions() (positionsResponse PositionsResponse, err error) {
-	if capClient.HttpClient.Transport == nil {
+func (capClient *CapitalClientAPI) GetPositions(ctx context.Context) (positionsResponse PositionsResponse, err error) {
+	if !capClient.hasSession() {
 		return positionsResponse, &CapitalClientUnathenticated{}
 	}
 
-	request, _ := http.NewRequest("GET", capClient.CapitalDomainName+"/api/v1/positions", nil)
-	response, err := capClient.HttpClient.Do(request)
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/positions", nil)
+	response, err := capClient.do(request)
 	if err != nil {
 		return positionsResponse, err
 	}
 	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(response.Body)
-		return positionsResponse, errors.New(fmt.Sprintf("Unexpected [%d] Status Code Response - %s", response.StatusCode, string(body)))
+		return positionsResponse, newCapitalAPIError(response)
 	}
 	positionsResponse = PositionsResponse{}
 	decoder := json.NewDecoder(response.Body)
@@ -282,6 +367,105 @@ func (capClient *CapitalClientAPI) GetPositions() (positionsResponse PositionsRe
 	return positionsResponse, nil
 }
 
+func (capClient *CapitalClientAPI) GetPosition(ctx context.Context, dealId string) (positionResponse PositionResponse, err error) {
+	if !capClient.hasSession() {
+		return positionResponse, &CapitalClientUnathenticated{}
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/positions/"+dealId, nil)
+	response, err := capClient.do(request)
+	if err != nil {
+		return positionResponse, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return positionResponse, newCapitalAPIError(response)
+	}
+	positionResponse = PositionResponse{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.Decode(&positionResponse)
+
+	return positionResponse, nil
+}
+
+func (capClient *CapitalClientAPI) CreatePosition(ctx context.Context, position CreatePositionBody) (dealReferenceResponse DealReferenceResponse, err error) {
+	if !capClient.hasSession() {
+		return dealReferenceResponse, &CapitalClientUnathenticated{}
+	}
+
+	body, err := json.Marshal(position)
+	if err != nil {
+		return dealReferenceResponse, err
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "POST", capClient.CapitalDomainName+"/api/v1/positions", bytes.NewBuffer(body))
+	request.Header.Set("Content-Type", "application/json")
+	response, err := capClient.do(request)
+	if err != nil {
+		return dealReferenceResponse, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return dealReferenceResponse, newCapitalAPIError(response)
+	}
+
+	dealReferenceResponse = DealReferenceResponse{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.Decode(&dealReferenceResponse)
+
+	return dealReferenceResponse, nil
+}
+
+func (capClient *CapitalClientAPI) UpdatePosition(ctx context.Context, dealId string, update UpdatePositionBody) (dealReferenceResponse DealReferenceResponse, err error) {
+	if !capClient.hasSession() {
+		return dealReferenceResponse, &CapitalClientUnathenticated{}
+	}
+
+	body, err := json.Marshal(update)
+	if err != nil {
+		return dealReferenceResponse, err
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "PUT", capClient.CapitalDomainName+"/api/v1/positions/"+dealId, bytes.NewBuffer(body))
+	request.Header.Set("Content-Type", "application/json")
+	response, err := capClient.do(request)
+	if err != nil {
+		return dealReferenceResponse, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return dealReferenceResponse, newCapitalAPIError(response)
+	}
+
+	dealReferenceResponse = DealReferenceResponse{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.Decode(&dealReferenceResponse)
+
+	return dealReferenceResponse, nil
+}
+
+func (capClient *CapitalClientAPI) ClosePosition(ctx context.Context, dealId string) (dealReferenceResponse DealReferenceResponse, err error) {
+	if !capClient.hasSession() {
+		return dealReferenceResponse, &CapitalClientUnathenticated{}
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "DELETE", capClient.CapitalDomainName+"/api/v1/positions/"+dealId, nil)
+	response, err := capClient.do(request)
+	if err != nil {
+		return dealReferenceResponse, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return dealReferenceResponse, newCapitalAPIError(response)
+	}
+
+	dealReferenceResponse = DealReferenceResponse{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.Decode(&dealReferenceResponse)
+
+	return dealReferenceResponse, nil
+}
+
 type OrderType string
 
 const (
@@ -289,32 +473,25 @@ const (
 	STOP  OrderType = "STOP"
 )
 
-func (capClient *CapitalClientAPI) CreateWorkingOrder(epic string, direction Signal, orderType OrderType, targetPrice float64, orderSize float64) (createWorkingOrder WorkingOrderResponse, err error) {
-	if capClient.HttpClient.Transport == nil {
+func (capClient *CapitalClientAPI) CreateWorkingOrder(ctx context.Context, workingOrder CreateWorkingOrderBody) (createWorkingOrder WorkingOrderResponse, err error) {
+	if !capClient.hasSession() {
 		return createWorkingOrder, &CapitalClientUnathenticated{}
 	}
 
-	body, err := json.Marshal(CreateWorkingOrderBody{
-		Epic:      epic,
-		Direction: direction,
-		Type:      orderType,
-		Level:     targetPrice,
-		Size:      orderSize,
-	})
+	body, err := json.Marshal(workingOrder)
 	if err != nil {
 		return createWorkingOrder, err
 	}
 
-	request, _ := http.NewRequest("POST", capClient.CapitalDomainName+"/api/v1/workingorders", bytes.NewBuffer(body))
+	request, _ := http.NewRequestWithContext(ctx, "POST", capClient.CapitalDomainName+"/api/v1/workingorders", bytes.NewBuffer(body))
 	request.Header.Set("Content-Type", "application/json")
-	response, err := capClient.HttpClient.Do(request)
+	response, err := capClient.do(request)
 	if err != nil {
 		return createWorkingOrder, err
 	}
 	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(response.Body)
-		return createWorkingOrder, errors.New(fmt.Sprintf("Unexpected [%d] Status Code Response - %s", response.StatusCode, string(body)))
+		return createWorkingOrder, newCapitalAPIError(response)
 	}
 
 	createWorkingOrder = WorkingOrderResponse{}
@@ -324,20 +501,47 @@ func (capClient *CapitalClientAPI) CreateWorkingOrder(epic string, direction Sig
 	return createWorkingOrder, nil
 }
 
-func (capClient *CapitalClientAPI) GetAllWorkingOrders() (workingOrdersResponse WorkingOrdersResponse, err error) {
-	if capClient.HttpClient.Transport == nil {
+func (capClient *CapitalClientAPI) UpdateWorkingOrder(ctx context.Context, dealId string, update UpdateWorkingOrderBody) (updateWorkingOrder WorkingOrderResponse, err error) {
+	if !capClient.hasSession() {
+		return updateWorkingOrder, &CapitalClientUnathenticated{}
+	}
+
+	body, err := json.Marshal(update)
+	if err != nil {
+		return updateWorkingOrder, err
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "PUT", capClient.CapitalDomainName+"/api/v1/workingorders/"+dealId, bytes.NewBuffer(body))
+	request.Header.Set("Content-Type", "application/json")
+	response, err := capClient.do(request)
+	if err != nil {
+		return updateWorkingOrder, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return updateWorkingOrder, newCapitalAPIError(response)
+	}
+
+	updateWorkingOrder = WorkingOrderResponse{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.Decode(&updateWorkingOrder)
+
+	return updateWorkingOrder, nil
+}
+
+func (capClient *CapitalClientAPI) GetAllWorkingOrders(ctx context.Context) (workingOrdersResponse WorkingOrdersResponse, err error) {
+	if !capClient.hasSession() {
 		return workingOrdersResponse, &CapitalClientUnathenticated{}
 	}
 
-	request, _ := http.NewRequest("GET", capClient.CapitalDomainName+"/api/v1/workingorders", nil)
-	response, err := capClient.HttpClient.Do(request)
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/workingorders", nil)
+	response, err := capClient.do(request)
 	if err != nil {
 		return workingOrdersResponse, err
 	}
 	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(response.Body)
-		return workingOrdersResponse, errors.New(fmt.Sprintf("Unexpected [%d] Status Code Response - %s", response.StatusCode, string(body)))
+		return workingOrdersResponse, newCapitalAPIError(response)
 	}
 
 	workingOrdersResponse = WorkingOrdersResponse{}
@@ -347,20 +551,19 @@ func (capClient *CapitalClientAPI) GetAllWorkingOrders() (workingOrdersResponse
 	return workingOrdersResponse, nil
 }
 
-func (capClient *CapitalClientAPI) GetPositionOrderConfirmation(dealReference string) (confirmation PositionOrderConfirmationResponse, err error) {
-	if capClient.HttpClient.Transport == nil {
+func (capClient *CapitalClientAPI) GetPositionOrderConfirmation(ctx context.Context, dealReference string) (confirmation PositionOrderConfirmationResponse, err error) {
+	if !capClient.hasSession() {
 		return confirmation, &CapitalClientUnathenticated{}
 	}
 
-	request, _ := http.NewRequest("GET", capClient.CapitalDomainName+"/api/v1/confirms/"+dealReference, nil)
-	response, err := capClient.HttpClient.Do(request)
+	request, _ := http.NewRequestWithContext(ctx, "GET", capClient.CapitalDomainName+"/api/v1/confirms/"+dealReference, nil)
+	response, err := capClient.do(request)
 	if err != nil {
 		return confirmation, err
 	}
 	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(response.Body)
-		return confirmation, errors.New(fmt.Sprintf("Unexpected [%d] Status Code Response - %s", response.StatusCode, string(body)))
+		return confirmation, newCapitalAPIError(response)
 	}
 
 	confirmation = PositionOrderConfirmationResponse{}
@@ -370,8 +573,8 @@ func (capClient *CapitalClientAPI) GetPositionOrderConfirmation(dealReference st
 	return confirmation, nil
 }
 
-func (capClient *CapitalClientAPI) GetPreferredAccount() (AccountResponse, error) {
-	accountsResponse, err := capClient.GetAllAccounts()
+func (capClient *CapitalClientAPI) GetPreferredAccount(ctx context.Context) (AccountResponse, error) {
+	accountsResponse, err := capClient.GetAllAccounts(ctx)
 	if err != nil {
 		return AccountResponse{}, err
 	}
@@ -383,20 +586,19 @@ func (capClient *CapitalClientAPI) GetPreferredAccount() (AccountResponse, error
 	return AccountResponse{}, errors.New("Unexpected Error: Preferred Account Doesn't exist. Please make an issue on Github")
 }
 
-func (capClient *CapitalClientAPI) DeleteWorkingOrder(dealReference string) (deleteWorkingResponse WorkingOrderResponse, err error) {
-	if capClient.HttpClient.Transport == nil {
+func (capClient *CapitalClientAPI) DeleteWorkingOrder(ctx context.Context, dealReference string) (deleteWorkingResponse WorkingOrderResponse, err error) {
+	if !capClient.hasSession() {
 		return deleteWorkingResponse, &CapitalClientUnathenticated{}
 	}
 
-	request, _ := http.NewRequest("DELETE", capClient.CapitalDomainName+"api/v1/workingorders/"+dealReference, nil)
-	response, err := capClient.HttpClient.Do(request)
+	request, _ := http.NewRequestWithContext(ctx, "DELETE", capClient.CapitalDomainName+"/api/v1/workingorders/"+dealReference, nil)
+	response, err := capClient.do(request)
 	if err != nil {
 		return deleteWorkingResponse, err
 	}
 	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(response.Body)
-		return deleteWorkingResponse, errors.New(fmt.Sprintf("Unexpected [%d] Status Code Response - %s", response.StatusCode, string(body)))
+		return deleteWorkingResponse, newCapitalAPIError(response)
 	}
 
 	deleteWorkingResponse = WorkingOrderResponse{}
@@ -7,9 +7,48 @@ type NewSessionBody struct {
 }
 
 type CreateWorkingOrderBody struct {
-	Epic      string    `json:"epic"`
-	Direction Signal    `json:"direction"`
-	Type      OrderType `json:"type"`
-	Size      float64   `json:"size"`
-	Level     float64   `json:"level"`
+	Epic           string    `json:"epic"`
+	Direction      Signal    `json:"direction"`
+	Type           OrderType `json:"type"`
+	Size           float64   `json:"size"`
+	Level          float64   `json:"level"`
+	GoodTillDate   string    `json:"goodTillDate,omitempty"` // YYYY-MM-DDTHH:MM:SS, UTC
+	GuaranteedStop bool      `json:"guaranteedStop,omitempty"`
+	TrailingStop   bool      `json:"trailingStop,omitempty"`
+	StopLevel      float64   `json:"stopLevel,omitempty"`
+	StopDistance   float64   `json:"stopDistance,omitempty"`
+	ProfitLevel    float64   `json:"profitLevel,omitempty"`
+	ProfitDistance float64   `json:"profitDistance,omitempty"`
+}
+
+type UpdateWorkingOrderBody struct {
+	Level          float64 `json:"level,omitempty"`
+	GoodTillDate   string  `json:"goodTillDate,omitempty"` // YYYY-MM-DDTHH:MM:SS, UTC
+	GuaranteedStop bool    `json:"guaranteedStop,omitempty"`
+	TrailingStop   bool    `json:"trailingStop,omitempty"`
+	StopLevel      float64 `json:"stopLevel,omitempty"`
+	StopDistance   float64 `json:"stopDistance,omitempty"`
+	ProfitLevel    float64 `json:"profitLevel,omitempty"`
+	ProfitDistance float64 `json:"profitDistance,omitempty"`
+}
+
+type CreatePositionBody struct {
+	Epic           string  `json:"epic"`
+	Direction      Signal  `json:"direction"`
+	Size           float64 `json:"size"`
+	GuaranteedStop bool    `json:"guaranteedStop,omitempty"`
+	TrailingStop   bool    `json:"trailingStop,omitempty"`
+	StopLevel      float64 `json:"stopLevel,omitempty"`
+	StopDistance   float64 `json:"stopDistance,omitempty"`
+	ProfitLevel    float64 `json:"profitLevel,omitempty"`
+	ProfitDistance float64 `json:"profitDistance,omitempty"`
+}
+
+type UpdatePositionBody struct {
+	GuaranteedStop bool    `json:"guaranteedStop,omitempty"`
+	TrailingStop   bool    `json:"trailingStop,omitempty"`
+	StopLevel      float64 `json:"stopLevel,omitempty"`
+	StopDistance   float64 `json:"stopDistance,omitempty"`
+	ProfitLevel    float64 `json:"profitLevel,omitempty"`
+	ProfitDistance float64 `json:"profitDistance,omitempty"`
 }
@@ -0,0 +1,106 @@
+package gominitrader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors of the Capital.com API. A CapitalAPIError unwraps to the one matching its status code
+// and errorCode, so callers can check them with errors.Is.
+var (
+	ErrSessionExpired     = errors.New("Capital Session Expired")
+	ErrInvalidCredentials = errors.New("Capital Invalid Credentials")
+	ErrRateLimited        = errors.New("Capital Rate Limit Exceeded")
+	ErrInsufficientFunds  = errors.New("Capital Insufficient Funds")
+	ErrMarketClosed       = errors.New("Capital Market Closed")
+	ErrInvalidSize        = errors.New("Capital Invalid Deal Size")
+	ErrInvalidStops       = errors.New("Capital Invalid Stop Loss Or Take Profit")
+	ErrNotFound           = errors.New("Capital Resource Not Found")
+	ErrInvalidRequest     = errors.New("Capital Invalid Request")
+)
+
+// capitalErrorCodes maps fragments of Capital.com errorCode strings to sentinel errors; the first match wins.
+var capitalErrorCodes = []struct {
+	fragment string
+	err      error
+}{
+	{"session.token", ErrSessionExpired},
+	{"client.token", ErrSessionExpired},
+	{"security.token", ErrSessionExpired},
+	{"api.key", ErrInvalidCredentials},
+	{"too-many", ErrRateLimited},
+	{"insufficient", ErrInsufficientFunds},
+	{"not-enough", ErrInsufficientFunds},
+	{"market.closed", ErrMarketClosed},
+	{"market-closed", ErrMarketClosed},
+	{"market_closed", ErrMarketClosed},
+	{"size", ErrInvalidSize},
+	{"stoploss", ErrInvalidStops},
+	{"takeprofit", ErrInvalidStops},
+	{"stop", ErrInvalidStops},
+	{"profit", ErrInvalidStops},
+	{"not-found", ErrNotFound},
+}
+
+// CapitalAPIError is a non successful Capital.com API response.
+type CapitalAPIError struct {
+	StatusCode int
+	ErrorCode  string // Capital.com errorCode, e.g. `error.invalid.session.token`
+	Method     string
+	Endpoint   string // request path
+	RequestID  string // X-Request-Id response header, if any
+	Body       string
+}
+
+func (apiError *CapitalAPIError) Error() string {
+	return fmt.Sprintf("Unexpected [%d] Status Code Response From %s %s - %s", apiError.StatusCode, apiError.Method, apiError.Endpoint, apiError.Body)
+}
+
+// Unwrap returns the sentinel error of the response, or nil if there is none.
+func (apiError *CapitalAPIError) Unwrap() error {
+	errorCode := strings.ToLower(apiError.ErrorCode)
+	for _, code := range capitalErrorCodes {
+		if errorCode != "" && strings.Contains(errorCode, code.fragment) {
+			return code.err
+		}
+	}
+	switch {
+	case apiError.StatusCode == http.StatusUnauthorized && apiError.Method == http.MethodPost && strings.HasSuffix(apiError.Endpoint, "/session"):
+		return ErrInvalidCredentials
+	case apiError.StatusCode == http.StatusUnauthorized:
+		return ErrSessionExpired
+	case apiError.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case apiError.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case apiError.StatusCode == http.StatusBadRequest:
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+// newCapitalAPIError reads an unexpected response into a CapitalAPIError. The response body is consumed
+// but not closed.
+func newCapitalAPIError(response *http.Response) *CapitalAPIError {
+	body, _ := ioutil.ReadAll(response.Body)
+	apiError := &CapitalAPIError{
+		StatusCode: response.StatusCode,
+		RequestID:  response.Header.Get("X-Request-Id"),
+		Body:       string(body),
+	}
+	if response.Request != nil {
+		apiError.Method = response.Request.Method
+		apiError.Endpoint = response.Request.URL.Path
+	}
+	errorResponse := struct {
+		ErrorCode string `json:"errorCode"`
+	}{}
+	if json.Unmarshal(body, &errorResponse) == nil {
+		apiError.ErrorCode = errorResponse.ErrorCode
+	}
+	return apiError
+}
@@ -0,0 +1,114 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCapitalAPIError(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+	capClient.Scheduler = nil
+
+	tests := []struct {
+		method        string
+		path          string
+		statusCode    int
+		errorCode     string
+		call          func() error
+		expectedError error
+	}{
+		{"POST", "/api/v1/positions", http.StatusUnauthorized, "error.invalid.session.token", func() error {
+			_, err := capClient.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 1})
+			return err
+		}, ErrSessionExpired},
+		{"GET", "/api/v1/prices/", http.StatusTooManyRequests, "error.too-many.requests", func() error {
+			_, err := capClient.GetHistoricalPrices(context.Background(), "USDMXN", MINUTE, 10)
+			return err
+		}, ErrRateLimited},
+		{"POST", "/api/v1/positions", http.StatusBadRequest, "error.invalid.size.minvalue", func() error {
+			_, err := capClient.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 0.0001})
+			return err
+		}, ErrInvalidSize},
+		{"POST", "/api/v1/workingorders", http.StatusBadRequest, "error.invalid.stoploss.maxvalue", func() error {
+			_, err := capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19, Size: 1})
+			return err
+		}, ErrInvalidStops},
+		{"GET", "/api/v1/markets", http.StatusBadRequest, "error.market.closed", func() error {
+			_, err := capClient.GetMarketsDetails(context.Background(), []string{"USDMXN"})
+			return err
+		}, ErrMarketClosed},
+		{"GET", "/api/v1/accounts", http.StatusTooManyRequests, "error.too-many.requests", func() error {
+			_, err := capClient.GetAllAccounts(context.Background())
+			return err
+		}, ErrRateLimited},
+		{"GET", "/api/v1/accounts", http.StatusTooManyRequests, "error.too-many.requests", func() error {
+			_, err := capClient.GetPreferredAccount(context.Background())
+			return err
+		}, ErrRateLimited},
+		{"", "", 0, "", func() error {
+			_, err := capClient.DeleteWorkingOrder(context.Background(), "unknown")
+			return err
+		}, ErrNotFound},
+	}
+	for i, test := range tests {
+		if test.statusCode != 0 {
+			emulator.FailNext(test.method, test.path, test.statusCode, test.errorCode)
+		}
+		err := test.call()
+		if !errors.Is(err, test.expectedError) {
+			t.Errorf("Test case %d: expected %v, got %v", i, test.expectedError, err)
+		}
+		var apiError *CapitalAPIError
+		if !errors.As(err, &apiError) || apiError.Endpoint == "" || apiError.RequestID == "" || (test.errorCode != "" && apiError.ErrorCode != test.errorCode) {
+			t.Errorf("Test case %d: unexpected API error %+v", i, apiError)
+		}
+	}
+}
+
+func TestCapitalClientUnauthenticated(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+
+	tests := []func() error{
+		func() error { _, err := capClient.GetWatchLists(context.Background()); return err },
+		func() error { _, err := capClient.GetAllAccounts(context.Background()); return err },
+		func() error {
+			_, err := capClient.GetMarketsDetails(context.Background(), []string{"USDMXN"})
+			return err
+		},
+		func() error {
+			_, err := capClient.GetHistoricalPrices(context.Background(), "USDMXN", MINUTE, 10)
+			return err
+		},
+	}
+	for i, call := range tests {
+		var unauthenticated *CapitalClientUnathenticated
+		if err := call(); !errors.As(err, &unauthenticated) {
+			t.Errorf("Test case %d: expected %T, got %v", i, unauthenticated, err)
+		}
+	}
+}
+
+func TestCapitalAPIErrorUnwrap(t *testing.T) {
+	tests := []struct {
+		apiError      CapitalAPIError
+		expectedError error
+	}{
+		{CapitalAPIError{StatusCode: 401, Method: "GET", Endpoint: "/api/v1/accounts"}, ErrSessionExpired},
+		{CapitalAPIError{StatusCode: 401, Method: "POST", Endpoint: "/api/v1/session", ErrorCode: "error.invalid.details"}, ErrInvalidCredentials},
+		{CapitalAPIError{StatusCode: 401, ErrorCode: "error.invalid.api.key"}, ErrInvalidCredentials},
+		{CapitalAPIError{StatusCode: 400, ErrorCode: "error.not-enough.funds"}, ErrInsufficientFunds},
+		{CapitalAPIError{StatusCode: 404, ErrorCode: "error.not-found.dealId"}, ErrNotFound},
+		{CapitalAPIError{StatusCode: 400, ErrorCode: "error.invalid.epic"}, ErrInvalidRequest},
+		{CapitalAPIError{StatusCode: 500, ErrorCode: "error.internal"}, nil},
+	}
+	for i, test := range tests {
+		if err := test.apiError.Unwrap(); err != test.expectedError {
+			t.Errorf("Test case %d: expected %v, got %v", i, test.expectedError, err)
+		}
+	}
+}
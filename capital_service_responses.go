@@ -1,15 +1,13 @@
 package gominitrader
 
-import "time"
-
 type WatchListsResponse struct {
-	Epics []string `json: "epics"`
-	Name  string   `json: "name"`
+	Epics []string `json:"epics"`
+	Name  string   `json:"name"`
 }
 
 type EncriptionResponse struct {
-	EncryptionKey string `json: "encryptionKey"`
-	TimeStamp     int    `json: "timeStamp"`
+	EncryptionKey string `json:"encryptionKey"`
+	TimeStamp     int    `json:"timeStamp"`
 }
 
 type NewSessionResponse struct {
@@ -57,63 +55,65 @@ type AccountResponse struct {
 }
 
 type MarketsDetailsResponse struct {
-	MarketDetails []struct {
-		Instrument struct {
-			Epic                     string  `json:"epic"`
-			Expiry                   string  `json:"expiry"`
-			Name                     string  `json:"name"`
-			LotSize                  int     `json:"lotSize"`
-			Type                     string  `json:"type"`
-			GuaranteedStopAllowed    bool    `json:"guaranteedStopAllowed"`
-			StreamingPricesAvailable bool    `json:"streamingPricesAvailable"`
-			Currency                 string  `json:"currency"`
-			MarginFactor             float64 `json:"marginFactor"`
-			MarginFactorUnit         string  `json:"marginFactorUnit"`
-			OpeningHours             string  `json:"openingHours"`
-			Country                  string  `json:"country"`
-		} `json:"instrument"`
-		DealingRules struct {
-			MinStepDistance struct {
-				Unit  string  `json:"unit"`
-				Value float64 `json:"value"`
-			} `json:"minStepDistance"`
-			MinDealSize struct {
-				Unit  string  `json:"unit"`
-				Value float64 `json:"value"`
-			} `json:"minDealSize"`
-			MaxDealSize struct {
-				Unit  string  `json:"unit"`
-				Value float64 `json:"value"`
-			} `json:"maxDealSize"`
-			MinSizeIncrement struct {
-				Unit  string  `json:"unit"`
-				Value float64 `json:"value"`
-			} `json:"minSizeIncrement"`
-			MinGuaranteedStopDistance struct {
-				Unit  string  `json:"unit"`
-				Value float64 `json:"value"`
-			} `json:"minGuaranteedStopDistance"`
-			MinStopOrProfitDistance struct {
-				Unit  string  `json:"unit"`
-				Value float64 `json:"value"`
-			} `json:"minStopOrProfitDistance"`
-			MaxStopOrProfitDistance struct {
-				Unit  string  `json:"unit"`
-				Value float64 `json:"value"`
-			} `json:"maxStopOrProfitDistance"`
-			MarketOrderPreference   string `json:"marketOrderPreference"`
-			TrailingStopsPreference string `json:"trailingStopsPreference"`
-		} `json:"dealingRules"`
-		Snapshot struct {
-			MarketStatus        string  `json:"marketStatus"`
-			UpdateTime          string  `json:"updateTime"`
-			DelayTime           int     `json:"delayTime"`
-			Bid                 float64 `json:"bid"`
-			Offer               float64 `json:"offer"`
-			DecimalPlacesFactor int     `json:"decimalPlacesFactor"`
-			ScalingFactor       int     `json:"scalingFactor"`
-		} `json:"snapshot"`
-	} `json:"marketDetails"`
+	MarketDetails []MarketDetails `json:"marketDetails"`
+}
+
+type MarketDetails struct {
+	Instrument struct {
+		Epic                     string  `json:"epic"`
+		Expiry                   string  `json:"expiry"`
+		Name                     string  `json:"name"`
+		LotSize                  int     `json:"lotSize"`
+		Type                     string  `json:"type"`
+		GuaranteedStopAllowed    bool    `json:"guaranteedStopAllowed"`
+		StreamingPricesAvailable bool    `json:"streamingPricesAvailable"`
+		Currency                 string  `json:"currency"`
+		MarginFactor             float64 `json:"marginFactor"`
+		MarginFactorUnit         string  `json:"marginFactorUnit"`
+		OpeningHours             string  `json:"openingHours"`
+		Country                  string  `json:"country"`
+	} `json:"instrument"`
+	DealingRules struct {
+		MinStepDistance struct {
+			Unit  string  `json:"unit"`
+			Value float64 `json:"value"`
+		} `json:"minStepDistance"`
+		MinDealSize struct {
+			Unit  string  `json:"unit"`
+			Value float64 `json:"value"`
+		} `json:"minDealSize"`
+		MaxDealSize struct {
+			Unit  string  `json:"unit"`
+			Value float64 `json:"value"`
+		} `json:"maxDealSize"`
+		MinSizeIncrement struct {
+			Unit  string  `json:"unit"`
+			Value float64 `json:"value"`
+		} `json:"minSizeIncrement"`
+		MinGuaranteedStopDistance struct {
+			Unit  string  `json:"unit"`
+			Value float64 `json:"value"`
+		} `json:"minGuaranteedStopDistance"`
+		MinStopOrProfitDistance struct {
+			Unit  string  `json:"unit"`
+			Value float64 `json:"value"`
+		} `json:"minStopOrProfitDistance"`
+		MaxStopOrProfitDistance struct {
+			Unit  string  `json:"unit"`
+			Value float64 `json:"value"`
+		} `json:"maxStopOrProfitDistance"`
+		MarketOrderPreference   string `json:"marketOrderPreference"`
+		TrailingStopsPreference string `json:"trailingStopsPreference"`
+	} `json:"dealingRules"`
+	Snapshot struct {
+		MarketStatus        string  `json:"marketStatus"`
+		UpdateTime          string  `json:"updateTime"`
+		DelayTime           int     `json:"delayTime"`
+		Bid                 float64 `json:"bid"`
+		Offer               float64 `json:"offer"`
+		DecimalPlacesFactor int     `json:"decimalPlacesFactor"`
+		ScalingFactor       int     `json:"scalingFactor"`
+	} `json:"snapshot"`
 }
 
 type PricesResponse struct {
@@ -143,40 +143,54 @@ type CapitalPrice struct {
 }
 
 type PositionsResponse struct {
-	Positions []struct {
-		Position struct {
-			ContractSize   int       `json:"contractSize"`
-			CreatedDate    time.Time `json:"createdDate"`
-			CreatedDateUTC time.Time `json:"createdDateUTC"`
-			DealID         string    `json:"dealId"`
-			DealReference  string    `json:"dealReference"`
-			Size           int       `json:"size"`
-			Direction      string    `json:"direction"`
-			Level          float64   `json:"level"`
-			Currency       string    `json:"currency"`
-			GuaranteedStop bool      `json:"guaranteedStop,omitempty"`
-			ControlledRisk bool      `json:"controlledRisk,omitempty"`
-		} `json:"position"`
-		Market struct {
-			InstrumentName       string    `json:"instrumentName"`
-			Expiry               string    `json:"expiry"`
-			MarketStatus         string    `json:"marketStatus"`
-			Epic                 string    `json:"epic"`
-			InstrumentType       string    `json:"instrumentType"`
-			LotSize              int       `json:"lotSize"`
-			High                 float64   `json:"high"`
-			Low                  float64   `json:"low"`
-			PercentageChange     float64   `json:"percentageChange"`
-			NetChange            float64   `json:"netChange"`
-			Bid                  float64   `json:"bid"`
-			Offer                float64   `json:"offer"`
-			UpdateTime           time.Time `json:"updateTime"`
-			UpdateTimeUTC        time.Time `json:"updateTimeUTC"`
-			DelayTime            int       `json:"delayTime"`
-			StreamingPricesAvail bool      `json:"streamingPricesAvailable"`
-			ScalingFactor        int       `json:"scalingFactor"`
-		} `json:"market"`
-	} `json:"positions"`
+	Positions []PositionResponse `json:"positions"`
+}
+
+type PositionResponse struct {
+	Position PositionData   `json:"position"`
+	Market   PositionMarket `json:"market"`
+}
+
+type PositionData struct {
+	ContractSize   int     `json:"contractSize"`
+	CreatedDate    string  `json:"createdDate"`
+	CreatedDateUTC string  `json:"createdDateUTC"`
+	DealID         string  `json:"dealId"`
+	DealReference  string  `json:"dealReference"`
+	Size           float64 `json:"size"`
+	Direction      string  `json:"direction"`
+	Level          float64 `json:"level"`
+	Currency       string  `json:"currency"`
+	GuaranteedStop bool    `json:"guaranteedStop,omitempty"`
+	ControlledRisk bool    `json:"controlledRisk,omitempty"`
+	TrailingStop   bool    `json:"trailingStop,omitempty"`
+	StopLevel      float64 `json:"stopLevel,omitempty"`
+	StopDistance   float64 `json:"stopDistance,omitempty"`
+	ProfitLevel    float64 `json:"profitLevel,omitempty"`
+}
+
+type PositionMarket struct {
+	InstrumentName       string  `json:"instrumentName"`
+	Expiry               string  `json:"expiry"`
+	MarketStatus         string  `json:"marketStatus"`
+	Epic                 string  `json:"epic"`
+	InstrumentType       string  `json:"instrumentType"`
+	LotSize              int     `json:"lotSize"`
+	High                 float64 `json:"high"`
+	Low                  float64 `json:"low"`
+	PercentageChange     float64 `json:"percentageChange"`
+	NetChange            float64 `json:"netChange"`
+	Bid                  float64 `json:"bid"`
+	Offer                float64 `json:"offer"`
+	UpdateTime           string  `json:"updateTime"`
+	UpdateTimeUTC        string  `json:"updateTimeUTC"`
+	DelayTime            int     `json:"delayTime"`
+	StreamingPricesAvail bool    `json:"streamingPricesAvailable"`
+	ScalingFactor        int     `json:"scalingFactor"`
+}
+
+type DealReferenceResponse struct {
+	DealReference string `json:"dealReference"`
 }
 
 type WorkingOrderResponse struct {
@@ -184,67 +198,87 @@ type WorkingOrderResponse struct {
 }
 
 type WorkingOrdersResponse struct {
-	WorkingOrders []struct {
-		WorkingOrderData struct {
-			DealID          string  `json:"dealId"`
-			Direction       string  `json:"direction"`
-			Epic            string  `json:"epic"`
-			OrderSize       int     `json:"orderSize"`
-			OrderLevel      int     `json:"orderLevel"`
-			TimeInForce     string  `json:"timeInForce"`
-			GoodTillDate    string  `json:"goodTillDate"`
-			GoodTillDateUTC string  `json:"goodTillDateUTC"`
-			CreatedDate     string  `json:"createdDate"`
-			CreatedDateUTC  string  `json:"createdDateUTC"`
-			GuaranteedStop  bool    `json:"guaranteedStop"`
-			OrderType       string  `json:"orderType"`
-			StopDistance    float64 `json:"stopDistance"`
-			ProfitDistance  float64 `json:"profitDistance"`
-			CurrencyCode    string  `json:"currencyCode"`
-		} `json:"workingOrderData"`
-		MarketData struct {
-			InstrumentName           string  `json:"instrumentName"`
-			Expiry                   string  `json:"expiry"`
-			MarketStatus             string  `json:"marketStatus"`
-			Epic                     string  `json:"epic"`
-			InstrumentType           string  `json:"instrumentType"`
-			LotSize                  int     `json:"lotSize"`
-			High                     float64 `json:"high"`
-			Low                      float64 `json:"low"`
-			PercentageChange         float64 `json:"percentageChange"`
-			NetChange                float64 `json:"netChange"`
-			Bid                      float64 `json:"bid"`
-			Offer                    float64 `json:"offer"`
-			UpdateTime               string  `json:"updateTime"`
-			UpdateTimeUTC            string  `json:"updateTimeUTC"`
-			DelayTime                int     `json:"delayTime"`
-			StreamingPricesAvailable bool    `json:"streamingPricesAvailable"`
-			ScalingFactor            int     `json:"scalingFactor"`
-		} `json:"marketData"`
-	} `json:"workingOrders"`
+	WorkingOrders []WorkingOrder `json:"workingOrders"`
+}
+
+type WorkingOrder struct {
+	WorkingOrderData WorkingOrderData       `json:"workingOrderData"`
+	MarketData       WorkingOrderMarketData `json:"marketData"`
+}
+
+type WorkingOrderData struct {
+	DealID          string  `json:"dealId"`
+	Direction       string  `json:"direction"`
+	Epic            string  `json:"epic"`
+	OrderSize       float64 `json:"orderSize"`
+	OrderLevel      float64 `json:"orderLevel"`
+	TimeInForce     string  `json:"timeInForce"`
+	GoodTillDate    string  `json:"goodTillDate"`
+	GoodTillDateUTC string  `json:"goodTillDateUTC"`
+	CreatedDate     string  `json:"createdDate"`
+	CreatedDateUTC  string  `json:"createdDateUTC"`
+	GuaranteedStop  bool    `json:"guaranteedStop"`
+	TrailingStop    bool    `json:"trailingStop"`
+	OrderType       string  `json:"orderType"`
+	StopLevel       float64 `json:"stopLevel"`
+	StopDistance    float64 `json:"stopDistance"`
+	ProfitLevel     float64 `json:"profitLevel"`
+	ProfitDistance  float64 `json:"profitDistance"`
+	CurrencyCode    string  `json:"currencyCode"`
+}
+
+type WorkingOrderMarketData struct {
+	InstrumentName           string  `json:"instrumentName"`
+	Expiry                   string  `json:"expiry"`
+	MarketStatus             string  `json:"marketStatus"`
+	Epic                     string  `json:"epic"`
+	InstrumentType           string  `json:"instrumentType"`
+	LotSize                  int     `json:"lotSize"`
+	High                     float64 `json:"high"`
+	Low                      float64 `json:"low"`
+	PercentageChange         float64 `json:"percentageChange"`
+	NetChange                float64 `json:"netChange"`
+	Bid                      float64 `json:"bid"`
+	Offer                    float64 `json:"offer"`
+	UpdateTime               string  `json:"updateTime"`
+	UpdateTimeUTC            string  `json:"updateTimeUTC"`
+	DelayTime                int     `json:"delayTime"`
+	StreamingPricesAvailable bool    `json:"streamingPricesAvailable"`
+	ScalingFactor            int     `json:"scalingFactor"`
 }
 
 type ConfirmationStatus string
 
 const (
-	DELETED ConfirmationStatus = "DELETED"
+	OPEN            ConfirmationStatus = "OPEN"
+	DELETED         ConfirmationStatus = "DELETED"
+	POSITION_CLOSED ConfirmationStatus = "CLOSED"
+)
+
+type DealStatus string
+
+const (
+	ACCEPTED DealStatus = "ACCEPTED"
+	REJECTED DealStatus = "REJECTED"
 )
 
 type PositionOrderConfirmationResponse struct {
-	Date          string `json:"date"`
-	Status        string `json:"status"`
-	Reason        string `json:"reason"`
-	DealStatus    string `json:"dealStatus"`
-	Epic          string `json:"epic"`
-	DealRef       string `json:"dealReference"`
-	DealID        string `json:"dealId"`
-	AffectedDeals []struct {
-		ID     string `json:"dealId"`
-		Status string `json:"status"`
-	} `json:"affectedDeals"`
-	Level          float64 `json:"level"`
-	Size           float64 `json:"size"` // maps to QTY (quantity)
-	Direction      string  `json:"direction"`
-	GuaranteedStop bool    `json:"guaranteedStop"`
-	TrailingStop   bool    `json:"trailingStop"`
+	Date           string         `json:"date"`
+	Status         string         `json:"status"`
+	Reason         string         `json:"reason"`
+	DealStatus     string         `json:"dealStatus"`
+	Epic           string         `json:"epic"`
+	DealRef        string         `json:"dealReference"`
+	DealID         string         `json:"dealId"`
+	AffectedDeals  []AffectedDeal `json:"affectedDeals"`
+	Level          float64        `json:"level"`
+	Size           float64        `json:"size"` // maps to QTY (quantity)
+	Direction      string         `json:"direction"`
+	GuaranteedStop bool           `json:"guaranteedStop"`
+	TrailingStop   bool           `json:"trailingStop"`
+}
+
+type AffectedDeal struct {
+	ID     string `json:"dealId"`
+	Status string `json:"status"`
 }
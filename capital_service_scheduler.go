@@ -0,0 +1,323 @@
+package gominitrader
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestClass groups Capital.com endpoints sharing a request budget.
+type RequestClass string
+
+const (
+	SESSION_REQUEST     RequestClass = "SESSION"
+	TRADING_REQUEST     RequestClass = "TRADING"
+	MARKET_DATA_REQUEST RequestClass = "MARKET_DATA"
+)
+
+// requestClassPriority orders waiting requests; order placement goes before data polling.
+var requestClassPriority = map[RequestClass]int{
+	TRADING_REQUEST:     2,
+	SESSION_REQUEST:     1,
+	MARKET_DATA_REQUEST: 0,
+}
+
+// RateLimit is a token bucket: Rate requests per second, with up to Burst requests at once.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RequestClassStats are the scheduler metrics of a request class.
+type RequestClassStats struct {
+	Requests    int64
+	Throttled   int64 // 429 responses
+	Waiting     int
+	TotalWait   time.Duration
+	MaxWait     time.Duration
+	BlockedTill time.Time // backoff after a 429 response
+}
+
+// RequestScheduler throttles the requests of a CapitalClientAPI. Every request takes a token from the
+// bucket of its class and from the bucket shared by all classes; when tokens run out, requests wait in
+// priority order. A 429 response blocks its class for the Retry-After time, or for an exponential
+// backoff starting at Backoff, and the request is retried up to MaxRetries times.
+type RequestScheduler struct {
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	MaxRetries int
+
+	mutex   sync.Mutex
+	global  *tokenBucket
+	buckets map[RequestClass]*tokenBucket
+	waiters []*scheduledRequest
+	timer   *time.Timer
+	seq     int64
+	stats   map[RequestClass]*RequestClassStats
+}
+
+type tokenBucket struct {
+	limit        RateLimit
+	tokens       float64
+	updated      time.Time
+	blockedUntil time.Time
+	backoff      time.Duration
+}
+
+type scheduledRequest struct {
+	class    RequestClass
+	seq      int64
+	enqueued time.Time
+	ready    chan struct{}
+}
+
+// DefaultRateLimits follow the Capital.com limits: 10 requests per second per user, one session per
+// second and one order every 0.1 seconds.
+var DefaultRateLimits = map[RequestClass]RateLimit{
+	SESSION_REQUEST:     {Rate: 1, Burst: 1},
+	TRADING_REQUEST:     {Rate: 10, Burst: 1},
+	MARKET_DATA_REQUEST: {Rate: 10, Burst: 10},
+}
+
+var DefaultGlobalRateLimit = RateLimit{Rate: 10, Burst: 10}
+
+func NewRequestScheduler(global RateLimit, limits map[RequestClass]RateLimit) *RequestScheduler {
+	now := time.Now()
+	scheduler := &RequestScheduler{
+		Backoff:    time.Second,
+		MaxBackoff: time.Second * 30,
+		MaxRetries: 3,
+		global:     &tokenBucket{limit: global, tokens: float64(global.Burst), updated: now},
+		buckets:    make(map[RequestClass]*tokenBucket),
+		stats:      make(map[RequestClass]*RequestClassStats),
+	}
+	for class := range requestClassPriority {
+		limit, ok := limits[class]
+		if !ok {
+			limit = global
+		}
+		scheduler.buckets[class] = &tokenBucket{limit: limit, tokens: float64(limit.Burst), updated: now}
+		scheduler.stats[class] = &RequestClassStats{}
+	}
+	return scheduler
+}
+
+func NewDefaultRequestScheduler() *RequestScheduler {
+	return NewRequestScheduler(DefaultGlobalRateLimit, DefaultRateLimits)
+}
+
+// Stats returns the metrics of every request class.
+func (scheduler *RequestScheduler) Stats() map[RequestClass]RequestClassStats {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	stats := make(map[RequestClass]RequestClassStats)
+	for class, classStats := range scheduler.stats {
+		copied := *classStats
+		if blockedUntil := scheduler.buckets[class].blockedUntil; blockedUntil.After(time.Now()) {
+			copied.BlockedTill = blockedUntil
+		}
+		stats[class] = copied
+	}
+	return stats
+}
+
+// Do sends request through client once the scheduler lets it go, retrying it after 429 responses. It
+// gives up waiting when the request context is done.
+func (scheduler *RequestScheduler) Do(client *http.Client, request *http.Request) (*http.Response, error) {
+	class := classifyRequest(request)
+	for retry := 0; ; retry++ {
+		if err := scheduler.wait(request.Context(), class); err != nil {
+			return nil, err
+		}
+		response, err := client.Do(request)
+		if err != nil || response.StatusCode != http.StatusTooManyRequests {
+			if err == nil {
+				scheduler.succeeded(class)
+			}
+			return response, err
+		}
+
+		scheduler.throttled(class, retryAfter(response.Header.Get("Retry-After"), time.Now()))
+		if retry >= scheduler.MaxRetries || (request.Body != nil && request.GetBody == nil) {
+			return response, nil
+		}
+		response.Body.Close()
+		if request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			request.Body = body
+		}
+	}
+}
+
+// wait blocks until the request can be sent, or until ctx is done.
+func (scheduler *RequestScheduler) wait(ctx context.Context, class RequestClass) error {
+	scheduler.mutex.Lock()
+	scheduler.seq++
+	waiter := &scheduledRequest{class: class, seq: scheduler.seq, enqueued: time.Now(), ready: make(chan struct{})}
+	scheduler.waiters = append(scheduler.waiters, waiter)
+	scheduler.stats[class].Waiting++
+	scheduler.schedule()
+	scheduler.mutex.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	for i, waiting := range scheduler.waiters {
+		if waiting == waiter {
+			scheduler.waiters = append(scheduler.waiters[:i], scheduler.waiters[i+1:]...)
+			scheduler.stats[class].Waiting--
+			return ctx.Err()
+		}
+	}
+	// the token was handed out while ctx was done
+	return nil
+}
+
+// schedule hands out tokens to waiting requests by priority, and sets a timer for when the next token
+// is available. It must be called with the mutex held.
+func (scheduler *RequestScheduler) schedule() {
+	now := time.Now()
+	scheduler.global.refill(now)
+	for _, bucket := range scheduler.buckets {
+		bucket.refill(now)
+	}
+	sort.SliceStable(scheduler.waiters, func(i, j int) bool {
+		a, b := scheduler.waiters[i], scheduler.waiters[j]
+		if requestClassPriority[a.class] != requestClassPriority[b.class] {
+			return requestClassPriority[a.class] > requestClassPriority[b.class]
+		}
+		return a.seq < b.seq
+	})
+
+	waiting := scheduler.waiters[:0]
+	next := time.Duration(-1)
+	for _, waiter := range scheduler.waiters {
+		bucket := scheduler.buckets[waiter.class]
+		wait := bucket.wait(now)
+		if globalWait := scheduler.global.wait(now); globalWait > wait {
+			wait = globalWait
+		}
+		if wait > 0 {
+			waiting = append(waiting, waiter)
+			if next < 0 || wait < next {
+				next = wait
+			}
+			continue
+		}
+
+		bucket.tokens--
+		scheduler.global.tokens--
+		stats := scheduler.stats[waiter.class]
+		waited := now.Sub(waiter.enqueued)
+		stats.Requests++
+		stats.Waiting--
+		stats.TotalWait += waited
+		if waited > stats.MaxWait {
+			stats.MaxWait = waited
+		}
+		close(waiter.ready)
+	}
+	scheduler.waiters = waiting
+
+	if scheduler.timer != nil {
+		scheduler.timer.Stop()
+		scheduler.timer = nil
+	}
+	if len(scheduler.waiters) != 0 {
+		scheduler.timer = time.AfterFunc(next, func() {
+			scheduler.mutex.Lock()
+			defer scheduler.mutex.Unlock()
+			scheduler.schedule()
+		})
+	}
+}
+
+// throttled blocks a class after a 429 response, for retryAfter or the next exponential backoff.
+func (scheduler *RequestScheduler) throttled(class RequestClass, retryAfter time.Duration) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	bucket := scheduler.buckets[class]
+	if bucket.backoff == 0 {
+		bucket.backoff = scheduler.Backoff
+	} else if bucket.backoff *= 2; bucket.backoff > scheduler.MaxBackoff {
+		bucket.backoff = scheduler.MaxBackoff
+	}
+	wait := bucket.backoff
+	if retryAfter > 0 {
+		wait = retryAfter
+	}
+	bucket.blockedUntil = time.Now().Add(wait)
+	bucket.tokens = 0
+	scheduler.stats[class].Throttled++
+}
+
+func (scheduler *RequestScheduler) succeeded(class RequestClass) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	scheduler.buckets[class].backoff = 0
+}
+
+func (bucket *tokenBucket) refill(now time.Time) {
+	if bucket.limit.Rate <= 0 {
+		bucket.tokens = float64(bucket.limit.Burst)
+		return
+	}
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * bucket.limit.Rate
+	if bucket.tokens > float64(bucket.limit.Burst) {
+		bucket.tokens = float64(bucket.limit.Burst)
+	}
+	bucket.updated = now
+}
+
+// wait is how long until the bucket has a token; zero if it has one now.
+func (bucket *tokenBucket) wait(now time.Time) time.Duration {
+	wait := time.Duration(0)
+	if bucket.blockedUntil.After(now) {
+		wait = bucket.blockedUntil.Sub(now)
+	}
+	if bucket.tokens < 1 && bucket.limit.Rate > 0 {
+		if tokenWait := time.Duration((1 - bucket.tokens) / bucket.limit.Rate * float64(time.Second)); tokenWait > wait {
+			wait = tokenWait
+		}
+	}
+	return wait
+}
+
+// classifyRequest returns the class of a Capital.com request from its method and path.
+func classifyRequest(request *http.Request) RequestClass {
+	path := request.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/api/v1/session") && request.Method == http.MethodPost:
+		return SESSION_REQUEST
+	case strings.Contains(path, "/api/v1/positions"), strings.Contains(path, "/api/v1/workingorders"), strings.Contains(path, "/api/v1/confirms"):
+		return TRADING_REQUEST
+	default:
+		return MARKET_DATA_REQUEST
+	}
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP date.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package gominitrader
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestSchedulerRateLimit(t *testing.T) {
+	scheduler := NewRequestScheduler(RateLimit{Rate: 20, Burst: 1}, nil)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		scheduler.wait(context.Background(), MARKET_DATA_REQUEST)
+	}
+	// one token right away and four more at 20 per second
+	if elapsed := time.Since(start); elapsed < time.Millisecond*190 {
+		t.Errorf("expected requests to be throttled, took %s", elapsed)
+	}
+	stats := scheduler.Stats()[MARKET_DATA_REQUEST]
+	if stats.Requests != 5 || stats.Waiting != 0 || stats.MaxWait < time.Millisecond*40 || stats.TotalWait < stats.MaxWait {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRequestSchedulerPriority(t *testing.T) {
+	scheduler := NewRequestScheduler(RateLimit{Rate: 10, Burst: 1}, nil)
+	scheduler.wait(context.Background(), MARKET_DATA_REQUEST)
+
+	mutex := sync.Mutex{}
+	order := []RequestClass{}
+	waitGroup := sync.WaitGroup{}
+	for _, class := range []RequestClass{MARKET_DATA_REQUEST, MARKET_DATA_REQUEST, TRADING_REQUEST} {
+		waitGroup.Add(1)
+		go func(class RequestClass) {
+			defer waitGroup.Done()
+			scheduler.wait(context.Background(), class)
+			mutex.Lock()
+			order = append(order, class)
+			mutex.Unlock()
+		}(class)
+		time.Sleep(time.Millisecond * 10)
+	}
+	waitGroup.Wait()
+	if order[0] != TRADING_REQUEST {
+		t.Errorf("expected the trading request to go first, got %v", order)
+	}
+}
+
+func TestRequestSchedulerCancel(t *testing.T) {
+	scheduler := NewRequestScheduler(RateLimit{Rate: 1, Burst: 1}, nil)
+	scheduler.wait(context.Background(), MARKET_DATA_REQUEST)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if err := scheduler.wait(ctx, MARKET_DATA_REQUEST); err != context.DeadlineExceeded {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+	if stats := scheduler.Stats()[MARKET_DATA_REQUEST]; stats.Waiting != 0 || stats.Requests != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRequestSchedulerBackoff(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+	capClient.Scheduler.Backoff = time.Millisecond * 20
+
+	tests := []struct {
+		failures          int
+		expectedError     bool
+		expectedThrottled int64
+	}{
+		{1, false, 1},
+		{2, false, 3},
+		{4, true, 7}, // 3 retries
+	}
+	for i, test := range tests {
+		for j := 0; j < test.failures; j++ {
+			emulator.FailNext("GET", "/api/v1/prices/", http.StatusTooManyRequests, "error.too-many.requests")
+		}
+		_, err := capClient.GetHistoricalPrices(context.Background(), "USDMXN", MINUTE, 10)
+		if (err != nil) != test.expectedError {
+			t.Errorf("Test case %d: unexpected error %v", i, err)
+		}
+		if throttled := capClient.Scheduler.Stats()[MARKET_DATA_REQUEST].Throttled; throttled != test.expectedThrottled {
+			t.Errorf("Test case %d: expected %d throttled requests, got %d", i, test.expectedThrottled, throttled)
+		}
+	}
+
+	// trading requests have their own budget
+	if !capClient.Scheduler.Stats()[TRADING_REQUEST].BlockedTill.IsZero() {
+		t.Errorf("trading requests should not be blocked by market data backoff")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header   string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"3", time.Second * 3},
+		{"Mon, 02 Jan 2023 00:00:05 GMT", time.Second * 5},
+		{"Mon, 01 Jan 2023 00:00:05 GMT", 0},
+		{"soon", 0},
+	}
+	for i, test := range tests {
+		if wait := retryAfter(test.header, now); wait != test.expected {
+			t.Errorf("Test case %d: expected %s, got %s", i, test.expected, wait)
+		}
+	}
+}
+
+func TestClassifyRequest(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected RequestClass
+	}{
+		{"POST", "/api/v1/session", SESSION_REQUEST},
+		{"GET", "/api/v1/session/encryptionKey", MARKET_DATA_REQUEST},
+		{"POST", "/api/v1/positions", TRADING_REQUEST},
+		{"DELETE", "/api/v1/workingorders/o_1", TRADING_REQUEST},
+		{"GET", "/api/v1/confirms/o_1", TRADING_REQUEST},
+		{"GET", "/api/v1/prices/USDMXN", MARKET_DATA_REQUEST},
+	}
+	for i, test := range tests {
+		request, _ := http.NewRequest(test.method, "https://example.com"+test.path, nil)
+		if class := classifyRequest(request); class != test.expected {
+			t.Errorf("Test case %d: expected %s, got %s", i, test.expected, class)
+		}
+	}
+}
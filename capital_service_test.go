@@ -1,34 +1,45 @@
 package gominitrader
 
 import (
-	"os"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
-
-	"github.com/joho/godotenv"
+	"time"
 )
 
-func _TestCapitalClient() (client *CapitalClientAPI, err error) {
-	err = godotenv.Load(".env")
+func _TestCapitalEmulator(t *testing.T) *CapitalEmulator {
+	emulator, err := NewCapitalEmulator("test@minitrader.com", "test-api-key", "test-api-key-password")
 	if err != nil {
-		return client, err
+		t.Fatal(err)
+	}
+	t.Cleanup(emulator.Close)
+
+	now := time.Now()
+	epicPrices := map[string]float64{"USDMXN": 19.5, "USDCAD": 1.35, "USDJPY": 140, "EURUSD": 1.08, "BTCUSD": 25000}
+	for epic, price := range epicPrices {
+		for _, timeframe := range []Timeframe{MINUTE, MINUTE_5, MINUTE_15, MINUTE_30, HOUR} {
+			emulator.SetPrices(epic, timeframe, GenerateCapitalPrices(now, timeframe, 500, price, price*0.0002))
+		}
 	}
-	capitalEmail := os.Getenv("CAPITAL_EMAIL")
-	capitalApiKey := os.Getenv("CAPITAL_API_KEY")
-	capitalApiKeyPassword := os.Getenv("CAPITAL_API_KEY_PASSWORD")
-	capClient, err := NewCapitalClient(capitalEmail, capitalApiKey, capitalApiKeyPassword, true)
-	return capClient, err
+	return emulator
+}
+
+func _TestCapitalClient(t *testing.T) (client *CapitalClientAPI, err error) {
+	return _TestCapitalEmulator(t).NewClient()
 }
 
 func TestNewCapitalClient(t *testing.T) {
-	_, err := _TestCapitalClient()
+	_, err := _TestCapitalClient(t)
 	if err != nil {
 		t.Errorf("%v", err)
 	}
 }
 
 func TestGetEncriptionKey(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	encription, err := capClient.GetEncriptionKey()
+	capClient, _ := _TestCapitalClient(t)
+	encription, err := capClient.GetEncriptionKey(context.Background())
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -39,8 +50,8 @@ func TestGetEncriptionKey(t *testing.T) {
 }
 
 func TestGetEncryptedPassword(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	encription, _ := capClient.GetEncriptionKey()
+	capClient, _ := _TestCapitalClient(t)
+	encription, _ := capClient.GetEncriptionKey(context.Background())
 	encryptedPassword, err := capClient.GetEncryptedPassword(encription)
 	if err != nil {
 		t.Errorf("%v", err)
@@ -48,9 +59,34 @@ func TestGetEncryptedPassword(t *testing.T) {
 	t.Logf("Gathered Key: %s", encryptedPassword)
 }
 
+func TestDecodeCapitalResponses(t *testing.T) {
+	tests := []struct {
+		fixture      string
+		response     interface{}
+		expectedKeys []string
+	}{
+		{`{"encryptionKey":"MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA","timeStamp":1649058606308}`, &EncriptionResponse{}, []string{"encryptionKey", "timeStamp"}},
+		{`{"epics":["USDMXN","EURUSD"],"name":"Popular Markets"}`, &WatchListsResponse{}, []string{"epics", "name"}},
+	}
+	for i, test := range tests {
+		if err := json.NewDecoder(strings.NewReader(test.fixture)).Decode(test.response); err != nil {
+			t.Errorf("Test case %d: %v", i, err)
+		}
+		value := reflect.ValueOf(test.response).Elem()
+		for j, key := range test.expectedKeys {
+			if tag, ok := value.Type().Field(j).Tag.Lookup("json"); !ok || tag != key {
+				t.Errorf("Test case %d: expected field %s to be tagged %q, got %q", i, value.Type().Field(j).Name, key, tag)
+			}
+			if value.Field(j).IsZero() {
+				t.Errorf("Test case %d: field %s was not decoded", i, value.Type().Field(j).Name)
+			}
+		}
+	}
+}
+
 func TestCreateNewSessionAccount(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	newSessionResponse, headerTokens, err := capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	newSessionResponse, headerTokens, err := capClient.CreateNewSession(context.Background())
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -62,10 +98,10 @@ func TestCreateNewSessionAccount(t *testing.T) {
 }
 
 func TestListWatchList(t *testing.T) { // TODO FIX, probably they change the json key
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
 
-	watchListResponse, err := capClient.GetWatchLists()
+	watchListResponse, err := capClient.GetWatchLists(context.Background())
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -73,10 +109,10 @@ func TestListWatchList(t *testing.T) { // TODO FIX, probably they change the jso
 }
 
 func TestGetAllAccounts(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
 
-	accounts, err := capClient.GetAllAccounts()
+	accounts, err := capClient.GetAllAccounts(context.Background())
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -84,10 +120,10 @@ func TestGetAllAccounts(t *testing.T) {
 }
 
 func TestGetMarketsDetails(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
 
-	marketsDetails, err := capClient.GetMarketsDetails([]string{"USDMXN", "EURUSD"})
+	marketsDetails, err := capClient.GetMarketsDetails(context.Background(), []string{"USDMXN", "EURUSD"})
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -98,10 +134,10 @@ func TestGetMarketsDetails(t *testing.T) {
 }
 
 func TestGetPrices(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
 
-	pricesResponse, err := capClient.GetHistoricalPrices("USDMXN", MINUTE_30, 250)
+	pricesResponse, err := capClient.GetHistoricalPrices(context.Background(), "USDMXN", MINUTE_30, 250)
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -112,10 +148,10 @@ func TestGetPrices(t *testing.T) {
 }
 
 func TestGetPositions(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
 
-	positionsResponse, err := capClient.GetPositions()
+	positionsResponse, err := capClient.GetPositions(context.Background())
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -123,10 +159,10 @@ func TestGetPositions(t *testing.T) {
 }
 
 func TestCreateWorkingOrder(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
 
-	workingOrderResponse, err := capClient.CreateWorkingOrder("BTCUSD", BUY, LIMIT, 24000.0, 10)
+	workingOrderResponse, err := capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "BTCUSD", Direction: BUY, Type: LIMIT, Level: 24000.0, Size: 10})
 	if err != nil {
 		t.Errorf("%v", err)
 
@@ -135,11 +171,11 @@ func TestCreateWorkingOrder(t *testing.T) {
 }
 
 func TestGetAllWorkingOrders(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
-	capClient.CreateWorkingOrder("USDMXN", BUY, LIMIT, 19.20, 1000)
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
+	capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19.20, Size: 1000})
 
-	workingOrdersResponse, err := capClient.GetAllWorkingOrders()
+	workingOrdersResponse, err := capClient.GetAllWorkingOrders(context.Background())
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -147,11 +183,11 @@ func TestGetAllWorkingOrders(t *testing.T) {
 }
 
 func TestGetPositionOrderConfirmation(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
 
-	workingOrderResponse, _ := capClient.CreateWorkingOrder("BTCUSD", BUY, LIMIT, 24000.0, 10)
-	positionOrderConfirmation, err := capClient.GetPositionOrderConfirmation(workingOrderResponse.DealReference)
+	workingOrderResponse, _ := capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "BTCUSD", Direction: BUY, Type: LIMIT, Level: 24000.0, Size: 10})
+	positionOrderConfirmation, err := capClient.GetPositionOrderConfirmation(context.Background(), workingOrderResponse.DealReference)
 	if err != nil {
 		t.Errorf("%v", err)
 
@@ -160,10 +196,10 @@ func TestGetPositionOrderConfirmation(t *testing.T) {
 }
 
 func TestGetPreferredAccount(t *testing.T) {
-	capClient, _ := _TestCapitalClient()
-	capClient.CreateNewSession()
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
 
-	accountResponse, err := capClient.GetPreferredAccount()
+	accountResponse, err := capClient.GetPreferredAccount(context.Background())
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -172,3 +208,106 @@ func TestGetPreferredAccount(t *testing.T) {
 	}
 	t.Logf("Prefered Account: %+v", accountResponse)
 }
+
+func TestDeleteWorkingOrder(t *testing.T) {
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
+
+	workingOrderResponse, err := capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19.0, Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = capClient.DeleteWorkingOrder(context.Background(), workingOrderResponse.DealReference)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	confirmation, _ := capClient.GetPositionOrderConfirmation(context.Background(), workingOrderResponse.DealReference)
+	if confirmation.Status != string(DELETED) {
+		t.Errorf("Working order should be deleted; Current Status: %s", confirmation.Status)
+	}
+}
+
+func TestPositionLifecycle(t *testing.T) {
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
+
+	positionResponse, err := capClient.CreatePosition(context.Background(), CreatePositionBody{
+		Epic:           "USDMXN",
+		Direction:      BUY,
+		Size:           100,
+		StopDistance:   0.5,
+		ProfitDistance: 0.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmation, err := capClient.GetPositionOrderConfirmation(context.Background(), positionResponse.DealReference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmation.DealStatus != string(ACCEPTED) || len(confirmation.AffectedDeals) != 1 {
+		t.Fatalf("Unexpected Position Confirmation: %+v", confirmation)
+	}
+	dealId := confirmation.AffectedDeals[0].ID
+
+	position, err := capClient.GetPosition(context.Background(), dealId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.Position.StopLevel == 0 || position.Position.ProfitLevel == 0 || position.Market.Epic != "USDMXN" {
+		t.Errorf("Stop and profit levels should be attached: %+v", position)
+	}
+
+	_, err = capClient.UpdatePosition(context.Background(), dealId, UpdatePositionBody{StopLevel: 1, ProfitLevel: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	position, _ = capClient.GetPosition(context.Background(), dealId)
+	if position.Position.StopLevel != 1 || position.Position.ProfitLevel != 100 {
+		t.Errorf("Stop and profit levels not updated: %+v", position.Position)
+	}
+
+	_, err = capClient.ClosePosition(context.Background(), dealId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = capClient.GetPosition(context.Background(), dealId); err == nil {
+		t.Error("Closed position should not be found")
+	}
+}
+
+func TestUpdateWorkingOrder(t *testing.T) {
+	capClient, _ := _TestCapitalClient(t)
+	capClient.CreateNewSession(context.Background())
+
+	workingOrderResponse, err := capClient.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{
+		Epic:         "USDMXN",
+		Direction:    BUY,
+		Type:         LIMIT,
+		Level:        19.0,
+		Size:         10,
+		GoodTillDate: time.Now().UTC().Add(time.Hour).Format("2006-01-02T15:04:05"),
+		StopLevel:    18.5,
+		ProfitLevel:  19.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmation, _ := capClient.GetPositionOrderConfirmation(context.Background(), workingOrderResponse.DealReference)
+
+	_, err = capClient.UpdateWorkingOrder(context.Background(), confirmation.DealID, UpdateWorkingOrderBody{Level: 18.9, StopLevel: 18.4, ProfitLevel: 19.4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workingOrdersResponse, err := capClient.GetAllWorkingOrders(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workingOrdersResponse.WorkingOrders) != 1 {
+		t.Fatalf("expected 1 working order, got %d", len(workingOrdersResponse.WorkingOrders))
+	}
+	workingOrder := workingOrdersResponse.WorkingOrders[0].WorkingOrderData
+	if workingOrder.OrderLevel != 18.9 || workingOrder.StopLevel != 18.4 || workingOrder.ProfitLevel != 19.4 || workingOrder.GoodTillDate == "" {
+		t.Errorf("Working order not updated: %+v", workingOrder)
+	}
+}
@@ -1,15 +1,176 @@
 package gominitrader
 
-import "net/http"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
 
+// AuthenticationTransport sets the session tokens on every request. When a request fails because the
+// session expired, the transport logs in again with Authenticate, once for all the requests that failed
+// with the same tokens, and retries the request if it is idempotent. Tokens are set before the first
+// request; afterwards, use SetTokens and Tokens.
 type AuthenticationTransport struct {
 	http.RoundTripper
 	X_SECURITY_TOKEN string
 	CST              string
+	Authenticate     func(ctx context.Context) (cst string, securityToken string, err error) // nil disables refreshing
+
+	mutex      sync.RWMutex
+	generation int64           // incremented on every new session
+	refreshing *sessionRefresh // login in progress, if any
+	onRefresh  []func()
+}
+
+type sessionRefresh struct {
+	done chan struct{}
+	err  error
 }
 
 func (t *AuthenticationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Set("X-SECURITY-TOKEN", t.X_SECURITY_TOKEN)
-	req.Header.Set("CST", t.CST)
-	return t.RoundTripper.RoundTrip(req)
+	if isLoginRequest(req) {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	cst, securityToken, generation := t.tokens()
+	response, err := t.RoundTripper.RoundTrip(withSessionTokens(req, cst, securityToken))
+	if err != nil || t.Authenticate == nil || !sessionExpired(response) {
+		return response, err
+	}
+	if refreshErr := t.refresh(req.Context(), generation); refreshErr != nil || !isIdempotent(req) {
+		// non idempotent requests are not retried; the caller gets the 401 and the next request the new session
+		return response, nil
+	}
+	if req.Body != nil {
+		if req.GetBody == nil {
+			return response, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return response, nil
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	response.Body.Close()
+
+	cst, securityToken, _ = t.tokens()
+	return t.RoundTripper.RoundTrip(withSessionTokens(req, cst, securityToken))
+}
+
+// SetTokens replaces the session tokens, e.g. after logging in again.
+func (t *AuthenticationTransport) SetTokens(cst string, securityToken string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.CST = cst
+	t.X_SECURITY_TOKEN = securityToken
+	t.generation++
+	t.redactTokens(cst, securityToken)
+}
+
+// Tokens returns the current CST and X-SECURITY-TOKEN.
+func (t *AuthenticationTransport) Tokens() (cst string, securityToken string) {
+	cst, securityToken, _ = t.tokens()
+	return cst, securityToken
+}
+
+func (t *AuthenticationTransport) tokens() (string, string, int64) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.CST, t.X_SECURITY_TOKEN, t.generation
+}
+
+// refresh logs in again unless the session of generation has already been replaced. Concurrent callers
+// wait for the login in progress and share its result.
+func (t *AuthenticationTransport) refresh(ctx context.Context, generation int64) error {
+	t.mutex.Lock()
+	if t.generation != generation {
+		t.mutex.Unlock()
+		return nil
+	}
+	if flight := t.refreshing; flight != nil {
+		t.mutex.Unlock()
+		select {
+		case <-flight.done:
+			return flight.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	flight := &sessionRefresh{done: make(chan struct{})}
+	t.refreshing = flight
+	t.mutex.Unlock()
+
+	cst, securityToken, err := t.Authenticate(ctx)
+
+	t.mutex.Lock()
+	if err == nil {
+		t.CST = cst
+		t.X_SECURITY_TOKEN = securityToken
+		t.generation++
+		t.redactTokens(cst, securityToken)
+	}
+	t.refreshing = nil
+	flight.err = err
+	onRefresh := t.onRefresh
+	t.mutex.Unlock()
+	close(flight.done)
+	if err == nil {
+		for _, callback := range onRefresh {
+			callback()
+		}
+	}
+	return err
+}
+
+// redactTokens keeps the session tokens out of the log output.
+func (t *AuthenticationTransport) redactTokens(cst string, securityToken string) {
+	RedactSecret(fmt.Sprintf("%p CST", t), cst)
+	RedactSecret(fmt.Sprintf("%p X-SECURITY-TOKEN", t), securityToken)
+}
+
+// OnRefresh registers a callback called every time the transport renews an expired session.
+func (t *AuthenticationTransport) OnRefresh(callback func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.onRefresh = append(t.onRefresh, callback)
+}
+
+// withSessionTokens returns a copy of req carrying the session tokens; a RoundTripper must not modify
+// the request it is given.
+func withSessionTokens(req *http.Request, cst string, securityToken string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-SECURITY-TOKEN", securityToken)
+	req.Header.Set("CST", cst)
+	return req
+}
+
+// sessionExpired reports whether response is a 401 for an invalid or missing session token. The body is
+// read and put back so the caller can still read it.
+func sessionExpired(response *http.Response) bool {
+	if response.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	apiError := newCapitalAPIError(response)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewBufferString(apiError.Body))
+	return errors.Is(apiError, ErrSessionExpired)
+}
+
+// isLoginRequest reports whether req is part of logging in, which needs no session tokens.
+func isLoginRequest(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/api/v1/session/encryptionKey") || (req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/api/v1/session"))
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,81 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func _TestEmulatorSessions(emulator *CapitalEmulator) int {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	return len(emulator.sessions)
+}
+
+func TestAuthenticationTransportRefresh(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.Scheduler = nil
+	capClient.CreateNewSession(context.Background())
+	emulator.ExpireSessions()
+
+	// every caller fails with the expired session, but only one of them logs in again
+	waitGroup := sync.WaitGroup{}
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			_, err := capClient.GetPositions(context.Background())
+			errs <- err
+		}()
+	}
+	waitGroup.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected the request to be retried with a new session, got %v", err)
+		}
+	}
+	if sessions := _TestEmulatorSessions(emulator); sessions != 1 {
+		t.Errorf("expected a single new session, got %d", sessions)
+	}
+}
+
+func TestAuthenticationTransportNonIdempotent(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.Scheduler = nil
+	capClient.CreateNewSession(context.Background())
+	emulator.ExpireSessions()
+
+	// orders are not sent twice; the session is renewed for the next request
+	_, err := capClient.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 1})
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("expected %v, got %v", ErrSessionExpired, err)
+	}
+	if _, err := capClient.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 1}); err != nil {
+		t.Errorf("expected the renewed session to be used, got %v", err)
+	}
+	if sessions := _TestEmulatorSessions(emulator); sessions != 1 {
+		t.Errorf("expected a single new session, got %d", sessions)
+	}
+}
+
+func TestCapitalClientPing(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.Scheduler = nil
+	if err := capClient.Ping(context.Background()); err == nil {
+		t.Errorf("expected an error without a session")
+	}
+	capClient.CreateNewSession(context.Background())
+	if err := capClient.Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	emulator.ExpireSessions()
+	if err := capClient.Ping(context.Background()); err != nil {
+		t.Errorf("expected the ping to renew the session, got %v", err)
+	}
+}
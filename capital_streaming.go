@@ -0,0 +1,393 @@
+package gominitrader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Capital.com streaming destinations
+const (
+	STREAM_QUOTE_SUBSCRIBE = "marketData.subscribe"
+	STREAM_OHLC_SUBSCRIBE  = "OHLCMarketData.subscribe"
+	STREAM_PING            = "ping"
+	STREAM_QUOTE           = "quote"
+	STREAM_OHLC            = "ohlc.event"
+)
+
+// StreamingBroker is implemented by brokers able to push prices through a PriceStream.
+// A MinitraderPool uses it when available and falls back to REST polling otherwise.
+type StreamingBroker interface {
+	NewPriceStream() (*PriceStream, error)
+}
+
+type StreamCredentials func() (cst string, securityToken string, err error)
+
+type StreamQuote struct {
+	Epic      string
+	Bid       float64
+	Ask       float64
+	Timestamp int64 // milliseconds
+}
+
+type StreamOHLC struct {
+	Epic       string
+	Resolution Timeframe
+	PriceType  string // "bid" or "ask"
+	Timestamp  int64  // milliseconds
+	Open       float64
+	High       float64
+	Low        float64
+	Close      float64
+}
+
+// PriceStream keeps a WebSocket connection to Capital.com streaming API, subscribed to quotes and
+// OHLC bars of a set of epics. It reconnects and subscribes again whenever the connection drops.
+type PriceStream struct {
+	Host           string
+	Credentials    StreamCredentials
+	Dialer         *websocket.Dialer
+	PingInterval   time.Duration
+	ReconnectDelay time.Duration
+
+	OnQuote func(StreamQuote)
+	OnOHLC  func(StreamOHLC)
+
+	mutex         sync.Mutex
+	connection    *websocket.Conn
+	connected     bool
+	correlationID int
+	epics         []string
+	resolutions   []Timeframe
+}
+
+type streamRequest struct {
+	Destination   string      `json:"destination"`
+	CorrelationID string      `json:"correlationId"`
+	CST           string      `json:"cst"`
+	SecurityToken string      `json:"securityToken"`
+	Payload       interface{} `json:"payload,omitempty"`
+}
+
+type streamResponse struct {
+	Status        string          `json:"status"`
+	Destination   string          `json:"destination"`
+	CorrelationID string          `json:"correlationId"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+type streamQuotePayload struct {
+	Epic      string  `json:"epic"`
+	Bid       float64 `json:"bid"`
+	Offer     float64 `json:"ofr"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+type streamOHLCPayload struct {
+	Epic       string    `json:"epic"`
+	Resolution Timeframe `json:"resolution"`
+	PriceType  string    `json:"priceType"`
+	Timestamp  int64     `json:"t"`
+	Open       float64   `json:"o"`
+	High       float64   `json:"h"`
+	Low        float64   `json:"l"`
+	Close      float64   `json:"c"`
+}
+
+func NewPriceStream(host string, credentials StreamCredentials) *PriceStream {
+	return &PriceStream{
+		Host:           host,
+		Credentials:    credentials,
+		Dialer:         websocket.DefaultDialer,
+		PingInterval:   time.Minute * 5,
+		ReconnectDelay: time.Second * 5,
+	}
+}
+
+// Subscribe adds epics and resolutions to the stream subscriptions. They are sent right away when
+// connected, and again after every reconnection.
+func (stream *PriceStream) Subscribe(epics []string, resolutions []Timeframe) error {
+	stream.mutex.Lock()
+	stream.epics = appendUnique(stream.epics, epics...)
+	for _, resolution := range resolutions {
+		if !containsTimeframe(stream.resolutions, resolution) {
+			stream.resolutions = append(stream.resolutions, resolution)
+		}
+	}
+	connected := stream.connected
+	stream.mutex.Unlock()
+
+	if !connected {
+		return nil
+	}
+	return stream.subscribe()
+}
+
+// Connected reports whether the stream currently has a live, subscribed connection.
+func (stream *PriceStream) Connected() bool {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.connected
+}
+
+// Run connects, subscribes and dispatches prices until stop is closed, reconnecting after
+// `ReconnectDelay` whenever the connection fails.
+func (stream *PriceStream) Run(stop <-chan struct{}) {
+	for {
+		err := stream.runOnce(stop)
+		if err == nil {
+			return
+		}
+		Logger().Warn("price stream disconnected; reconnecting", append(errorAttrs(err), "delay", stream.ReconnectDelay)...)
+		select {
+		case <-stop:
+			return
+		case <-time.After(stream.ReconnectDelay):
+		}
+	}
+}
+
+func (stream *PriceStream) runOnce(stop <-chan struct{}) error {
+	if stream.Host == "" {
+		return errors.New("Streaming host is unknown; Create a session first")
+	}
+	connection, _, err := stream.Dialer.Dial(strings.TrimSuffix(stream.Host, "/")+"/connect", nil)
+	if err != nil {
+		return err
+	}
+	stream.mutex.Lock()
+	stream.connection = connection
+	stream.mutex.Unlock()
+	defer stream.disconnect()
+
+	if err := stream.subscribe(); err != nil {
+		return err
+	}
+	stream.mutex.Lock()
+	stream.connected = true
+	stream.mutex.Unlock()
+
+	// close the connection on stop so the read loop below returns
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(stream.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				connection.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := stream.send(STREAM_PING, nil); err != nil {
+					Logger().Warn("unable to ping the price stream", errorAttrs(err)...)
+				}
+			}
+		}
+	}()
+
+	for {
+		var response streamResponse
+		if err := connection.ReadJSON(&response); err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+				return err
+			}
+		}
+		stream.dispatch(response)
+	}
+}
+
+func (stream *PriceStream) subscribe() error {
+	stream.mutex.Lock()
+	epics := append([]string{}, stream.epics...)
+	resolutions := append([]Timeframe{}, stream.resolutions...)
+	stream.mutex.Unlock()
+
+	if len(epics) == 0 {
+		return nil
+	}
+	if err := stream.send(STREAM_QUOTE_SUBSCRIBE, map[string]interface{}{"epics": epics}); err != nil {
+		return err
+	}
+	if len(resolutions) == 0 {
+		return nil
+	}
+	return stream.send(STREAM_OHLC_SUBSCRIBE, map[string]interface{}{
+		"epics":       epics,
+		"resolutions": resolutions,
+		"type":        "classic",
+	})
+}
+
+func (stream *PriceStream) send(destination string, payload interface{}) error {
+	cst, securityToken, err := stream.Credentials()
+	if err != nil {
+		return err
+	}
+
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if stream.connection == nil {
+		return errors.New("Price stream is not connected")
+	}
+	stream.correlationID++
+	return stream.connection.WriteJSON(streamRequest{
+		Destination:   destination,
+		CorrelationID: strconv.Itoa(stream.correlationID),
+		CST:           cst,
+		SecurityToken: securityToken,
+		Payload:       payload,
+	})
+}
+
+func (stream *PriceStream) disconnect() {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if stream.connection != nil {
+		stream.connection.Close()
+	}
+	stream.connection = nil
+	stream.connected = false
+}
+
+func (stream *PriceStream) dispatch(response streamResponse) {
+	switch response.Destination {
+	case STREAM_QUOTE:
+		var payload streamQuotePayload
+		if json.Unmarshal(response.Payload, &payload) != nil || stream.OnQuote == nil {
+			return
+		}
+		stream.OnQuote(StreamQuote{
+			Epic:      payload.Epic,
+			Bid:       payload.Bid,
+			Ask:       payload.Offer,
+			Timestamp: payload.Timestamp,
+		})
+	case STREAM_OHLC:
+		var payload streamOHLCPayload
+		if json.Unmarshal(response.Payload, &payload) != nil || stream.OnOHLC == nil {
+			return
+		}
+		stream.OnOHLC(StreamOHLC{
+			Epic:       payload.Epic,
+			Resolution: payload.Resolution,
+			PriceType:  payload.PriceType,
+			Timestamp:  payload.Timestamp,
+			Open:       payload.Open,
+			High:       payload.High,
+			Low:        payload.Low,
+			Close:      payload.Close,
+		})
+	}
+}
+
+// NewPriceStream returns a PriceStream for the session streaming host, authenticated with the
+// current session tokens.
+func (capClient *CapitalClientAPI) NewPriceStream() (*PriceStream, error) {
+	capClient.mutex.Lock()
+	streamingHost := capClient.streamingHost
+	capClient.mutex.Unlock()
+	if streamingHost == "" {
+		return nil, &CapitalClientUnathenticated{}
+	}
+	return NewPriceStream(streamingHost, func() (string, string, error) {
+		if !capClient.hasSession() {
+			return "", "", &CapitalClientUnathenticated{}
+		}
+		cst, securityToken := capClient.transport.Tokens()
+		return cst, securityToken, nil
+	}), nil
+}
+
+// applyStreamOHLC updates the bid or ask side of the candle at event.Timestamp, appending a new candle
+// when the event opens a new bar. The oldest candle is dropped so the window keeps its size.
+func (candles Candles) applyStreamOHLC(event StreamOHLC) (Candles, error) {
+	if len(candles) == 0 {
+		return candles, errors.New("Candles must be seeded before applying streamed prices")
+	}
+	timestamp := event.Timestamp / 1000
+	last := &candles[len(candles)-1]
+	switch {
+	case timestamp == last.Timestamp:
+	case timestamp > last.Timestamp:
+		// new bar; seed both sides from the previous close until the other side arrives
+		spread := last.Close.Ask - last.Close.Bid
+		price := BidAskPrice{Bid: event.Open, Ask: event.Open + spread}
+		if event.PriceType == "ask" {
+			price = BidAskPrice{Bid: event.Open - spread, Ask: event.Open}
+		}
+		candles = append(candles[1:], Candle{Timestamp: timestamp, Open: price, High: price, Low: price, Close: price})
+		last = &candles[len(candles)-1]
+	default:
+		return candles, fmt.Errorf("Streamed bar %d is older than the last candle %d", timestamp, last.Timestamp)
+	}
+
+	if event.PriceType == "ask" {
+		last.Open.Ask, last.High.Ask, last.Low.Ask, last.Close.Ask = event.Open, event.High, event.Low, event.Close
+	} else {
+		last.Open.Bid, last.High.Bid, last.Low.Bid, last.Close.Bid = event.Open, event.High, event.Low, event.Close
+	}
+	return candles, nil
+}
+
+// applyStreamQuote moves the close of the last candle to the quote, widening its high and low. Quotes
+// outside the last bar of the timeframe are ignored; the next OHLC event opens the new bar.
+func (candles Candles) applyStreamQuote(quote StreamQuote, timeframe Timeframe) {
+	if len(candles) == 0 {
+		return
+	}
+	last := &candles[len(candles)-1]
+	barEnd := last.Timestamp + int64(TimeframeMinuteMap[timeframe])*60
+	if quote.Timestamp/1000 < last.Timestamp || quote.Timestamp/1000 >= barEnd {
+		return
+	}
+	last.Close = BidAskPrice{Bid: quote.Bid, Ask: quote.Ask}
+	if quote.Bid > last.High.Bid {
+		last.High.Bid = quote.Bid
+	}
+	if quote.Ask > last.High.Ask {
+		last.High.Ask = quote.Ask
+	}
+	if quote.Bid < last.Low.Bid {
+		last.Low.Bid = quote.Bid
+	}
+	if quote.Ask < last.Low.Ask {
+		last.Low.Ask = quote.Ask
+	}
+}
+
+func appendUnique(values []string, newValues ...string) []string {
+	for _, newValue := range newValues {
+		found := false
+		for _, value := range values {
+			if value == newValue {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, newValue)
+		}
+	}
+	return values
+}
+
+func containsTimeframe(timeframes []Timeframe, timeframe Timeframe) bool {
+	for _, t := range timeframes {
+		if t == timeframe {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package gominitrader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func _TestWaitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestPriceStreamQuotesAndReconnection(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+
+	stream, err := capClient.NewPriceStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.ReconnectDelay = time.Millisecond * 10
+	quotes := make(chan StreamQuote, 10)
+	stream.OnQuote = func(quote StreamQuote) { quotes <- quote }
+	stream.Subscribe([]string{"USDMXN"}, []Timeframe{MINUTE})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go stream.Run(stop)
+
+	for round := 0; round < 2; round++ {
+		_TestWaitFor(t, func() bool { return emulator.StreamsConnected() == 1 })
+		emulator.PushQuote("USDMXN", 19.1+float64(round), 19.2+float64(round), time.Now())
+		select {
+		case quote := <-quotes:
+			if quote.Epic != "USDMXN" || quote.Bid != 19.1+float64(round) || quote.Ask != 19.2+float64(round) {
+				t.Errorf("Round %d: unexpected quote %+v", round, quote)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Round %d: quote not received", round)
+		}
+		// drop the connection; the stream should reconnect and subscribe again
+		emulator.DisconnectStreams()
+	}
+}
+
+func TestApplyStreamOHLC(t *testing.T) {
+	candles := _TestCandles(1, 2, 3)
+	for i := range candles {
+		candles[i].Close.Ask = candles[i].Close.Bid + 0.1
+	}
+
+	// update of the last bar bid side
+	candles, err := candles.applyStreamOHLC(StreamOHLC{PriceType: "bid", Timestamp: 120 * 1000, Open: 3, High: 4, Low: 2.5, Close: 3.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	candles, _ = candles.applyStreamOHLC(StreamOHLC{PriceType: "ask", Timestamp: 120 * 1000, Open: 3.1, High: 4.1, Low: 2.6, Close: 3.6})
+	if len(candles) != 3 || candles[2].High.Bid != 4 || candles[2].Close.Bid != 3.5 || candles[2].Close.Ask != 3.6 {
+		t.Errorf("last bar not updated: %+v", candles[2])
+	}
+
+	// a new bar drops the oldest candle and keeps the spread on the missing side
+	candles, err = candles.applyStreamOHLC(StreamOHLC{PriceType: "bid", Timestamp: 180 * 1000, Open: 5, High: 5, Low: 5, Close: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 3 || candles[0].Timestamp != 60 || candles[2].Timestamp != 180 {
+		t.Errorf("unexpected window after new bar: %+v", candles)
+	}
+	if candles[2].Open.Ask-candles[2].Open.Bid < 0.09 {
+		t.Errorf("ask side should be seeded from the previous spread: %+v", candles[2])
+	}
+
+	// quotes move the close of the current bar only
+	candles.applyStreamQuote(StreamQuote{Bid: 6, Ask: 6.1, Timestamp: 200 * 1000}, MINUTE)
+	if candles[2].Close.Bid != 6 || candles[2].High.Bid != 6 {
+		t.Errorf("quote not applied: %+v", candles[2])
+	}
+	candles.applyStreamQuote(StreamQuote{Bid: 7, Ask: 7.1, Timestamp: 240 * 1000}, MINUTE)
+	if candles[2].Close.Bid != 6 {
+		t.Errorf("quote of the next bar should be ignored: %+v", candles[2])
+	}
+
+	if _, err := candles.applyStreamOHLC(StreamOHLC{PriceType: "bid", Timestamp: 0}); err == nil {
+		t.Error("older bars should be rejected")
+	}
+}
+
+func TestMinitraderPoolStreamsCandles(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	pool, _ := NewMinitraderPool(capClient, minitrader)
+
+	go pool.StreamMinitradersData(context.Background(), capClient, time.Millisecond*10)
+	go pool.UpdateMinitradersData(context.Background(), time.Millisecond*50)
+
+	// candles are seeded through REST first
+	candles := <-minitrader.candlesChannel
+	last := candles[len(candles)-1]
+	_TestWaitFor(t, func() bool { return pool.streaming() })
+
+	emulator.PushOHLC(StreamOHLC{
+		Epic:       "USDMXN",
+		Resolution: MINUTE_15,
+		PriceType:  "bid",
+		Timestamp:  (last.Timestamp + 15*60) * 1000,
+		Open:       20, High: 20.5, Low: 19.9, Close: 20.2,
+	})
+	timeout := time.After(time.Second * 5)
+	for {
+		select {
+		case candles = <-minitrader.candlesChannel:
+		case <-timeout:
+			t.Fatal("streamed candle not received")
+		}
+		if candles[len(candles)-1].Timestamp == last.Timestamp+15*60 {
+			break
+		}
+	}
+	if len(candles) != 200 || candles[len(candles)-1].Close.Bid != 20.2 {
+		t.Errorf("unexpected streamed candles: %d candles, last %+v", len(candles), candles[len(candles)-1])
+	}
+}
+
+func TestMinitraderPoolFallsBackToPolling(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	emulator.SetStreamingEnabled(false)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	pool, _ := NewMinitraderPool(capClient, minitrader)
+
+	go pool.StreamMinitradersData(context.Background(), capClient, time.Millisecond*10)
+	go pool.UpdateMinitradersData(context.Background(), time.Millisecond*10)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case candles := <-minitrader.candlesChannel:
+			if len(candles) != 200 {
+				t.Errorf("expected 200 polled candles, got %d", len(candles))
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("polled candles not received")
+		}
+	}
+	if pool.streaming() {
+		t.Error("pool should not be streaming")
+	}
+}
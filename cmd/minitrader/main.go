@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	gominitrader "github.com/menesesghz/go-minitrader"
+)
+
+const usage = `usage: minitrader <command> [flags]
+
+commands:
+  import    import exported history into a candle store to seed backtests offline
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = importCommand(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// importCommand reads TradingView, MetaTrader or Dukascopy files and appends their candles to a store.
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "format of the files: tradingview, mt-csv, mt-hst or dukascopy")
+	epic := flags.String("epic", "", "epic the candles are stored under, e.g. EURUSD")
+	timeframe := flags.String("timeframe", "", "timeframe candles are resampled or ticks aggregated to, e.g. MINUTE_15 or HOUR_4; defaults to the timeframe of the exported bars, or MINUTE for ticks")
+	storePath := flags.String("store", "", "key-value candle store file")
+	csvDirectory := flags.String("csv-dir", "", "CSV candle store directory, used instead of -store")
+	spread := flags.Float64("spread", 0, "spread added to single-sided prices to derive the ask")
+	point := flags.Float64("point", 0.00001, "value of a price point; MetaTrader spreads and Dukascopy prices are in points")
+	location := flags.String("location", "UTC", "timezone of exported times without one")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: minitrader import -format <format> -epic <epic> (-store <file> | -csv-dir <directory>) [flags] <files...>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *epic == "" || flags.NArg() == 0 || (*storePath == "") == (*csvDirectory == "") {
+		flags.Usage()
+		os.Exit(2)
+	}
+	timeLocation, err := time.LoadLocation(*location)
+	if err != nil {
+		return err
+	}
+	options := gominitrader.ImportOptions{Spread: *spread, Point: *point, Location: timeLocation}
+
+	candles, storedTimeframe, err := importFiles(*format, flags.Args(), gominitrader.Timeframe(*timeframe), options)
+	if err != nil {
+		return err
+	}
+
+	var store gominitrader.CandleStore
+	if *storePath != "" {
+		store, err = gominitrader.OpenKVCandleStore(*storePath)
+	} else {
+		store, err = gominitrader.NewCSVCandleStore(*csvDirectory)
+	}
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if err := store.Append(*epic, storedTimeframe, candles); err != nil {
+		return err
+	}
+	if len(candles) != 0 {
+		log.Printf("imported %d %s %s candles from %s to %s", len(candles), *epic, storedTimeframe,
+			time.Unix(candles[0].Timestamp, 0).UTC().Format(time.RFC3339),
+			time.Unix(candles[len(candles)-1].Timestamp, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// importFiles returns the candles of the files in timeframe, and timeframe; an empty timeframe is MINUTE
+// for ticks and the timeframe of the bars otherwise.
+func importFiles(format string, paths []string, timeframe gominitrader.Timeframe, options gominitrader.ImportOptions) (gominitrader.Candles, gominitrader.Timeframe, error) {
+	if format == "dukascopy" {
+		if timeframe == "" {
+			timeframe = gominitrader.MINUTE
+		}
+		ticks := []gominitrader.Tick{}
+		for _, path := range paths {
+			hour, err := gominitrader.ParseDukascopyPath(path)
+			if err != nil {
+				return nil, "", err
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, "", err
+			}
+			fileTicks, err := gominitrader.ImportDukascopyBi5(file, hour, options)
+			file.Close()
+			if err != nil {
+				return nil, "", fmt.Errorf("%s: %s", path, err)
+			}
+			ticks = append(ticks, fileTicks...)
+		}
+		sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+		candles, err := gominitrader.TicksToCandles(ticks, timeframe)
+		return candles, timeframe, err
+	}
+
+	importers := map[string]func(io.Reader, gominitrader.ImportOptions) (gominitrader.Candles, error){
+		"tradingview": gominitrader.ImportTradingViewCSV,
+		"mt-csv":      gominitrader.ImportMetaTraderCSV,
+		"mt-hst":      gominitrader.ImportMetaTraderHST,
+	}
+	importer, ok := importers[format]
+	if !ok {
+		return nil, "", errors.New(fmt.Sprintf("Unknown Import Format: %s", format))
+	}
+
+	candles := gominitrader.Candles{}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, "", err
+		}
+		fileCandles, err := importer(file, options)
+		file.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %s", path, err)
+		}
+		candles = append(candles, fileCandles...)
+	}
+	sort.SliceStable(candles, func(i, j int) bool { return candles[i].Timestamp < candles[j].Timestamp })
+
+	// exports are resampled to the stored timeframe; it must be a multiple of theirs
+	source, err := barsTimeframe(candles)
+	if err != nil {
+		return nil, "", err
+	}
+	if timeframe == "" {
+		timeframe = source
+	}
+	duration, err := timeframe.Duration()
+	if err != nil {
+		return nil, "", err
+	}
+	sourceDuration, _ := source.Duration()
+	if duration%sourceDuration != 0 {
+		return nil, "", fmt.Errorf("Timeframe %s Is Not A Multiple Of The Exported %s Bars", timeframe, source)
+	}
+	resampled, err := candles.ResampleIn(timeframe, options.Location)
+	return resampled, timeframe, err
+}
+
+// barsTimeframe infers the timeframe of sorted bars from the smallest spacing between two of them; gaps such
+// as weekends only make spacings larger.
+func barsTimeframe(candles gominitrader.Candles) (gominitrader.Timeframe, error) {
+	spacing := int64(0)
+	for i := 1; i < len(candles); i++ {
+		if step := candles[i].Timestamp - candles[i-1].Timestamp; step > 0 && (spacing == 0 || step < spacing) {
+			spacing = step
+		}
+	}
+	if spacing == 0 {
+		return "", errors.New("Unable To Infer The Timeframe Of The Exported Bars; At Least Two Are Needed")
+	}
+	return gominitrader.NewTimeframe(time.Duration(spacing) * time.Second)
+}
@@ -0,0 +1,215 @@
+package gominitrader
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ControlServer is an HTTP API to inspect and control a running MinitraderPool. Every request must carry
+// the Token as `Authorization: Bearer <token>`; with an empty Token every request is refused. It is an
+// http.Handler, so it can be served on its own, mounted under a prefix with http.StripPrefix, or tested
+// with httptest.
+//
+//	GET    /minitraders                  snapshot of every minitrader
+//	POST   /minitraders                  add a minitrader, described by a MinitraderRequest
+//	GET    /minitraders/{id}             snapshot of a minitrader
+//	DELETE /minitraders/{id}             remove a minitrader; its trade is left open
+//	GET    /minitraders/{id}/candles     last candles evaluated, the last ?count of them if given
+//	GET    /minitraders/{id}/signal      last signal generated
+//	POST   /minitraders/{id}/pause       stop entering new trades
+//	POST   /minitraders/{id}/resume      enter trades again, recovering from error statuses
+//	POST   /minitraders/{id}/flatten     pause, and delete the working order or close the position
+//	POST   /kill                         pause everything, close every trade and stop the pool
+type ControlServer struct {
+	Pool       *MinitraderPool
+	Token      string
+	Strategies map[string]Strategy // strategies minitraders can be added with, by name
+}
+
+// MinitraderRequest describes a minitrader to add to the pool.
+type MinitraderRequest struct {
+	Epic                 string
+	Timeframe            Timeframe
+	Strategy             string // name of one of the Strategies of the server
+	InvestmentPercentage float64
+	StopLossPercentage   float64
+	ProfitPercentage     float64
+	EntryType            EntryType  // WORKING_ORDER if empty
+	Evaluation           Evaluation // INTRABAR if empty
+}
+
+// SignalResponse is the last signal a minitrader generated.
+type SignalResponse struct {
+	ID     string
+	Signal Signal
+	Price  float64
+	Time   time.Time
+}
+
+type controlErrorResponse struct {
+	Error string
+}
+
+func NewControlServer(pool *MinitraderPool, token string) *ControlServer {
+	return &ControlServer{
+		Pool:  pool,
+		Token: token,
+		Strategies: map[string]Strategy{
+			"GPTStrategy":          GPTStrategy,
+			"GPTShortTermStrategy": GPTShortTermStrategy,
+		},
+	}
+}
+
+func (server *ControlServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !server.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeControlError(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "kill":
+		server.handleKill(w, r)
+	case len(path) == 1 && path[0] == "minitraders":
+		server.handleMinitraders(w, r)
+	case len(path) >= 2 && path[0] == "minitraders":
+		minitrader, err := server.Pool.GetMinitrader(path[1])
+		if err != nil {
+			writeControlError(w, http.StatusNotFound, err)
+			return
+		}
+		server.handleMinitrader(w, r, minitrader, path[2:])
+	default:
+		writeControlError(w, http.StatusNotFound, errors.New(fmt.Sprintf("Endpoint %s Not Found", r.URL.Path)))
+	}
+}
+
+func (server *ControlServer) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return server.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(server.Token)) == 1
+}
+
+func (server *ControlServer) handleMinitraders(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		snapshots := []MinitraderSnapshot{}
+		for _, minitrader := range server.Pool.ListMinitraders() {
+			snapshots = append(snapshots, minitrader.Snapshot())
+		}
+		writeControlJSON(w, http.StatusOK, snapshots)
+	case http.MethodPost:
+		request := MinitraderRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		minitrader, err := server.newMinitrader(request)
+		if err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := server.Pool.AddMinitrader(minitrader); err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeControlJSON(w, http.StatusCreated, minitrader.Snapshot())
+	default:
+		writeControlError(w, http.StatusMethodNotAllowed, errors.New(fmt.Sprintf("Method %s Not Allowed", r.Method)))
+	}
+}
+
+func (server *ControlServer) handleMinitrader(w http.ResponseWriter, r *http.Request, minitrader *Minitrader, path []string) {
+	action := strings.Join(path, "/")
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeControlJSON(w, http.StatusOK, minitrader.Snapshot())
+	case action == "" && r.Method == http.MethodDelete:
+		removed, err := server.Pool.RemoveMinitrader(minitrader.ID())
+		if err != nil {
+			writeControlError(w, http.StatusNotFound, err)
+			return
+		}
+		writeControlJSON(w, http.StatusOK, removed.Snapshot())
+	case action == "candles" && r.Method == http.MethodGet:
+		count := 0
+		if value := r.URL.Query().Get("count"); value != "" {
+			var err error
+			if count, err = strconv.Atoi(value); err != nil || count < 0 {
+				writeControlError(w, http.StatusBadRequest, errors.New(fmt.Sprintf("Invalid Count %s", value)))
+				return
+			}
+		}
+		writeControlJSON(w, http.StatusOK, minitrader.LastCandles(count))
+	case action == "signal" && r.Method == http.MethodGet:
+		snapshot := minitrader.Snapshot()
+		writeControlJSON(w, http.StatusOK, SignalResponse{ID: snapshot.ID, Signal: snapshot.Signal, Price: snapshot.SignalPrice, Time: snapshot.SignalTime})
+	case action == "pause" && r.Method == http.MethodPost:
+		minitrader.Pause()
+		writeControlJSON(w, http.StatusOK, minitrader.Snapshot())
+	case action == "resume" && r.Method == http.MethodPost:
+		minitrader.Resume()
+		writeControlJSON(w, http.StatusOK, minitrader.Snapshot())
+	case action == "flatten" && r.Method == http.MethodPost:
+		if err := server.Pool.FlattenMinitrader(r.Context(), minitrader.ID()); err != nil {
+			writeControlError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeControlJSON(w, http.StatusOK, minitrader.Snapshot())
+	default:
+		writeControlError(w, http.StatusNotFound, errors.New(fmt.Sprintf("Endpoint %s %s Not Found", r.Method, r.URL.Path)))
+	}
+}
+
+func (server *ControlServer) handleKill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeControlError(w, http.StatusMethodNotAllowed, errors.New(fmt.Sprintf("Method %s Not Allowed", r.Method)))
+		return
+	}
+	if err := server.Pool.Kill(r.Context()); err != nil {
+		writeControlError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (server *ControlServer) newMinitrader(request MinitraderRequest) (*Minitrader, error) {
+	strategy, ok := server.Strategies[request.Strategy]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown Strategy %s", request.Strategy))
+	}
+	if request.Epic == "" {
+		return nil, errors.New("Epic Is Required")
+	}
+	if _, err := request.Timeframe.Duration(); err != nil {
+		return nil, err
+	}
+	if request.InvestmentPercentage <= 0 {
+		return nil, errors.New(fmt.Sprintf("Invalid InvestmentPercentage %f", request.InvestmentPercentage))
+	}
+	minitrader := NewMinitrader(request.Epic, request.InvestmentPercentage, request.StopLossPercentage, request.ProfitPercentage, request.Timeframe, strategy)
+	if request.EntryType != "" {
+		minitrader.EntryType = request.EntryType
+	}
+	if request.Evaluation != "" {
+		minitrader.Evaluation = request.Evaluation
+	}
+	return minitrader, nil
+}
+
+func writeControlJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeControlError(w http.ResponseWriter, statusCode int, err error) {
+	writeControlJSON(w, statusCode, controlErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,157 @@
+package gominitrader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func _TestControlRequest(t *testing.T, server *httptest.Server, token string, method string, path string, body interface{}, response interface{}) int {
+	payload, _ := json.Marshal(body)
+	request, _ := http.NewRequest(method, server.URL+path, bytes.NewBuffer(payload))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	httpResponse, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResponse.Body.Close()
+	if response != nil {
+		json.NewDecoder(httpResponse.Body).Decode(response)
+	}
+	return httpResponse.StatusCode
+}
+
+func TestControlServerAuth(t *testing.T) {
+	pool, _ := NewMinitraderPool(&_TestBroker{}, NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy))
+	tests := []struct {
+		serverToken    string
+		token          string
+		expectedStatus int
+	}{
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "wrong", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusOK},
+		{"", "", http.StatusUnauthorized}, // never open without a token
+	}
+	for i, test := range tests {
+		server := httptest.NewServer(NewControlServer(pool, test.serverToken))
+		if status := _TestControlRequest(t, server, test.token, http.MethodGet, "/minitraders", nil, nil); status != test.expectedStatus {
+			t.Errorf("Test case %d: expected status %d, got %d", i, test.expectedStatus, status)
+		}
+		server.Close()
+	}
+}
+
+func TestControlServerMinitraders(t *testing.T) {
+	paper := NewPaperBroker(nil, 10000, "USD")
+	paper.UpdatePrice("USDMXN", 19.5, 19.5)
+	paper.UpdatePrice("EURUSD", 1.1, 1.1)
+	paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 1})
+	paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "EURUSD", Direction: BUY, Size: 1})
+	pool, _ := NewMinitraderPool(paper, NewMinitrader("USDMXN", 60, 5, 0.5, MINUTE_15, GPTStrategy), NewMinitrader("USDMXN", 40, 5, 0.5, MINUTE_15, GPTStrategy))
+	server := httptest.NewServer(NewControlServer(pool, "secret"))
+	defer server.Close()
+
+	tests := []struct {
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+		expectedIDs    []string
+	}{
+		{http.MethodGet, "/minitraders", nil, http.StatusOK, []string{"USDMXN-MINUTE_15", "USDMXN-MINUTE_15-2"}},
+		{http.MethodPost, "/minitraders", MinitraderRequest{Epic: "EURUSD", Timeframe: HOUR, Strategy: "GPTStrategy", InvestmentPercentage: 40}, http.StatusBadRequest, nil},
+		{http.MethodDelete, "/minitraders/USDMXN-MINUTE_15-2", nil, http.StatusOK, nil},
+		{http.MethodPost, "/minitraders", MinitraderRequest{Epic: "EURUSD", Timeframe: HOUR, Strategy: "Unknown", InvestmentPercentage: 40}, http.StatusBadRequest, nil},
+		{http.MethodPost, "/minitraders", MinitraderRequest{Epic: "EURUSD", Timeframe: HOUR, Strategy: "GPTStrategy", InvestmentPercentage: 40}, http.StatusCreated, nil},
+		{http.MethodGet, "/minitraders", nil, http.StatusOK, []string{"USDMXN-MINUTE_15", "EURUSD-HOUR"}},
+		{http.MethodGet, "/minitraders/USDMXN-MINUTE_15-2", nil, http.StatusNotFound, nil},
+		{http.MethodGet, "/minitraders/EURUSD-HOUR/candles?count=x", nil, http.StatusBadRequest, nil},
+		{http.MethodGet, "/minitraders/EURUSD-HOUR/candles?count=10", nil, http.StatusOK, nil},
+		{http.MethodGet, "/minitraders/EURUSD-HOUR/signal", nil, http.StatusOK, nil},
+		{http.MethodPost, "/minitraders/EURUSD-HOUR/pause", nil, http.StatusOK, nil},
+		{http.MethodGet, "/minitraders/EURUSD-HOUR/pause", nil, http.StatusNotFound, nil},
+		{http.MethodGet, "/kill", nil, http.StatusMethodNotAllowed, nil},
+	}
+	for i, test := range tests {
+		snapshots := []MinitraderSnapshot{}
+		status := _TestControlRequest(t, server, "secret", test.method, test.path, test.body, &snapshots)
+		if status != test.expectedStatus {
+			t.Errorf("Test case %d: expected status %d for %s %s, got %d", i, test.expectedStatus, test.method, test.path, status)
+			continue
+		}
+		if test.expectedIDs == nil {
+			continue
+		}
+		if len(snapshots) != len(test.expectedIDs) {
+			t.Errorf("Test case %d: expected %v, got %+v", i, test.expectedIDs, snapshots)
+			continue
+		}
+		for j, snapshot := range snapshots {
+			if snapshot.ID != test.expectedIDs[j] || snapshot.Status != NEW {
+				t.Errorf("Test case %d: expected %s, got %+v", i, test.expectedIDs[j], snapshot)
+			}
+		}
+	}
+
+	snapshot := MinitraderSnapshot{}
+	_TestControlRequest(t, server, "secret", http.MethodGet, "/minitraders/EURUSD-HOUR", nil, &snapshot)
+	if !snapshot.Paused || snapshot.InvestmentPercentage != 40 || snapshot.Timeframe != HOUR {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+
+	// the kill switch closes the trades of the pool epics only
+	if status := _TestControlRequest(t, server, "secret", http.MethodPost, "/kill", nil, nil); status != http.StatusNoContent {
+		t.Errorf("expected the pool to be killed, got status %d", status)
+	}
+	positions, _ := paper.GetPositions(context.Background())
+	if len(positions.Positions) != 0 {
+		t.Errorf("expected every position to be closed, got %+v", positions.Positions)
+	}
+}
+
+func TestMinitraderPoolAddRemoveRunning(t *testing.T) {
+	feed := &_TestBroker{Account: AccountResponse{Preferred: true}}
+	feed.Prices = PricesResponse{Prices: GenerateCapitalPrices(time.Now(), MINUTE_15, 200, 19.5, 0.01)}
+	paper := NewPaperBroker(feed, 10000, "USD")
+	none := func(candles Candles) (Signal, float64) { return NONE, 0 }
+	pool, _ := NewMinitraderPool(paper, NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, none))
+	stopped := make(chan error)
+	go func() { stopped <- pool.Start(context.Background()) }()
+
+	if _, err := pool.RemoveMinitrader("USDMXN-MINUTE_15"); err != nil {
+		t.Fatal(err)
+	}
+	added := NewMinitrader("EURUSD", 100, 5, 0.5, HOUR, none)
+	if err := pool.AddMinitrader(added); err != nil {
+		t.Fatal(err)
+	}
+
+	// the added minitrader is started and fed the candles of its epic
+	deadline := time.Now().Add(time.Second * 5)
+	for len(added.LastCandles(0)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if len(added.LastCandles(0)) == 0 {
+		t.Errorf("expected the added minitrader to receive candles")
+	}
+	if minitraders := pool.ListMinitraders(); len(minitraders) != 1 || minitraders[0] != added {
+		t.Errorf("expected only the added minitrader, got %v", minitraders)
+	}
+
+	pool.Stop()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected the pool to stop")
+	}
+}
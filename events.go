@@ -0,0 +1,240 @@
+package gominitrader
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType string
+
+const (
+	EVENT_CANDLES_UPDATED       EventType = "CANDLES_UPDATED"
+	EVENT_SIGNAL_GENERATED      EventType = "SIGNAL_GENERATED"
+	EVENT_ORDER_SUBMITTED       EventType = "ORDER_SUBMITTED"
+	EVENT_ORDER_CONFIRMED       EventType = "ORDER_CONFIRMED"
+	EVENT_ORDER_REJECTED        EventType = "ORDER_REJECTED"
+	EVENT_ORDER_DELETED         EventType = "ORDER_DELETED"
+	EVENT_POSITION_OPENED       EventType = "POSITION_OPENED"
+	EVENT_POSITION_CLOSED       EventType = "POSITION_CLOSED"
+	EVENT_STATUS_CHANGED        EventType = "STATUS_CHANGED"
+	EVENT_MARKET_STATUS_CHANGED EventType = "MARKET_STATUS_CHANGED"
+	EVENT_SESSION_REFRESHED     EventType = "SESSION_REFRESHED"
+	EVENT_TRADE_RESTORED        EventType = "TRADE_RESTORED"
+	EVENT_ORPHAN_TRADE_FOUND    EventType = "ORPHAN_TRADE_FOUND"
+)
+
+// Event is something that happened in a MinitraderPool; subscribers switch on its concrete type.
+type Event interface {
+	Type() EventType
+	Info() EventInfo
+}
+
+// EventInfo is common to every event; Epic and Timeframe are empty for pool wide events.
+type EventInfo struct {
+	Time      time.Time
+	Epic      string
+	Timeframe Timeframe
+}
+
+func (info EventInfo) Info() EventInfo {
+	return info
+}
+
+// CandlesUpdated is published when a minitrader receives new candles.
+type CandlesUpdated struct {
+	EventInfo
+	Last  Candle // bar still forming
+	Count int
+}
+
+// SignalGenerated is published every time a minitrader evaluates its strategy.
+type SignalGenerated struct {
+	EventInfo
+	Strategy        string // Name of the strategy, e.g. `GPTStrategy`
+	Signal          Signal
+	Price           float64
+	CandleTimestamp int64 // of the last candle the strategy evaluated
+}
+
+// OrderSubmitted is published when a working order or a position is accepted for processing.
+type OrderSubmitted struct {
+	EventInfo
+	DealReference string
+	EntryType     EntryType
+	Direction     Signal
+	Level         float64
+	Size          float64
+	StopLevel     float64
+	ProfitLevel   float64
+}
+
+// OrderConfirmed is published when the confirmation of a submitted order is accepted.
+type OrderConfirmed struct {
+	EventInfo
+	DealReference string
+	DealID        string
+	Status        string // deal status of the confirmation, e.g. `ACCEPTED`
+	Reason        string
+	Level         float64
+	Size          float64
+}
+
+// OrderRejected is published when an order could not be submitted, or its confirmation was rejected.
+type OrderRejected struct {
+	EventInfo
+	DealReference string
+	Reason        string
+}
+
+// OrderDeleted is published when a pending working order is deleted.
+type OrderDeleted struct {
+	EventInfo
+	DealID string
+}
+
+// PositionOpened is published when a market entry opens a position.
+type PositionOpened struct {
+	EventInfo
+	DealID string
+	Level  float64
+	Size   float64
+}
+
+// PositionClosed is published when the position of a minitrader is closed, by the minitrader or by the
+// stop loss or take profit attached to it on the broker side.
+type PositionClosed struct {
+	EventInfo
+	DealID         string
+	EntryLevel     float64
+	Level          float64 // price the exit was triggered at
+	Size           float64
+	ProfitLoss     float64 // of the position at Level
+	Reason         string
+	ClosedByBroker bool // the broker closed it first; Level is the price the minitrader noticed it at
+}
+
+// TradeRestored is published when the pool reattaches a position or working order found on the broker to a
+// minitrader on startup.
+type TradeRestored struct {
+	EventInfo
+	DealReference string
+	DealID        string
+	WorkingOrder  bool // the trade is a pending working order, not a position
+	Level         float64
+	Size          float64
+	FromJournal   bool // the journal recorded the trade for the minitrader; otherwise it matched by epic and direction
+}
+
+// OrphanTradeFound is published for every position or working order of the pool epics that the pool can not
+// attribute to any of its minitraders on startup. Epic is set, Timeframe is not.
+type OrphanTradeFound struct {
+	EventInfo
+	DealReference string
+	DealID        string
+	WorkingOrder  bool
+	Direction     string
+	Level         float64
+	Size          float64
+	Reason        string
+}
+
+// StatusChanged is published on every minitrader transition.
+type StatusChanged struct {
+	EventInfo
+	Transition Transition
+}
+
+// MarketStatusChanged is published when the market of a minitrader opens or closes.
+type MarketStatusChanged struct {
+	EventInfo
+	From MinitraderMarketStatus
+	To   MinitraderMarketStatus
+}
+
+// SessionRefreshed is published when the broker session is created or renewed.
+type SessionRefreshed struct {
+	EventInfo
+	Reason string
+}
+
+func (event CandlesUpdated) Type() EventType      { return EVENT_CANDLES_UPDATED }
+func (event SignalGenerated) Type() EventType     { return EVENT_SIGNAL_GENERATED }
+func (event OrderSubmitted) Type() EventType      { return EVENT_ORDER_SUBMITTED }
+func (event OrderConfirmed) Type() EventType      { return EVENT_ORDER_CONFIRMED }
+func (event OrderRejected) Type() EventType       { return EVENT_ORDER_REJECTED }
+func (event OrderDeleted) Type() EventType        { return EVENT_ORDER_DELETED }
+func (event PositionOpened) Type() EventType      { return EVENT_POSITION_OPENED }
+func (event PositionClosed) Type() EventType      { return EVENT_POSITION_CLOSED }
+func (event StatusChanged) Type() EventType       { return EVENT_STATUS_CHANGED }
+func (event MarketStatusChanged) Type() EventType { return EVENT_MARKET_STATUS_CHANGED }
+func (event SessionRefreshed) Type() EventType    { return EVENT_SESSION_REFRESHED }
+func (event TradeRestored) Type() EventType       { return EVENT_TRADE_RESTORED }
+func (event OrphanTradeFound) Type() EventType    { return EVENT_ORPHAN_TRADE_FOUND }
+
+// EventBus delivers events to its subscribers without ever blocking the publisher: every subscriber has
+// its own buffer, and events that do not fit in it are dropped for that subscriber only.
+type EventBus struct {
+	mutex       sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events of a bus on Events until it is unsubscribed.
+type Subscription struct {
+	Events <-chan Event
+
+	bus     *EventBus
+	events  chan Event
+	types   map[EventType]bool // every type if empty
+	dropped int64
+	once    sync.Once
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription buffering up to buffer events of the given types, or of every type if
+// none are given.
+func (bus *EventBus) Subscribe(buffer int, types ...EventType) *Subscription {
+	events := make(chan Event, buffer)
+	subscription := &Subscription{Events: events, bus: bus, events: events, types: make(map[EventType]bool)}
+	for _, eventType := range types {
+		subscription.types[eventType] = true
+	}
+	bus.mutex.Lock()
+	bus.subscribers[subscription] = struct{}{}
+	bus.mutex.Unlock()
+	return subscription
+}
+
+// Publish hands event to every subscriber with room for it.
+func (bus *EventBus) Publish(event Event) {
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+	for subscription := range bus.subscribers {
+		if len(subscription.types) != 0 && !subscription.types[event.Type()] {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			atomic.AddInt64(&subscription.dropped, 1)
+		}
+	}
+}
+
+// Unsubscribe stops the deliveries and closes Events.
+func (subscription *Subscription) Unsubscribe() {
+	subscription.once.Do(func() {
+		subscription.bus.mutex.Lock()
+		delete(subscription.bus.subscribers, subscription)
+		subscription.bus.mutex.Unlock()
+		close(subscription.events)
+	})
+}
+
+// Dropped is the number of events that did not fit in the subscription buffer.
+func (subscription *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&subscription.dropped)
+}
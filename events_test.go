@@ -0,0 +1,115 @@
+package gominitrader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	all := bus.Subscribe(10)
+	orders := bus.Subscribe(10, EVENT_ORDER_SUBMITTED, EVENT_ORDER_CONFIRMED)
+	slow := bus.Subscribe(1)
+
+	bus.Publish(OrderSubmitted{DealReference: "o_1"})
+	bus.Publish(StatusChanged{Transition: Transition{From: RUNNING, To: BUY_ORDER_ACTIVE}})
+	bus.Publish(OrderConfirmed{DealReference: "o_1", DealID: "p_1"})
+
+	tests := []struct {
+		subscription    *Subscription
+		expectedTypes   []EventType
+		expectedDropped int64
+	}{
+		{all, []EventType{EVENT_ORDER_SUBMITTED, EVENT_STATUS_CHANGED, EVENT_ORDER_CONFIRMED}, 0},
+		{orders, []EventType{EVENT_ORDER_SUBMITTED, EVENT_ORDER_CONFIRMED}, 0},
+		{slow, []EventType{EVENT_ORDER_SUBMITTED}, 2},
+	}
+	for i, test := range tests {
+		test.subscription.Unsubscribe()
+		types := []EventType{}
+		for event := range test.subscription.Events {
+			types = append(types, event.Type())
+		}
+		if len(types) != len(test.expectedTypes) {
+			t.Errorf("Test case %d: expected %v, got %v", i, test.expectedTypes, types)
+			continue
+		}
+		for j := range types {
+			if types[j] != test.expectedTypes[j] {
+				t.Errorf("Test case %d: expected %v, got %v", i, test.expectedTypes, types)
+				break
+			}
+		}
+		if test.subscription.Dropped() != test.expectedDropped {
+			t.Errorf("Test case %d: expected %d dropped events, got %d", i, test.expectedDropped, test.subscription.Dropped())
+		}
+	}
+
+	// publishing after every subscriber is gone must not panic or block
+	bus.Publish(OrderDeleted{DealID: "o_1"})
+	all.Unsubscribe()
+}
+
+func TestMinitraderPoolEvents(t *testing.T) {
+	broker := &_TestBroker{Confirmation: PositionOrderConfirmationResponse{DealID: "p_1", DealStatus: "ACCEPTED", Level: 19.5}}
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader.EntryType = MARKET_ENTRY
+	pool, err := NewMinitraderPool(broker, minitrader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minitrader.broker = pool.Broker
+	subscription := pool.Subscribe(10)
+	defer subscription.Unsubscribe()
+
+	minitrader.transition(RUNNING, "started")
+	minitrader.setVolatileValues(100, 10)
+	minitrader.Effect(context.Background(), BUY, 19.5)
+
+	expectedTypes := []EventType{EVENT_STATUS_CHANGED, EVENT_STATUS_CHANGED, EVENT_ORDER_SUBMITTED, EVENT_ORDER_CONFIRMED, EVENT_POSITION_OPENED, EVENT_STATUS_CHANGED}
+	for i, expectedType := range expectedTypes {
+		select {
+		case event := <-subscription.Events:
+			if event.Type() != expectedType {
+				t.Errorf("Test case %d: expected %s, got %s", i, expectedType, event.Type())
+			}
+			if info := event.Info(); info.Epic != "USDMXN" || info.Timeframe != MINUTE_15 || info.Time.IsZero() {
+				t.Errorf("Test case %d: unexpected event info %+v", i, info)
+			}
+			if opened, ok := event.(PositionOpened); ok && opened.DealID != "p_1" {
+				t.Errorf("Test case %d: expected deal p_1, got %s", i, opened.DealID)
+			}
+		default:
+			t.Fatalf("Test case %d: expected %s, got no event", i, expectedType)
+		}
+	}
+}
+
+func TestMinitraderPoolSessionRefreshedEvents(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.Scheduler = nil
+	pool, _ := NewMinitraderPool(capClient, NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy))
+	subscription := pool.Subscribe(10, EVENT_SESSION_REFRESHED)
+	defer subscription.Unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	pool.AuthenticateSession(ctx, time.Millisecond*20)
+	emulator.ExpireSessions()
+	if _, err := capClient.GetPositions(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expectedReason := range []string{"created", "expired"} {
+		select {
+		case event := <-subscription.Events:
+			if refreshed := event.(SessionRefreshed); refreshed.Reason != expectedReason {
+				t.Errorf("Test case %d: expected reason %s, got %s", i, expectedReason, refreshed.Reason)
+			}
+		default:
+			t.Fatalf("Test case %d: expected a %s session event", i, expectedReason)
+		}
+	}
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	gominitrader "github.com/menesesghz/go-minitrader"
+	"github.com/menesesghz/go-minitrader/telegram"
 )
 
 func main() {
@@ -14,6 +22,9 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Log JSON records; secrets are redacted before they reach the handler.
+	gominitrader.SetLogHandler(slog.NewJSONHandler(os.Stdout, nil))
+
 	capitalEmail := os.Getenv("CAPITAL_EMAIL")
 	capitalApiKey := os.Getenv("CAPITAL_API_KEY")
 	capitalApiKeyPassword := os.Getenv("CAPITAL_API_KEY_PASSWORD")
@@ -39,5 +50,39 @@ func main() {
 	)
 
 	minitraderPool, _ := gominitrader.NewMinitraderPool(capitalClient, minitraderUSDJPY, minitraderUSDCAD, minitraderUSDMXN)
-	minitraderPool.Start()
+
+	// Journal every signal, order and round trip of the minitraders.
+	journal, err := gominitrader.OpenJSONLinesJournal("journal.jsonl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer journal.Close()
+	minitraderPool.SetJournal(journal)
+
+	// On Ctrl+C, cancel pending working orders and give in-flight orders 30 seconds to complete.
+	minitraderPool.SetShutdownOptions(gominitrader.ShutdownOptions{CancelWorkingOrders: true, Timeout: time.Second * 30})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Monitor and control the pool from Telegram when a bot token and a chat are configured.
+	if telegramToken := os.Getenv("TELEGRAM_TOKEN"); telegramToken != "" {
+		chatID, err := strconv.ParseInt(os.Getenv("TELEGRAM_CHAT_ID"), 10, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go telegram.NewBot(telegram.NewClient(telegramToken), minitraderPool, chatID).Run(ctx)
+	}
+
+	// Export Prometheus metrics on /metrics when an address is configured, e.g. localhost:9090.
+	if metricsAddr := os.Getenv("METRICS_ADDR"); metricsAddr != "" {
+		metrics := gominitrader.NewMetrics(minitraderPool)
+		capitalClient.Metrics = metrics
+		go metrics.Run(ctx)
+		http.Handle("/metrics", metrics)
+		go http.ListenAndServe(metricsAddr, nil)
+	}
+
+	if err := minitraderPool.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,8 +1,10 @@
 module github.com/menesesghz/go-minitrader
 
-go 1.19
+go 1.21
 
 require (
 	github.com/deckarep/golang-set v1.8.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.4.0
+	github.com/ulikunitz/xz v0.5.15
 )
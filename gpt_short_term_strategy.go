@@ -10,24 +10,16 @@ If the short-term moving average crosses below the long-term moving average, it
 */
 package gominitrader
 
-func GPTShortTermStrategy(candles Candles) (Signal, float64) {
-	numberOfCandles := len(candles)
-
-	// Calculate the simple moving average for the last 20 candles
-	var shortSMA float64
-	for i := numberOfCandles - 20; i < numberOfCandles; i++ {
-		shortSMA += candles[i].Close.Bid
-	}
-	shortSMA /= 20
+import (
+	"github.com/menesesghz/go-minitrader/indicators"
+)
 
-	// Calculate the simple moving average for the last 50 candles
-	var longSMA float64
-	for i := numberOfCandles - 50; i < numberOfCandles; i++ {
-		longSMA += candles[i].Close.Bid
-	}
-	longSMA /= 50
+func GPTShortTermStrategy(candles Candles) (Signal, float64) {
+	// simple moving averages of the last 20 and 50 bid closes
+	shortSMA := indicators.Last(indicators.SMA(candles, indicators.BID, 20))
+	longSMA := indicators.Last(indicators.SMA(candles, indicators.BID, 50))
 
-	price := candles[numberOfCandles-1].Close.Bid
+	price := candles[len(candles)-1].Close.Bid
 
 	// Buy signal: short-term SMA crosses above long-term SMA
 	if shortSMA > longSMA && shortSMA > price {
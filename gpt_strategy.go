@@ -32,70 +32,22 @@ Ideally this strategy was for a 15 minutes timeframe.
 package gominitrader
 
 import (
-	"math"
+	"github.com/menesesghz/go-minitrader/indicators"
 )
 
+// GPTStrategy buys when the bid closes below the lower Bollinger Band (20, 2) with a Wilder RSI (14)
+// under 30, and sells when it closes above the upper band with the RSI over 70.
 func GPTStrategy(candles Candles) (Signal, float64) {
-	rsi := []float64{}
-	numberOfCandles := len(candles)
-	for i := 14; i < numberOfCandles; i++ {
-		// Calculate the average gain and average loss
-		var avgGain float64
-		var avgLoss float64
-		for j := i - 13; j < i; j++ {
-			// calculate the change in price between the current candle and the previous candle
-			change := candles[j].Close.Bid - candles[j-1].Close.Bid
-			if change > 0 {
-				// if the change is positive, add it to the average gain
-				avgGain += change
-			} else {
-				// if the change is negative, add it to the average loss
-				avgLoss += change
-			}
-		}
-		// divide the average gain and average loss by 14 to get the average gain and loss over the last 14 candles
-		avgGain /= 14
-		avgLoss /= 14
+	rsi := indicators.Last(indicators.RSI(candles, indicators.BID, 14))
+	bands := indicators.Bollinger(candles, indicators.BID, 20, 2)
+	band := bands[len(bands)-1]
 
-		// Calculate the relative strength
-		rs := avgGain / -avgLoss
-
-		// Calculate the relative strength index
-		rsi = append(rsi, 100-(100/(1+rs)))
-	}
-
-	// Create slices for the Bollinger Bands values
-	upperBand := []float64{}
-	middleBand := []float64{}
-	lowerBand := []float64{}
-
-	// Calculate the Bollinger Bands for each data point
-	for i := 20; i < numberOfCandles; i++ {
-		// Calculate the moving average
-		var movingAvg float64
-		for j := i - 20; j < i; j++ {
-			movingAvg += candles[j].Close.Bid
-		}
-		movingAvg /= 20
-
-		// Calculate the standard deviation
-		var variance float64
-		for j := i - 20; j < i; j++ {
-			variance += math.Pow(candles[j].Close.Bid-movingAvg, 2)
-		}
-		stdDev := math.Sqrt(variance / float64(20))
-
-		// Calculate the Bollinger Bands
-		upperBand = append(upperBand, movingAvg+2*stdDev)
-		middleBand = append(middleBand, movingAvg)
-		lowerBand = append(lowerBand, movingAvg-2*stdDev)
-	}
-
-	price := candles[numberOfCandles-1].Close.Bid
-	if price < lowerBand[numberOfCandles-21] && rsi[len(rsi)-1] < 30 {
+	// NaN values during the warm up never satisfy the comparisons below
+	price := candles[len(candles)-1].Close.Bid
+	if price < band.Lower && rsi < 30 {
 		return BUY, price
 	}
-	if price > upperBand[numberOfCandles-21] && rsi[len(rsi)-1] > 70 {
+	if price > band.Upper && rsi > 70 {
 		return SELL, price
 	}
 	return NONE, price
@@ -0,0 +1,359 @@
+package gominitrader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// ImportOptions configures how exported history is turned into Candles.
+type ImportOptions struct {
+	// Spread is added to the prices of sources that only have one side, which are taken as the bid,
+	// to derive the ask.
+	Spread float64
+	// Point is the value of a price point, e.g. 0.00001 for EURUSD and 0.001 for USDJPY. MetaTrader
+	// spreads are given in points, and Dukascopy prices are integers of points; defaults to 0.00001.
+	Point float64
+	// Location is the timezone of exported times without one; defaults to UTC.
+	Location *time.Location
+}
+
+func (options ImportOptions) point() float64 {
+	if options.Point <= 0 {
+		return 0.00001
+	}
+	return options.Point
+}
+
+func (options ImportOptions) location() *time.Location {
+	if options.Location == nil {
+		return time.UTC
+	}
+	return options.Location
+}
+
+// Tick is a single bid/ask quote.
+type Tick struct {
+	Time      time.Time
+	Bid       float64
+	Ask       float64
+	BidVolume float64
+	AskVolume float64
+}
+
+// singleSidedCandle builds a candle from one-sided prices, deriving the ask from spread.
+func singleSidedCandle(timestamp int64, open float64, high float64, low float64, close float64, volume int64, spread float64) Candle {
+	return Candle{
+		Timestamp: timestamp,
+		Volume:    volume,
+		Open:      BidAskPrice{Bid: open, Ask: open + spread},
+		High:      BidAskPrice{Bid: high, Ask: high + spread},
+		Low:       BidAskPrice{Bid: low, Ask: low + spread},
+		Close:     BidAskPrice{Bid: close, Ask: close + spread},
+	}
+}
+
+// ImportTradingViewCSV reads a TradingView chart export ("Export chart data..."). Columns are found by
+// name: time, open, high, low, close and an optional volume; indicator columns are ignored. Times can be
+// UNIX seconds or ISO 8601.
+func ImportTradingViewCSV(reader io.Reader, options ImportOptions) (Candles, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+	for _, name := range []string{"time", "open", "high", "low", "close"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.New(fmt.Sprintf("TradingView Export Column Not Found: %s", name))
+		}
+	}
+
+	candles := Candles{}
+	for line := 2; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if index, ok := columns[name]; ok && index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+
+		timestamp, err := parseTradingViewTime(field("time"), options.location())
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid time on line %d - %s", line, err))
+		}
+		prices, err := parseFloats(field("open"), field("high"), field("low"), field("close"))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid price on line %d - %s", line, err))
+		}
+		volume := int64(0)
+		if value := field("volume"); value != "" && value != "NaN" {
+			parsedVolume, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid volume on line %d - %s", line, err))
+			}
+			volume = int64(parsedVolume)
+		}
+		candles = append(candles, singleSidedCandle(timestamp, prices[0], prices[1], prices[2], prices[3], volume, options.Spread))
+	}
+	return candles, nil
+}
+
+func parseTradingViewTime(value string, location *time.Location) (int64, error) {
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return timestamp, nil
+	}
+	if parsedTime, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsedTime.Unix(), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if parsedTime, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsedTime.Unix(), nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("unknown time format %q", value))
+}
+
+// ImportMetaTraderCSV reads MetaTrader history exports: MetaTrader 5 files with a <DATE> <TIME> <OPEN>
+// ... <SPREAD> header, and MetaTrader 4 files without header of date,time,open,high,low,close,volume.
+// Tab and comma separated files are accepted. A non-zero SPREAD, in points, is used instead of
+// options.Spread.
+func ImportMetaTraderCSV(reader io.Reader, options ImportOptions) (Candles, error) {
+	scanner := bufio.NewScanner(reader)
+	columns := map[string]int{"<DATE>": 0, "<TIME>": 1, "<OPEN>": 2, "<HIGH>": 3, "<LOW>": 4, "<CLOSE>": 5, "<TICKVOL>": 6}
+
+	candles := Candles{}
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if text == "" {
+			continue
+		}
+		separator := ","
+		if strings.Contains(text, "\t") {
+			separator = "\t"
+		}
+		fields := strings.Split(text, separator)
+		if line == 1 && strings.HasPrefix(text, "<") {
+			columns = map[string]int{}
+			for i, name := range fields {
+				columns[strings.ToUpper(strings.TrimSpace(name))] = i
+			}
+			continue
+		}
+		field := func(name string) string {
+			if index, ok := columns[name]; ok && index < len(fields) {
+				return strings.TrimSpace(fields[index])
+			}
+			return ""
+		}
+
+		value := field("<DATE>")
+		layout := "2006.01.02"
+		if clock := field("<TIME>"); clock != "" {
+			value += " " + clock
+			layout += " 15:04"
+			if strings.Count(clock, ":") == 2 {
+				layout += ":05"
+			}
+		}
+		parsedTime, err := time.ParseInLocation(layout, value, options.location())
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid time on line %d - %s", line, err))
+		}
+		prices, err := parseFloats(field("<OPEN>"), field("<HIGH>"), field("<LOW>"), field("<CLOSE>"))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid price on line %d - %s", line, err))
+		}
+
+		volume := int64(0)
+		for _, name := range []string{"<VOL>", "<TICKVOL>"} {
+			if value := field(name); value != "" && volume == 0 {
+				parsedVolume, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("Invalid volume on line %d - %s", line, err))
+				}
+				volume = int64(parsedVolume)
+			}
+		}
+		spread := options.Spread
+		if value := field("<SPREAD>"); value != "" {
+			points, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid spread on line %d - %s", line, err))
+			}
+			if points > 0 {
+				spread = points * options.point()
+			}
+		}
+		candles = append(candles, singleSidedCandle(parsedTime.Unix(), prices[0], prices[1], prices[2], prices[3], volume, spread))
+	}
+	return candles, scanner.Err()
+}
+
+// ImportMetaTraderHST reads a MetaTrader 4 history file, of version 400 or 401. Version 401 records carry
+// their spread in points, which is used instead of options.Spread when it is not zero.
+func ImportMetaTraderHST(reader io.Reader, options ImportOptions) (Candles, error) {
+	header := make([]byte, 148)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	version := binary.LittleEndian.Uint32(header)
+	recordSize := 0
+	switch version {
+	case 400:
+		recordSize = 44
+	case 401:
+		recordSize = 60
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported HST Version: %d", version))
+	}
+
+	candles := Candles{}
+	record := make([]byte, recordSize)
+	for {
+		_, err := io.ReadFull(reader, record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		float := func(offset int) float64 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(record[offset:]))
+		}
+		if version == 400 {
+			// time, open, low, high, close, volume
+			timestamp := int64(int32(binary.LittleEndian.Uint32(record)))
+			candles = append(candles, singleSidedCandle(timestamp, float(4), float(20), float(12), float(28), int64(float(36)), options.Spread))
+			continue
+		}
+		// time, open, high, low, close, tick volume, spread, real volume
+		timestamp := int64(binary.LittleEndian.Uint64(record))
+		spread := options.Spread
+		if points := int32(binary.LittleEndian.Uint32(record[48:])); points > 0 {
+			spread = float64(points) * options.point()
+		}
+		volume := int64(binary.LittleEndian.Uint64(record[40:]))
+		candles = append(candles, singleSidedCandle(timestamp, float(8), float(16), float(24), float(32), volume, spread))
+	}
+	return candles, nil
+}
+
+var dukascopyPathRegexp = regexp.MustCompile(`(\d{4})[/\\](\d{2})[/\\](\d{2})[/\\](\d{2})h_ticks\.bi5$`)
+
+// ParseDukascopyPath returns the hour of a Dukascopy tick file from its path, e.g.
+// EURUSD/2023/00/02/13h_ticks.bi5; months in the path start at 00.
+func ParseDukascopyPath(path string) (time.Time, error) {
+	match := dukascopyPathRegexp.FindStringSubmatch(filepath.ToSlash(path))
+	if match == nil {
+		return time.Time{}, errors.New(fmt.Sprintf("Not a Dukascopy tick file path: %s", path))
+	}
+	values := make([]int, 4)
+	for i := range values {
+		values[i], _ = strconv.Atoi(match[i+1])
+	}
+	return time.Date(values[0], time.Month(values[1]+1), values[2], values[3], 0, 0, 0, time.UTC), nil
+}
+
+// ImportDukascopyBi5 reads a Dukascopy LZMA compressed tick file holding the ticks of the hour starting
+// at hour. Prices are stored as integers of options.Point.
+func ImportDukascopyBi5(reader io.Reader, hour time.Time, options ImportOptions) ([]Tick, error) {
+	ticks := []Tick{}
+	buffered := bufio.NewReader(reader)
+	if _, err := buffered.Peek(1); err == io.EOF {
+		// hours without ticks are published as empty files
+		return ticks, nil
+	}
+	decompressed, err := lzma.NewReader(buffered)
+	if err != nil {
+		return nil, err
+	}
+
+	// milliseconds since hour, ask, bid, ask volume and bid volume; big endian
+	record := make([]byte, 20)
+	for {
+		_, err := io.ReadFull(decompressed, record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ticks = append(ticks, Tick{
+			Time:      hour.Add(time.Duration(binary.BigEndian.Uint32(record)) * time.Millisecond),
+			Ask:       float64(binary.BigEndian.Uint32(record[4:])) * options.point(),
+			Bid:       float64(binary.BigEndian.Uint32(record[8:])) * options.point(),
+			AskVolume: float64(math.Float32frombits(binary.BigEndian.Uint32(record[12:]))),
+			BidVolume: float64(math.Float32frombits(binary.BigEndian.Uint32(record[16:]))),
+		})
+	}
+	return ticks, nil
+}
+
+// TicksToCandles aggregates ticks sorted by time into candles of any timeframe, aligned to UTC. The
+// volume of a candle is its number of ticks.
+func TicksToCandles(ticks []Tick, timeframe Timeframe) (Candles, error) {
+	duration, err := timeframe.Duration()
+	if err != nil {
+		return nil, err
+	}
+	candles := Candles{}
+	for _, tick := range ticks {
+		price := BidAskPrice{Bid: tick.Bid, Ask: tick.Ask}
+		start := bucketStart(tick.Time.Unix(), duration, time.UTC)
+		last := len(candles) - 1
+		if last >= 0 && start < candles[last].Timestamp {
+			return nil, errors.New("Ticks must be sorted from oldest to newest to be aggregated")
+		}
+		if last < 0 || start > candles[last].Timestamp {
+			candles = append(candles, Candle{Timestamp: start, Volume: 1, Open: price, High: price, Low: price, Close: price})
+			continue
+		}
+
+		candle := &candles[last]
+		candle.High.Bid = math.Max(candle.High.Bid, tick.Bid)
+		candle.High.Ask = math.Max(candle.High.Ask, tick.Ask)
+		candle.Low.Bid = math.Min(candle.Low.Bid, tick.Bid)
+		candle.Low.Ask = math.Min(candle.Low.Ask, tick.Ask)
+		candle.Close = price
+		candle.Volume++
+	}
+	return candles, nil
+}
+
+func parseFloats(values ...string) ([]float64, error) {
+	floats := make([]float64, len(values))
+	for i, value := range values {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		floats[i] = parsed
+	}
+	return floats, nil
+}
@@ -0,0 +1,144 @@
+package gominitrader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+func TestImportCSV(t *testing.T) {
+	tradingView := "time,open,high,low,close,Volume,RSI\n" +
+		"1672617600,1.07,1.08,1.06,1.075,120,55\n" +
+		"2023-01-02T00:15:00Z,1.075,1.09,1.07,1.08,,NaN\n"
+	metaTrader5 := "<DATE>\t<TIME>\t<OPEN>\t<HIGH>\t<LOW>\t<CLOSE>\t<TICKVOL>\t<VOL>\t<SPREAD>\n" +
+		"2023.01.02\t00:00:00\t1.07\t1.08\t1.06\t1.075\t120\t0\t20\n" +
+		"2023.01.02\t00:15:00\t1.075\t1.09\t1.07\t1.08\t0\t0\t0\n"
+	metaTrader4 := "2023.01.02,00:00,1.07,1.08,1.06,1.075,120\n2023.01.02,00:15,1.075,1.09,1.07,1.08,0\n"
+
+	tests := []struct {
+		importer        func(io.Reader, ImportOptions) (Candles, error)
+		input           string
+		expectedSpreads []float64
+	}{
+		{ImportTradingViewCSV, tradingView, []float64{0.0001, 0.0001}},
+		{ImportMetaTraderCSV, metaTrader5, []float64{0.0002, 0.0001}},
+		{ImportMetaTraderCSV, metaTrader4, []float64{0.0001, 0.0001}},
+	}
+	for i, test := range tests {
+		candles, err := test.importer(strings.NewReader(test.input), ImportOptions{Spread: 0.0001})
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		if len(candles) != 2 {
+			t.Fatalf("Test case %d: expected 2 candles, got %d", i, len(candles))
+		}
+		if candles[0].Timestamp != 1672617600 || candles[1].Timestamp != 1672617600+15*60 {
+			t.Errorf("Test case %d: unexpected timestamps %d %d", i, candles[0].Timestamp, candles[1].Timestamp)
+		}
+		if candles[0].Volume != 120 || candles[0].High.Bid != 1.08 || candles[0].Close.Bid != 1.075 {
+			t.Errorf("Test case %d: unexpected candle %+v", i, candles[0])
+		}
+		for j, candle := range candles {
+			if math.Abs(candle.Close.Ask-candle.Close.Bid-test.expectedSpreads[j]) > 1e-9 || math.Abs(candle.Low.Ask-candle.Low.Bid-test.expectedSpreads[j]) > 1e-9 {
+				t.Errorf("Test case %d: expected a %f spread on candle %d, got %+v", i, test.expectedSpreads[j], j, candle)
+			}
+		}
+	}
+
+	if _, err := ImportTradingViewCSV(strings.NewReader("date,price\n1,2\n"), ImportOptions{}); err == nil {
+		t.Errorf("expected an error importing a file without the TradingView columns")
+	}
+}
+
+func TestImportMetaTraderHST(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	header := make([]byte, 148)
+	binary.LittleEndian.PutUint32(header, 401)
+	buffer.Write(header)
+	for i, prices := range [][4]float64{{110, 111, 109, 110.5}, {110.5, 112, 110, 111}} {
+		record := make([]byte, 60)
+		binary.LittleEndian.PutUint64(record, uint64(1672617600+i*3600))
+		for j, price := range prices {
+			binary.LittleEndian.PutUint64(record[8+8*j:], math.Float64bits(price))
+		}
+		binary.LittleEndian.PutUint64(record[40:], 50)
+		binary.LittleEndian.PutUint32(record[48:], 15)
+		buffer.Write(record)
+	}
+
+	candles, err := ImportMetaTraderHST(buffer, ImportOptions{Point: 0.001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 || candles[1].Timestamp != 1672617600+3600 || candles[1].High.Bid != 112 || candles[0].Volume != 50 {
+		t.Fatalf("unexpected candles %+v", candles)
+	}
+	if math.Abs(candles[0].Open.Ask-110.015) > 1e-9 {
+		t.Errorf("expected the ask to include the 15 points spread, got %f", candles[0].Open.Ask)
+	}
+
+	binary.LittleEndian.PutUint32(header, 500)
+	if _, err := ImportMetaTraderHST(bytes.NewReader(header), ImportOptions{}); err == nil {
+		t.Errorf("expected an error importing an unsupported HST version")
+	}
+}
+
+func TestImportDukascopy(t *testing.T) {
+	hour, err := ParseDukascopyPath("EURUSD/2023/00/02/13h_ticks.bi5")
+	if err != nil || !hour.Equal(time.Date(2023, time.January, 2, 13, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected hour %s %v", hour, err)
+	}
+	if _, err := ParseDukascopyPath("EURUSD/2023/13h.csv"); err == nil {
+		t.Errorf("expected an error parsing a path that is not a tick file")
+	}
+
+	// milliseconds since the hour, ask and bid in points
+	rawTicks := [][3]uint32{{0, 107010, 107000}, {30000, 107030, 107020}, {59000, 106990, 106980}, {61000, 107050, 107040}}
+	compressed := &bytes.Buffer{}
+	writer, _ := lzma.NewWriter(compressed)
+	for _, rawTick := range rawTicks {
+		record := make([]byte, 20)
+		binary.BigEndian.PutUint32(record, rawTick[0])
+		binary.BigEndian.PutUint32(record[4:], rawTick[1])
+		binary.BigEndian.PutUint32(record[8:], rawTick[2])
+		binary.BigEndian.PutUint32(record[12:], math.Float32bits(1.5))
+		binary.BigEndian.PutUint32(record[16:], math.Float32bits(2.5))
+		writer.Write(record)
+	}
+	writer.Close()
+
+	ticks, err := ImportDukascopyBi5(compressed, hour, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ticks) != 4 || !ticks[1].Time.Equal(hour.Add(30*time.Second)) || math.Abs(ticks[1].Bid-1.0702) > 1e-9 || math.Abs(ticks[1].Ask-1.0703) > 1e-9 || ticks[1].BidVolume != 2.5 {
+		t.Fatalf("unexpected ticks %+v", ticks)
+	}
+
+	candles, err := TicksToCandles(ticks, MINUTE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 || candles[0].Timestamp != hour.Unix() || candles[1].Timestamp != hour.Unix()+60 || candles[0].Volume != 3 {
+		t.Fatalf("unexpected candles %+v", candles)
+	}
+	first := candles[0]
+	if math.Abs(first.Open.Bid-1.07) > 1e-9 || math.Abs(first.High.Ask-1.0703) > 1e-9 || math.Abs(first.Low.Bid-1.0698) > 1e-9 || math.Abs(first.Close.Ask-1.0699) > 1e-9 {
+		t.Errorf("unexpected aggregated candle %+v", first)
+	}
+	candles, _ = TicksToCandles(ticks, "MINUTE_2")
+	if len(candles) != 1 || candles[0].Volume != 4 {
+		t.Errorf("expected a single 2 minutes candle, got %+v", candles)
+	}
+
+	ticks, err = ImportDukascopyBi5(bytes.NewReader(nil), hour, ImportOptions{})
+	if err != nil || len(ticks) != 0 {
+		t.Errorf("expected no ticks from an empty hour, got %d %v", len(ticks), err)
+	}
+}
@@ -0,0 +1,102 @@
+package indicators
+
+import "math"
+
+type ADXValue struct {
+	ADX     float64
+	PlusDI  float64
+	MinusDI float64
+}
+
+// ADXStream is Wilder's average directional index. Directional movements and true ranges start on the
+// second bar and are smoothed with Wilder sums, so the directional indicators are available on bar
+// Period+1 and the ADX, an average of Period DX values, on bar 2*Period.
+type ADXStream struct {
+	Period int
+
+	count      int
+	previous   OHLCV
+	trSum      float64
+	plusDMSum  float64
+	minusDMSum float64
+	dxCount    int
+	dxSum      float64
+	value      ADXValue
+}
+
+func NewADXStream(period int) *ADXStream {
+	adx := &ADXStream{Period: period}
+	adx.Reset()
+	return adx
+}
+
+func (adx *ADXStream) Update(bar OHLCV) ADXValue {
+	adx.count++
+	previous := adx.previous
+	adx.previous = bar
+	if adx.count == 1 {
+		return adx.value
+	}
+
+	up, down := bar.High-previous.High, previous.Low-bar.Low
+	plusDM, minusDM := 0.0, 0.0
+	if up > down && up > 0 {
+		plusDM = up
+	}
+	if down > up && down > 0 {
+		minusDM = down
+	}
+	tr := trueRange(bar, previous.Close)
+
+	period := float64(adx.Period)
+	if adx.count <= adx.Period+1 {
+		adx.trSum += tr
+		adx.plusDMSum += plusDM
+		adx.minusDMSum += minusDM
+		if adx.count <= adx.Period {
+			return adx.value
+		}
+	} else {
+		adx.trSum += tr - adx.trSum/period
+		adx.plusDMSum += plusDM - adx.plusDMSum/period
+		adx.minusDMSum += minusDM - adx.minusDMSum/period
+	}
+
+	adx.value.PlusDI, adx.value.MinusDI = 0, 0
+	if adx.trSum > 0 {
+		adx.value.PlusDI = 100 * adx.plusDMSum / adx.trSum
+		adx.value.MinusDI = 100 * adx.minusDMSum / adx.trSum
+	}
+	dx := 0.0
+	if sum := adx.value.PlusDI + adx.value.MinusDI; sum > 0 {
+		dx = 100 * math.Abs(adx.value.PlusDI-adx.value.MinusDI) / sum
+	}
+
+	adx.dxCount++
+	switch {
+	case adx.dxCount < adx.Period:
+		adx.dxSum += dx
+	case adx.dxCount == adx.Period:
+		adx.value.ADX = (adx.dxSum + dx) / period
+	default:
+		adx.value.ADX = (adx.value.ADX*(period-1) + dx) / period
+	}
+	return adx.value
+}
+
+func (adx *ADXStream) Ready() bool     { return adx.dxCount >= adx.Period }
+func (adx *ADXStream) Value() ADXValue { return adx.value }
+
+func (adx *ADXStream) Reset() {
+	*adx = ADXStream{Period: adx.Period, value: ADXValue{ADX: math.NaN(), PlusDI: math.NaN(), MinusDI: math.NaN()}}
+}
+
+// ADX returns Wilder's average directional index of the series, with its directional indicators.
+func ADX(series Series, source Source, period int) []ADXValue {
+	adx := NewADXStream(period)
+	values := make([]ADXValue, series.Len())
+	for i := range values {
+		values[i] = adx.Update(series.Bar(i, source))
+	}
+	return values
+}
@@ -0,0 +1,61 @@
+package indicators
+
+import "math"
+
+// trueRange is the largest of the bar range and the gaps from the previous close.
+func trueRange(bar OHLCV, previousClose float64) float64 {
+	return math.Max(bar.High-bar.Low, math.Max(math.Abs(bar.High-previousClose), math.Abs(bar.Low-previousClose)))
+}
+
+// ATRStream is Wilder's average true range. True ranges start on the second bar, so the first value is
+// the simple average of Period true ranges, available on bar Period+1.
+type ATRStream struct {
+	Period int
+
+	count    int
+	previous float64
+	sum      float64
+	value    float64
+}
+
+func NewATRStream(period int) *ATRStream {
+	return &ATRStream{Period: period, value: math.NaN()}
+}
+
+func (atr *ATRStream) Update(bar OHLCV) float64 {
+	atr.count++
+	if atr.count == 1 {
+		atr.previous = bar.Close
+		return atr.value
+	}
+	tr := trueRange(bar, atr.previous)
+	atr.previous = bar.Close
+
+	period := float64(atr.Period)
+	switch {
+	case atr.count <= atr.Period:
+		atr.sum += tr
+	case atr.count == atr.Period+1:
+		atr.value = (atr.sum + tr) / period
+	default:
+		atr.value = (atr.value*(period-1) + tr) / period
+	}
+	return atr.value
+}
+
+func (atr *ATRStream) Ready() bool    { return atr.count > atr.Period }
+func (atr *ATRStream) Value() float64 { return atr.value }
+
+func (atr *ATRStream) Reset() {
+	*atr = *NewATRStream(atr.Period)
+}
+
+// ATR is Wilder's average true range of the series.
+func ATR(series Series, source Source, period int) []float64 {
+	atr := NewATRStream(period)
+	values := make([]float64, series.Len())
+	for i := range values {
+		values[i] = atr.Update(series.Bar(i, source))
+	}
+	return values
+}
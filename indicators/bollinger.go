@@ -0,0 +1,57 @@
+package indicators
+
+import "math"
+
+type BollingerBands struct {
+	Upper  float64
+	Middle float64
+	Lower  float64
+}
+
+// BollingerStream is the simple moving average of the last Period closes, surrounded by bands Deviations
+// population standard deviations away.
+type BollingerStream struct {
+	Period     int
+	Deviations float64
+
+	sma   *SMAStream
+	value BollingerBands
+}
+
+func NewBollingerStream(period int, deviations float64) *BollingerStream {
+	bollinger := &BollingerStream{Period: period, Deviations: deviations, sma: NewSMAStream(period)}
+	bollinger.Reset()
+	return bollinger
+}
+
+func (bollinger *BollingerStream) Update(bar OHLCV) BollingerBands {
+	middle := bollinger.sma.Add(bar.Close)
+	if !bollinger.sma.Ready() {
+		return bollinger.value
+	}
+	var variance float64
+	bollinger.sma.window.each(func(value float64) {
+		variance += (value - middle) * (value - middle)
+	})
+	deviation := math.Sqrt(variance/float64(bollinger.Period)) * bollinger.Deviations
+	bollinger.value = BollingerBands{Upper: middle + deviation, Middle: middle, Lower: middle - deviation}
+	return bollinger.value
+}
+
+func (bollinger *BollingerStream) Ready() bool           { return bollinger.sma.Ready() }
+func (bollinger *BollingerStream) Value() BollingerBands { return bollinger.value }
+
+func (bollinger *BollingerStream) Reset() {
+	bollinger.sma.Reset()
+	bollinger.value = BollingerBands{Upper: math.NaN(), Middle: math.NaN(), Lower: math.NaN()}
+}
+
+// Bollinger returns the Bollinger Bands of the closes of the series.
+func Bollinger(series Series, source Source, period int, deviations float64) []BollingerBands {
+	bollinger := NewBollingerStream(period, deviations)
+	values := make([]BollingerBands, series.Len())
+	for i := range values {
+		values[i] = bollinger.Update(series.Bar(i, source))
+	}
+	return values
+}
@@ -0,0 +1,53 @@
+package indicators
+
+import "math"
+
+// CCIStream is the commodity channel index: the distance of the typical price from its Period simple
+// average, in units of 0.015 mean absolute deviations. A flat window gives 0.
+type CCIStream struct {
+	Period int
+
+	sma   *SMAStream
+	value float64
+}
+
+func NewCCIStream(period int) *CCIStream {
+	return &CCIStream{Period: period, sma: NewSMAStream(period), value: math.NaN()}
+}
+
+func (cci *CCIStream) Update(bar OHLCV) float64 {
+	typical := (bar.High + bar.Low + bar.Close) / 3
+	average := cci.sma.Add(typical)
+	if !cci.sma.Ready() {
+		return cci.value
+	}
+	var deviation float64
+	cci.sma.window.each(func(value float64) {
+		deviation += math.Abs(value - average)
+	})
+	deviation /= float64(cci.Period)
+
+	cci.value = 0
+	if deviation > 0 {
+		cci.value = (typical - average) / (0.015 * deviation)
+	}
+	return cci.value
+}
+
+func (cci *CCIStream) Ready() bool    { return cci.sma.Ready() }
+func (cci *CCIStream) Value() float64 { return cci.value }
+
+func (cci *CCIStream) Reset() {
+	cci.sma.Reset()
+	cci.value = math.NaN()
+}
+
+// CCI returns the commodity channel index of the series.
+func CCI(series Series, source Source, period int) []float64 {
+	cci := NewCCIStream(period)
+	values := make([]float64, series.Len())
+	for i := range values {
+		values[i] = cci.Update(series.Bar(i, source))
+	}
+	return values
+}
@@ -0,0 +1,100 @@
+// Package indicators implements technical indicators over candle series. Every indicator comes in two
+// flavours: a batch function computing the whole series at once, and a streaming type updated one closed
+// bar at a time. Both produce the same values; batch results are aligned with the input and hold NaN
+// until the indicator has seen enough bars.
+package indicators
+
+import "math"
+
+// Source selects which side of a bid/ask candle an indicator is computed on.
+type Source string
+
+const (
+	BID Source = "BID"
+	ASK Source = "ASK"
+	MID Source = "MID"
+)
+
+// OHLCV is a single-sided bar.
+type OHLCV struct {
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Series is a sequence of bars, oldest first, able to give each bar on the requested side.
+// gominitrader.Candles implements it.
+type Series interface {
+	Len() int
+	Bar(i int, source Source) OHLCV
+}
+
+// Price returns the price of side `source` of a bid/ask quote.
+func Price(bid float64, ask float64, source Source) float64 {
+	switch source {
+	case ASK:
+		return ask
+	case MID:
+		return (bid + ask) / 2
+	}
+	return bid
+}
+
+// Closes returns the close prices of the series.
+func Closes(series Series, source Source) []float64 {
+	closes := make([]float64, series.Len())
+	for i := range closes {
+		closes[i] = series.Bar(i, source).Close
+	}
+	return closes
+}
+
+// Last returns the last value of a batch result, or NaN if it is empty.
+func Last(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	return values[len(values)-1]
+}
+
+// window keeps the last `size` values pushed into it.
+type window struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func newWindow(size int) *window {
+	return &window{values: make([]float64, size)}
+}
+
+// push adds value, returning the value it evicts once the window is full.
+func (w *window) push(value float64) (evicted float64, ok bool) {
+	evicted, ok = w.values[w.next], w.full
+	w.values[w.next] = value
+	w.next++
+	if w.next == len(w.values) {
+		w.next = 0
+		w.full = true
+	}
+	return evicted, ok
+}
+
+// each calls f with the values in the window, oldest first.
+func (w *window) each(f func(value float64)) {
+	if w.full {
+		for _, value := range w.values[w.next:] {
+			f(value)
+		}
+	}
+	for _, value := range w.values[:w.next] {
+		f(value)
+	}
+}
+
+func (w *window) reset() {
+	w.next = 0
+	w.full = false
+}
@@ -0,0 +1,213 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+const toleranceError = 1e-6
+
+// _TestSeries is a bid series; the ask side is `spread` above it.
+type _TestSeries struct {
+	bars   []OHLCV
+	spread float64
+}
+
+func (series _TestSeries) Len() int { return len(series.bars) }
+
+func (series _TestSeries) Bar(i int, source Source) OHLCV {
+	bar := series.bars[i]
+	shift := Price(0, series.spread, source)
+	bar.Open, bar.High, bar.Low, bar.Close = bar.Open+shift, bar.High+shift, bar.Low+shift, bar.Close+shift
+	return bar
+}
+
+func _TestCloses(closes ...float64) _TestSeries {
+	series := _TestSeries{}
+	for _, price := range closes {
+		series.bars = append(series.bars, OHLCV{Open: price, High: price, Low: price, Close: price})
+	}
+	return series
+}
+
+// _TestWaves is a deterministic 60 bar series; reference values were computed independently from the
+// textbook definitions of each indicator.
+func _TestWaves() _TestSeries {
+	series := _TestSeries{spread: 0.02}
+	for i := 0; i < 60; i++ {
+		price := 20 + math.Sin(float64(i)/3)*2 + float64(i)*0.05
+		series.bars = append(series.bars, OHLCV{
+			Open:   price,
+			High:   price + 0.3 + 0.1*math.Cos(float64(i)),
+			Low:    price - 0.25 - 0.1*math.Sin(float64(i)),
+			Close:  price,
+			Volume: float64(100 + i*10),
+		})
+	}
+	return series
+}
+
+func TestRSIWilderReference(t *testing.T) {
+	// reference closes and values from Wilder's RSI example published by StockCharts
+	series := _TestCloses(44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08, 45.89, 46.03, 45.61, 46.28, 46.28,
+		46.00, 46.03, 46.41, 46.22, 45.64, 46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18, 44.22, 44.57, 43.42, 42.66, 43.13)
+	expected := []float64{70.46, 66.25, 66.48, 69.35, 66.29, 57.92, 62.88, 63.21, 56.01, 62.34, 54.67, 50.39, 40.02, 41.49, 41.90, 45.50, 37.32, 33.09, 37.79}
+
+	rsi := RSI(series, BID, 14)
+	for i := 0; i < 14; i++ {
+		if !math.IsNaN(rsi[i]) {
+			t.Errorf("Test case %d: expected NaN during warm up, got %f", i, rsi[i])
+		}
+	}
+	for i, value := range expected {
+		if math.Abs(rsi[i+14]-value) > 0.005 {
+			t.Errorf("Test case %d: expected RSI %.2f, got %.4f", i, value, rsi[i+14])
+		}
+	}
+}
+
+func TestIndicatorsReference(t *testing.T) {
+	series := _TestWaves()
+	bollinger := Bollinger(series, BID, 20, 2)
+	macd := MACD(series, BID, 12, 26, 9)
+	stochastic := Stochastic(series, BID, 14, 3)
+	adx := ADX(series, BID, 14)
+
+	tests := []struct {
+		name     string
+		values   []float64
+		expected []float64 // at bars 30 and 59
+	}{
+		{"SMA", SMA(series, BID, 10), []float64{22.233215, 21.967732}},
+		{"EMA", EMA(series, BID, 10), []float64{21.578634, 22.672494}},
+		{"WMA", WMA(series, BID, 10), []float64{21.854656, 22.627188}},
+		{"Bollinger upper", []float64{bollinger[30].Upper, bollinger[59].Upper}, []float64{24.129119, 25.153918}},
+		{"Bollinger middle", []float64{bollinger[30].Middle, bollinger[59].Middle}, []float64{20.964943, 22.556764}},
+		{"Bollinger lower", []float64{bollinger[30].Lower, bollinger[59].Lower}, []float64{17.800768, 19.959610}},
+		{"ATR", ATR(series, BID, 14), []float64{0.748147, 0.785058}},
+		{"Stochastic K", []float64{stochastic[30].K, stochastic[59].K}, []float64{21.907280, 94.907562}},
+		{"Stochastic D", []float64{stochastic[30].D, stochastic[59].D}, []float64{42.450983, 85.998148}},
+		{"CCI", CCI(series, BID, 20), []float64{-24.587038, 103.201825}},
+		{"OBV", OBV(series, BID), []float64{-450, 2540}},
+		// MACD and ADX reference values are at bars 45 and 59, and 27 and 59
+		{"MACD", []float64{macd[45].MACD, macd[59].MACD}, []float64{0.814642, 0.394612}},
+		{"MACD signal", []float64{macd[45].Signal, macd[59].Signal}, []float64{0.564520, 0.173933}},
+		{"MACD histogram", []float64{macd[45].Histogram, macd[59].Histogram}, []float64{0.250123, 0.220679}},
+		{"ADX", []float64{adx[27].ADX, adx[59].ADX}, []float64{25.275736, 20.925068}},
+		{"+DI", []float64{adx[27].PlusDI, adx[59].PlusDI}, []float64{33.243950, 40.245873}},
+		{"-DI", []float64{adx[27].MinusDI, adx[59].MinusDI}, []float64{24.689881, 19.517580}},
+	}
+	for _, test := range tests {
+		values := test.values
+		if len(values) == series.Len() {
+			values = []float64{values[30], values[59]}
+		}
+		for i := range values {
+			if math.Abs(values[i]-test.expected[i]) > toleranceError {
+				t.Errorf("%s: expected %f, got %f", test.name, test.expected[i], values[i])
+			}
+		}
+	}
+
+	// warm up
+	if !math.IsNaN(SMA(series, BID, 10)[8]) || math.IsNaN(SMA(series, BID, 10)[9]) {
+		t.Error("SMA should be ready on bar 10")
+	}
+	if !math.IsNaN(ATR(series, BID, 14)[13]) || math.IsNaN(ATR(series, BID, 14)[14]) {
+		t.Error("ATR should be ready on bar 15")
+	}
+	if !math.IsNaN(adx[26].ADX) || math.IsNaN(adx[14].PlusDI) {
+		t.Error("ADX should be ready on bar 28 and its directional indicators on bar 15")
+	}
+	if !math.IsNaN(macd[32].Signal) || math.IsNaN(macd[33].Signal) || math.IsNaN(macd[25].MACD) {
+		t.Error("MACD line should be ready on bar 26 and its signal on bar 34")
+	}
+}
+
+func TestIndicatorsSource(t *testing.T) {
+	series := _TestWaves()
+	tests := []struct {
+		source        Source
+		expectedShift float64
+	}{
+		{BID, 0},
+		{ASK, 0.02},
+		{MID, 0.01},
+	}
+	bid := SMA(series, BID, 10)
+	for i, test := range tests {
+		shifted := SMA(series, test.source, 10)
+		if math.Abs(shifted[59]-bid[59]-test.expectedShift) > toleranceError {
+			t.Errorf("Test case %d: expected %s SMA %f above the bid, got %f", i, test.source, test.expectedShift, shifted[59]-bid[59])
+		}
+		// oscillators do not depend on the side of a constant spread
+		if math.Abs(RSI(series, test.source, 14)[59]-RSI(series, BID, 14)[59]) > toleranceError {
+			t.Errorf("Test case %d: RSI should not depend on a constant spread", i)
+		}
+	}
+}
+
+func TestStreamingMatchesBatch(t *testing.T) {
+	series := _TestWaves()
+	sma, ema, wma, rsi := NewSMAStream(10), NewEMAStream(10), NewWMAStream(10), NewRSIStream(14)
+	atr, cci, obv := NewATRStream(14), NewCCIStream(20), NewOBVStream()
+	bollinger, macd := NewBollingerStream(20, 2), NewMACDStream(12, 26, 9)
+	stochastic, adx := NewStochasticStream(14, 3), NewADXStream(14)
+
+	batch := map[string][]float64{
+		"SMA": SMA(series, MID, 10), "EMA": EMA(series, MID, 10), "WMA": WMA(series, MID, 10), "RSI": RSI(series, MID, 14),
+		"ATR": ATR(series, MID, 14), "CCI": CCI(series, MID, 20), "OBV": OBV(series, MID),
+	}
+	bollingerBatch, macdBatch := Bollinger(series, MID, 20, 2), MACD(series, MID, 12, 26, 9)
+	stochasticBatch, adxBatch := Stochastic(series, MID, 14, 3), ADX(series, MID, 14)
+
+	// the second round checks that Reset starts over
+	for round := 0; round < 2; round++ {
+		for i := 0; i < series.Len(); i++ {
+			bar := series.Bar(i, MID)
+			streamed := map[string]float64{
+				"SMA": sma.Update(bar), "EMA": ema.Update(bar), "WMA": wma.Update(bar), "RSI": rsi.Update(bar),
+				"ATR": atr.Update(bar), "CCI": cci.Update(bar), "OBV": obv.Update(bar),
+			}
+			for name, value := range streamed {
+				if !sameValue(value, batch[name][i]) {
+					t.Fatalf("Round %d: %s differs on bar %d: %f != %f", round, name, i, value, batch[name][i])
+				}
+			}
+			if value := bollinger.Update(bar); !sameValue(value.Upper, bollingerBatch[i].Upper) || !sameValue(value.Lower, bollingerBatch[i].Lower) {
+				t.Fatalf("Round %d: Bollinger differs on bar %d", round, i)
+			}
+			if value := macd.Update(bar); !sameValue(value.Signal, macdBatch[i].Signal) || !sameValue(value.MACD, macdBatch[i].MACD) {
+				t.Fatalf("Round %d: MACD differs on bar %d", round, i)
+			}
+			if value := stochastic.Update(bar); !sameValue(value.K, stochasticBatch[i].K) || !sameValue(value.D, stochasticBatch[i].D) {
+				t.Fatalf("Round %d: Stochastic differs on bar %d", round, i)
+			}
+			if value := adx.Update(bar); !sameValue(value.ADX, adxBatch[i].ADX) || !sameValue(value.PlusDI, adxBatch[i].PlusDI) {
+				t.Fatalf("Round %d: ADX differs on bar %d", round, i)
+			}
+		}
+		if !sma.Ready() || !ema.Ready() || !wma.Ready() || !rsi.Ready() || !atr.Ready() || !cci.Ready() || !obv.Ready() ||
+			!bollinger.Ready() || !macd.Ready() || !stochastic.Ready() || !adx.Ready() {
+			t.Errorf("Round %d: every indicator should be ready", round)
+		}
+		sma.Reset()
+		ema.Reset()
+		wma.Reset()
+		rsi.Reset()
+		atr.Reset()
+		cci.Reset()
+		obv.Reset()
+		bollinger.Reset()
+		macd.Reset()
+		stochastic.Reset()
+		adx.Reset()
+	}
+}
+
+func sameValue(a float64, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return a == b
+}
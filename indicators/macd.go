@@ -0,0 +1,60 @@
+package indicators
+
+import "math"
+
+type MACDValue struct {
+	MACD      float64
+	Signal    float64
+	Histogram float64
+}
+
+// MACDStream is the difference between a fast and a slow EMA of the closes, with an EMA of that
+// difference as signal line. The MACD line starts once the slow EMA is ready.
+type MACDStream struct {
+	Fast         int
+	Slow         int
+	SignalPeriod int
+
+	fast   *EMAStream
+	slow   *EMAStream
+	signal *EMAStream
+	value  MACDValue
+}
+
+func NewMACDStream(fast int, slow int, signal int) *MACDStream {
+	macd := &MACDStream{Fast: fast, Slow: slow, SignalPeriod: signal}
+	macd.Reset()
+	return macd
+}
+
+func (macd *MACDStream) Update(bar OHLCV) MACDValue {
+	fast := macd.fast.Add(bar.Close)
+	slow := macd.slow.Add(bar.Close)
+	if !macd.fast.Ready() || !macd.slow.Ready() {
+		return macd.value
+	}
+	macd.value.MACD = fast - slow
+	macd.value.Signal = macd.signal.Add(macd.value.MACD)
+	macd.value.Histogram = macd.value.MACD - macd.value.Signal
+	return macd.value
+}
+
+func (macd *MACDStream) Ready() bool      { return macd.signal.Ready() }
+func (macd *MACDStream) Value() MACDValue { return macd.value }
+
+func (macd *MACDStream) Reset() {
+	macd.fast = NewEMAStream(macd.Fast)
+	macd.slow = NewEMAStream(macd.Slow)
+	macd.signal = NewEMAStream(macd.SignalPeriod)
+	macd.value = MACDValue{MACD: math.NaN(), Signal: math.NaN(), Histogram: math.NaN()}
+}
+
+// MACD returns the MACD line, signal line and histogram of the closes of the series.
+func MACD(series Series, source Source, fast int, slow int, signal int) []MACDValue {
+	macd := NewMACDStream(fast, slow, signal)
+	values := make([]MACDValue, series.Len())
+	for i := range values {
+		values[i] = macd.Update(series.Bar(i, source))
+	}
+	return values
+}
@@ -0,0 +1,147 @@
+package indicators
+
+import "math"
+
+// SMAStream is the simple moving average of the last Period values.
+type SMAStream struct {
+	Period int
+
+	window *window
+	sum    float64
+	value  float64
+}
+
+func NewSMAStream(period int) *SMAStream {
+	return &SMAStream{Period: period, window: newWindow(period), value: math.NaN()}
+}
+
+func (sma *SMAStream) Update(bar OHLCV) float64 {
+	return sma.Add(bar.Close)
+}
+
+// Add updates the average with a raw value instead of a bar close.
+func (sma *SMAStream) Add(value float64) float64 {
+	evicted, ok := sma.window.push(value)
+	sma.sum += value
+	if ok {
+		sma.sum -= evicted
+	}
+	if sma.window.full {
+		sma.value = sma.sum / float64(sma.Period)
+	}
+	return sma.value
+}
+
+func (sma *SMAStream) Ready() bool    { return sma.window.full }
+func (sma *SMAStream) Value() float64 { return sma.value }
+
+func (sma *SMAStream) Reset() {
+	sma.window.reset()
+	sma.sum = 0
+	sma.value = math.NaN()
+}
+
+// EMAStream is the exponential moving average of Period values, seeded with the simple average of the
+// first Period values.
+type EMAStream struct {
+	Period int
+
+	count int
+	sum   float64
+	value float64
+}
+
+func NewEMAStream(period int) *EMAStream {
+	return &EMAStream{Period: period, value: math.NaN()}
+}
+
+func (ema *EMAStream) Update(bar OHLCV) float64 {
+	return ema.Add(bar.Close)
+}
+
+// Add updates the average with a raw value instead of a bar close.
+func (ema *EMAStream) Add(value float64) float64 {
+	ema.count++
+	switch {
+	case ema.count < ema.Period:
+		ema.sum += value
+	case ema.count == ema.Period:
+		ema.value = (ema.sum + value) / float64(ema.Period)
+	default:
+		alpha := 2 / float64(ema.Period+1)
+		ema.value += alpha * (value - ema.value)
+	}
+	return ema.value
+}
+
+func (ema *EMAStream) Ready() bool    { return ema.count >= ema.Period }
+func (ema *EMAStream) Value() float64 { return ema.value }
+
+func (ema *EMAStream) Reset() {
+	ema.count = 0
+	ema.sum = 0
+	ema.value = math.NaN()
+}
+
+// WMAStream is the linearly weighted moving average of the last Period values; the newest value weighs Period.
+type WMAStream struct {
+	Period int
+
+	window *window
+	value  float64
+}
+
+func NewWMAStream(period int) *WMAStream {
+	return &WMAStream{Period: period, window: newWindow(period), value: math.NaN()}
+}
+
+func (wma *WMAStream) Update(bar OHLCV) float64 {
+	return wma.Add(bar.Close)
+}
+
+// Add updates the average with a raw value instead of a bar close.
+func (wma *WMAStream) Add(value float64) float64 {
+	wma.window.push(value)
+	if !wma.window.full {
+		return wma.value
+	}
+	var weighted float64
+	weight := 0.0
+	wma.window.each(func(value float64) {
+		weight++
+		weighted += weight * value
+	})
+	wma.value = weighted / (weight * (weight + 1) / 2)
+	return wma.value
+}
+
+func (wma *WMAStream) Ready() bool    { return wma.window.full }
+func (wma *WMAStream) Value() float64 { return wma.value }
+
+func (wma *WMAStream) Reset() {
+	wma.window.reset()
+	wma.value = math.NaN()
+}
+
+// SMA is the simple moving average of the closes of the series.
+func SMA(series Series, source Source, period int) []float64 {
+	return closesThrough(series, source, NewSMAStream(period).Add)
+}
+
+// EMA is the exponential moving average of the closes of the series.
+func EMA(series Series, source Source, period int) []float64 {
+	return closesThrough(series, source, NewEMAStream(period).Add)
+}
+
+// WMA is the weighted moving average of the closes of the series.
+func WMA(series Series, source Source, period int) []float64 {
+	return closesThrough(series, source, NewWMAStream(period).Add)
+}
+
+func closesThrough(series Series, source Source, add func(value float64) float64) []float64 {
+	values := make([]float64, series.Len())
+	for i := range values {
+		values[i] = add(series.Bar(i, source).Close)
+	}
+	return values
+}
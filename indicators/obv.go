@@ -0,0 +1,44 @@
+package indicators
+
+// OBVStream is the on-balance volume: the running sum of the volume of up bars minus the volume of
+// down bars, starting at 0 on the first bar.
+type OBVStream struct {
+	count    int
+	previous float64
+	value    float64
+}
+
+func NewOBVStream() *OBVStream {
+	return &OBVStream{}
+}
+
+func (obv *OBVStream) Update(bar OHLCV) float64 {
+	obv.count++
+	if obv.count > 1 {
+		switch {
+		case bar.Close > obv.previous:
+			obv.value += bar.Volume
+		case bar.Close < obv.previous:
+			obv.value -= bar.Volume
+		}
+	}
+	obv.previous = bar.Close
+	return obv.value
+}
+
+func (obv *OBVStream) Ready() bool    { return obv.count > 0 }
+func (obv *OBVStream) Value() float64 { return obv.value }
+
+func (obv *OBVStream) Reset() {
+	*obv = OBVStream{}
+}
+
+// OBV returns the on-balance volume of the series.
+func OBV(series Series, source Source) []float64 {
+	obv := NewOBVStream()
+	values := make([]float64, series.Len())
+	for i := range values {
+		values[i] = obv.Update(series.Bar(i, source))
+	}
+	return values
+}
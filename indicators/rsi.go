@@ -0,0 +1,71 @@
+package indicators
+
+import "math"
+
+// RSIStream is Wilder's relative strength index: the average gain and loss of the first Period changes
+// are simple averages, and later ones are smoothed with 1/Period.
+type RSIStream struct {
+	Period int
+
+	count     int
+	previous  float64
+	averageUp float64
+	averageDn float64
+	value     float64
+}
+
+func NewRSIStream(period int) *RSIStream {
+	return &RSIStream{Period: period, value: math.NaN()}
+}
+
+func (rsi *RSIStream) Update(bar OHLCV) float64 {
+	return rsi.Add(bar.Close)
+}
+
+// Add updates the index with a raw value instead of a bar close.
+func (rsi *RSIStream) Add(value float64) float64 {
+	rsi.count++
+	if rsi.count == 1 {
+		rsi.previous = value
+		return rsi.value
+	}
+	change := value - rsi.previous
+	rsi.previous = value
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+	period := float64(rsi.Period)
+	switch {
+	case rsi.count <= rsi.Period:
+		rsi.averageUp += gain
+		rsi.averageDn += loss
+		return rsi.value
+	case rsi.count == rsi.Period+1:
+		rsi.averageUp = (rsi.averageUp + gain) / period
+		rsi.averageDn = (rsi.averageDn + loss) / period
+	default:
+		rsi.averageUp = (rsi.averageUp*(period-1) + gain) / period
+		rsi.averageDn = (rsi.averageDn*(period-1) + loss) / period
+	}
+
+	switch {
+	case rsi.averageDn == 0 && rsi.averageUp == 0:
+		rsi.value = 50
+	case rsi.averageDn == 0:
+		rsi.value = 100
+	default:
+		rsi.value = 100 - 100/(1+rsi.averageUp/rsi.averageDn)
+	}
+	return rsi.value
+}
+
+func (rsi *RSIStream) Ready() bool    { return rsi.count > rsi.Period }
+func (rsi *RSIStream) Value() float64 { return rsi.value }
+
+func (rsi *RSIStream) Reset() {
+	*rsi = *NewRSIStream(rsi.Period)
+}
+
+// RSI is Wilder's relative strength index of the closes of the series.
+func RSI(series Series, source Source, period int) []float64 {
+	return closesThrough(series, source, NewRSIStream(period).Add)
+}
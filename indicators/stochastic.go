@@ -0,0 +1,64 @@
+package indicators
+
+import "math"
+
+type StochasticValue struct {
+	K float64
+	D float64
+}
+
+// StochasticStream is the fast stochastic oscillator: %K places the close within the high-low range of
+// the last KPeriod bars, and %D is the simple average of the last DPeriod %K values. A flat range gives 50.
+type StochasticStream struct {
+	KPeriod int
+	DPeriod int
+
+	highs *window
+	lows  *window
+	d     *SMAStream
+	value StochasticValue
+}
+
+func NewStochasticStream(kPeriod int, dPeriod int) *StochasticStream {
+	stochastic := &StochasticStream{KPeriod: kPeriod, DPeriod: dPeriod, highs: newWindow(kPeriod), lows: newWindow(kPeriod), d: NewSMAStream(dPeriod)}
+	stochastic.Reset()
+	return stochastic
+}
+
+func (stochastic *StochasticStream) Update(bar OHLCV) StochasticValue {
+	stochastic.highs.push(bar.High)
+	stochastic.lows.push(bar.Low)
+	if !stochastic.highs.full {
+		return stochastic.value
+	}
+	highest, lowest := math.Inf(-1), math.Inf(1)
+	stochastic.highs.each(func(value float64) { highest = math.Max(highest, value) })
+	stochastic.lows.each(func(value float64) { lowest = math.Min(lowest, value) })
+
+	stochastic.value.K = 50
+	if highest > lowest {
+		stochastic.value.K = 100 * (bar.Close - lowest) / (highest - lowest)
+	}
+	stochastic.value.D = stochastic.d.Add(stochastic.value.K)
+	return stochastic.value
+}
+
+func (stochastic *StochasticStream) Ready() bool            { return stochastic.d.Ready() }
+func (stochastic *StochasticStream) Value() StochasticValue { return stochastic.value }
+
+func (stochastic *StochasticStream) Reset() {
+	stochastic.highs.reset()
+	stochastic.lows.reset()
+	stochastic.d.Reset()
+	stochastic.value = StochasticValue{K: math.NaN(), D: math.NaN()}
+}
+
+// Stochastic returns the fast stochastic oscillator of the series.
+func Stochastic(series Series, source Source, kPeriod int, dPeriod int) []StochasticValue {
+	stochastic := NewStochasticStream(kPeriod, dPeriod)
+	values := make([]StochasticValue, series.Len())
+	for i := range values {
+		values[i] = stochastic.Update(series.Bar(i, source))
+	}
+	return values
+}
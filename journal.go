@@ -0,0 +1,345 @@
+package gominitrader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type JournalEntryType string
+
+const (
+	JOURNAL_SIGNAL          JournalEntryType = "SIGNAL"
+	JOURNAL_ORDER_SUBMITTED JournalEntryType = "ORDER_SUBMITTED"
+	JOURNAL_ORDER_CONFIRMED JournalEntryType = "ORDER_CONFIRMED"
+	JOURNAL_ORDER_REJECTED  JournalEntryType = "ORDER_REJECTED"
+	JOURNAL_ORDER_DELETED   JournalEntryType = "ORDER_DELETED"
+	JOURNAL_POSITION_OPENED JournalEntryType = "POSITION_OPENED"
+	JOURNAL_ROUND_TRIP      JournalEntryType = "ROUND_TRIP"     // a position closed, with its realized P&L
+	JOURNAL_TRADE_RESTORED  JournalEntryType = "TRADE_RESTORED" // a trade found on the broker on startup
+)
+
+// JournalEntry is a decision or an execution of a minitrader. Level is the signal price, the order level,
+// the fill level or the exit level, depending on Type.
+type JournalEntry struct {
+	Time            time.Time        `json:"time"`
+	Type            JournalEntryType `json:"type"`
+	Epic            string           `json:"epic"`
+	Timeframe       Timeframe        `json:"timeframe"`
+	Strategy        string           `json:"strategy,omitempty"`
+	Signal          Signal           `json:"signal,omitempty"`
+	CandleTimestamp int64            `json:"candleTimestamp,omitempty"`
+	DealReference   string           `json:"dealReference,omitempty"`
+	DealID          string           `json:"dealId,omitempty"`
+	EntryType       EntryType        `json:"entryType,omitempty"`
+	Direction       Signal           `json:"direction,omitempty"`
+	Status          string           `json:"status,omitempty"`
+	Reason          string           `json:"reason,omitempty"`
+	EntryLevel      float64          `json:"entryLevel,omitempty"`
+	Level           float64          `json:"level,omitempty"`
+	Size            float64          `json:"size,omitempty"`
+	StopLevel       float64          `json:"stopLevel,omitempty"`
+	ProfitLevel     float64          `json:"profitLevel,omitempty"`
+	ProfitLoss      float64          `json:"profitLoss,omitempty"`
+	ClosedByBroker  bool             `json:"closedByBroker,omitempty"`
+}
+
+// JournalFilter selects journal entries; empty fields select everything.
+type JournalFilter struct {
+	Epic     string
+	Strategy string
+	Types    []JournalEntryType
+	From     time.Time // inclusive
+	To       time.Time // exclusive
+}
+
+// Journal is an append-only record of what the minitraders of a pool decided and executed, so it can be
+// reviewed after the bot exits.
+type Journal interface {
+	Append(entry JournalEntry) error
+	// Entries returns the entries selected by filter, oldest first.
+	Entries(filter JournalFilter) ([]JournalEntry, error)
+	Close() error
+}
+
+// JournalStats summarizes the entries of a journal. Only round trips count towards the P&L.
+type JournalStats struct {
+	Signals         int
+	OrdersSubmitted int
+	OrdersConfirmed int
+	OrdersRejected  int
+	OrdersDeleted   int
+	RoundTrips      int
+	Wins            int
+	Losses          int
+	ProfitLoss      float64
+	GrossProfit     float64
+	GrossLoss       float64 // negative
+	MaxDrawdown     float64 // largest fall of the cumulative P&L from a previous high
+}
+
+// SetJournal sets the journal the minitraders record their signals, orders and round trips to. It must be
+// set before starting the pool.
+func (pool *MinitraderPool) SetJournal(journal Journal) {
+	pool.layoutMutex.Lock()
+	defer pool.layoutMutex.Unlock()
+	pool.journal = journal
+	for _, minitrader := range pool.Minitraders {
+		minitrader.journal = journal
+	}
+}
+
+// journalEvent appends the entry of event to the journal of the minitrader, if any. A signal is only
+// journaled the first time it is generated for a candle, and NONE never is.
+func (minitrader *Minitrader) journalEvent(event Event) {
+	if minitrader.journal == nil {
+		return
+	}
+	if signal, ok := event.(SignalGenerated); ok {
+		if signal.Signal == NONE || (signal.Signal == minitrader.journaledSignal && signal.CandleTimestamp == minitrader.journaledCandle) {
+			return
+		}
+		minitrader.journaledSignal, minitrader.journaledCandle = signal.Signal, signal.CandleTimestamp
+	}
+	entry, ok := newJournalEntry(event)
+	if !ok {
+		return
+	}
+	entry.Strategy = minitrader.strategyName()
+	if err := minitrader.journal.Append(entry); err != nil {
+		minitrader.logger().Error("unable to journal entry", append(errorAttrs(err), "type", entry.Type)...)
+	}
+}
+
+// newJournalEntry returns the entry of event, if it is journaled.
+func newJournalEntry(event Event) (JournalEntry, bool) {
+	info := event.Info()
+	entry := JournalEntry{Time: info.Time, Epic: info.Epic, Timeframe: info.Timeframe}
+	switch event := event.(type) {
+	case SignalGenerated:
+		entry.Type = JOURNAL_SIGNAL
+		entry.Signal, entry.Level, entry.CandleTimestamp = event.Signal, event.Price, event.CandleTimestamp
+	case OrderSubmitted:
+		entry.Type = JOURNAL_ORDER_SUBMITTED
+		entry.DealReference, entry.EntryType, entry.Direction = event.DealReference, event.EntryType, event.Direction
+		entry.Level, entry.Size, entry.StopLevel, entry.ProfitLevel = event.Level, event.Size, event.StopLevel, event.ProfitLevel
+	case OrderConfirmed:
+		entry.Type = JOURNAL_ORDER_CONFIRMED
+		entry.DealReference, entry.DealID, entry.Status, entry.Reason = event.DealReference, event.DealID, event.Status, event.Reason
+		entry.Level, entry.Size = event.Level, event.Size
+	case OrderRejected:
+		entry.Type = JOURNAL_ORDER_REJECTED
+		entry.DealReference, entry.Status, entry.Reason = event.DealReference, string(REJECTED), event.Reason
+	case OrderDeleted:
+		entry.Type = JOURNAL_ORDER_DELETED
+		entry.DealID = event.DealID
+	case PositionOpened:
+		entry.Type = JOURNAL_POSITION_OPENED
+		entry.DealID, entry.Level, entry.Size = event.DealID, event.Level, event.Size
+	case PositionClosed:
+		entry.Type = JOURNAL_ROUND_TRIP
+		entry.DealID, entry.Reason, entry.ClosedByBroker = event.DealID, event.Reason, event.ClosedByBroker
+		entry.EntryLevel, entry.Level, entry.Size, entry.ProfitLoss = event.EntryLevel, event.Level, event.Size, event.ProfitLoss
+	case TradeRestored:
+		entry.Type = JOURNAL_TRADE_RESTORED
+		entry.DealReference, entry.DealID, entry.Level, entry.Size = event.DealReference, event.DealID, event.Level, event.Size
+	default:
+		return entry, false
+	}
+	return entry, true
+}
+
+func (filter JournalFilter) matches(entry JournalEntry) bool {
+	if filter.Epic != "" && entry.Epic != filter.Epic {
+		return false
+	}
+	if filter.Strategy != "" && entry.Strategy != filter.Strategy {
+		return false
+	}
+	if !filter.From.IsZero() && entry.Time.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !entry.Time.Before(filter.To) {
+		return false
+	}
+	if len(filter.Types) == 0 {
+		return true
+	}
+	for _, entryType := range filter.Types {
+		if entry.Type == entryType {
+			return true
+		}
+	}
+	return false
+}
+
+// NewJournalStats summarizes entries, oldest first.
+func NewJournalStats(entries []JournalEntry) JournalStats {
+	stats := JournalStats{}
+	var high float64
+	for _, entry := range entries {
+		switch entry.Type {
+		case JOURNAL_SIGNAL:
+			stats.Signals++
+		case JOURNAL_ORDER_SUBMITTED:
+			stats.OrdersSubmitted++
+		case JOURNAL_ORDER_CONFIRMED:
+			stats.OrdersConfirmed++
+		case JOURNAL_ORDER_REJECTED:
+			stats.OrdersRejected++
+		case JOURNAL_ORDER_DELETED:
+			stats.OrdersDeleted++
+		case JOURNAL_ROUND_TRIP:
+			stats.RoundTrips++
+			stats.ProfitLoss += entry.ProfitLoss
+			if entry.ProfitLoss > 0 {
+				stats.Wins++
+				stats.GrossProfit += entry.ProfitLoss
+			} else {
+				stats.Losses++
+				stats.GrossLoss += entry.ProfitLoss
+			}
+			if stats.ProfitLoss > high {
+				high = stats.ProfitLoss
+			}
+			if drawdown := high - stats.ProfitLoss; drawdown > stats.MaxDrawdown {
+				stats.MaxDrawdown = drawdown
+			}
+		}
+	}
+	return stats
+}
+
+// WinRate is the share of round trips with a profit, from 0 to 1.
+func (stats JournalStats) WinRate() float64 {
+	if stats.RoundTrips == 0 {
+		return 0
+	}
+	return float64(stats.Wins) / float64(stats.RoundTrips)
+}
+
+// ProfitFactor is the gross profit over the gross loss; 0 without losses.
+func (stats JournalStats) ProfitFactor() float64 {
+	if stats.GrossLoss == 0 {
+		return 0
+	}
+	return stats.GrossProfit / -stats.GrossLoss
+}
+
+// JournalStatsByEpic returns the stats of the entries selected by filter, by epic.
+func JournalStatsByEpic(journal Journal, filter JournalFilter) (map[string]JournalStats, error) {
+	return journalStatsBy(journal, filter, func(entry JournalEntry) string { return entry.Epic })
+}
+
+// JournalStatsByStrategy returns the stats of the entries selected by filter, by strategy.
+func JournalStatsByStrategy(journal Journal, filter JournalFilter) (map[string]JournalStats, error) {
+	return journalStatsBy(journal, filter, func(entry JournalEntry) string { return entry.Strategy })
+}
+
+func journalStatsBy(journal Journal, filter JournalFilter, key func(JournalEntry) string) (map[string]JournalStats, error) {
+	entries, err := journal.Entries(filter)
+	if err != nil {
+		return nil, err
+	}
+	grouped := make(map[string][]JournalEntry)
+	for _, entry := range entries {
+		grouped[key(entry)] = append(grouped[key(entry)], entry)
+	}
+	stats := make(map[string]JournalStats)
+	for group, groupEntries := range grouped {
+		stats[group] = NewJournalStats(groupEntries)
+	}
+	return stats, nil
+}
+
+// JSONLinesJournal appends every entry as a line of JSON to a file, synced before Append returns. A line
+// torn by a crash is truncated away on open.
+type JSONLinesJournal struct {
+	Path string
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func OpenJSONLinesJournal(path string) (*JSONLinesJournal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// keep the complete lines only
+	size := int64(bytes.LastIndexByte(data, '\n') + 1)
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &JSONLinesJournal{Path: path, file: file}, nil
+}
+
+func (journal *JSONLinesJournal) Append(entry JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if journal.file == nil {
+		return errors.New("JSONLinesJournal is closed")
+	}
+	if _, err := journal.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return journal.file.Sync()
+}
+
+func (journal *JSONLinesJournal) Entries(filter JournalFilter) ([]JournalEntry, error) {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	file, err := os.Open(journal.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []JournalEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := JournalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid journal entry on line %d of %s - %s", line, journal.Path, err))
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func (journal *JSONLinesJournal) Close() error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if journal.file == nil {
+		return nil
+	}
+	err := journal.file.Close()
+	journal.file = nil
+	return err
+}
@@ -0,0 +1,198 @@
+package gominitrader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// KV_JOURNAL_MAGIC starts every file written by KVJournal.
+const KV_JOURNAL_MAGIC = "GMJRNL01"
+
+// kvJournalMaxRecord bounds the payload of a record, so a corrupted length is not allocated.
+const kvJournalMaxRecord = 1 << 20
+
+// KVJournal keeps the journal in an append-only file of checksummed records, each holding an entry as
+// JSON. The entries are indexed by epic and strategy in memory on open, so the query helpers only read
+// the records they need, and a record torn by a crash is truncated away.
+type KVJournal struct {
+	Path string
+
+	mutex      sync.Mutex
+	file       *os.File
+	size       int64
+	offsets    []int64            // offset of every record, in the order they were appended
+	byEpic     map[string][]int64 // offsets of the records of an epic
+	byStrategy map[string][]int64 // offsets of the records of a strategy
+}
+
+func OpenKVJournal(path string) (*KVJournal, error) {
+	journal := &KVJournal{Path: path}
+	if err := journal.open(); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+func (journal *KVJournal) Append(entry JournalEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if journal.file == nil {
+		return errors.New("KVJournal is closed")
+	}
+
+	if _, err := journal.file.WriteAt(encodeKVJournalRecord(payload), journal.size); err != nil {
+		// drop whatever was partially written
+		journal.file.Truncate(journal.size)
+		return err
+	}
+	if err := journal.file.Sync(); err != nil {
+		return err
+	}
+	journal.index(entry, journal.size)
+	journal.size += int64(8 + len(payload))
+	return nil
+}
+
+func (journal *KVJournal) Entries(filter JournalFilter) ([]JournalEntry, error) {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if journal.file == nil {
+		return nil, errors.New("KVJournal is closed")
+	}
+	offsets := journal.offsets
+	if filter.Epic != "" {
+		offsets = journal.byEpic[filter.Epic]
+	}
+	if filter.Strategy != "" && (filter.Epic == "" || len(journal.byStrategy[filter.Strategy]) < len(offsets)) {
+		offsets = journal.byStrategy[filter.Strategy]
+	}
+
+	entries := []JournalEntry{}
+	for _, offset := range offsets {
+		entry, err := journal.read(offset)
+		if err != nil {
+			return nil, err
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (journal *KVJournal) Close() error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if journal.file == nil {
+		return nil
+	}
+	journal.file.Sync()
+	err := journal.file.Close()
+	journal.file = nil
+	return err
+}
+
+// open opens or creates the file and rebuilds the indexes by replaying its records.
+func (journal *KVJournal) open() error {
+	file, err := os.OpenFile(journal.Path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	journal.file = file
+	journal.offsets = nil
+	journal.byEpic = make(map[string][]int64)
+	journal.byStrategy = make(map[string][]int64)
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(KV_JOURNAL_MAGIC))
+	n, err := io.ReadFull(reader, magic)
+	if n == 0 && err == io.EOF {
+		if _, err := file.WriteAt([]byte(KV_JOURNAL_MAGIC), 0); err != nil {
+			file.Close()
+			return err
+		}
+		journal.size = int64(len(KV_JOURNAL_MAGIC))
+		return nil
+	}
+	if err != nil || string(magic) != KV_JOURNAL_MAGIC {
+		file.Close()
+		return errors.New(fmt.Sprintf("%s is not a KVJournal file", journal.Path))
+	}
+
+	offset := int64(len(KV_JOURNAL_MAGIC))
+	for {
+		payload, err := readKVJournalRecord(reader)
+		if err != nil {
+			// io.EOF ends the file; anything else is a torn or corrupted tail
+			break
+		}
+		entry := JournalEntry{}
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			break
+		}
+		journal.index(entry, offset)
+		offset += int64(8 + len(payload))
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return err
+	}
+	journal.size = offset
+	return nil
+}
+
+func (journal *KVJournal) index(entry JournalEntry, offset int64) {
+	journal.offsets = append(journal.offsets, offset)
+	journal.byEpic[entry.Epic] = append(journal.byEpic[entry.Epic], offset)
+	journal.byStrategy[entry.Strategy] = append(journal.byStrategy[entry.Strategy], offset)
+}
+
+func (journal *KVJournal) read(offset int64) (JournalEntry, error) {
+	entry := JournalEntry{}
+	payload, err := readKVJournalRecord(io.NewSectionReader(journal.file, offset, journal.size-offset))
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(payload, &entry)
+	return entry, err
+}
+
+// encodeKVJournalRecord encodes crc32 | payload length | payload, little endian. The checksum covers the
+// payload.
+func encodeKVJournalRecord(payload []byte) []byte {
+	record := make([]byte, 8+len(payload))
+	binary.LittleEndian.PutUint32(record, crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(record[4:], uint32(len(payload)))
+	copy(record[8:], payload)
+	return record
+}
+
+func readKVJournalRecord(reader io.Reader) ([]byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[4:])
+	if length > kvJournalMaxRecord {
+		return nil, errors.New("KVJournal record too large")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header) {
+		return nil, errors.New("KVJournal record checksum mismatch")
+	}
+	return payload, nil
+}
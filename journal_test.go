@@ -0,0 +1,233 @@
+package gominitrader
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func _TestJournalEntries() []JournalEntry {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []JournalEntry{
+		{Time: start, Type: JOURNAL_SIGNAL, Epic: "USDMXN", Timeframe: MINUTE_15, Strategy: "GPTStrategy", Signal: BUY, Level: 19.5, CandleTimestamp: 1709294400},
+		{Time: start.Add(time.Second), Type: JOURNAL_ORDER_SUBMITTED, Epic: "USDMXN", Timeframe: MINUTE_15, Strategy: "GPTStrategy", DealReference: "o_1", EntryType: MARKET_ENTRY, Direction: BUY, Size: 10},
+		{Time: start.Add(2 * time.Second), Type: JOURNAL_ORDER_CONFIRMED, Epic: "USDMXN", Timeframe: MINUTE_15, Strategy: "GPTStrategy", DealReference: "o_1", DealID: "p_1", Status: "ACCEPTED", Level: 19.5, Size: 10},
+		{Time: start.Add(time.Hour), Type: JOURNAL_ROUND_TRIP, Epic: "USDMXN", Timeframe: MINUTE_15, Strategy: "GPTStrategy", DealID: "p_1", EntryLevel: 19.5, Level: 19.7, Size: 10, ProfitLoss: 2},
+		{Time: start.Add(2 * time.Hour), Type: JOURNAL_ROUND_TRIP, Epic: "USDJPY", Timeframe: MINUTE_15, Strategy: "GPTShortTermStrategy", DealID: "p_2", EntryLevel: 150, Level: 149, Size: 1, ProfitLoss: -1, ClosedByBroker: true},
+		{Time: start.Add(3 * time.Hour), Type: JOURNAL_ORDER_REJECTED, Epic: "USDJPY", Timeframe: MINUTE_15, Strategy: "GPTShortTermStrategy", DealReference: "o_3", Status: "REJECTED", Reason: "INSUFFICIENT_FUNDS"},
+	}
+}
+
+func TestJournals(t *testing.T) {
+	directory := t.TempDir()
+	openJournals := []func() (Journal, error){
+		func() (Journal, error) { return OpenJSONLinesJournal(filepath.Join(directory, "journal.jsonl")) },
+		func() (Journal, error) { return OpenKVJournal(filepath.Join(directory, "journal.kv")) },
+	}
+	entries := _TestJournalEntries()
+	start := entries[0].Time
+
+	for i, openJournal := range openJournals {
+		journal, err := openJournal()
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		for _, entry := range entries {
+			if err := journal.Append(entry); err != nil {
+				t.Fatalf("Test case %d: %s", i, err)
+			}
+		}
+		journal.Close()
+
+		// entries survive reopening
+		journal, err = openJournal()
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		filters := []struct {
+			filter        JournalFilter
+			expectedDeals []string
+		}{
+			{JournalFilter{}, []string{"", "", "p_1", "p_1", "p_2", ""}},
+			{JournalFilter{Epic: "USDJPY"}, []string{"p_2", ""}},
+			{JournalFilter{Strategy: "GPTStrategy", Types: []JournalEntryType{JOURNAL_ROUND_TRIP}}, []string{"p_1"}},
+			{JournalFilter{Epic: "USDMXN", Strategy: "GPTShortTermStrategy"}, []string{}},
+			{JournalFilter{From: start.Add(time.Hour), To: start.Add(3 * time.Hour)}, []string{"p_1", "p_2"}},
+		}
+		for j, test := range filters {
+			selected, err := journal.Entries(test.filter)
+			if err != nil {
+				t.Fatalf("Test case %d.%d: %s", i, j, err)
+			}
+			if len(selected) != len(test.expectedDeals) {
+				t.Errorf("Test case %d.%d: expected %d entries, got %d", i, j, len(test.expectedDeals), len(selected))
+				continue
+			}
+			for k, entry := range selected {
+				if entry.DealID != test.expectedDeals[k] {
+					t.Errorf("Test case %d.%d: expected deal %q, got %q", i, j, test.expectedDeals[k], entry.DealID)
+				}
+			}
+		}
+		all, _ := journal.Entries(JournalFilter{})
+		if len(all) == len(entries) && (all[4] != entries[4] || !all[0].Time.Equal(entries[0].Time)) {
+			t.Errorf("Test case %d: expected %+v, got %+v", i, entries[4], all[4])
+		}
+		journal.Close()
+	}
+}
+
+func TestJournalRecovery(t *testing.T) {
+	directory := t.TempDir()
+	tests := []struct {
+		path        string
+		openJournal func(path string) (Journal, error)
+	}{
+		{filepath.Join(directory, "journal.jsonl"), func(path string) (Journal, error) { return OpenJSONLinesJournal(path) }},
+		{filepath.Join(directory, "journal.kv"), func(path string) (Journal, error) { return OpenKVJournal(path) }},
+	}
+	entries := _TestJournalEntries()
+
+	for i, test := range tests {
+		journal, err := test.openJournal(test.path)
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		journal.Append(entries[0])
+		journal.Append(entries[1])
+		journal.Close()
+
+		// a crash tears the last record
+		info, _ := os.Stat(test.path)
+		os.Truncate(test.path, info.Size()-5)
+
+		journal, err = test.openJournal(test.path)
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		if err := journal.Append(entries[2]); err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		recovered, err := journal.Entries(JournalFilter{})
+		if err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		if len(recovered) != 2 || recovered[0].Type != JOURNAL_SIGNAL || recovered[1].Type != JOURNAL_ORDER_CONFIRMED {
+			t.Errorf("Test case %d: unexpected recovered entries %+v", i, recovered)
+		}
+		journal.Close()
+	}
+
+	os.WriteFile(filepath.Join(directory, "other.kv"), []byte("GMCANDL1"), 0644)
+	if _, err := OpenKVJournal(filepath.Join(directory, "other.kv")); err == nil {
+		t.Errorf("expected an error opening a file of another format")
+	}
+}
+
+func TestJournalStats(t *testing.T) {
+	roundTrip := func(profitLoss float64) JournalEntry {
+		return JournalEntry{Type: JOURNAL_ROUND_TRIP, ProfitLoss: profitLoss}
+	}
+	tests := []struct {
+		entries              []JournalEntry
+		expected             JournalStats
+		expectedWinRate      float64
+		expectedProfitFactor float64
+	}{
+		{[]JournalEntry{}, JournalStats{}, 0, 0},
+		{
+			_TestJournalEntries(),
+			JournalStats{Signals: 1, OrdersSubmitted: 1, OrdersConfirmed: 1, OrdersRejected: 1, RoundTrips: 2, Wins: 1, Losses: 1, ProfitLoss: 1, GrossProfit: 2, GrossLoss: -1, MaxDrawdown: 1},
+			0.5, 2,
+		},
+		{
+			[]JournalEntry{roundTrip(5), roundTrip(-3), roundTrip(-4), roundTrip(10), roundTrip(-1)},
+			JournalStats{RoundTrips: 5, Wins: 2, Losses: 3, ProfitLoss: 7, GrossProfit: 15, GrossLoss: -8, MaxDrawdown: 7},
+			0.4, 15.0 / 8,
+		},
+	}
+
+	for i, test := range tests {
+		stats := NewJournalStats(test.entries)
+		if stats != test.expected {
+			t.Errorf("Test case %d: expected %+v, got %+v", i, test.expected, stats)
+		}
+		if math.Abs(stats.WinRate()-test.expectedWinRate) > 1e-9 || math.Abs(stats.ProfitFactor()-test.expectedProfitFactor) > 1e-9 {
+			t.Errorf("Test case %d: unexpected win rate %f or profit factor %f", i, stats.WinRate(), stats.ProfitFactor())
+		}
+	}
+
+	journal, _ := OpenKVJournal(filepath.Join(t.TempDir(), "journal.kv"))
+	defer journal.Close()
+	for _, entry := range _TestJournalEntries() {
+		journal.Append(entry)
+	}
+	byEpic, err := JournalStatsByEpic(journal, JournalFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byEpic) != 2 || byEpic["USDMXN"].ProfitLoss != 2 || byEpic["USDJPY"].OrdersRejected != 1 {
+		t.Errorf("unexpected stats by epic %+v", byEpic)
+	}
+	byStrategy, _ := JournalStatsByStrategy(journal, JournalFilter{Types: []JournalEntryType{JOURNAL_ROUND_TRIP}})
+	if len(byStrategy) != 2 || byStrategy["GPTShortTermStrategy"].Losses != 1 || byStrategy["GPTStrategy"].Signals != 0 {
+		t.Errorf("unexpected stats by strategy %+v", byStrategy)
+	}
+}
+
+func TestMinitraderJournal(t *testing.T) {
+	paper := NewPaperBroker(nil, 1000, "USD")
+	paper.UpdatePrice("USDMXN", 20, 20)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 1, MINUTE_15, GPTStrategy)
+	minitrader.EntryType = MARKET_ENTRY
+	pool, err := NewMinitraderPool(paper, minitrader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal, _ := OpenJSONLinesJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	defer journal.Close()
+	pool.SetJournal(journal)
+	minitrader.broker = pool.Broker
+	minitrader.status = RUNNING
+	minitrader.volatileAmountAvailable = 10
+
+	// a signal is journaled once per candle, and NONE never is
+	for _, signal := range []Signal{NONE, BUY, BUY} {
+		minitrader.publish(SignalGenerated{EventInfo: minitrader.eventInfo(), Signal: signal, Price: 20, CandleTimestamp: 900})
+	}
+	if err := minitrader.Effect(context.Background(), BUY, 20); err != nil {
+		t.Fatal(err)
+	}
+	// the broker stops the position out before the minitrader sees the price
+	paper.UpdatePrice("USDMXN", 18.9, 18.9)
+	if err := minitrader.Effect(context.Background(), NONE, 18.9); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := journal.Entries(JournalFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTypes := []JournalEntryType{JOURNAL_SIGNAL, JOURNAL_ORDER_SUBMITTED, JOURNAL_ORDER_CONFIRMED, JOURNAL_POSITION_OPENED, JOURNAL_ROUND_TRIP}
+	if len(entries) != len(expectedTypes) {
+		t.Fatalf("expected %d entries, got %+v", len(expectedTypes), entries)
+	}
+	for i, entry := range entries {
+		if entry.Type != expectedTypes[i] || entry.Epic != "USDMXN" || entry.Strategy != "GPTStrategy" {
+			t.Errorf("Test case %d: unexpected entry %+v", i, entry)
+		}
+	}
+	if entries[0].Signal != BUY || entries[0].CandleTimestamp != 900 || entries[0].Level != 20 {
+		t.Errorf("unexpected signal entry %+v", entries[0])
+	}
+	if entries[2].Status != "ACCEPTED" || entries[2].DealReference == "" || entries[2].Size != 10 {
+		t.Errorf("unexpected confirmation entry %+v", entries[2])
+	}
+	roundTrip := entries[4]
+	if !roundTrip.ClosedByBroker || roundTrip.EntryLevel != 20 || roundTrip.Size != 10 || math.Abs(roundTrip.ProfitLoss-(-11)) > 1e-9 {
+		t.Errorf("unexpected round trip entry %+v", roundTrip)
+	}
+}
@@ -0,0 +1,155 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const REDACTED = "[REDACTED]"
+
+// secretKeys are attribute keys and header names whose values are always redacted, compared in lower case.
+var secretKeys = map[string]bool{
+	"cst":                      true,
+	"x-security-token":         true,
+	"x_security_token":         true,
+	"securitytoken":            true,
+	"x-cap-api-key":            true,
+	"capital_api_key":          true,
+	"apikey":                   true,
+	"api_key":                  true,
+	"capital_api_key_password": true,
+	"password":                 true,
+	"authorization":            true,
+	"token":                    true,
+}
+
+var logger atomic.Pointer[slog.Logger]
+
+// secrets are the values redacted wherever they appear in a record, by name; a renewed session token
+// replaces the one it renews.
+var secrets = struct {
+	sync.RWMutex
+	values map[string]string
+}{values: make(map[string]string)}
+
+// SetLogHandler sends the records of the package to handler, e.g. `slog.NewJSONHandler(os.Stdout, nil)`.
+// Secrets are redacted before handler sees them. Records go to `slog.Default()` until it is called.
+func SetLogHandler(handler slog.Handler) {
+	logger.Store(slog.New(&redactingHandler{handler: handler}))
+}
+
+// Logger returns the logger of the package.
+func Logger() *slog.Logger {
+	if configured := logger.Load(); configured != nil {
+		return configured
+	}
+	return slog.New(&redactingHandler{handler: slog.Default().Handler()})
+}
+
+// RedactSecret keeps value out of every record of the package, replacing the secret previously registered
+// under name.
+func RedactSecret(name string, value string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	if value == "" {
+		delete(secrets.values, name)
+		return
+	}
+	secrets.values[name] = value
+}
+
+// errorAttrs are the attributes of a record about err: the error and, for Capital.com errors, the request ID.
+func errorAttrs(err error) []any {
+	attrs := []any{"error", err}
+	var apiError *CapitalAPIError
+	if errors.As(err, &apiError) && apiError.RequestID != "" {
+		attrs = append(attrs, "request_id", apiError.RequestID)
+	}
+	return attrs
+}
+
+// redactingHandler removes the secret keys and registered secrets from the records it hands to handler.
+type redactingHandler struct {
+	handler slog.Handler
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, redactSecrets(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return &redactingHandler{handler: h.handler.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{handler: h.handler.WithGroup(name)}
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if secretKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, REDACTED)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		attr.Value = slog.StringValue(redactSecrets(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, groupAttr := range group {
+			redacted[i] = redactAttr(groupAttr)
+		}
+		attr.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		value := attr.Value.Any()
+		if header, ok := value.(http.Header); ok {
+			value = redactHeader(header)
+			attr.Value = slog.AnyValue(value)
+		}
+		// values are only replaced by their text when it carries a secret, to keep their structure otherwise
+		text := fmt.Sprintf("%+v", value)
+		if redacted := redactSecrets(text); redacted != text {
+			attr.Value = slog.StringValue(redacted)
+		}
+	}
+	return attr
+}
+
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for name := range redacted {
+		if secretKeys[strings.ToLower(name)] {
+			redacted[name] = []string{REDACTED}
+		}
+	}
+	return redacted
+}
+
+// redactSecrets replaces every registered secret in text.
+func redactSecrets(text string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, secret := range secrets.values {
+		text = strings.ReplaceAll(text, secret, REDACTED)
+	}
+	return text
+}
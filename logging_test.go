@@ -0,0 +1,113 @@
+package gominitrader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type _TestLogBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (buffer *_TestLogBuffer) Write(p []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.buffer.Write(p)
+}
+
+func (buffer *_TestLogBuffer) String() string {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.buffer.String()
+}
+
+// _TestLogHandler sends the records of the package to the returned buffer until the test ends.
+func _TestLogHandler(t *testing.T) *_TestLogBuffer {
+	buffer := &_TestLogBuffer{}
+	SetLogHandler(slog.NewTextHandler(buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() { logger.Store(nil) })
+	return buffer
+}
+
+func TestRedactingHandler(t *testing.T) {
+	buffer := _TestLogHandler(t)
+	RedactSecret("test CST", "cst-3f9a1c")
+	t.Cleanup(func() { RedactSecret("test CST", "") })
+
+	header := http.Header{}
+	header.Set("CST", "header-cst")
+	header.Set("X-SECURITY-TOKEN", "header-security-token")
+	header.Set("Content-Type", "application/json")
+
+	tests := []struct {
+		log              func(logger *slog.Logger)
+		expectedContains string
+		secret           string
+	}{
+		{func(logger *slog.Logger) { logger.Info("session cst-3f9a1c created") }, "session [REDACTED] created", "cst-3f9a1c"},
+		{func(logger *slog.Logger) { logger.Info("login", "password", "hunter2") }, "password=[REDACTED]", "hunter2"},
+		{func(logger *slog.Logger) { logger.Info("login", "X-SECURITY-TOKEN", "security-token") }, "X-SECURITY-TOKEN=[REDACTED]", "security-token"},
+		{func(logger *slog.Logger) { logger.Info("request", "header", header) }, "application/json", "header-security-token"},
+		{func(logger *slog.Logger) { logger.Warn("failed", "error", errors.New("bad token cst-3f9a1c")) }, "bad token [REDACTED]", "cst-3f9a1c"},
+		{func(logger *slog.Logger) { logger.Info("login", slog.Group("credentials", "api_key", "key-1")) }, "credentials.api_key=[REDACTED]", "key-1"},
+		{func(logger *slog.Logger) { logger.With("session", "cst-3f9a1c").Info("bound") }, "session=[REDACTED]", "cst-3f9a1c"},
+		{func(logger *slog.Logger) {
+			logger.Warn("failed", errorAttrs(&CapitalAPIError{StatusCode: 500, RequestID: "req-1"})...)
+		}, "request_id=req-1", ""},
+	}
+	for i, test := range tests {
+		before := len(buffer.String())
+		test.log(Logger())
+		record := buffer.String()[before:]
+		if !strings.Contains(record, test.expectedContains) {
+			t.Errorf("Test case %d: expected %q in %q", i, test.expectedContains, record)
+		}
+		if test.secret != "" && strings.Contains(record, test.secret) {
+			t.Errorf("Test case %d: expected %q to be redacted from %q", i, test.secret, record)
+		}
+	}
+}
+
+func TestCapitalClientRedaction(t *testing.T) {
+	buffer := _TestLogHandler(t)
+	capClient, err := _TestCapitalClient(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := capClient.CreateNewSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cst, securityToken := capClient.transport.Tokens()
+	Logger().Info(fmt.Sprintf("%s %s %s", cst, securityToken, capClient.CAPITAL_API_KEY_PASSWORD), "value", capClient.CAPITAL_API_KEY)
+
+	for i, secret := range []string{cst, securityToken, capClient.CAPITAL_API_KEY, capClient.CAPITAL_API_KEY_PASSWORD} {
+		if strings.Contains(buffer.String(), secret) {
+			t.Errorf("Test case %d: expected %q to be redacted from %q", i, secret, buffer.String())
+		}
+	}
+}
+
+func TestMinitraderLogFields(t *testing.T) {
+	buffer := _TestLogHandler(t)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader.setTrade("o_1", "p_1", 19.5, 10)
+	minitrader.transition(RUNNING, "started")
+	minitrader.transition(ERROR_ON_MAKING_ORDER, "entry failed")
+
+	for i, expected := range []string{
+		"level=INFO msg=\"status changed\" epic=USDMXN timeframe=MINUTE_15 strategy=GPTStrategy deal_reference=o_1 from=NEW to=RUNNING",
+		"level=WARN msg=\"status changed\" epic=USDMXN timeframe=MINUTE_15 strategy=GPTStrategy deal_reference=o_1 from=RUNNING to=ERROR_ON_MAKING_ORDER",
+	} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("Test case %d: expected %q in %q", i, expected, buffer.String())
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package gominitrader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestDurationBuckets are the upper bounds, in seconds, of the Capital.com request latency histogram.
+var requestDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// capitalIDCollections are the Capital.com collections addressed by id, whose ids are replaced by `{id}`
+// in the endpoint label so that every deal does not make a new series.
+var capitalIDCollections = map[string]bool{"prices": true, "positions": true, "workingorders": true, "confirms": true}
+
+// Metrics exports the health of a MinitraderPool and of the Capital.com API in the Prometheus text
+// format. It is an http.Handler to be served as `/metrics`. Requests are recorded by the CapitalClientAPI
+// it is set on as `Metrics`, and the events of the pool while Run runs; the minitrader statuses, the open
+// exposure and the unrealized P&L are read from the pool and its broker on every scrape.
+type Metrics struct {
+	Pool          *MinitraderPool // nil to export the Capital.com requests only
+	ScrapeTimeout time.Duration   // for the broker requests of a scrape
+
+	mutex       sync.Mutex
+	registry    *metricRegistry
+	candleTimes map[string]time.Time // last candles received, by epic and timeframe
+}
+
+func NewMetrics(pool *MinitraderPool) *Metrics {
+	registry := newMetricRegistry()
+	registry.define("gominitrader_capital_requests_total", "counter", "Capital.com requests by endpoint and status code.")
+	registry.define("gominitrader_capital_request_duration_seconds", "histogram", "Capital.com request latency, including the wait for the request scheduler.", requestDurationBuckets...)
+	registry.define("gominitrader_capital_request_errors_total", "counter", "Capital.com requests failed, by endpoint and error code.")
+	registry.define("gominitrader_session_refreshes_total", "counter", "Sessions created or renewed.")
+	registry.define("gominitrader_signals_total", "counter", "Signals generated by epic, timeframe and strategy.")
+	registry.define("gominitrader_orders_submitted_total", "counter", "Orders accepted for processing.")
+	registry.define("gominitrader_orders_confirmed_total", "counter", "Orders confirmed.")
+	registry.define("gominitrader_orders_rejected_total", "counter", "Orders that could not be submitted or were rejected.")
+	registry.define("gominitrader_orders_deleted_total", "counter", "Pending working orders deleted.")
+	registry.define("gominitrader_realized_profit_loss", "gauge", "P&L of the positions closed by the minitraders.")
+
+	return &Metrics{
+		Pool:          pool,
+		ScrapeTimeout: time.Second * 5,
+		registry:      registry,
+		candleTimes:   make(map[string]time.Time),
+	}
+}
+
+// Run records the events of the pool until ctx is done. Without a pool there are no events to record, and
+// it returns right away.
+func (metrics *Metrics) Run(ctx context.Context) error {
+	if metrics.Pool == nil {
+		return nil
+	}
+	subscription := metrics.Pool.Subscribe(1000,
+		EVENT_CANDLES_UPDATED, EVENT_SIGNAL_GENERATED, EVENT_SESSION_REFRESHED, EVENT_POSITION_CLOSED,
+		EVENT_ORDER_SUBMITTED, EVENT_ORDER_CONFIRMED, EVENT_ORDER_REJECTED, EVENT_ORDER_DELETED,
+	)
+	defer subscription.Unsubscribe()
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return nil
+			}
+			metrics.record(event)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (metrics *Metrics) record(event Event) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	info := event.Info()
+	epic, timeframe := info.Epic, string(info.Timeframe)
+	switch event := event.(type) {
+	case CandlesUpdated:
+		metrics.candleTimes[epic+"-"+timeframe] = info.Time
+	case SignalGenerated:
+		metrics.registry.add("gominitrader_signals_total", 1, "epic", epic, "timeframe", timeframe, "strategy", event.Strategy, "signal", string(event.Signal))
+	case SessionRefreshed:
+		metrics.registry.add("gominitrader_session_refreshes_total", 1, "reason", event.Reason)
+	case OrderSubmitted:
+		metrics.registry.add("gominitrader_orders_submitted_total", 1, "epic", epic, "timeframe", timeframe, "entry_type", string(event.EntryType))
+	case OrderConfirmed:
+		metrics.registry.add("gominitrader_orders_confirmed_total", 1, "epic", epic, "timeframe", timeframe)
+	case OrderRejected:
+		metrics.registry.add("gominitrader_orders_rejected_total", 1, "epic", epic, "timeframe", timeframe)
+	case OrderDeleted:
+		metrics.registry.add("gominitrader_orders_deleted_total", 1, "epic", epic, "timeframe", timeframe)
+	case PositionClosed:
+		metrics.registry.add("gominitrader_realized_profit_loss", event.ProfitLoss, "epic", epic)
+	}
+}
+
+// observeRequest records a Capital.com request; response is nil when err is not.
+func (metrics *Metrics) observeRequest(request *http.Request, response *http.Response, err error, duration time.Duration) {
+	endpoint := capitalEndpoint(request.URL.Path)
+	code, errorCode := "error", "transport"
+	if err == nil {
+		code, errorCode = strconv.Itoa(response.StatusCode), ""
+		if response.StatusCode >= 400 {
+			errorCode = responseErrorCode(response)
+		}
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.registry.add("gominitrader_capital_requests_total", 1, "method", request.Method, "endpoint", endpoint, "code", code)
+	metrics.registry.observe("gominitrader_capital_request_duration_seconds", duration.Seconds(), "method", request.Method, "endpoint", endpoint)
+	if err != nil || response.StatusCode >= 400 {
+		metrics.registry.add("gominitrader_capital_request_errors_total", 1, "method", request.Method, "endpoint", endpoint, "error_code", errorCode)
+	}
+}
+
+func (metrics *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, fmt.Sprintf("Method %s Not Allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	gauges := newMetricRegistry()
+	if metrics.Pool != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), metrics.ScrapeTimeout)
+		defer cancel()
+		metrics.collect(ctx, gauges, time.Now())
+	}
+
+	buffer := bytes.Buffer{}
+	metrics.mutex.Lock()
+	metrics.registry.write(&buffer)
+	metrics.mutex.Unlock()
+	gauges.write(&buffer)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buffer.Bytes())
+}
+
+// collect reads the gauges of the current state of the pool into gauges.
+func (metrics *Metrics) collect(ctx context.Context, gauges *metricRegistry, now time.Time) {
+	gauges.define("gominitrader_minitrader_status", "gauge", "1 for the current status of every minitrader, 0 for the others.")
+	gauges.define("gominitrader_minitrader_paused", "gauge", "1 if the minitrader is paused.")
+	gauges.define("gominitrader_candles_lag_seconds", "gauge", "Seconds since the minitrader last received candles.")
+	gauges.define("gominitrader_open_exposure", "gauge", "Size times entry level of the open positions of the pool epics.")
+	gauges.define("gominitrader_unrealized_profit_loss", "gauge", "P&L of the open positions of the pool epics at the current prices.")
+
+	statuses := []MinitraderStatus{}
+	for status := range minitraderTransitions {
+		statuses = append(statuses, status)
+	}
+
+	epics := map[string]bool{}
+	metrics.mutex.Lock()
+	for _, minitrader := range metrics.Pool.ListMinitraders() {
+		snapshot := minitrader.Snapshot()
+		epics[snapshot.Epic] = true
+		for _, status := range statuses {
+			gauges.set("gominitrader_minitrader_status", boolMetric(snapshot.Status == status), "id", snapshot.ID, "epic", snapshot.Epic, "timeframe", string(snapshot.Timeframe), "status", string(status))
+		}
+		gauges.set("gominitrader_minitrader_paused", boolMetric(snapshot.Paused), "id", snapshot.ID)
+		if candleTime, ok := metrics.candleTimes[snapshot.Epic+"-"+string(snapshot.Timeframe)]; ok {
+			gauges.set("gominitrader_candles_lag_seconds", now.Sub(candleTime).Seconds(), "epic", snapshot.Epic, "timeframe", string(snapshot.Timeframe))
+		}
+	}
+	metrics.mutex.Unlock()
+
+	positionsResponse, err := metrics.Pool.Broker.GetPositions(ctx)
+	if err != nil {
+		Logger().Warn("unable to get positions for metrics", errorAttrs(err)...)
+		return
+	}
+	for _, position := range positionsResponse.Positions {
+		if !epics[position.Market.Epic] {
+			continue
+		}
+		price := position.Market.Bid
+		if position.Position.Direction == string(SELL) {
+			price = position.Market.Offer
+		}
+		gauges.add("gominitrader_open_exposure", position.Position.Size*position.Position.Level, "epic", position.Market.Epic, "direction", position.Position.Direction)
+		gauges.add("gominitrader_unrealized_profit_loss", positionProfitLoss(position, price), "epic", position.Market.Epic)
+	}
+}
+
+// capitalEndpoint is the path of a Capital.com request with the ids replaced, e.g. `/api/v1/positions/{id}`.
+func capitalEndpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 0; i < len(segments)-1; i++ {
+		if capitalIDCollections[segments[i]] {
+			return strings.Join(segments[:i+1], "/") + "/{id}"
+		}
+	}
+	return path
+}
+
+// responseErrorCode returns the Capital.com errorCode of an error response, leaving its body to be read again.
+func responseErrorCode(response *http.Response) string {
+	apiError := newCapitalAPIError(response)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewBufferString(apiError.Body))
+	if apiError.ErrorCode == "" {
+		return "unknown"
+	}
+	return apiError.ErrorCode
+}
+
+// positionProfitLoss is the P&L of position closed at price.
+func positionProfitLoss(position PositionResponse, price float64) float64 {
+	if position.Position.Direction == string(SELL) {
+		return (position.Position.Level - price) * position.Position.Size
+	}
+	return (price - position.Position.Level) * position.Position.Size
+}
+
+func boolMetric(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// metricRegistry holds metric families and writes them in the Prometheus text format. It is not safe for
+// concurrent use.
+type metricRegistry struct {
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	kind    string // counter, gauge or histogram
+	help    string
+	buckets []float64
+	series  map[string]*metricSeries // by rendered labels
+}
+
+type metricSeries struct {
+	value   float64
+	buckets []uint64 // cumulative observations of histograms, by bucket
+	count   uint64
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func newMetricRegistry() *metricRegistry {
+	return &metricRegistry{families: make(map[string]*metricFamily)}
+}
+
+func (registry *metricRegistry) define(name string, kind string, help string, buckets ...float64) {
+	registry.families[name] = &metricFamily{kind: kind, help: help, buckets: buckets, series: make(map[string]*metricSeries)}
+}
+
+// series returns the series of name with labels, given as name and value pairs, creating it if needed.
+func (registry *metricRegistry) series(name string, labels []string) *metricSeries {
+	family, ok := registry.families[name]
+	if !ok {
+		panic(errors.New(fmt.Sprintf("Metric %s Not Defined", name)))
+	}
+	rendered := []string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		rendered = append(rendered, fmt.Sprintf(`%s="%s"`, labels[i], metricLabelEscaper.Replace(labels[i+1])))
+	}
+	key := strings.Join(rendered, ",")
+	series, ok := family.series[key]
+	if !ok {
+		series = &metricSeries{buckets: make([]uint64, len(family.buckets))}
+		family.series[key] = series
+	}
+	return series
+}
+
+func (registry *metricRegistry) add(name string, value float64, labels ...string) {
+	registry.series(name, labels).value += value
+}
+
+func (registry *metricRegistry) set(name string, value float64, labels ...string) {
+	registry.series(name, labels).value = value
+}
+
+func (registry *metricRegistry) observe(name string, value float64, labels ...string) {
+	series := registry.series(name, labels)
+	for i, bound := range registry.families[name].buckets {
+		if value <= bound {
+			series.buckets[i]++
+		}
+	}
+	series.value += value
+	series.count++
+}
+
+// write writes the families with at least one series, sorted by name and labels.
+func (registry *metricRegistry) write(w io.Writer) {
+	names := []string{}
+	for name, family := range registry.families {
+		if len(family.series) != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := registry.families[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.kind)
+		keys := []string{}
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series := family.series[key]
+			if family.kind != "histogram" {
+				fmt.Fprintf(w, "%s%s %s\n", name, metricLabels(key, ""), formatMetric(series.value))
+				continue
+			}
+			for i, bound := range family.buckets {
+				fmt.Fprintf(w, "%s_bucket%s %d\n", name, metricLabels(key, `le="`+formatMetric(bound)+`"`), series.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, metricLabels(key, `le="+Inf"`), series.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", name, metricLabels(key, ""), formatMetric(series.value))
+			fmt.Fprintf(w, "%s_count%s %d\n", name, metricLabels(key, ""), series.count)
+		}
+	}
+}
+
+func metricLabels(rendered string, extra string) string {
+	labels := []string{}
+	for _, label := range []string{rendered, extra} {
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func formatMetric(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,212 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func _TestScrape(t *testing.T, metrics *Metrics) string {
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+	response, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	return string(body)
+}
+
+// _TestScrapedValue returns the value of series in scraped, or NaN if it is missing.
+func _TestScrapedValue(scraped string, series string) float64 {
+	for _, line := range strings.Split(scraped, "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			value, _ := strconv.ParseFloat(strings.TrimPrefix(line, series+" "), 64)
+			return value
+		}
+	}
+	return math.NaN()
+}
+
+func TestCapitalEndpoint(t *testing.T) {
+	tests := []struct {
+		path             string
+		expectedEndpoint string
+	}{
+		{"/api/v1/positions", "/api/v1/positions"},
+		{"/api/v1/positions/006011e7-0055-311e-0000-000080507631", "/api/v1/positions/{id}"},
+		{"/api/v1/prices/USDMXN", "/api/v1/prices/{id}"},
+		{"/api/v1/confirms/o_0c2c4e8e", "/api/v1/confirms/{id}"},
+		{"/api/v1/session/encryptionKey", "/api/v1/session/encryptionKey"},
+	}
+	for i, test := range tests {
+		if endpoint := capitalEndpoint(test.path); endpoint != test.expectedEndpoint {
+			t.Errorf("Test case %d: expected %s, got %s", i, test.expectedEndpoint, endpoint)
+		}
+	}
+}
+
+func TestMetricsCapitalRequests(t *testing.T) {
+	capClient, err := _TestCapitalClient(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := NewMetrics(nil)
+	capClient.Metrics = metrics
+
+	// without a pool, Run has no events to record and returns
+	ctx := context.Background()
+	if err := metrics.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := capClient.CreateNewSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	capClient.GetPositions(ctx)
+	capClient.GetPositions(ctx)
+	_, err = capClient.ClosePosition(ctx, "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the error response to be left readable, got %v", err)
+	}
+
+	scraped := _TestScrape(t, metrics)
+	for i, expected := range []string{
+		`gominitrader_capital_requests_total{method="POST",endpoint="/api/v1/session",code="200"} 1`,
+		`gominitrader_capital_requests_total{method="GET",endpoint="/api/v1/positions",code="200"} 2`,
+		`gominitrader_capital_requests_total{method="DELETE",endpoint="/api/v1/positions/{id}",code="404"} 1`,
+		`gominitrader_capital_request_errors_total{method="DELETE",endpoint="/api/v1/positions/{id}",error_code="error.not-found.dealId"} 1`,
+		`gominitrader_capital_request_duration_seconds_bucket{method="GET",endpoint="/api/v1/positions",le="+Inf"} 2`,
+		`gominitrader_capital_request_duration_seconds_count{method="GET",endpoint="/api/v1/positions"} 2`,
+		"# TYPE gominitrader_capital_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(scraped, expected) {
+			t.Errorf("Test case %d: expected %s in\n%s", i, expected, scraped)
+		}
+	}
+	if strings.Contains(scraped, "gominitrader_minitrader_status") {
+		t.Errorf("expected no pool metrics without a pool, got\n%s", scraped)
+	}
+}
+
+func TestMetricsPool(t *testing.T) {
+	paper := NewPaperBroker(nil, 10000, "USD")
+	paper.UpdatePrice("USDMXN", 19.5, 19.5)
+	paper.UpdatePrice("EURUSD", 1.1, 1.1)
+	paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 10})
+	paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "EURUSD", Direction: BUY, Size: 10})
+	paper.UpdatePrice("USDMXN", 19.7, 19.7)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader.Pause()
+	pool, _ := NewMinitraderPool(paper, minitrader)
+	metrics := NewMetrics(pool)
+
+	now := time.Now()
+	info := EventInfo{Time: now.Add(-time.Second * 30), Epic: "USDMXN", Timeframe: MINUTE_15}
+	for _, event := range []Event{
+		CandlesUpdated{EventInfo: info},
+		SignalGenerated{EventInfo: info, Strategy: minitrader.strategyName(), Signal: BUY},
+		SignalGenerated{EventInfo: info, Strategy: minitrader.strategyName(), Signal: BUY},
+		OrderSubmitted{EventInfo: info, EntryType: MARKET_ENTRY},
+		OrderConfirmed{EventInfo: info},
+		OrderRejected{EventInfo: info},
+		PositionClosed{EventInfo: info, ProfitLoss: 1.5},
+		PositionClosed{EventInfo: info, ProfitLoss: -0.5},
+		SessionRefreshed{EventInfo: EventInfo{Time: now}, Reason: "expired"},
+	} {
+		metrics.record(event)
+	}
+
+	scraped := _TestScrape(t, metrics)
+	tests := []struct {
+		series        string
+		expectedValue float64
+		tolerance     float64
+	}{
+		{`gominitrader_signals_total{epic="USDMXN",timeframe="MINUTE_15",strategy="GPTStrategy",signal="BUY"}`, 2, 0},
+		{`gominitrader_orders_submitted_total{epic="USDMXN",timeframe="MINUTE_15",entry_type="MARKET"}`, 1, 0},
+		{`gominitrader_orders_confirmed_total{epic="USDMXN",timeframe="MINUTE_15"}`, 1, 0},
+		{`gominitrader_orders_rejected_total{epic="USDMXN",timeframe="MINUTE_15"}`, 1, 0},
+		{`gominitrader_realized_profit_loss{epic="USDMXN"}`, 1, 0},
+		{`gominitrader_session_refreshes_total{reason="expired"}`, 1, 0},
+		{`gominitrader_minitrader_status{id="USDMXN-MINUTE_15",epic="USDMXN",timeframe="MINUTE_15",status="NEW"}`, 1, 0},
+		{`gominitrader_minitrader_status{id="USDMXN-MINUTE_15",epic="USDMXN",timeframe="MINUTE_15",status="RUNNING"}`, 0, 0},
+		{`gominitrader_minitrader_paused{id="USDMXN-MINUTE_15"}`, 1, 0},
+		{`gominitrader_candles_lag_seconds{epic="USDMXN",timeframe="MINUTE_15"}`, 30, 5},
+		{`gominitrader_open_exposure{epic="USDMXN",direction="BUY"}`, 195, 0.0001},
+		{`gominitrader_unrealized_profit_loss{epic="USDMXN"}`, 2, 0.0001},
+	}
+	for i, test := range tests {
+		if value := _TestScrapedValue(scraped, test.series); !(math.Abs(value-test.expectedValue) <= test.tolerance) {
+			t.Errorf("Test case %d: expected %s %g, got %g in\n%s", i, test.series, test.expectedValue, value, scraped)
+		}
+	}
+	if strings.Contains(scraped, `epic="EURUSD"`) {
+		t.Errorf("expected only the pool epics to be exported, got\n%s", scraped)
+	}
+}
+
+// _TestThresholdStrategy buys above a threshold; its name carries the parameter.
+type _TestThresholdStrategy struct {
+	threshold float64
+}
+
+func (strategy *_TestThresholdStrategy) Name() string {
+	return fmt.Sprintf("Threshold(%g)", strategy.threshold)
+}
+
+func (strategy *_TestThresholdStrategy) Parameters() []StrategyParameter {
+	return []StrategyParameter{{Name: "threshold", Value: strategy.threshold}}
+}
+
+func (strategy *_TestThresholdStrategy) WarmUp() int { return 1 }
+
+func (strategy *_TestThresholdStrategy) OnCandle(candle Candle) (Signal, float64) {
+	if candle.Close.Bid > strategy.threshold {
+		return BUY, candle.Close.Bid
+	}
+	return NONE, candle.Close.Bid
+}
+
+func (strategy *_TestThresholdStrategy) Reset() {}
+
+func TestMinitraderStrategyName(t *testing.T) {
+	tests := []struct {
+		minitrader   *Minitrader
+		expectedName string
+	}{
+		{NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy), "GPTStrategy"},
+		{NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTShortTermStrategy), "GPTShortTermStrategy"},
+		{NewStatefulMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, NewFuncStrategy(GPTStrategy, BACKTEST_WINDOW)), "GPTStrategy"},
+		{NewStatefulMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, &_TestThresholdStrategy{threshold: 19}), "Threshold(19)"},
+		{NewStatefulMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, &_TestThresholdStrategy{threshold: 20}), "Threshold(20)"},
+	}
+	for i, test := range tests {
+		if name := test.minitrader.strategyName(); name != test.expectedName {
+			t.Errorf("Test case %d: expected %s, got %s", i, test.expectedName, name)
+		}
+	}
+
+	// two instances of one strategy type are told apart in the journal
+	journal, _ := OpenKVJournal(filepath.Join(t.TempDir(), "journal.kv"))
+	defer journal.Close()
+	for _, test := range tests[3:] {
+		test.minitrader.journal = journal
+		test.minitrader.publish(PositionClosed{EventInfo: test.minitrader.eventInfo(), DealID: "p_1", ProfitLoss: 1})
+	}
+	byStrategy, err := JournalStatsByStrategy(journal, JournalFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byStrategy) != 2 || byStrategy["Threshold(19)"].RoundTrips != 1 || byStrategy["Threshold(20)"].RoundTrips != 1 {
+		t.Errorf("unexpected stats by strategy %+v", byStrategy)
+	}
+}
@@ -1,8 +1,10 @@
 package gominitrader
 
 import (
+	"context"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -10,20 +12,51 @@ import (
 type Minitrader struct {
 	Epic                 string
 	Timeframe            Timeframe
-	Status               MinitraderStatus
-	MarketStatus         MinitraderMarketStatus
 	Strategy             Strategy
+	StatefulStrategy     StatefulStrategy // used instead of Strategy when set
 	InvestmentPercentage float64
 	StopLossPercentage   float64
 	ProfitPercentage     float64
-
-	capitalClient       *CapitalClientAPI
-	candlesChannel      chan Candles // TODO: Implement "Pipeline" Pattern To Handle Larger Data Efficiently
-	activeDealReference string
-
-	payedPrice                   float64
+	EntryType            EntryType
+	Evaluation           Evaluation
+	OnBarClosed          func(minitrader *Minitrader, candle Candle)         // called for every bar of Timeframe that closes
+	OnTransition         func(minitrader *Minitrader, transition Transition) // called on every status change; must not change the status
+
+	id                string // unique within its pool
+	broker            Broker
+	events            *EventBus     // events of the pool the minitrader belongs to
+	candlesChannel    chan Candles  // TODO: Implement "Pipeline" Pattern To Handle Larger Data Efficiently
+	done              chan struct{} // closed when Start returns
+	removed           chan struct{} // closed when the minitrader is removed from its pool
+	err               error         // last Effect error, until the minitrader is back to trading
+	strategyAdapter   *FuncStrategy
+	strategyOnce      sync.Once // creates strategyAdapter; the name is read from other goroutines
+	strategyTimestamp int64     // timestamp of the last candle fed to the strategy
+	barTimestamp      int64     // timestamp of the forming bar of the last candles received
+	journal           Journal
+	journaledSignal   Signal // last signal journaled, and the timestamp of its candle
+	journaledCandle   int64
+	orderCheckedAt    time.Time // last time a restored working order was looked up on the broker
+
+	// guarded by stateMutex; status changes go through transition and trade changes through setTrade. The
+	// goroutine evaluating the minitrader is the only writer, so it reads them without locking.
+	stateMutex                   sync.Mutex
+	transitionMutex              sync.Mutex
+	status                       MinitraderStatus
+	marketStatus                 MinitraderMarketStatus
 	volatileAmountAvailable      float64
 	volatileInvestmentPercentage float64
+	activeDealReference          string
+	activeDealID                 string // deal id of the entry working order, or of the position of market entries
+	payedPrice                   float64
+	payedSize                    float64
+	candles                      Candles // last candles received
+	signal                       Signal  // last signal generated
+	signalPrice                  float64
+	signalTime                   time.Time
+	paused                       bool
+	resumeRequested              bool
+	flattenRequested             bool
 }
 
 type MinitraderStatus string
@@ -38,8 +71,8 @@ const (
 
 	// error statuses
 	ERROR_ON_UPDATE_CANDLES_DATA MinitraderStatus = "ERROR_ON_UPDATE_CANDLES_DATA"
-	ERROR_ON_MAKING_ORDER        MinitraderStatus = "ERROR_ON_MAKING_ORDER"
-	ERROR_ON_DELETING_ORDER      MinitraderStatus = "ERROR_ON_DELETING_ORDER"
+	ERROR_ON_MAKING_ORDER        MinitraderStatus = "ERROR_ON_MAKING_ORDER"   // an entry failed
+	ERROR_ON_DELETING_ORDER      MinitraderStatus = "ERROR_ON_DELETING_ORDER" // a stop loss or take profit exit failed
 )
 
 type MinitraderMarketStatus string
@@ -49,6 +82,24 @@ const (
 	CLOSED    MinitraderMarketStatus = "CLOSED"
 )
 
+// EntryType selects how a minitrader opens a trade: a LIMIT working order at the signal price,
+// or a market position opened right away with its stop loss and take profit attached on the broker side.
+type EntryType string
+
+const (
+	WORKING_ORDER_ENTRY EntryType = "WORKING_ORDER"
+	MARKET_ENTRY        EntryType = "MARKET"
+)
+
+// Evaluation selects when a minitrader evaluates its strategy: on every candles update, with the bar
+// still forming, or only when a bar closes, with the closed bars.
+type Evaluation string
+
+const (
+	INTRABAR  Evaluation = "INTRABAR"
+	BAR_CLOSE Evaluation = "BAR_CLOSE"
+)
+
 type Timeframe string
 
 const (
@@ -79,59 +130,142 @@ func NewMinitrader(epic string, investmentPercentage float64, stopLossPercentage
 		InvestmentPercentage:         investmentPercentage,
 		Timeframe:                    timeframe,
 		Strategy:                     strategy,
-		Status:                       NEW,
+		status:                       NEW,
 		StopLossPercentage:           stopLossPercentage,
 		ProfitPercentage:             profitPercentage,
+		EntryType:                    WORKING_ORDER_ENTRY,
+		Evaluation:                   INTRABAR,
 		candlesChannel:               make(chan Candles),
+		done:                         make(chan struct{}),
+		removed:                      make(chan struct{}),
 		volatileInvestmentPercentage: investmentPercentage,
 	}
 }
 
-func (minitrader *Minitrader) Start(waitGroup *sync.WaitGroup) {
+func NewStatefulMinitrader(epic string, investmentPercentage float64, stopLossPercentage float64, profitPercentage float64, timeframe Timeframe, strategy StatefulStrategy) *Minitrader {
+	minitrader := NewMinitrader(epic, investmentPercentage, stopLossPercentage, profitPercentage, timeframe, nil)
+	minitrader.StatefulStrategy = strategy
+	return minitrader
+}
+
+// Start evaluates the candles sent to the minitrader until its candles channel is closed, or it is removed
+// from its pool. Orders are placed with ctx, so cancelling it aborts the order in progress; to stop a
+// minitrader gracefully, close the channel and let the last Effect finish. A failed Effect does not stop
+// the minitrader: it keeps following the candles in its error status, without trading, until resumed.
+func (minitrader *Minitrader) Start(ctx context.Context, waitGroup *sync.WaitGroup) {
 	defer waitGroup.Done()
-	defer close(minitrader.candlesChannel)
-	minitrader.Status = RUNNING
-	for candles := range minitrader.candlesChannel {
-		signal, price := minitrader.Strategy(candles)
-		err := minitrader.Effect(signal, price)
-		log.Printf("Epic: %s - Timeframe: %v - Signal: %v - Price: %v", minitrader.Epic, minitrader.Timeframe, signal, price)
-		if err != nil {
-			log.Printf("Error On Minitrader Effect: %v", err)
+	defer close(minitrader.done)
+	if minitrader.Status() == NEW {
+		minitrader.transition(RUNNING, "started")
+	}
+	for {
+		var candles Candles
+		var ok bool
+		select {
+		case candles, ok = <-minitrader.candlesChannel:
+		case <-minitrader.removed:
+		}
+		if !ok {
 			return
 		}
+		if len(candles) != 0 {
+			minitrader.publish(CandlesUpdated{EventInfo: minitrader.eventInfo(), Last: candles[len(candles)-1], Count: len(candles)})
+		}
+		signal, price := minitrader.onCandles(candles)
+		minitrader.setLastEvaluation(candles, signal, price)
+		minitrader.publish(SignalGenerated{EventInfo: minitrader.eventInfo(), Strategy: minitrader.strategyName(), Signal: signal, Price: price, CandleTimestamp: minitrader.strategyTimestamp})
+		err := minitrader.Effect(ctx, signal, price)
+		minitrader.logger().Debug("signal evaluated", "signal", signal, "price", price)
+		if err != nil {
+			minitrader.logger().Error("unable to effect signal", append(errorAttrs(err), "status", minitrader.Status())...)
+			minitrader.err = err
+		} else if !minitrader.Status().IsError() {
+			minitrader.err = nil
+		}
 	}
 }
 
-func (minitrader *Minitrader) Effect(signal Signal, price float64) error {
-	if minitrader.MarketStatus == CLOSED {
+// publish hands event to the subscribers of the pool of the minitrader, and to its journal, if any.
+func (minitrader *Minitrader) publish(event Event) {
+	if minitrader.events != nil {
+		minitrader.events.Publish(event)
+	}
+	minitrader.journalEvent(event)
+}
+
+// logger returns the logger of the package with the fields of the minitrader and of its trade.
+func (minitrader *Minitrader) logger() *slog.Logger {
+	dealReference, _ := minitrader.trade()
+	return Logger().With("epic", minitrader.Epic, "timeframe", minitrader.Timeframe, "strategy", minitrader.strategyName(), "deal_reference", dealReference)
+}
+
+func (minitrader *Minitrader) eventInfo() EventInfo {
+	return EventInfo{Time: time.Now(), Epic: minitrader.Epic, Timeframe: minitrader.Timeframe}
+}
+
+// send hands candles to the minitrader, unless it has stopped or ctx is done first.
+func (minitrader *Minitrader) send(ctx context.Context, candles Candles) bool {
+	select {
+	case minitrader.candlesChannel <- candles:
+		return true
+	case <-minitrader.done:
+	case <-ctx.Done():
+	}
+	return false
+}
+
+func (minitrader *Minitrader) Effect(ctx context.Context, signal Signal, price float64) error {
+	minitrader.applyControls()
+	if minitrader.MarketStatus() == CLOSED {
 		return errors.New("Unable To Do Trading; Market Closed")
 	}
 
-	// quick sell out with looses
-	lowerBoundPrice := minitrader.payedPrice * (1 + (100-minitrader.StopLossPercentage)/100)
-	if (minitrader.Status == HOLDING) && (lowerBoundPrice >= price) {
-		err := minitrader.deleteOrder(minitrader.activeDealReference)
+	// a restored working order is pending until the broker fills it
+	if minitrader.Status() == BUY_ORDER_ACTIVE {
+		if err := minitrader.followRestoredOrder(ctx); err != nil {
+			return err
+		}
+	}
+
+	// quick sell out with looses; entries carry their stop loss, so this only catches up with the broker. No
+	// price means no evaluation, e.g. while the strategy warms up, and is never an exit.
+	if minitrader.Status() == HOLDING && price > 0 && hitsStopLoss(minitrader.payedPrice, minitrader.StopLossPercentage, price) {
+		var err error
+		if minitrader.EntryType == MARKET_ENTRY {
+			err = minitrader.closePosition(ctx, price, "STOP_LOSS")
+		} else {
+			err = minitrader.closeWorkingOrderEntry(ctx, price, "STOP_LOSS")
+		}
 		if err != nil {
-			minitrader.Status = ERROR_ON_DELETING_ORDER
+			minitrader.transition(ERROR_ON_DELETING_ORDER, fmt.Sprintf("stop loss exit failed: %s", err))
 			return err
 		}
 	}
 
-	// sell out with profit
-	upperBoundPrice := minitrader.payedPrice * (1 + (100+minitrader.ProfitPercentage)/100)
-	if (minitrader.Status == HOLDING) && (upperBoundPrice <= price) {
-		err := minitrader.makeOrderAndWaitUntilComplete(minitrader.Epic, SELL, LIMIT, price)
+	// sell out with profit; same as above with the attached take profit
+	if minitrader.Status() == HOLDING && price > 0 && hitsTakeProfit(minitrader.payedPrice, minitrader.ProfitPercentage, price) {
+		var err error
+		if minitrader.EntryType == MARKET_ENTRY {
+			err = minitrader.closePosition(ctx, price, "TAKE_PROFIT")
+		} else {
+			err = minitrader.closeWorkingOrderEntry(ctx, price, "TAKE_PROFIT")
+		}
 		if err != nil {
-			minitrader.Status = ERROR_ON_MAKING_ORDER
+			minitrader.transition(ERROR_ON_DELETING_ORDER, fmt.Sprintf("take profit exit failed: %s", err))
 			return err
 		}
 	}
 
 	// make a buy/sell order and wait 3:30 minutes or less if order has been completed before wait time.
-	if minitrader.Status == RUNNING && signal == BUY { // || (minitrader.Status == HOLDING && signal == SELL) {
-		err := minitrader.makeOrderAndWaitUntilComplete(minitrader.Epic, signal, LIMIT, price)
+	if minitrader.Status() == RUNNING && signal == BUY && !minitrader.Paused() { // || (minitrader.Status() == HOLDING && signal == SELL) {
+		var err error
+		if minitrader.EntryType == MARKET_ENTRY {
+			err = minitrader.makePositionAndWaitUntilComplete(ctx, minitrader.Epic, signal, price)
+		} else {
+			err = minitrader.makeOrderAndWaitUntilComplete(ctx, minitrader.Epic, signal, LIMIT, price)
+		}
 		if err != nil {
-			minitrader.Status = ERROR_ON_MAKING_ORDER
+			minitrader.transition(ERROR_ON_MAKING_ORDER, fmt.Sprintf("entry failed: %s", err))
 			return err
 		}
 	}
@@ -139,7 +273,111 @@ func (minitrader *Minitrader) Effect(signal Signal, price float64) error {
 	return nil
 }
 
-func (minitrader *Minitrader) makeOrderAndWaitUntilComplete(epic string, signal Signal, orderType OrderType, targetPrice float64) error {
+// strategy returns the StatefulStrategy of the minitrader, adapting the plain Strategy function if needed.
+func (minitrader *Minitrader) strategy() StatefulStrategy {
+	if minitrader.StatefulStrategy != nil {
+		return minitrader.StatefulStrategy
+	}
+	minitrader.strategyOnce.Do(func() {
+		minitrader.strategyAdapter = NewFuncStrategy(minitrader.Strategy, BACKTEST_WINDOW)
+	})
+	return minitrader.strategyAdapter
+}
+
+// strategyName is the Name of the strategy of the minitrader, e.g. `GPTStrategy` for a plain function.
+func (minitrader *Minitrader) strategyName() string {
+	return minitrader.strategy().Name()
+}
+
+// onCandles notifies the bars closed since the last candles received and evaluates the strategy. With
+// BAR_CLOSE evaluation, the strategy only sees closed bars; in between, the minitrader keeps following the
+// price of the forming bar without a signal, so exits are still handled.
+func (minitrader *Minitrader) onCandles(candles Candles) (Signal, float64) {
+	closed := minitrader.closedBars(candles)
+	if minitrader.OnBarClosed != nil {
+		for _, candle := range closed {
+			minitrader.OnBarClosed(minitrader, candle)
+		}
+	}
+	if minitrader.Evaluation != BAR_CLOSE {
+		return minitrader.evaluate(candles)
+	}
+	if len(closed) == 0 {
+		if len(candles) == 0 {
+			return NONE, 0
+		}
+		return NONE, candles[len(candles)-1].Close.Bid
+	}
+	return minitrader.evaluate(candles[:len(candles)-1])
+}
+
+// closedBars returns the bars closed since the last candles received; the last candle is the forming bar,
+// which closes once a newer bar shows up.
+func (minitrader *Minitrader) closedBars(candles Candles) Candles {
+	if len(candles) == 0 {
+		return nil
+	}
+	forming := minitrader.barTimestamp
+	minitrader.barTimestamp = candles[len(candles)-1].Timestamp
+	if forming == 0 || minitrader.barTimestamp <= forming {
+		return nil
+	}
+	closed := Candles{}
+	for _, candle := range candles[:len(candles)-1] {
+		if candle.Timestamp >= forming {
+			closed = append(closed, candle)
+		}
+	}
+	return closed
+}
+
+// evaluate feeds the strategy the candles it has not seen yet and returns the signal of the last one. The
+// last candle seen is fed again, since polling and streaming keep updating the bar in progress. When the
+// candles no longer overlap with what the strategy has seen, it is reset and warmed up again.
+func (minitrader *Minitrader) evaluate(candles Candles) (Signal, float64) {
+	strategy := minitrader.strategy()
+	if len(candles) == 0 {
+		return NONE, 0
+	}
+
+	start := 0
+	if minitrader.strategyTimestamp == 0 || candles[0].Timestamp > minitrader.strategyTimestamp || candles[len(candles)-1].Timestamp < minitrader.strategyTimestamp {
+		strategy.Reset()
+	} else {
+		for start < len(candles) && candles[start].Timestamp < minitrader.strategyTimestamp {
+			start++
+		}
+	}
+
+	signal, price := NONE, 0.0
+	for _, candle := range candles[start:] {
+		signal, price = strategy.OnCandle(candle)
+	}
+	minitrader.strategyTimestamp = candles[len(candles)-1].Timestamp
+	return signal, price
+}
+
+// stopLossLevel is the price stopLossPercentage percent below payedPrice.
+func stopLossLevel(payedPrice float64, stopLossPercentage float64) float64 {
+	return payedPrice * (1 - stopLossPercentage/100)
+}
+
+// takeProfitLevel is the price profitPercentage percent above payedPrice.
+func takeProfitLevel(payedPrice float64, profitPercentage float64) float64 {
+	return payedPrice * (1 + profitPercentage/100)
+}
+
+// hitsStopLoss reports whether price has fallen stopLossPercentage percent or more below payedPrice.
+func hitsStopLoss(payedPrice float64, stopLossPercentage float64, price float64) bool {
+	return stopLossLevel(payedPrice, stopLossPercentage) >= price
+}
+
+// hitsTakeProfit reports whether price has risen profitPercentage percent or more above payedPrice.
+func hitsTakeProfit(payedPrice float64, profitPercentage float64, price float64) bool {
+	return takeProfitLevel(payedPrice, profitPercentage) <= price
+}
+
+func (minitrader *Minitrader) makeOrderAndWaitUntilComplete(ctx context.Context, epic string, signal Signal, orderType OrderType, targetPrice float64) error {
 	var amount float64
 	var err error
 	var dealReference string
@@ -147,110 +385,288 @@ func (minitrader *Minitrader) makeOrderAndWaitUntilComplete(epic string, signal
 	// update minitrader status and get amount available
 	// from preferred account or get amount from position/order confirmation
 	if signal == BUY {
-		minitrader.Status = BUY_ORDER_ACTIVE
-		amount = minitrader.volatileAmountAvailable // TODO; Double check if quantity good (amount)
+		err = minitrader.transition(BUY_ORDER_ACTIVE, fmt.Sprintf("buy working order at %f", targetPrice))
+		_, amount = minitrader.volatileValues() // TODO; Double check if quantity good (amount)
 	} else {
-		minitrader.Status = SELL_ORDER_ACTIVE
-		amount, err = minitrader.getAmountFromPositionOrderConfirmation()
+		err = minitrader.transition(SELL_ORDER_ACTIVE, fmt.Sprintf("sell working order at %f", targetPrice))
+		if err == nil {
+			amount, err = minitrader.getAmountFromPositionOrderConfirmation(ctx)
+		}
 	}
 	if err != nil {
 		return err
 	}
 
-	// create a working order and retry if it fails
-	orderResponse, err := minitrader.createWorkingOrderWithRetries(epic, signal, orderType, targetPrice, amount)
+	// create a working order and retry if it fails; buy entries carry their stop loss and take profit
+	workingOrder := CreateWorkingOrderBody{
+		Epic:      epic,
+		Direction: signal,
+		Type:      orderType,
+		Level:     targetPrice,
+		Size:      amount,
+	}
+	if signal == BUY {
+		workingOrder.StopLevel = stopLossLevel(targetPrice, minitrader.StopLossPercentage)
+		workingOrder.ProfitLevel = takeProfitLevel(targetPrice, minitrader.ProfitPercentage)
+	}
+	orderResponse, err := minitrader.createWorkingOrderWithRetries(ctx, workingOrder)
 	if err != nil {
+		minitrader.publish(OrderRejected{EventInfo: minitrader.eventInfo(), Reason: err.Error()})
 		return err
 	}
 	dealReference = orderResponse.DealReference
+	minitrader.publish(OrderSubmitted{
+		EventInfo:     minitrader.eventInfo(),
+		DealReference: dealReference,
+		EntryType:     WORKING_ORDER_ENTRY,
+		Direction:     signal,
+		Level:         targetPrice,
+		Size:          amount,
+		StopLevel:     workingOrder.StopLevel,
+		ProfitLevel:   workingOrder.ProfitLevel,
+	})
 
 	// check if the working order status it was successfully completed
-	confirmationStatus, err := minitrader.waitUntilConfirmationWithRetries(dealReference)
+	confirmation, err := minitrader.getConfirmationWithRetries(ctx, dealReference)
 	if err != nil {
 		return err
 	}
-	if confirmationStatus == string(DELETED) {
-		minitrader.Status = RUNNING
-		return nil
+	if confirmation.Status == string(DELETED) {
+		minitrader.publish(OrderDeleted{EventInfo: minitrader.eventInfo(), DealID: confirmation.DealID})
+		return minitrader.transition(RUNNING, "working order deleted")
 	}
+	if confirmation.DealStatus == string(REJECTED) {
+		minitrader.publish(OrderRejected{EventInfo: minitrader.eventInfo(), DealReference: dealReference, Reason: confirmation.Reason})
+		return fmt.Errorf("Working Order Rejected: %s", confirmation.Reason)
+	}
+	minitrader.publish(OrderConfirmed{EventInfo: minitrader.eventInfo(), DealReference: dealReference, DealID: confirmation.DealID, Status: confirmation.DealStatus, Reason: confirmation.Reason, Level: targetPrice, Size: amount})
 
 	// update minitrader status, active deal reference and payed price
 	if signal == BUY {
-		minitrader.Status = HOLDING
-		minitrader.activeDealReference = dealReference
-		minitrader.payedPrice = targetPrice
-	} else {
-		minitrader.Status = RUNNING
-		minitrader.activeDealReference = ""
-		minitrader.payedPrice = 0.0
+		minitrader.setTrade(dealReference, confirmation.DealID, targetPrice, amount)
+		return minitrader.transition(HOLDING, fmt.Sprintf("buy working order %s accepted", dealReference))
 	}
+	minitrader.setTrade("", "", 0, 0)
+	return minitrader.transition(RUNNING, fmt.Sprintf("sell working order %s accepted", dealReference))
+}
 
-	return nil
+// makePositionAndWaitUntilComplete opens a market position with the stop loss and take profit attached,
+// so they are honored by the broker even if Effect is not called in time.
+func (minitrader *Minitrader) makePositionAndWaitUntilComplete(ctx context.Context, epic string, signal Signal, targetPrice float64) error {
+	if err := minitrader.transition(BUY_ORDER_ACTIVE, fmt.Sprintf("buy position at %f", targetPrice)); err != nil {
+		return err
+	}
+	_, amount := minitrader.volatileValues()
+
+	position := CreatePositionBody{
+		Epic:        epic,
+		Direction:   signal,
+		Size:        amount,
+		StopLevel:   stopLossLevel(targetPrice, minitrader.StopLossPercentage),
+		ProfitLevel: takeProfitLevel(targetPrice, minitrader.ProfitPercentage),
+	}
+	positionResponse, err := minitrader.createPositionWithRetries(ctx, position)
+	if err != nil {
+		minitrader.publish(OrderRejected{EventInfo: minitrader.eventInfo(), Reason: err.Error()})
+		return err
+	}
+	minitrader.publish(OrderSubmitted{
+		EventInfo:     minitrader.eventInfo(),
+		DealReference: positionResponse.DealReference,
+		EntryType:     MARKET_ENTRY,
+		Direction:     signal,
+		Level:         targetPrice,
+		Size:          amount,
+		StopLevel:     position.StopLevel,
+		ProfitLevel:   position.ProfitLevel,
+	})
+
+	confirmation, err := minitrader.getConfirmationWithRetries(ctx, positionResponse.DealReference)
+	if err != nil {
+		return err
+	}
+	if confirmation.DealStatus == string(REJECTED) {
+		minitrader.publish(OrderRejected{EventInfo: minitrader.eventInfo(), DealReference: positionResponse.DealReference, Reason: confirmation.Reason})
+		return fmt.Errorf("Position Rejected: %s", confirmation.Reason)
+	}
+
+	// the position deal id is the opened affected deal
+	dealID := confirmation.DealID
+	for _, affectedDeal := range confirmation.AffectedDeals {
+		if affectedDeal.Status == "OPENED" {
+			dealID = affectedDeal.ID
+		}
+	}
+	payedPrice := confirmation.Level
+	if payedPrice == 0 {
+		payedPrice = targetPrice
+	}
+
+	minitrader.setTrade(positionResponse.DealReference, dealID, payedPrice, amount)
+	minitrader.publish(OrderConfirmed{EventInfo: minitrader.eventInfo(), DealReference: positionResponse.DealReference, DealID: dealID, Status: confirmation.DealStatus, Reason: confirmation.Reason, Level: payedPrice, Size: amount})
+	minitrader.publish(PositionOpened{EventInfo: minitrader.eventInfo(), DealID: dealID, Level: payedPrice, Size: amount})
+
+	return minitrader.transition(HOLDING, fmt.Sprintf("position %s opened at %f", dealID, payedPrice))
 }
 
-func (minitrader *Minitrader) getAmountFromPositionOrderConfirmation() (amount float64, err error) { // TODO: Unused
-	tryCounter := 0
-	for tryCounter < 3 {
-		positionOrderResponse, err := minitrader.capitalClient.GetPositionOrderConfirmation(minitrader.activeDealReference)
-		if err != nil {
-			tryCounter++
-			time.Sleep(time.Second * 5)
-			continue
+// closeWorkingOrderEntry exits a working order entry: the order is deleted while still pending, and the
+// position it opened is closed unless its attached stop loss or take profit already did.
+func (minitrader *Minitrader) closeWorkingOrderEntry(ctx context.Context, price float64, reason string) error {
+	workingOrdersResponse, err := minitrader.broker.GetAllWorkingOrders(ctx)
+	if err != nil {
+		return err
+	}
+	for _, workingOrder := range workingOrdersResponse.WorkingOrders {
+		if workingOrder.WorkingOrderData.DealID == minitrader.activeDealID {
+			return minitrader.deleteOrder(ctx, workingOrder.WorkingOrderData.DealID)
 		}
+	}
 
-		amount = positionOrderResponse.Size
-		break
+	positionsResponse, err := minitrader.broker.GetPositions(ctx)
+	if err != nil {
+		return err
 	}
-	if tryCounter == 3 {
-		return 0, err
+	// as in closePosition, the exit is estimated at price when the attached exits got there first
+	closed := PositionClosed{
+		EventInfo:      minitrader.eventInfo(),
+		DealID:         minitrader.activeDealID,
+		EntryLevel:     minitrader.payedPrice,
+		Level:          price,
+		Size:           minitrader.payedSize,
+		ProfitLoss:     (price - minitrader.payedPrice) * minitrader.payedSize,
+		Reason:         reason,
+		ClosedByBroker: true,
+	}
+	for _, position := range positionsResponse.Positions {
+		if position.Position.DealReference != minitrader.activeDealReference {
+			continue
+		}
+		if _, err := minitrader.broker.ClosePosition(ctx, position.Position.DealID); err != nil {
+			return err
+		}
+		closed.DealID, closed.EntryLevel, closed.Size, closed.ClosedByBroker = position.Position.DealID, position.Position.Level, position.Position.Size, false
+		closed.ProfitLoss = positionProfitLoss(position, price)
+		break
 	}
-	return amount, nil
+	minitrader.publish(closed)
+	minitrader.setTrade("", "", 0, 0)
+
+	return minitrader.transition(RUNNING, "working order entry closed")
 }
 
-func (minitrader *Minitrader) createWorkingOrderWithRetries(epic string, signal Signal, orderType OrderType, targetPrice float64, amount float64) (workingOrderResponse WorkingOrderResponse, err error) {
-	tryCounter := 0
-	for tryCounter < 3 {
-		workingOrderResponse, err = minitrader.capitalClient.CreateWorkingOrder(epic, signal, orderType, targetPrice, amount)
-		if err != nil {
-			tryCounter++
-			time.Sleep(time.Second * 5)
+// closePosition closes the active market position, unless the broker already closed it.
+func (minitrader *Minitrader) closePosition(ctx context.Context, price float64, reason string) error {
+	positionsResponse, err := minitrader.broker.GetPositions(ctx)
+	if err != nil {
+		return err
+	}
+	// entries are always BUY; when the broker got there first, the exit is estimated at price
+	closed := PositionClosed{
+		EventInfo:      minitrader.eventInfo(),
+		DealID:         minitrader.activeDealID,
+		EntryLevel:     minitrader.payedPrice,
+		Level:          price,
+		Size:           minitrader.payedSize,
+		ProfitLoss:     (price - minitrader.payedPrice) * minitrader.payedSize,
+		Reason:         reason,
+		ClosedByBroker: true,
+	}
+	for _, position := range positionsResponse.Positions {
+		if position.Position.DealID != minitrader.activeDealID {
 			continue
 		}
+		if _, err := minitrader.broker.ClosePosition(ctx, minitrader.activeDealID); err != nil {
+			return err
+		}
+		closed.EntryLevel, closed.Size, closed.ClosedByBroker = position.Position.Level, position.Position.Size, false
+		closed.ProfitLoss = positionProfitLoss(position, price)
 		break
 	}
-	if tryCounter == 3 {
-		return workingOrderResponse, err
+	minitrader.publish(closed)
+	minitrader.setTrade("", "", 0, 0)
+
+	return minitrader.transition(RUNNING, "position closed")
+}
+
+func (minitrader *Minitrader) createPositionWithRetries(ctx context.Context, position CreatePositionBody) (dealReferenceResponse DealReferenceResponse, err error) {
+	for tryCounter := 0; tryCounter < 3; tryCounter++ {
+		dealReferenceResponse, err = minitrader.broker.CreatePosition(ctx, position)
+		if err == nil {
+			return dealReferenceResponse, nil
+		}
+		minitrader.logger().Warn("unable to create position", append(errorAttrs(err), "try", tryCounter+1)...)
+		if sleepErr := sleep(ctx, retryDelay); sleepErr != nil {
+			return dealReferenceResponse, err
+		}
 	}
-	return workingOrderResponse, nil
+	return dealReferenceResponse, err
 }
 
-func (minitrader *Minitrader) waitUntilConfirmationWithRetries(dealReference string) (orderPositionStatus string, err error) {
+func (minitrader *Minitrader) getConfirmationWithRetries(ctx context.Context, dealReference string) (confirmation PositionOrderConfirmationResponse, err error) {
+	for tryCounter := 0; tryCounter < 3; tryCounter++ {
+		confirmation, err = minitrader.broker.GetPositionOrderConfirmation(ctx, dealReference)
+		if err == nil {
+			return confirmation, nil
+		}
+		minitrader.logger().Warn("unable to get confirmation", append(errorAttrs(err), "deal_reference", dealReference, "try", tryCounter+1)...)
+		if sleepErr := sleep(ctx, retryDelay); sleepErr != nil {
+			return confirmation, err
+		}
+	}
+	return confirmation, err
+}
+
+func (minitrader *Minitrader) getAmountFromPositionOrderConfirmation(ctx context.Context) (amount float64, err error) {
+	confirmation, err := minitrader.getConfirmationWithRetries(ctx, minitrader.activeDealReference)
+	if err != nil {
+		return 0, err
+	}
+	return confirmation.Size, nil
+}
+
+func (minitrader *Minitrader) createWorkingOrderWithRetries(ctx context.Context, workingOrder CreateWorkingOrderBody) (workingOrderResponse WorkingOrderResponse, err error) {
 	tryCounter := 0
 	for tryCounter < 3 {
-		confirmationResponse, err := minitrader.capitalClient.GetPositionOrderConfirmation(dealReference)
+		workingOrderResponse, err = minitrader.broker.CreateWorkingOrder(ctx, workingOrder)
 		if err != nil {
 			tryCounter++
-			time.Sleep(time.Second * 5)
+			minitrader.logger().Warn("unable to create working order", append(errorAttrs(err), "try", tryCounter)...)
+			if sleepErr := sleep(ctx, retryDelay); sleepErr != nil {
+				return workingOrderResponse, err
+			}
 			continue
 		}
-
-		orderPositionStatus = confirmationResponse.Status
 		break
 	}
 	if tryCounter == 3 {
-		return "", err
+		return workingOrderResponse, err
 	}
-	return orderPositionStatus, nil
+	return workingOrderResponse, nil
 }
 
-func (minitrader *Minitrader) deleteOrder(dealReference string) error {
-	_, err := minitrader.capitalClient.DeleteWorkingOrder(dealReference)
-	if err != nil {
+func (minitrader *Minitrader) deleteOrder(ctx context.Context, dealReference string) error {
+	// a working order that is not found anymore was filled or cancelled in the meantime
+	_, err := minitrader.broker.DeleteWorkingOrder(ctx, dealReference)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return err
 	}
-	minitrader.activeDealReference = ""
-	minitrader.Status = RUNNING
-	minitrader.payedPrice = 0.0
+	minitrader.publish(OrderDeleted{EventInfo: minitrader.eventInfo(), DealID: dealReference})
+	minitrader.setTrade("", "", 0, 0)
 
-	return nil
+	return minitrader.transition(RUNNING, fmt.Sprintf("working order %s deleted", dealReference))
+}
+
+// retryDelay is the wait between tries of a broker request.
+var retryDelay = time.Second * 5
+
+// sleep waits for duration, returning early with the error of ctx if it is done first.
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,159 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Pause stops the minitrader from entering new trades; the exits of the trade it holds are still handled.
+func (minitrader *Minitrader) Pause() {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	minitrader.paused = true
+}
+
+// Resume lets a paused minitrader enter trades again. A minitrader left in an error status goes back to
+// RUNNING, or to HOLDING if it still has a trade, before its next evaluation.
+func (minitrader *Minitrader) Resume() {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	minitrader.paused = false
+	minitrader.resumeRequested = true
+}
+
+// Paused reports whether the minitrader is paused.
+func (minitrader *Minitrader) Paused() bool {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	return minitrader.paused
+}
+
+// flatten pauses the minitrader and makes it forget its trade before its next evaluation; the trades of its
+// epic are closed on the broker by the pool.
+func (minitrader *Minitrader) flatten() {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	minitrader.paused = true
+	minitrader.flattenRequested = true
+}
+
+// applyControls carries out the Resume and flatten requests. It runs on the goroutine evaluating the
+// minitrader, which is the only one touching its trade.
+func (minitrader *Minitrader) applyControls() {
+	minitrader.stateMutex.Lock()
+	resume, flatten := minitrader.resumeRequested, minitrader.flattenRequested
+	minitrader.resumeRequested, minitrader.flattenRequested = false, false
+	minitrader.stateMutex.Unlock()
+
+	if flatten {
+		minitrader.setTrade("", "", 0, 0)
+		minitrader.transition(RUNNING, "flattened")
+	}
+	if resume && minitrader.Status().IsError() {
+		status := RUNNING
+		if minitrader.activeDealID != "" {
+			status = HOLDING
+		}
+		minitrader.transition(status, "resumed")
+	}
+}
+
+// Pause stops the minitraders of epic from entering new trades.
+func (pool *MinitraderPool) Pause(epic string) error {
+	minitraders, err := pool.minitradersOf(epic)
+	if err != nil {
+		return err
+	}
+	for _, minitrader := range minitraders {
+		minitrader.Pause()
+	}
+	return nil
+}
+
+// Resume lets the minitraders of epic enter trades again, recovering them from error statuses.
+func (pool *MinitraderPool) Resume(epic string) error {
+	minitraders, err := pool.minitradersOf(epic)
+	if err != nil {
+		return err
+	}
+	for _, minitrader := range minitraders {
+		minitrader.Resume()
+	}
+	return nil
+}
+
+// Flatten pauses the minitraders of epic, deletes the working orders of epic and closes its positions.
+// Resume the epic to trade it again.
+func (pool *MinitraderPool) Flatten(ctx context.Context, epic string) error {
+	minitraders, err := pool.minitradersOf(epic)
+	if err != nil {
+		return err
+	}
+	for _, minitrader := range minitraders {
+		minitrader.flatten()
+	}
+	errs := pool.closeTradesWhere(ctx,
+		func(workingOrder WorkingOrderData) bool { return workingOrder.Epic == epic },
+		func(position PositionResponse) bool { return position.Market.Epic == epic },
+	)
+	if len(errs) != 0 {
+		return fmt.Errorf("Unable To Flatten %s: %w", epic, errs[0])
+	}
+	return nil
+}
+
+// FlattenMinitrader pauses the minitrader with the given ID, and deletes its working order or closes its
+// position. Other minitraders of the same epic keep their trades.
+func (pool *MinitraderPool) FlattenMinitrader(ctx context.Context, id string) error {
+	minitrader, err := pool.GetMinitrader(id)
+	if err != nil {
+		return err
+	}
+	minitrader.flatten()
+	dealReference, dealID := minitrader.trade()
+	if dealReference == "" && dealID == "" {
+		return nil
+	}
+	errs := pool.closeTradesWhere(ctx,
+		func(workingOrder WorkingOrderData) bool { return workingOrder.DealID == dealID },
+		func(position PositionResponse) bool {
+			return position.Position.DealID == dealID || (dealReference != "" && position.Position.DealReference == dealReference)
+		},
+	)
+	if len(errs) != 0 {
+		return fmt.Errorf("Unable To Flatten %s: %w", id, errs[0])
+	}
+	return nil
+}
+
+// Kill is the kill switch of the pool: every minitrader is paused, the working orders and positions of the
+// pool epics are closed, and the pool is stopped.
+func (pool *MinitraderPool) Kill(ctx context.Context) error {
+	groups := pool.groups()
+	for _, minitrader := range groups.minitraders {
+		minitrader.flatten()
+	}
+	errs := pool.closeTradesWhere(ctx,
+		func(workingOrder WorkingOrderData) bool { return groups.byEpic[workingOrder.Epic] != nil },
+		func(position PositionResponse) bool { return groups.byEpic[position.Market.Epic] != nil },
+	)
+	pool.Stop()
+	if len(errs) != 0 {
+		return fmt.Errorf("Unable To Close Every Trade: %w", errs[0])
+	}
+	return nil
+}
+
+// Stop makes Start return, as if its context was done.
+func (pool *MinitraderPool) Stop() {
+	pool.stop(nil)
+}
+
+func (pool *MinitraderPool) minitradersOf(epic string) ([]*Minitrader, error) {
+	minitraders, ok := pool.groups().byEpic[epic]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("No Minitrader Trades %s", epic))
+	}
+	return minitraders, nil
+}
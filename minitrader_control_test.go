@@ -0,0 +1,121 @@
+package gominitrader
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMinitraderPoolControls(t *testing.T) {
+	paper := NewPaperBroker(nil, 10000, "USD")
+	paper.UpdatePrice("USDMXN", 19.5, 19.5)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader.EntryType = MARKET_ENTRY
+	pool, err := NewMinitraderPool(paper, minitrader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minitrader.broker = pool.Broker
+	minitrader.transition(RUNNING, "started")
+	minitrader.setVolatileValues(100, 10)
+
+	tests := []struct {
+		control          func() error
+		signal           Signal
+		expectedStatus   MinitraderStatus
+		expectedPaused   bool
+		expectedPosition bool
+	}{
+		{func() error { return pool.Pause("USDMXN") }, BUY, RUNNING, true, false},
+		{func() error { return pool.Resume("USDMXN") }, BUY, HOLDING, false, true},
+		{func() error { return pool.Flatten(context.Background(), "USDMXN") }, BUY, RUNNING, true, false},
+		{func() error { minitrader.status = ERROR_ON_MAKING_ORDER; return nil }, NONE, ERROR_ON_MAKING_ORDER, true, false},
+		{func() error { return pool.Resume("USDMXN") }, NONE, RUNNING, false, false},
+	}
+	for i, test := range tests {
+		if err := test.control(); err != nil {
+			t.Fatalf("Test case %d: %v", i, err)
+		}
+		if err := minitrader.Effect(context.Background(), test.signal, 19.5); err != nil {
+			t.Fatalf("Test case %d: %v", i, err)
+		}
+		if minitrader.Status() != test.expectedStatus {
+			t.Errorf("Test case %d: expected status %s, got %s", i, test.expectedStatus, minitrader.Status())
+		}
+		if minitrader.Paused() != test.expectedPaused {
+			t.Errorf("Test case %d: expected paused=%t, got %t", i, test.expectedPaused, minitrader.Paused())
+		}
+		positions, _ := paper.GetPositions(context.Background())
+		if hasPosition := len(positions.Positions) != 0; hasPosition != test.expectedPosition {
+			t.Errorf("Test case %d: expected position=%t, got %+v", i, test.expectedPosition, positions.Positions)
+		}
+	}
+
+	if err := pool.Pause("EURUSD"); err == nil {
+		t.Errorf("expected an error for an epic the pool does not trade")
+	}
+}
+
+// _TestRejectingBroker is a PaperBroker that rejects positions while reject is set.
+type _TestRejectingBroker struct {
+	*PaperBroker
+	reject atomic.Bool
+}
+
+func (broker *_TestRejectingBroker) CreatePosition(ctx context.Context, position CreatePositionBody) (DealReferenceResponse, error) {
+	if broker.reject.Load() {
+		return DealReferenceResponse{DealReference: "o_rejected"}, nil
+	}
+	return broker.PaperBroker.CreatePosition(ctx, position)
+}
+
+func (broker *_TestRejectingBroker) GetPositionOrderConfirmation(ctx context.Context, dealReference string) (PositionOrderConfirmationResponse, error) {
+	if dealReference == "o_rejected" {
+		return PositionOrderConfirmationResponse{DealStatus: string(REJECTED), Reason: "INSUFFICIENT_FUNDS"}, nil
+	}
+	return broker.PaperBroker.GetPositionOrderConfirmation(ctx, dealReference)
+}
+
+func TestMinitraderPoolResumeAfterError(t *testing.T) {
+	feed := &_TestBroker{Account: AccountResponse{Preferred: true}}
+	// sizes are the amount available, so the price is kept below 1 for the paper account to afford it
+	feed.Prices = PricesResponse{Prices: GenerateCapitalPrices(time.Now(), MINUTE_15, 200, 0.9, 0)}
+	broker := &_TestRejectingBroker{PaperBroker: NewPaperBroker(feed, 10000, "USD")}
+	broker.UpdatePrice("USDMXN", 0.9, 0.9001)
+	broker.reject.Store(true)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, func(candles Candles) (Signal, float64) {
+		return BUY, candles[len(candles)-1].Close.Bid
+	})
+	minitrader.EntryType = MARKET_ENTRY
+	pool, err := NewMinitraderPool(broker, minitrader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan error)
+	go func() { stopped <- pool.Start(ctx) }()
+
+	waitStatus := func(status MinitraderStatus) {
+		deadline := time.Now().Add(time.Second * 5)
+		for minitrader.Status() != status {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected status %s, got %s", status, minitrader.Status())
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+	// the rejected entry leaves the minitrader in an error status, still following the candles
+	waitStatus(ERROR_ON_MAKING_ORDER)
+	broker.reject.Store(false)
+	if err := pool.Resume("USDMXN"); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(HOLDING)
+
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("expected the resumed minitrader to stop without errors, got %v", err)
+	}
+}
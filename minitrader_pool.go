@@ -1,9 +1,11 @@
 package gominitrader
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,158 +13,878 @@ import (
 )
 
 type MinitraderPool struct {
-	Minitraders   []*Minitrader
-	CapitalClient *CapitalClientAPI
+	Minitraders []*Minitrader // replaced, not modified, by AddMinitrader and RemoveMinitrader; see ListMinitraders
+	Broker      Broker
 
-	wg                         *sync.WaitGroup
+	wg *sync.WaitGroup
+
+	// guarded by layoutMutex; the maps are replaced, not modified, when minitraders are added or removed
+	layoutMutex                sync.RWMutex
 	epics                      []string                 // slice of unique epics use on minitraders
 	epicMinitraderMap          map[string][]*Minitrader // used for checking market status
-	epicTimeframeMinitraderMap map[string][]*Minitrader // used for fetching historical prices
+	epicTimeframeMinitraderMap map[string][]*Minitrader // used for fetching historical prices; keyed by epic + base timeframe
+	epicBaseTimeframe          map[string]Timeframe     // timeframe fetched for an epic and resampled for its minitraders
+	buffers                    map[string]*CandleBuffer // latest candles by epic + timeframe key
+	running                    bool                     // minitraders added while running are started right away
+	workCtx                    context.Context          // context minitraders are started with
+	restream                   context.CancelFunc       // restarts the price stream with the new epics
+	sessionLocation            *time.Location
+	journal                    Journal
+
+	candlesMutex sync.Mutex
+	stream       *PriceStream
+
+	store            CandleStore
+	storeMutex       sync.Mutex
+	storedTimestamps map[string]int64 // newest closed candle stored by key
+
+	events *EventBus
+
+	shutdown    ShutdownOptions
+	cancelMutex sync.Mutex
+	cancel      context.CancelFunc
+	failures    []error // errors that stopped the pool
+}
+
+// ShutdownOptions select what a pool does with the trades of its epics when it stops.
+type ShutdownOptions struct {
+	CancelWorkingOrders bool          // delete the pending working orders
+	ClosePositions      bool          // close the open positions
+	Timeout             time.Duration // time for in-flight orders to complete and for the clean up; 30 seconds if zero
+}
+
+// ShutdownError gathers the errors a pool stopped with, or found while cleaning up.
+type ShutdownError struct {
+	Errors []error
+}
+
+func (shutdownError *ShutdownError) Error() string {
+	messages := []string{}
+	for _, err := range shutdownError.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("Minitrader Pool Stopped With %d Errors: %s", len(shutdownError.Errors), strings.Join(messages, "; "))
+}
+
+func (shutdownError *ShutdownError) Unwrap() []error {
+	return shutdownError.Errors
 }
 
-func NewMinitraderPool(capitalClient *CapitalClientAPI, minitraders ...*Minitrader) (*MinitraderPool, error) {
+func NewMinitraderPool(broker Broker, minitraders ...*Minitrader) (*MinitraderPool, error) {
 	pool := &MinitraderPool{
-		CapitalClient: capitalClient,
-		Minitraders:   minitraders,
+		Broker:      broker,
+		Minitraders: minitraders,
 
 		wg:                         &sync.WaitGroup{},
 		epics:                      make([]string, 0),
 		epicMinitraderMap:          make(map[string][]*Minitrader),
 		epicTimeframeMinitraderMap: make(map[string][]*Minitrader),
+		epicBaseTimeframe:          make(map[string]Timeframe),
+		sessionLocation:            time.UTC,
+		buffers:                    make(map[string]*CandleBuffer),
+		storedTimestamps:           make(map[string]int64),
+		events:                     NewEventBus(),
 	}
 
-	// creates an epic minitraders map, since multiple minitraders can be using same Epic + a slice of unique epics
-	epicsSet := mapset.NewSet()
-	for _, minitrader := range minitraders {
-		epicsSet.Add(minitrader.Epic)
-		pool.epicMinitraderMap[minitrader.Epic] = append(pool.epicMinitraderMap[minitrader.Epic], minitrader)
-	}
-	for _, v := range epicsSet.ToSlice() {
-		pool.epics = append(pool.epics, v.(string))
+	for i, minitrader := range minitraders {
+		pool.register(minitrader, minitraders[:i])
 	}
 
-	// build a map for avoiding requesting same data while getting historical prices
-	// giving a key, the minitrader list for that key will contain minitraders with the same epic and timeframe
 	availablePercentage := 0.0
 	for _, minitrader := range minitraders {
-		key := minitrader.Epic + string(minitrader.Timeframe)
-		pool.epicTimeframeMinitraderMap[key] = append(pool.epicTimeframeMinitraderMap[key], minitrader)
-
 		availablePercentage += minitrader.InvestmentPercentage
 	}
 
 	if availablePercentage != 100.0 {
 		return &MinitraderPool{}, errors.New(fmt.Sprintf("Minitraders InvestmentPercentage` Sum Must Be 100.0; Current Sum: %f", availablePercentage))
 	}
+	if err := pool.groupMinitraders(); err != nil {
+		return &MinitraderPool{}, err
+	}
+	if sessionRefresher, ok := broker.(SessionRefresher); ok {
+		sessionRefresher.OnSessionRefreshed(func() {
+			pool.events.Publish(SessionRefreshed{EventInfo: EventInfo{Time: time.Now()}, Reason: "expired"})
+		})
+	}
 
 	return pool, nil
 }
 
-func (pool *MinitraderPool) Start() {
+// Subscribe returns a subscription to the events of the pool and its minitraders, of the given types or of
+// every type if none are given. Up to buffer events wait to be received; the rest are dropped, so a slow
+// subscriber never holds up trading. Unsubscribe when done.
+func (pool *MinitraderPool) Subscribe(buffer int, types ...EventType) *Subscription {
+	return pool.events.Subscribe(buffer, types...)
+}
+
+// SetSessionLocation sets the timezone day and week bars are aligned to when they are resampled from a
+// lower timeframe. It defaults to UTC and must be set before starting the pool.
+func (pool *MinitraderPool) SetSessionLocation(location *time.Location) error {
+	pool.layoutMutex.Lock()
+	defer pool.layoutMutex.Unlock()
+	pool.sessionLocation = location
+	return pool.groupMinitraders()
+}
+
+// SetCandleStore sets a store to warm minitraders up from before the first API request; closed candles
+// of the base timeframe of every epic are appended to it as they arrive. It must be set before starting
+// the pool.
+func (pool *MinitraderPool) SetCandleStore(store CandleStore) {
+	pool.store = store
+}
+
+// SetShutdownOptions sets what the pool does with its trades when Start returns.
+func (pool *MinitraderPool) SetShutdownOptions(options ShutdownOptions) {
+	pool.shutdown = options
+}
+
+// register makes minitrader part of the pool, with an ID not used by the others.
+func (pool *MinitraderPool) register(minitrader *Minitrader, others []*Minitrader) {
+	ids := map[string]bool{}
+	for _, other := range others {
+		ids[other.ID()] = true
+	}
+	id := fmt.Sprintf("%s-%s", minitrader.Epic, minitrader.Timeframe)
+	for n := 2; ids[id]; n++ {
+		id = fmt.Sprintf("%s-%s-%d", minitrader.Epic, minitrader.Timeframe, n)
+	}
+	minitrader.stateMutex.Lock()
+	minitrader.id = id
+	minitrader.stateMutex.Unlock()
+	minitrader.events = pool.events
+	minitrader.journal = pool.journal
+}
+
+// groupMinitraders builds a map for avoiding requesting same data while getting historical prices. Only one
+// base timeframe is fetched per epic; giving a key, the minitrader list for that key will contain the
+// minitraders of the epic, whose candles are resampled from the base timeframe. The buffers of the keys
+// that remain are kept. It must be called with layoutMutex locked.
+func (pool *MinitraderPool) groupMinitraders() error {
+	// creates an epic minitraders map, since multiple minitraders can be using same Epic + a slice of unique epics
+	epics := []string{}
+	epicsSet := mapset.NewSet()
+	epicMinitraderMap := make(map[string][]*Minitrader)
 	for _, minitrader := range pool.Minitraders {
-		minitrader.capitalClient = pool.CapitalClient
-		go minitrader.Start(pool.wg)
+		if epicsSet.Add(minitrader.Epic) {
+			epics = append(epics, minitrader.Epic)
+		}
+		epicMinitraderMap[minitrader.Epic] = append(epicMinitraderMap[minitrader.Epic], minitrader)
+	}
+
+	epicBaseTimeframe := make(map[string]Timeframe)
+	epicTimeframeMinitraderMap := make(map[string][]*Minitrader)
+	for epic, minitraders := range epicMinitraderMap {
+		timeframes := []Timeframe{}
+		for _, minitrader := range minitraders {
+			timeframes = append(timeframes, minitrader.Timeframe)
+		}
+		base, err := baseTimeframe(pool.sessionLocation, timeframes...)
+		if err != nil {
+			return err
+		}
+		epicBaseTimeframe[epic] = base
+		epicTimeframeMinitraderMap[epic+string(base)] = minitraders
+	}
+	buffers := make(map[string]*CandleBuffer)
+	for key, minitraders := range epicTimeframeMinitraderMap {
+		capacity := pool.candlesToFetch(epicBaseTimeframe[minitraders[0].Epic], minitraders)
+		if buffer, ok := pool.buffers[key]; ok && buffer.Capacity == capacity {
+			buffers[key] = buffer
+			continue
+		}
+		buffers[key] = NewCandleBuffer(capacity)
+	}
+
+	pool.epics = epics
+	pool.epicMinitraderMap = epicMinitraderMap
+	pool.epicBaseTimeframe = epicBaseTimeframe
+	pool.epicTimeframeMinitraderMap = epicTimeframeMinitraderMap
+	pool.buffers = buffers
+	return nil
+}
+
+// minitraderGroups are the maps built by groupMinitraders at one point in time.
+type minitraderGroups struct {
+	minitraders []*Minitrader
+	epics       []string
+	byEpic      map[string][]*Minitrader
+	byKey       map[string][]*Minitrader
+	base        map[string]Timeframe
+	buffers     map[string]*CandleBuffer
+}
+
+// groups returns the current grouping of the minitraders; it stays valid while minitraders are added or
+// removed, since the maps are replaced rather than modified.
+func (pool *MinitraderPool) groups() minitraderGroups {
+	pool.layoutMutex.RLock()
+	defer pool.layoutMutex.RUnlock()
+	return minitraderGroups{
+		minitraders: pool.Minitraders,
+		epics:       pool.epics,
+		byEpic:      pool.epicMinitraderMap,
+		byKey:       pool.epicTimeframeMinitraderMap,
+		base:        pool.epicBaseTimeframe,
+		buffers:     pool.buffers,
+	}
+}
+
+// ListMinitraders returns the minitraders of the pool; it is safe to call while the pool runs.
+func (pool *MinitraderPool) ListMinitraders() []*Minitrader {
+	return append([]*Minitrader{}, pool.groups().minitraders...)
+}
+
+// GetMinitrader returns the minitrader with the given ID.
+func (pool *MinitraderPool) GetMinitrader(id string) (*Minitrader, error) {
+	for _, minitrader := range pool.groups().minitraders {
+		if minitrader.ID() == id {
+			return minitrader, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("Minitrader %s Not Found", id))
+}
+
+// AddMinitrader adds minitrader to the pool, and starts it if the pool is running. The InvestmentPercentage
+// of the minitraders of a pool can not add up to more than 100.
+func (pool *MinitraderPool) AddMinitrader(minitrader *Minitrader) error {
+	pool.layoutMutex.Lock()
+	defer pool.layoutMutex.Unlock()
+
+	availablePercentage := minitrader.InvestmentPercentage
+	for _, other := range pool.Minitraders {
+		if other == minitrader {
+			return errors.New(fmt.Sprintf("Minitrader %s Already In The Pool", other.id))
+		}
+		availablePercentage += other.InvestmentPercentage
+	}
+	if availablePercentage > 100.0 {
+		return errors.New(fmt.Sprintf("Minitraders InvestmentPercentage` Sum Must Not Exceed 100.0; Sum With New Minitrader: %f", availablePercentage))
+	}
+
+	previous := pool.Minitraders
+	pool.register(minitrader, previous)
+	pool.Minitraders = append(append([]*Minitrader{}, previous...), minitrader)
+	if err := pool.groupMinitraders(); err != nil {
+		pool.Minitraders = previous
+		pool.groupMinitraders()
+		return err
+	}
+	if pool.running {
+		minitrader.broker = pool.Broker
 		pool.wg.Add(1)
+		go minitrader.Start(pool.workCtx, pool.wg)
+	}
+	pool.restreamMinitraders()
+	return nil
+}
+
+// RemoveMinitrader stops the minitrader with the given ID once its current evaluation is done, and removes
+// it from the pool. Its trade, if any, is left open; flatten it first to close it.
+func (pool *MinitraderPool) RemoveMinitrader(id string) (*Minitrader, error) {
+	pool.layoutMutex.Lock()
+	defer pool.layoutMutex.Unlock()
+
+	minitraders := []*Minitrader{}
+	var removed *Minitrader
+	for _, minitrader := range pool.Minitraders {
+		if minitrader.id == id {
+			removed = minitrader
+			continue
+		}
+		minitraders = append(minitraders, minitrader)
+	}
+	if removed == nil {
+		return nil, errors.New(fmt.Sprintf("Minitrader %s Not Found", id))
+	}
+
+	pool.Minitraders = minitraders
+	pool.groupMinitraders()
+	close(removed.removed)
+	pool.restreamMinitraders()
+	return removed, nil
+}
+
+// restreamMinitraders restarts the price stream, if any, so it streams the epics of the current
+// minitraders. It must be called with layoutMutex locked.
+func (pool *MinitraderPool) restreamMinitraders() {
+	if pool.restream != nil {
+		pool.restream()
 	}
-	go pool.UpdateMinitradersData(time.Second)
-	go pool.UpdateMarketStatus(time.Minute)
-	go pool.AuthenticateSession(time.Minute * 9)
-	go pool.Pulse()
+}
+
+// Start runs the minitraders until ctx is done or the session can no longer be authenticated. On the way
+// out, no more candles are sent, the orders in progress are given the shutdown Timeout to complete, and
+// the working orders and positions of the pool epics are cancelled and closed if the shutdown options say
+// so. The errors the pool stopped with are returned as a ShutdownError.
+func (pool *MinitraderPool) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pool.cancelMutex.Lock()
+	pool.cancel = cancel
+	pool.failures = nil
+	pool.cancelMutex.Unlock()
+
+	// trades held when the pool last stopped are restored before any minitrader can enter new ones; the
+	// session created for it is then kept alive by AuthenticateSession
+	if _, _, err := pool.Broker.CreateNewSession(ctx); err != nil {
+		return errors.New(fmt.Sprintf("Unable To Create Session: %s", err))
+	}
+	if err := pool.reconcile(ctx); err != nil {
+		return errors.New(fmt.Sprintf("Unable To Reconcile Trades: %s", err))
+	}
+
+	// orders outlive ctx, so they are not left half done; they are cancelled after the shutdown timeout
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	pool.layoutMutex.Lock()
+	pool.running = true
+	pool.workCtx = workCtx
+	for _, minitrader := range pool.Minitraders {
+		minitrader.broker = pool.Broker
+		pool.wg.Add(1)
+		go minitrader.Start(workCtx, pool.wg)
+	}
+	pool.layoutMutex.Unlock()
+
+	loops := sync.WaitGroup{}
+	run := func(loop func()) {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			loop()
+		}()
+	}
+	if streamingBroker, ok := pool.Broker.(StreamingBroker); ok {
+		run(func() { pool.StreamMinitradersData(ctx, streamingBroker, time.Second*5) })
+	}
+	run(func() { pool.UpdateMinitradersData(ctx, time.Second) })
+	run(func() { pool.UpdateMarketStatus(ctx, time.Minute) })
+	run(func() { pool.AuthenticateSession(ctx, time.Minute*9) })
+	run(func() { pool.Pulse(ctx) })
+	<-ctx.Done()
+
+	loops.Wait()
+	pool.layoutMutex.Lock()
+	pool.running = false
+	minitraders := pool.Minitraders
+	pool.layoutMutex.Unlock()
+	for _, minitrader := range minitraders {
+		close(minitrader.candlesChannel)
+	}
+	timer := time.AfterFunc(pool.shutdown.timeout(), cancelWork)
+	defer timer.Stop()
 	pool.wg.Wait()
+
+	pool.cancelMutex.Lock()
+	errs := append([]error{}, pool.failures...)
+	pool.cancelMutex.Unlock()
+	for _, minitrader := range minitraders {
+		if minitrader.err != nil {
+			errs = append(errs, errors.New(fmt.Sprintf("%s Minitrader: %s", minitrader.Epic, minitrader.err)))
+		}
+	}
+	errs = append(errs, pool.closeTrades(workCtx)...)
+	if len(errs) != 0 {
+		return &ShutdownError{Errors: errs}
+	}
+	return nil
 }
 
-func (pool *MinitraderPool) UpdateMarketStatus(sleepTime time.Duration) {
+// stop makes Start return, reporting err if not nil.
+func (pool *MinitraderPool) stop(err error) {
+	pool.cancelMutex.Lock()
+	defer pool.cancelMutex.Unlock()
+	if err != nil {
+		pool.failures = append(pool.failures, err)
+	}
+	if pool.cancel != nil {
+		pool.cancel()
+	}
+}
+
+func (options ShutdownOptions) timeout() time.Duration {
+	if options.Timeout <= 0 {
+		return time.Second * 30
+	}
+	return options.Timeout
+}
+
+// closeTrades cancels the working orders and closes the positions of the pool epics, as selected by the
+// shutdown options.
+func (pool *MinitraderPool) closeTrades(ctx context.Context) []error {
+	byEpic := pool.groups().byEpic
+	var workingOrders func(WorkingOrderData) bool
+	var positions func(PositionResponse) bool
+	if pool.shutdown.CancelWorkingOrders {
+		workingOrders = func(workingOrder WorkingOrderData) bool {
+			_, ok := byEpic[workingOrder.Epic]
+			return ok
+		}
+	}
+	if pool.shutdown.ClosePositions {
+		positions = func(position PositionResponse) bool {
+			_, ok := byEpic[position.Market.Epic]
+			return ok
+		}
+	}
+	return pool.closeTradesWhere(ctx, workingOrders, positions)
+}
+
+// closeTradesWhere cancels the working orders and closes the positions selected; a nil selection selects
+// none.
+func (pool *MinitraderPool) closeTradesWhere(ctx context.Context, workingOrders func(WorkingOrderData) bool, positions func(PositionResponse) bool) []error {
+	errs := []error{}
+	if workingOrders != nil {
+		workingOrdersResponse, err := pool.Broker.GetAllWorkingOrders(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, workingOrder := range workingOrdersResponse.WorkingOrders {
+			if !workingOrders(workingOrder.WorkingOrderData) {
+				continue
+			}
+			if _, err := pool.Broker.DeleteWorkingOrder(ctx, workingOrder.WorkingOrderData.DealID); err != nil && !errors.Is(err, ErrNotFound) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if positions != nil {
+		positionsResponse, err := pool.Broker.GetPositions(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, position := range positionsResponse.Positions {
+			if !positions(position) {
+				continue
+			}
+			if _, err := pool.Broker.ClosePosition(ctx, position.Position.DealID); err != nil && !errors.Is(err, ErrNotFound) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+func (pool *MinitraderPool) UpdateMarketStatus(ctx context.Context, sleepTime time.Duration) {
 	for {
-		marketsDetailsResponse, err := pool.CapitalClient.GetMarketsDetails(pool.epics)
+		groups := pool.groups()
+		marketsDetailsResponse, err := pool.Broker.GetMarketsDetails(ctx, groups.epics)
 		if err != nil {
-			// sleep and retry. AuthenticateSession goroutine should handle this; TODO: Improve error handling
-			time.Sleep(sleepTime)
+			// sleep and retry; an expired session is renewed by AuthenticateSession
+			Logger().Warn("unable to update market status", append(errorAttrs(err), "epics", groups.epics)...)
+			if sleep(ctx, sleepTime) != nil {
+				return
+			}
 			continue
 		}
 		for _, detail := range marketsDetailsResponse.MarketDetails {
 			marketStatus := MinitraderMarketStatus(detail.Snapshot.MarketStatus)
-			for _, minitrader := range pool.epicMinitraderMap[detail.Instrument.Epic] {
-				minitrader.MarketStatus = marketStatus
+			for _, minitrader := range groups.byEpic[detail.Instrument.Epic] {
+				minitrader.setMarketStatus(marketStatus)
 			}
 		}
-		time.Sleep(sleepTime)
+		if sleep(ctx, sleepTime) != nil {
+			return
+		}
 	}
 }
 
-func (pool *MinitraderPool) UpdateMinitradersData(sleepTime time.Duration) {
+func (pool *MinitraderPool) UpdateMinitradersData(ctx context.Context, sleepTime time.Duration) {
+	pool.warmUpMinitraders(ctx)
 	for {
 		// update minitraderes amountAvailable to invest
-		account, err := pool.CapitalClient.GetPreferredAccount()
+		account, err := pool.Broker.GetPreferredAccount(ctx)
 		if err != nil {
-			// sleep and retry. AuthenticateSession goroutine should handle this; TODO: Improve error handling
-			time.Sleep(sleepTime)
+			// sleep and retry; an expired session is renewed by AuthenticateSession
+			Logger().Warn("unable to update the available balance", errorAttrs(err)...)
+			if sleep(ctx, sleepTime) != nil {
+				return
+			}
 			continue
 		}
 		pool.updateMinitradersVolatileValues(account.Balance.Available)
 
-		// update minitraders candles data
-		for _, minitraders := range pool.epicTimeframeMinitraderMap {
-			epic, timeframe := minitraders[0].Epic, minitraders[0].Timeframe
-			pricesResponse, err := pool.CapitalClient.GetHistoricalPrices(epic, timeframe, 200)
-			if err != nil {
-				// sleep and retry. AuthenticateSession goroutine should handle this; TODO: Improve error handling
-				break
+		// update minitraders candles data; streamed keys are kept up to date by StreamMinitradersData
+		groups := pool.groups()
+		for key, minitraders := range groups.byKey {
+			buffer := groups.buffers[key]
+			if pool.streaming() && buffer.Len() != 0 {
+				continue
 			}
-
-			var candles Candles
-			candles.MarshalCapitalPrices(pricesResponse.Prices)
+			epic := minitraders[0].Epic
+			timeframe := groups.base[epic]
+			if err := pool.updateBuffer(ctx, buffer, epic, timeframe); err != nil {
+				// retried on the next tick; an expired session is renewed by AuthenticateSession
+				Logger().Warn("unable to update candles", append(errorAttrs(err), "epic", epic, "timeframe", timeframe)...)
+				for _, minitrader := range minitraders {
+					minitrader.transition(ERROR_ON_UPDATE_CANDLES_DATA, fmt.Sprintf("candles update failed: %s", err))
+				}
+				continue
+			}
+			candles := buffer.Candles()
+			pool.storeCandles(key, epic, timeframe, candles)
 
 			for _, minitrader := range minitraders {
-				if err != nil {
-					minitrader.Status = ERROR_ON_UPDATE_CANDLES_DATA
-					continue
-				}
-				minitrader.candlesChannel <- candles
+				pool.sendCandles(ctx, minitrader, timeframe, candles)
 			}
 		}
-		time.Sleep(sleepTime)
+		if sleep(ctx, sleepTime) != nil {
+			return
+		}
+	}
+}
+
+// StreamMinitradersData subscribes to the broker price stream and pushes every streamed update to the
+// minitraders until ctx is done. While the stream is down, UpdateMinitradersData keeps polling candles
+// through REST. When minitraders are added or removed, the stream is restarted with the new epics.
+func (pool *MinitraderPool) StreamMinitradersData(ctx context.Context, broker StreamingBroker, retryTime time.Duration) {
+	for ctx.Err() == nil {
+		streamCtx, restream := context.WithCancel(ctx)
+		pool.layoutMutex.Lock()
+		pool.restream = restream
+		pool.layoutMutex.Unlock()
+
+		// changes from here on cancel streamCtx, so the groups streamed are never outdated for long
+		pool.streamMinitraderGroups(streamCtx, broker, retryTime, pool.groups())
+		restream()
+	}
+	pool.layoutMutex.Lock()
+	pool.restream = nil
+	pool.layoutMutex.Unlock()
+}
+
+// streamMinitraderGroups streams the prices of groups until ctx is done.
+func (pool *MinitraderPool) streamMinitraderGroups(ctx context.Context, broker StreamingBroker, retryTime time.Duration, groups minitraderGroups) {
+	// the stream needs a session; wait until one has been created
+	stream, err := broker.NewPriceStream()
+	for err != nil {
+		Logger().Debug("price stream unavailable", errorAttrs(err)...)
+		if sleep(ctx, retryTime) != nil {
+			return
+		}
+		stream, err = broker.NewPriceStream()
+	}
+
+	resolutions := []Timeframe{}
+	candlesNotify := make(map[string]chan struct{}) // wakes up the streamed candles dispatcher of a key
+	dispatchers := sync.WaitGroup{}
+	defer dispatchers.Wait()
+	for key, minitraders := range groups.byKey {
+		base := groups.base[minitraders[0].Epic]
+		if !containsTimeframe(resolutions, base) {
+			resolutions = append(resolutions, base)
+		}
+		candlesNotify[key] = make(chan struct{}, 1)
+		dispatchers.Add(1)
+		go func(key string) {
+			defer dispatchers.Done()
+			pool.dispatchStreamedCandles(ctx, key, candlesNotify[key], groups)
+		}(key)
+	}
+
+	notifyCandles := func(key string) {
+		select {
+		case candlesNotify[key] <- struct{}{}:
+		default:
+		}
+	}
+	stream.OnOHLC = func(event StreamOHLC) {
+		key := event.Epic + string(event.Resolution)
+		buffer, ok := groups.buffers[key]
+		if !ok {
+			return
+		}
+		err := buffer.Apply(func(candles Candles) (Candles, error) {
+			return candles.applyStreamOHLC(event)
+		})
+		if err == nil {
+			notifyCandles(key)
+		}
+	}
+	stream.OnQuote = func(quote StreamQuote) {
+		for key, minitraders := range groups.byKey {
+			if minitraders[0].Epic != quote.Epic {
+				continue
+			}
+			groups.buffers[key].Apply(func(candles Candles) (Candles, error) {
+				candles.applyStreamQuote(quote, groups.base[quote.Epic])
+				return candles, nil
+			})
+			notifyCandles(key)
+		}
+	}
+	stream.Subscribe(groups.epics, resolutions)
+
+	pool.candlesMutex.Lock()
+	pool.stream = stream
+	pool.candlesMutex.Unlock()
+	stream.Run(ctx.Done())
+	pool.candlesMutex.Lock()
+	pool.stream = nil
+	pool.candlesMutex.Unlock()
+}
+
+// dispatchStreamedCandles sends the latest candles of a key to its minitraders every time notify is
+// signaled, until ctx is done. Updates arriving while minitraders are busy are coalesced into a single send.
+func (pool *MinitraderPool) dispatchStreamedCandles(ctx context.Context, key string, notify <-chan struct{}, groups minitraderGroups) {
+	minitraders := groups.byKey[key]
+	epic := minitraders[0].Epic
+	base := groups.base[epic]
+	for {
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return
+		}
+		candles := groups.buffers[key].Candles()
+		pool.storeCandles(key, epic, base, candles)
+
+		for _, minitrader := range minitraders {
+			pool.sendCandles(ctx, minitrader, base, candles)
+		}
+	}
+}
+
+func (pool *MinitraderPool) streaming() bool {
+	pool.candlesMutex.Lock()
+	defer pool.candlesMutex.Unlock()
+	return pool.stream != nil && pool.stream.Connected()
+}
+
+// warmUpMinitraders sends the stored candles of every key to its minitraders, so they can evaluate their
+// strategies before the first API response.
+func (pool *MinitraderPool) warmUpMinitraders(ctx context.Context) {
+	if pool.store == nil {
+		return
+	}
+	groups := pool.groups()
+	for key, minitraders := range groups.byKey {
+		epic := minitraders[0].Epic
+		base := groups.base[epic]
+		candles, err := pool.store.Last(epic, base, pool.candlesToFetch(base, minitraders))
+		if err != nil {
+			Logger().Warn("unable to warm up minitraders from the candle store", append(errorAttrs(err), "epic", epic, "timeframe", base)...)
+			continue
+		}
+		if len(candles) == 0 {
+			continue
+		}
+		pool.storeMutex.Lock()
+		pool.storedTimestamps[key] = candles[len(candles)-1].Timestamp
+		pool.storeMutex.Unlock()
+		for _, minitrader := range minitraders {
+			pool.sendCandles(ctx, minitrader, base, candles)
+		}
+	}
+}
+
+// storeCandles appends the closed candles of a key, of epic and its base timeframe, that are not stored
+// yet; the last candle is still forming, so it is left out.
+func (pool *MinitraderPool) storeCandles(key string, epic string, base Timeframe, candles Candles) {
+	if pool.store == nil || len(candles) < 2 {
+		return
+	}
+	pool.storeMutex.Lock()
+	defer pool.storeMutex.Unlock()
+	closed := candles[:len(candles)-1]
+	start := sort.Search(len(closed), func(i int) bool { return closed[i].Timestamp > pool.storedTimestamps[key] })
+	if start == len(closed) {
+		return
+	}
+	if err := pool.store.Append(epic, base, closed[start:]); err != nil {
+		Logger().Warn("unable to store candles", append(errorAttrs(err), "epic", epic, "timeframe", base)...)
+		return
 	}
+	pool.storedTimestamps[key] = closed[len(closed)-1].Timestamp
 }
 
-func (pool *MinitraderPool) AuthenticateSession(sleepTime time.Duration) {
+// AuthenticateSession creates a session and keeps it alive every sleepTime until ctx is done, by pinging it
+// when the broker is a KeepAliveBroker or by creating a new one otherwise. After three failures in a row
+// the pool is stopped.
+func (pool *MinitraderPool) AuthenticateSession(ctx context.Context, sleepTime time.Duration) {
+	keepAliveBroker, keepAlive := pool.Broker.(KeepAliveBroker)
+	authenticated := false
 	tryCounter := 0
+	var err error
 	for tryCounter < 3 {
-		_, _, err := pool.CapitalClient.CreateNewSession()
-		time.Sleep(sleepTime)
+		if authenticated && keepAlive {
+			err = keepAliveBroker.Ping(ctx)
+		} else {
+			_, _, err = pool.Broker.CreateNewSession(ctx)
+		}
+		if sleep(ctx, sleepTime) != nil {
+			return
+		}
 		if err != nil {
 			tryCounter++
+			Logger().Warn("unable to keep the session alive", append(errorAttrs(err), "try", tryCounter)...)
 		} else {
+			if !authenticated || !keepAlive {
+				pool.events.Publish(SessionRefreshed{EventInfo: EventInfo{Time: time.Now()}, Reason: "created"})
+			}
+			authenticated = true
 			tryCounter = 0
 		}
 	}
 
-	// stop minitrader_pool; TODO: improve logging
-	for i := 0; i < len(pool.Minitraders); i++ {
-		pool.wg.Done()
-	}
+	// stop minitrader_pool
+	Logger().Error("unable to authenticate session; stopping the pool", errorAttrs(err)...)
+	pool.stop(errors.New(fmt.Sprintf("Unable To Authenticate Session: %s", err)))
 }
 
-func (pool *MinitraderPool) Pulse() {
+func (pool *MinitraderPool) Pulse(ctx context.Context) {
 	for {
-		log.Print("beat.")
-		time.Sleep(time.Second)
+		Logger().Debug("beat")
+		if sleep(ctx, time.Second) != nil {
+			return
+		}
 	}
 }
 
+// updateMinitradersVolatileValues shares amountAvailable among the minitraders free to enter a trade: the
+// ones without a trade, including those in an error status, which trade again once resumed.
 func (pool *MinitraderPool) updateMinitradersVolatileValues(amountAvailable float64) {
 	var totalPercent float64
-	for _, minitrader := range pool.Minitraders {
-		if minitrader.Status == NEW || minitrader.Status == RUNNING {
+	minitraders := pool.groups().minitraders
+	free := make([]bool, len(minitraders))
+	for i, minitrader := range minitraders {
+		status := minitrader.Status()
+		_, dealID := minitrader.trade()
+		free[i] = status == NEW || status == RUNNING || (status.IsError() && dealID == "")
+		if free[i] {
 			totalPercent += minitrader.InvestmentPercentage
 		}
 	}
-	for _, minitrader := range pool.Minitraders {
-		if minitrader.Status != NEW && minitrader.Status != RUNNING {
-			minitrader.volatileInvestmentPercentage = 0
-			minitrader.volatileAmountAvailable = 0
+	for i, minitrader := range minitraders {
+		if !free[i] {
+			minitrader.setVolatileValues(0, 0)
 			continue
 		}
-		minitrader.volatileInvestmentPercentage = minitrader.InvestmentPercentage / totalPercent * 100
-		minitrader.volatileAmountAvailable = minitrader.InvestmentPercentage / 100 * amountAvailable
+		minitrader.setVolatileValues(minitrader.InvestmentPercentage/totalPercent*100, minitrader.InvestmentPercentage/100*amountAvailable)
+	}
+}
+
+// minitraderWindow is the number of candles handed to a minitrader; enough for its strategy to warm up,
+// plus the forming bar held back from the strategy with BAR_CLOSE evaluation.
+func minitraderWindow(minitrader *Minitrader) int {
+	window := BACKTEST_WINDOW
+	if minitrader.StatefulStrategy != nil && minitrader.StatefulStrategy.WarmUp() > BACKTEST_WINDOW {
+		window = minitrader.StatefulStrategy.WarmUp()
+	}
+	if minitrader.Evaluation == BAR_CLOSE {
+		window++
+	}
+	return window
+}
+
+// candlesToFetch is the number of base timeframe candles fetched for the minitraders of an epic, enough
+// to resample a full window for each of them, plus one incomplete bar.
+func (pool *MinitraderPool) candlesToFetch(base Timeframe, minitraders []*Minitrader) int {
+	baseDuration, _ := base.Duration()
+	numberOfCandles := 0
+	for _, minitrader := range minitraders {
+		duration, _ := minitrader.Timeframe.Duration()
+		ratio := int(duration / baseDuration)
+		if ratio > 1 {
+			ratio++
+		}
+		if n := minitraderWindow(minitrader) * ratio; n > numberOfCandles {
+			numberOfCandles = n
+		}
+	}
+	return numberOfCandles
+}
+
+// maxCandlesUpdate is the most candles fetched to bring a buffer up to date.
+const maxCandlesUpdate = 10
+
+// candlesSince is the number of candles to fetch to bring a buffer up to date at now: its forming bar
+// and the bars opened since, up to maxCandlesUpdate. An empty buffer is seeded with a full window; a
+// buffer further behind is seeded again by updateBuffer once the fetched candles show the gap.
+func (pool *MinitraderPool) candlesSince(buffer *CandleBuffer, timeframe Timeframe, now time.Time) int {
+	lastTimestamp := buffer.LastTimestamp()
+	duration, err := timeframe.Duration()
+	if lastTimestamp == 0 || err != nil {
+		return buffer.Capacity
+	}
+	numberOfCandles := int((now.Unix()-lastTimestamp)/int64(duration.Seconds())) + 1
+	if numberOfCandles < 1 {
+		numberOfCandles = 1
+	}
+	if numberOfCandles > maxCandlesUpdate {
+		numberOfCandles = maxCandlesUpdate
+	}
+	if numberOfCandles > buffer.Capacity {
+		numberOfCandles = buffer.Capacity
+	}
+	return numberOfCandles
+}
+
+// updateBuffer fetches the candles of epic missing from buffer. The buffer is seeded when it is empty, or
+// when the candles fetched do not reach back to its forming bar, e.g. after the pool lost the connection
+// for a while. While a market is closed, the last bars fetched are the ones already buffered.
+func (pool *MinitraderPool) updateBuffer(ctx context.Context, buffer *CandleBuffer, epic string, timeframe Timeframe) error {
+	numberOfCandles := pool.candlesSince(buffer, timeframe, time.Now())
+	pricesResponse, err := pool.Broker.GetHistoricalPrices(ctx, epic, timeframe, numberOfCandles)
+	if err != nil {
+		return err
+	}
+	var fetched Candles
+	fetched.MarshalCapitalPrices(pricesResponse.Prices)
+	lastTimestamp := buffer.LastTimestamp()
+	if lastTimestamp == 0 {
+		buffer.Seed(fetched)
+		return nil
+	}
+	if len(fetched) != 0 && fetched[0].Timestamp > lastTimestamp && numberOfCandles < buffer.Capacity {
+		pricesResponse, err = pool.Broker.GetHistoricalPrices(ctx, epic, timeframe, buffer.Capacity)
+		if err != nil {
+			return err
+		}
+		fetched = nil
+		fetched.MarshalCapitalPrices(pricesResponse.Prices)
+		buffer.Seed(fetched)
+		return nil
+	}
+	buffer.Update(fetched)
+	return nil
+}
+
+// sendCandles hands the candles of base to minitrader, resampled to its timeframe; the minitrader skips
+// the update if they can not be resampled. A minitrader left in ERROR_ON_UPDATE_CANDLES_DATA by a failed
+// update goes back to RUNNING, or HOLDING if it holds a trade.
+func (pool *MinitraderPool) sendCandles(ctx context.Context, minitrader *Minitrader, base Timeframe, candles Candles) {
+	minitraderCandles := pool.minitraderCandles(minitrader, base, candles)
+	if minitraderCandles == nil {
+		return
+	}
+	if minitrader.Status() == ERROR_ON_UPDATE_CANDLES_DATA {
+		status := RUNNING
+		if _, dealID := minitrader.trade(); dealID != "" {
+			status = HOLDING
+		}
+		minitrader.transition(status, "candles updated")
+	}
+	minitrader.send(ctx, minitraderCandles)
+}
+
+// minitraderCandles returns the last window of candles of the minitrader timeframe, resampled from
+// candles of base, the base timeframe of its epic. An incomplete first bar is dropped. It returns nil,
+// leaving the minitrader in ERROR_ON_UPDATE_CANDLES_DATA, if the candles can not be resampled.
+func (pool *MinitraderPool) minitraderCandles(minitrader *Minitrader, base Timeframe, candles Candles) Candles {
+	if minitrader.Timeframe != base && len(candles) != 0 {
+		resampled, err := candles.ResampleIn(minitrader.Timeframe, pool.sessionLocation)
+		if err != nil {
+			minitrader.logger().Error("unable to resample candles", append(errorAttrs(err), "base", base)...)
+			minitrader.transition(ERROR_ON_UPDATE_CANDLES_DATA, fmt.Sprintf("candles resample failed: %s", err))
+			return nil
+		}
+		if resampled[0].Timestamp != candles[0].Timestamp {
+			resampled = resampled[1:]
+		}
+		candles = resampled
+	}
+	if window := minitraderWindow(minitrader); len(candles) > window {
+		candles = candles[len(candles)-window:]
 	}
+	return candles
 }
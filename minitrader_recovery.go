@@ -0,0 +1,249 @@
+package gominitrader
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// brokerTrade is a position or a working order found on the broker.
+type brokerTrade struct {
+	epic          string
+	direction     string
+	dealReference string
+	dealID        string
+	level         float64
+	size          float64
+	workingOrder  bool
+}
+
+// reconcile reattaches the positions and working orders found on the broker to the minitraders that have not
+// started yet, so a pool restarted while its minitraders held trades does not enter them again. A trade goes
+// to the minitrader the journal last recorded it for, if any, and otherwise to the first free minitrader of
+// its epic; working orders only go to minitraders with working order entries, since market entries only
+// look for positions. Buy trades of the pool epics that can not be attributed are flagged as orphans.
+func (pool *MinitraderPool) reconcile(ctx context.Context) error {
+	positionsResponse, err := pool.Broker.GetPositions(ctx)
+	if err != nil {
+		return err
+	}
+	workingOrdersResponse, err := pool.Broker.GetAllWorkingOrders(ctx)
+	if err != nil {
+		return err
+	}
+	trades := []brokerTrade{}
+	for _, position := range positionsResponse.Positions {
+		trades = append(trades, brokerTrade{
+			epic:          position.Market.Epic,
+			direction:     position.Position.Direction,
+			dealReference: position.Position.DealReference,
+			dealID:        position.Position.DealID,
+			level:         position.Position.Level,
+			size:          position.Position.Size,
+		})
+	}
+	for _, workingOrder := range workingOrdersResponse.WorkingOrders {
+		trades = append(trades, brokerTrade{
+			epic:         workingOrder.WorkingOrderData.Epic,
+			direction:    workingOrder.WorkingOrderData.Direction,
+			dealID:       workingOrder.WorkingOrderData.DealID,
+			level:        workingOrder.WorkingOrderData.OrderLevel,
+			size:         workingOrder.WorkingOrderData.OrderSize,
+			workingOrder: true,
+		})
+	}
+	pool.layoutMutex.RLock()
+	journal := pool.journal
+	pool.layoutMutex.RUnlock()
+	journaled := map[string]JournalEntry{}
+	if journal != nil {
+		if journaled, err = journaledTrades(journal); err != nil {
+			return err
+		}
+	}
+
+	groups := pool.groups()
+	owners := make([]*Minitrader, len(trades))
+	fromJournal := make([]bool, len(trades))
+	claimed := map[*Minitrader]bool{}
+	for _, minitrader := range groups.minitraders {
+		if minitrader.Status() != NEW {
+			claimed[minitrader] = true
+			continue
+		}
+		entry, ok := journaled[journalKey(minitrader.Epic, minitrader.Timeframe, minitrader.strategyName())]
+		if !ok {
+			continue
+		}
+		delete(journaled, journalKey(minitrader.Epic, minitrader.Timeframe, minitrader.strategyName()))
+		i := matchingTrade(trades, owners, entry)
+		if i < 0 {
+			minitrader.logger().Info("journaled trade not found on the broker", "deal_id", entry.DealID, "journaled_deal_reference", entry.DealReference)
+			continue
+		}
+		if trades[i].dealReference == "" {
+			trades[i].dealReference = entry.DealReference
+		}
+		owners[i], fromJournal[i], claimed[minitrader] = minitrader, true, true
+	}
+
+	for i, trade := range trades {
+		if owners[i] != nil {
+			continue
+		}
+		if _, ok := groups.byEpic[trade.epic]; !ok {
+			continue
+		}
+		if trade.direction != string(BUY) {
+			pool.flagOrphan(trade, "minitraders only enter buy trades")
+			continue
+		}
+		for _, minitrader := range groups.byEpic[trade.epic] {
+			if !claimed[minitrader] && (!trade.workingOrder || minitrader.EntryType != MARKET_ENTRY) {
+				owners[i], claimed[minitrader] = minitrader, true
+				break
+			}
+		}
+		if owners[i] == nil {
+			pool.flagOrphan(trade, "no minitrader of the epic is free to hold it")
+		}
+	}
+
+	for i, trade := range trades {
+		if owners[i] != nil {
+			if err := owners[i].restore(trade, fromJournal[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pool *MinitraderPool) flagOrphan(trade brokerTrade, reason string) {
+	Logger().Warn("orphan trade found", "epic", trade.epic, "deal_id", trade.dealID, "working_order", trade.workingOrder, "direction", trade.direction, "reason", reason)
+	pool.events.Publish(OrphanTradeFound{
+		EventInfo:     EventInfo{Time: time.Now(), Epic: trade.epic},
+		DealReference: trade.dealReference,
+		DealID:        trade.dealID,
+		WorkingOrder:  trade.workingOrder,
+		Direction:     trade.direction,
+		Level:         trade.level,
+		Size:          trade.size,
+		Reason:        reason,
+	})
+}
+
+// restore makes the minitrader hold trade, as if it had entered it. A working order still pending is
+// restored as BUY_ORDER_ACTIVE, and followed by Effect until it fills or goes away.
+func (minitrader *Minitrader) restore(trade brokerTrade, fromJournal bool) error {
+	minitrader.setTrade(trade.dealReference, trade.dealID, trade.level, trade.size)
+	minitrader.publish(TradeRestored{
+		EventInfo:     minitrader.eventInfo(),
+		DealReference: trade.dealReference,
+		DealID:        trade.dealID,
+		WorkingOrder:  trade.workingOrder,
+		Level:         trade.level,
+		Size:          trade.size,
+		FromJournal:   fromJournal,
+	})
+	if trade.workingOrder {
+		return minitrader.transition(BUY_ORDER_ACTIVE, fmt.Sprintf("restored working order %s at %f", trade.dealID, trade.level))
+	}
+	return minitrader.transition(HOLDING, fmt.Sprintf("restored position %s at %f", trade.dealID, trade.level))
+}
+
+// restoredOrderInterval is how often a restored working order is looked up on the broker.
+const restoredOrderInterval = time.Minute
+
+// restoredSizeTolerance is the relative difference allowed between the size of a restored working order
+// and the size of the position it fills, which brokers may round.
+const restoredSizeTolerance = 0.01
+
+// followRestoredOrder checks the restored working order of the minitrader on the broker, at most once every
+// restoredOrderInterval. Once filled, the minitrader holds the position it opened, and goes back to RUNNING
+// if the order is gone without one. The position is the one with the deal reference or deal id of the
+// order or, since brokers may not keep either, the buy position of the epic with the size of the order.
+func (minitrader *Minitrader) followRestoredOrder(ctx context.Context) error {
+	if time.Since(minitrader.orderCheckedAt) < restoredOrderInterval {
+		return nil
+	}
+	minitrader.orderCheckedAt = time.Now()
+	dealReference, dealID := minitrader.trade()
+	workingOrdersResponse, err := minitrader.broker.GetAllWorkingOrders(ctx)
+	if err != nil {
+		return err
+	}
+	for _, workingOrder := range workingOrdersResponse.WorkingOrders {
+		if workingOrder.WorkingOrderData.DealID == dealID {
+			return nil
+		}
+	}
+
+	positionsResponse, err := minitrader.broker.GetPositions(ctx)
+	if err != nil {
+		return err
+	}
+	filled := -1
+	for i, position := range positionsResponse.Positions {
+		if position.Market.Epic != minitrader.Epic || position.Position.Direction != string(BUY) {
+			continue
+		}
+		if (dealReference != "" && position.Position.DealReference == dealReference) || position.Position.DealID == dealID {
+			filled = i
+			break
+		}
+		if filled < 0 && math.Abs(position.Position.Size-minitrader.payedSize) <= minitrader.payedSize*restoredSizeTolerance {
+			filled = i
+		}
+	}
+	if filled >= 0 {
+		position := positionsResponse.Positions[filled].Position
+		minitrader.setTrade(position.DealReference, position.DealID, position.Level, position.Size)
+		minitrader.publish(PositionOpened{EventInfo: minitrader.eventInfo(), DealID: position.DealID, Level: position.Level, Size: position.Size})
+		return minitrader.transition(HOLDING, fmt.Sprintf("restored working order %s filled at %f", dealID, position.Level))
+	}
+
+	minitrader.setTrade("", "", 0, 0)
+	minitrader.publish(OrderDeleted{EventInfo: minitrader.eventInfo(), DealID: dealID})
+	return minitrader.transition(RUNNING, fmt.Sprintf("restored working order %s gone", dealID))
+}
+
+// matchingTrade returns the index of the trade not owned yet that entry records, or -1.
+func matchingTrade(trades []brokerTrade, owners []*Minitrader, entry JournalEntry) int {
+	for i, trade := range trades {
+		if owners[i] != nil {
+			continue
+		}
+		if (entry.DealReference != "" && trade.dealReference == entry.DealReference) || (entry.DealID != "" && trade.dealID == entry.DealID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func journalKey(epic string, timeframe Timeframe, strategy string) string {
+	return epic + "/" + string(timeframe) + "/" + strategy
+}
+
+// journaledTrades returns the trades journal records as still held, by epic, timeframe and strategy: the last
+// order confirmed or trade restored of each, unless a round trip or a deletion followed it.
+func journaledTrades(journal Journal) (map[string]JournalEntry, error) {
+	entries, err := journal.Entries(JournalFilter{Types: []JournalEntryType{
+		JOURNAL_ORDER_CONFIRMED, JOURNAL_TRADE_RESTORED, JOURNAL_ORDER_DELETED, JOURNAL_ROUND_TRIP,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	trades := map[string]JournalEntry{}
+	for _, entry := range entries {
+		key := journalKey(entry.Epic, entry.Timeframe, entry.Strategy)
+		switch entry.Type {
+		case JOURNAL_ORDER_CONFIRMED, JOURNAL_TRADE_RESTORED:
+			trades[key] = entry
+		default:
+			delete(trades, key)
+		}
+	}
+	return trades, nil
+}
@@ -0,0 +1,229 @@
+package gominitrader
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMinitraderPoolReconcile(t *testing.T) {
+	paper := NewPaperBroker(nil, 10000, "USD")
+	for _, epic := range []string{"USDMXN", "USDJPY", "USDCAD", "EURUSD"} {
+		paper.UpdatePrice(epic, 20, 20)
+	}
+	paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 10})
+	paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 5})
+	paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDCAD", Direction: SELL, Size: 1})
+	paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "EURUSD", Direction: BUY, Size: 1})
+	paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDJPY", Direction: BUY, Type: LIMIT, Level: 19, Size: 3})
+
+	minitraderUSDMXN := NewMinitrader("USDMXN", 50, 5, 1, MINUTE_15, GPTStrategy)
+	minitraderUSDMXN.EntryType = MARKET_ENTRY
+	minitraderUSDJPY := NewMinitrader("USDJPY", 25, 5, 1, MINUTE_15, GPTStrategy)
+	minitraderUSDCAD := NewMinitrader("USDCAD", 25, 5, 1, MINUTE_15, GPTStrategy)
+	pool, err := NewMinitraderPool(paper, minitraderUSDMXN, minitraderUSDJPY, minitraderUSDCAD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscription := pool.Subscribe(20, EVENT_TRADE_RESTORED, EVENT_ORPHAN_TRADE_FOUND)
+	defer subscription.Unsubscribe()
+
+	if err := pool.reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		minitrader     *Minitrader
+		expectedStatus MinitraderStatus
+		expectedPrice  float64
+		expectedSize   float64
+	}{
+		{minitraderUSDMXN, HOLDING, 20, 10},
+		{minitraderUSDJPY, BUY_ORDER_ACTIVE, 19, 3},
+		{minitraderUSDCAD, NEW, 0, 0}, // sells are never restored
+	}
+	for i, test := range tests {
+		if test.minitrader.Status() != test.expectedStatus || test.minitrader.payedPrice != test.expectedPrice || test.minitrader.payedSize != test.expectedSize {
+			t.Errorf("Test case %d: expected %s at %f x %f, got %s at %f x %f", i, test.expectedStatus, test.expectedPrice, test.expectedSize,
+				test.minitrader.Status(), test.minitrader.payedPrice, test.minitrader.payedSize)
+		}
+		if _, dealID := test.minitrader.trade(); (dealID != "") != (test.expectedStatus != NEW) {
+			t.Errorf("Test case %d: unexpected deal id %q", i, dealID)
+		}
+	}
+
+	restored, orphans := []TradeRestored{}, []OrphanTradeFound{}
+	for len(subscription.Events) > 0 {
+		switch event := (<-subscription.Events).(type) {
+		case TradeRestored:
+			restored = append(restored, event)
+		case OrphanTradeFound:
+			orphans = append(orphans, event)
+		}
+	}
+	if len(restored) != 2 || restored[0].WorkingOrder || !restored[1].WorkingOrder || restored[0].FromJournal {
+		t.Errorf("unexpected restored trades %+v", restored)
+	}
+	// the second USDMXN position and the USDCAD sell; EURUSD is not traded by the pool
+	if len(orphans) != 2 || orphans[0].Epic != "USDMXN" || orphans[0].Size != 5 || orphans[1].Epic != "USDCAD" {
+		t.Errorf("unexpected orphans %+v", orphans)
+	}
+
+	// a short history, e.g. warming up from the candle store, does not exit the restored position
+	minitraderUSDMXN.broker = pool.Broker
+	signal, price := minitraderUSDMXN.onCandles(_TestCandles(20, 20.1))
+	if err := minitraderUSDMXN.Effect(context.Background(), signal, price); err != nil {
+		t.Fatal(err)
+	}
+	if minitraderUSDMXN.Status() != HOLDING {
+		t.Errorf("expected the restored position to be held, got %s", minitraderUSDMXN.Status())
+	}
+
+	// the restored position is exited like one the minitrader entered
+	paper.UpdatePrice("USDMXN", 18.9, 18.9)
+	if err := minitraderUSDMXN.Effect(context.Background(), NONE, 18.9); err != nil {
+		t.Fatal(err)
+	}
+	positions, _ := paper.GetPositions(context.Background())
+	for _, position := range positions.Positions {
+		if position.Market.Epic == "USDMXN" && position.Position.Size == 10 {
+			t.Errorf("expected the restored position to be closed")
+		}
+	}
+	if minitraderUSDMXN.Status() != RUNNING {
+		t.Errorf("expected status %s, got %s", RUNNING, minitraderUSDMXN.Status())
+	}
+
+	// the restored working order is followed until it fills, and its position is exited like any other
+	minitraderUSDJPY.broker = pool.Broker
+	fills := []struct {
+		price          float64
+		checkAgain     bool // restoredOrderInterval elapsed since the last look up
+		expectedStatus MinitraderStatus
+		expectedPrice  float64
+		expectedSize   float64
+	}{
+		{19.5, true, BUY_ORDER_ACTIVE, 19, 3}, // still pending
+		{19, false, BUY_ORDER_ACTIVE, 19, 3},  // filled, but not looked up again yet
+		{19, true, HOLDING, 19, 3},            // filled
+		{18, true, RUNNING, 0, 0},             // stop loss
+	}
+	for i, fill := range fills {
+		if fill.checkAgain {
+			minitraderUSDJPY.orderCheckedAt = time.Time{}
+		}
+		paper.UpdatePrice("USDJPY", fill.price, fill.price)
+		if err := minitraderUSDJPY.Effect(context.Background(), NONE, fill.price); err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		if minitraderUSDJPY.Status() != fill.expectedStatus || minitraderUSDJPY.payedPrice != fill.expectedPrice || minitraderUSDJPY.payedSize != fill.expectedSize {
+			t.Errorf("Test case %d: expected %s at %f x %f, got %s at %f x %f", i, fill.expectedStatus, fill.expectedPrice, fill.expectedSize,
+				minitraderUSDJPY.Status(), minitraderUSDJPY.payedPrice, minitraderUSDJPY.payedSize)
+		}
+	}
+	positions, _ = paper.GetPositions(context.Background())
+	for _, position := range positions.Positions {
+		if position.Market.Epic == "USDJPY" {
+			t.Errorf("expected the position of the restored working order to be closed, got %+v", position.Position)
+		}
+	}
+}
+
+func TestMinitraderPoolReconcileFromJournal(t *testing.T) {
+	paper := NewPaperBroker(nil, 10000, "USD")
+	paper.UpdatePrice("USDMXN", 20, 20)
+	response, _ := paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 10})
+
+	journal, _ := OpenKVJournal(filepath.Join(t.TempDir(), "journal.kv"))
+	defer journal.Close()
+	now := time.Now()
+	for _, entry := range []JournalEntry{
+		// the MINUTE_15 minitrader held the position when the pool stopped
+		{Time: now, Type: JOURNAL_ORDER_CONFIRMED, Epic: "USDMXN", Timeframe: MINUTE_15, Strategy: "GPTStrategy", DealReference: response.DealReference, Level: 20, Size: 10},
+		// the HOUR minitrader had closed its trade, and the trade of the MINUTE_5 one is gone from the broker
+		{Time: now, Type: JOURNAL_ORDER_CONFIRMED, Epic: "USDMXN", Timeframe: HOUR, Strategy: "GPTStrategy", DealReference: "o_closed", DealID: "p_closed"},
+		{Time: now, Type: JOURNAL_ROUND_TRIP, Epic: "USDMXN", Timeframe: HOUR, Strategy: "GPTStrategy", DealID: "p_closed"},
+		{Time: now, Type: JOURNAL_ORDER_CONFIRMED, Epic: "USDMXN", Timeframe: MINUTE_5, Strategy: "GPTStrategy", DealReference: "o_gone", DealID: "p_gone"},
+	} {
+		journal.Append(entry)
+	}
+
+	minitraders := []*Minitrader{
+		NewMinitrader("USDMXN", 25, 5, 1, MINUTE_5, GPTStrategy),
+		NewMinitrader("USDMXN", 25, 5, 1, HOUR, GPTStrategy),
+		NewMinitrader("USDMXN", 50, 5, 1, MINUTE_15, GPTStrategy),
+	}
+	pool, err := NewMinitraderPool(paper, minitraders...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetJournal(journal)
+	if err := pool.reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expectedStatus := range []MinitraderStatus{NEW, NEW, HOLDING} {
+		if minitraders[i].Status() != expectedStatus {
+			t.Errorf("Test case %d: expected status %s, got %s", i, expectedStatus, minitraders[i].Status())
+		}
+	}
+	if dealReference, _ := minitraders[2].trade(); dealReference != response.DealReference || math.Abs(minitraders[2].payedPrice-20) > 1e-9 {
+		t.Errorf("unexpected restored trade %s at %f", dealReference, minitraders[2].payedPrice)
+	}
+
+	// the restoration is journaled, so the trade is still attributed after another restart
+	trades, err := journaledTrades(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := trades[journalKey("USDMXN", MINUTE_15, "GPTStrategy")]; entry.Type != JOURNAL_TRADE_RESTORED || entry.DealReference != response.DealReference {
+		t.Errorf("unexpected journaled trade %+v", entry)
+	}
+}
+
+// _TestPositionsBroker is a _TestBroker with positions open.
+type _TestPositionsBroker struct {
+	_TestBroker
+	positions []PositionResponse
+}
+
+func (broker *_TestPositionsBroker) GetPositions(ctx context.Context) (PositionsResponse, error) {
+	return PositionsResponse{Positions: broker.positions}, nil
+}
+
+func TestMinitraderFollowRestoredOrder(t *testing.T) {
+	position := func(dealReference string, dealID string, size float64) PositionResponse {
+		var position PositionResponse
+		position.Market.Epic = "USDJPY"
+		position.Position = PositionData{DealReference: dealReference, DealID: dealID, Direction: string(BUY), Level: 19, Size: size}
+		return position
+	}
+	tests := []struct {
+		dealReference         string
+		positions             []PositionResponse
+		expectedStatus        MinitraderStatus
+		expectedDealReference string
+	}{
+		// the deal reference of the order wins over a position of the same size
+		{"o_1", []PositionResponse{position("o_2", "p_2", 3), position("o_1", "p_1", 3.02)}, HOLDING, "o_1"},
+		// the deal id of the order
+		{"", []PositionResponse{position("o_2", "p_2", 5), position("o_3", "w_1", 5)}, HOLDING, "o_3"},
+		// no reference; the size rounded by the broker
+		{"", []PositionResponse{position("o_2", "p_2", 2.999)}, HOLDING, "o_2"},
+		{"", []PositionResponse{position("o_2", "p_2", 5)}, RUNNING, ""},
+	}
+	for i, test := range tests {
+		minitrader := NewMinitrader("USDJPY", 100, 5, 1, MINUTE_15, GPTStrategy)
+		minitrader.broker = &_TestPositionsBroker{positions: test.positions}
+		minitrader.restore(brokerTrade{epic: "USDJPY", direction: string(BUY), dealReference: test.dealReference, dealID: "w_1", level: 19, size: 3, workingOrder: true}, false)
+
+		if err := minitrader.followRestoredOrder(context.Background()); err != nil {
+			t.Fatalf("Test case %d: %s", i, err)
+		}
+		if dealReference, _ := minitrader.trade(); minitrader.Status() != test.expectedStatus || dealReference != test.expectedDealReference {
+			t.Errorf("Test case %d: expected %s with %q, got %s with %q", i, test.expectedStatus, test.expectedDealReference, minitrader.Status(), dealReference)
+		}
+	}
+}
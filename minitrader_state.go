@@ -0,0 +1,215 @@
+package gominitrader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Transition is a change of status of a minitrader.
+type Transition struct {
+	From   MinitraderStatus
+	To     MinitraderStatus
+	Reason string
+	Time   time.Time
+}
+
+// minitraderTransitions are the statuses a minitrader can move to from each status:
+// NEW → RUNNING → BUY_ORDER_ACTIVE → HOLDING → SELL_ORDER_ACTIVE → RUNNING, with orders that are deleted
+// or exits that close going back to RUNNING, error statuses that are left by resuming, and NEW going straight
+// to HOLDING, or to BUY_ORDER_ACTIVE for a pending working order, when the pool restores a trade found on
+// the broker.
+var minitraderTransitions = map[MinitraderStatus][]MinitraderStatus{
+	NEW:                          {RUNNING, BUY_ORDER_ACTIVE, HOLDING, ERROR_ON_UPDATE_CANDLES_DATA},
+	RUNNING:                      {BUY_ORDER_ACTIVE, SELL_ORDER_ACTIVE, ERROR_ON_UPDATE_CANDLES_DATA, ERROR_ON_MAKING_ORDER},
+	BUY_ORDER_ACTIVE:             {HOLDING, RUNNING, ERROR_ON_MAKING_ORDER},
+	HOLDING:                      {SELL_ORDER_ACTIVE, RUNNING, ERROR_ON_UPDATE_CANDLES_DATA, ERROR_ON_DELETING_ORDER},
+	SELL_ORDER_ACTIVE:            {RUNNING, HOLDING, ERROR_ON_MAKING_ORDER},
+	ERROR_ON_UPDATE_CANDLES_DATA: {RUNNING, HOLDING},
+	ERROR_ON_MAKING_ORDER:        {RUNNING, HOLDING},
+	ERROR_ON_DELETING_ORDER:      {RUNNING, HOLDING},
+}
+
+// IllegalTransitionError is returned when a minitrader is asked to move to a status it can not reach from
+// its current one.
+type IllegalTransitionError struct {
+	From MinitraderStatus
+	To   MinitraderStatus
+}
+
+func (err *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("Illegal Minitrader Transition From %s To %s", err.From, err.To)
+}
+
+// Status returns the current status of the minitrader.
+func (minitrader *Minitrader) Status() MinitraderStatus {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	return minitrader.status
+}
+
+// MarketStatus returns the last known status of the market of the minitrader epic.
+func (minitrader *Minitrader) MarketStatus() MinitraderMarketStatus {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	return minitrader.marketStatus
+}
+
+// transition moves the minitrader to status, and notifies OnTransition. Moving to the current status does
+// nothing; moving to a status not reachable from the current one returns an IllegalTransitionError.
+func (minitrader *Minitrader) transition(status MinitraderStatus, reason string) error {
+	minitrader.transitionMutex.Lock()
+	defer minitrader.transitionMutex.Unlock()
+
+	minitrader.stateMutex.Lock()
+	from := minitrader.status
+	if from == status {
+		minitrader.stateMutex.Unlock()
+		return nil
+	}
+	if !containsStatus(minitraderTransitions[from], status) {
+		minitrader.stateMutex.Unlock()
+		return &IllegalTransitionError{From: from, To: status}
+	}
+	minitrader.status = status
+	minitrader.stateMutex.Unlock()
+
+	transition := Transition{From: from, To: status, Reason: reason, Time: time.Now()}
+	level := slog.LevelInfo
+	if status.IsError() {
+		level = slog.LevelWarn
+	}
+	minitrader.logger().Log(context.Background(), level, "status changed", "from", from, "to", status, "reason", reason)
+	if minitrader.OnTransition != nil {
+		minitrader.OnTransition(minitrader, transition)
+	}
+	minitrader.publish(StatusChanged{EventInfo: EventInfo{Time: transition.Time, Epic: minitrader.Epic, Timeframe: minitrader.Timeframe}, Transition: transition})
+	return nil
+}
+
+func (minitrader *Minitrader) setMarketStatus(marketStatus MinitraderMarketStatus) {
+	minitrader.stateMutex.Lock()
+	from := minitrader.marketStatus
+	minitrader.marketStatus = marketStatus
+	minitrader.stateMutex.Unlock()
+	if from != marketStatus {
+		minitrader.publish(MarketStatusChanged{EventInfo: minitrader.eventInfo(), From: from, To: marketStatus})
+	}
+}
+
+// setVolatileValues sets the share of the available balance the minitrader can invest.
+func (minitrader *Minitrader) setVolatileValues(investmentPercentage float64, amountAvailable float64) {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	minitrader.volatileInvestmentPercentage = investmentPercentage
+	minitrader.volatileAmountAvailable = amountAvailable
+}
+
+func (minitrader *Minitrader) volatileValues() (investmentPercentage float64, amountAvailable float64) {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	return minitrader.volatileInvestmentPercentage, minitrader.volatileAmountAvailable
+}
+
+// setTrade records the trade the minitrader holds; empty values mean there is none.
+func (minitrader *Minitrader) setTrade(dealReference string, dealID string, payedPrice float64, size float64) {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	minitrader.activeDealReference = dealReference
+	minitrader.activeDealID = dealID
+	minitrader.payedPrice = payedPrice
+	minitrader.payedSize = size
+}
+
+// trade returns the trade the minitrader holds, for goroutines other than the one evaluating it.
+func (minitrader *Minitrader) trade() (dealReference string, dealID string) {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	return minitrader.activeDealReference, minitrader.activeDealID
+}
+
+func (minitrader *Minitrader) setLastEvaluation(candles Candles, signal Signal, price float64) {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	minitrader.candles = candles
+	minitrader.signal = signal
+	minitrader.signalPrice = price
+	minitrader.signalTime = time.Now()
+}
+
+// MinitraderSnapshot is the state of a minitrader at one point in time.
+type MinitraderSnapshot struct {
+	ID                           string
+	Epic                         string
+	Timeframe                    Timeframe
+	Status                       MinitraderStatus
+	MarketStatus                 MinitraderMarketStatus
+	Paused                       bool
+	InvestmentPercentage         float64
+	VolatileInvestmentPercentage float64 // share of the balance while other minitraders hold trades
+	AmountAvailable              float64
+	ActiveDealReference          string
+	ActiveDealID                 string
+	EntryPrice                   float64
+	Size                         float64
+	Signal                       Signal // last signal generated, and the price and time it was generated at
+	SignalPrice                  float64
+	SignalTime                   time.Time
+}
+
+// ID identifies the minitrader within its pool, e.g. `USDMXN-MINUTE_15`.
+func (minitrader *Minitrader) ID() string {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	return minitrader.id
+}
+
+// Snapshot returns the current state of the minitrader; it is safe to call while the pool runs.
+func (minitrader *Minitrader) Snapshot() MinitraderSnapshot {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	return MinitraderSnapshot{
+		ID:                           minitrader.id,
+		Epic:                         minitrader.Epic,
+		Timeframe:                    minitrader.Timeframe,
+		Status:                       minitrader.status,
+		MarketStatus:                 minitrader.marketStatus,
+		Paused:                       minitrader.paused,
+		InvestmentPercentage:         minitrader.InvestmentPercentage,
+		VolatileInvestmentPercentage: minitrader.volatileInvestmentPercentage,
+		AmountAvailable:              minitrader.volatileAmountAvailable,
+		ActiveDealReference:          minitrader.activeDealReference,
+		ActiveDealID:                 minitrader.activeDealID,
+		EntryPrice:                   minitrader.payedPrice,
+		Size:                         minitrader.payedSize,
+		Signal:                       minitrader.signal,
+		SignalPrice:                  minitrader.signalPrice,
+		SignalTime:                   minitrader.signalTime,
+	}
+}
+
+// LastCandles returns the last candles the minitrader evaluated, up to count of them if count is positive.
+func (minitrader *Minitrader) LastCandles(count int) Candles {
+	minitrader.stateMutex.Lock()
+	defer minitrader.stateMutex.Unlock()
+	candles := minitrader.candles
+	if count > 0 && len(candles) > count {
+		candles = candles[len(candles)-count:]
+	}
+	return append(Candles{}, candles...)
+}
+
+// IsError reports whether status is one of the error statuses a minitrader is left in when an order fails.
+func (status MinitraderStatus) IsError() bool {
+	return status == ERROR_ON_UPDATE_CANDLES_DATA || status == ERROR_ON_MAKING_ORDER || status == ERROR_ON_DELETING_ORDER
+}
+
+func containsStatus(statuses []MinitraderStatus, status MinitraderStatus) bool {
+	for _, candidate := range statuses {
+		if candidate == status {
+			return true
+		}
+	}
+	return false
+}
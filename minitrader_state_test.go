@@ -0,0 +1,68 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMinitraderTransitions(t *testing.T) {
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	transitions := []Transition{}
+	minitrader.OnTransition = func(minitrader *Minitrader, transition Transition) {
+		transitions = append(transitions, transition)
+	}
+
+	tests := []struct {
+		status        MinitraderStatus
+		expectedLegal bool
+	}{
+		{SELL_ORDER_ACTIVE, false},
+		{RUNNING, true},
+		{RUNNING, true}, // staying is not a transition
+		{SELL_ORDER_ACTIVE, true},
+		{HOLDING, true},
+		{BUY_ORDER_ACTIVE, false},
+		{ERROR_ON_MAKING_ORDER, false}, // failed exits are ERROR_ON_DELETING_ORDER
+		{ERROR_ON_DELETING_ORDER, true},
+		{BUY_ORDER_ACTIVE, false},
+		{RUNNING, true},
+		{NEW, false},
+	}
+	for i, test := range tests {
+		from := minitrader.Status()
+		err := minitrader.transition(test.status, "test")
+		var illegal *IllegalTransitionError
+		if legal := !errors.As(err, &illegal); legal != test.expectedLegal {
+			t.Errorf("Test case %d: expected legal=%t from %s to %s, got %v", i, test.expectedLegal, from, test.status, err)
+		}
+		if !test.expectedLegal && minitrader.Status() != from {
+			t.Errorf("Test case %d: an illegal transition should not change the status, got %s", i, minitrader.Status())
+		}
+	}
+
+	if len(transitions) != 5 {
+		t.Fatalf("expected 5 transition events, got %+v", transitions)
+	}
+	if transitions[0].From != NEW || transitions[0].To != RUNNING || transitions[0].Reason != "test" || transitions[0].Time.IsZero() {
+		t.Errorf("unexpected transition %+v", transitions[0])
+	}
+}
+
+func TestMinitraderEffectTransitions(t *testing.T) {
+	broker := &_TestBroker{Confirmation: PositionOrderConfirmationResponse{DealID: "p_1", DealStatus: "ACCEPTED", Level: 19.5}}
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader.EntryType = MARKET_ENTRY
+	minitrader.broker = broker
+	minitrader.transition(RUNNING, "started")
+	minitrader.setVolatileValues(100, 10)
+	statuses := []MinitraderStatus{}
+	minitrader.OnTransition = func(minitrader *Minitrader, transition Transition) {
+		statuses = append(statuses, transition.To)
+	}
+
+	minitrader.Effect(context.Background(), BUY, 19.5)
+	if len(statuses) != 2 || statuses[0] != BUY_ORDER_ACTIVE || statuses[1] != HOLDING {
+		t.Errorf("expected BUY_ORDER_ACTIVE and HOLDING, got %v", statuses)
+	}
+}
@@ -1,13 +1,14 @@
 package gominitrader
 
 import (
+	"context"
 	"math"
 	"testing"
 	"time"
 )
 
-func _TestNewPool() *MinitraderPool {
-	capClient, _ := _TestCapitalClient()
+func _TestNewPool(t *testing.T) *MinitraderPool {
+	capClient, _ := _TestCapitalClient(t)
 	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
 
 	minitraderPool, _ := NewMinitraderPool(
@@ -19,11 +20,11 @@ func _TestNewPool() *MinitraderPool {
 }
 
 func TestMinitraderPoolUpdateCandlesData(t *testing.T) {
-	pool := _TestNewPool()
+	pool := _TestNewPool(t)
 	minitrader := pool.Minitraders[0]
 
-	go pool.AuthenticateSession(time.Minute)
-	go pool.UpdateMinitradersData(time.Second)
+	go pool.AuthenticateSession(context.Background(), time.Minute)
+	go pool.UpdateMinitradersData(context.Background(), time.Second)
 
 	select {
 	case candles := <-minitrader.candlesChannel:
@@ -34,11 +35,11 @@ func TestMinitraderPoolUpdateCandlesData(t *testing.T) {
 }
 
 func TestStrategy(t *testing.T) {
-	pool := _TestNewPool()
+	pool := _TestNewPool(t)
 	minitrader := pool.Minitraders[0]
 
-	go pool.AuthenticateSession(time.Minute)
-	go pool.UpdateMinitradersData(time.Second)
+	go pool.AuthenticateSession(context.Background(), time.Minute)
+	go pool.UpdateMinitradersData(context.Background(), time.Second)
 
 	select {
 	case candles := <-minitrader.candlesChannel:
@@ -53,12 +54,12 @@ func TestMinitradersAmount(t *testing.T) {
 	toleranceError := 1e-5
 
 	t.Run("2 Minitraders", func(t *testing.T) {
-		capClient, _ := _TestCapitalClient()
+		broker := &_TestBroker{}
 
 		minitrader1 := NewMinitrader("USDMXN", 50, 5, 0.25, MINUTE_15, GPTStrategy)
 		minitrader2 := NewMinitrader("USDCAD", 50, 5, 0.25, MINUTE_15, GPTStrategy)
 		minitraderPool, _ := NewMinitraderPool(
-			capClient,
+			broker,
 			minitrader1,
 			minitrader2,
 		)
@@ -74,7 +75,7 @@ func TestMinitradersAmount(t *testing.T) {
 		}
 
 		for i, test := range tests {
-			minitrader1.Status, minitrader2.Status = test.minitraderStatus[0], test.minitraderStatus[1]
+			minitrader1.status, minitrader2.status = test.minitraderStatus[0], test.minitraderStatus[1]
 			minitraderPool.updateMinitradersVolatileValues(0)
 
 			if math.Abs(minitrader1.volatileInvestmentPercentage-test.expectedResult[0]) > toleranceError {
@@ -87,11 +88,11 @@ func TestMinitradersAmount(t *testing.T) {
 	})
 
 	t.Run("3 Minitraders", func(t *testing.T) {
-		capClient, _ := _TestCapitalClient()
+		broker := &_TestBroker{}
 		minitrader1 := NewMinitrader("USDMXN", 10, 3, 0.25, MINUTE_15, GPTStrategy)
 		minitrader2 := NewMinitrader("USDCAD", 30, 5, 0.25, MINUTE_30, GPTStrategy)
 		minitrader3 := NewMinitrader("USDJPN", 60, 5, 0.25, MINUTE_5, GPTStrategy)
-		minitraderPool, _ := NewMinitraderPool(capClient, minitrader1, minitrader2, minitrader3)
+		minitraderPool, _ := NewMinitraderPool(broker, minitrader1, minitrader2, minitrader3)
 
 		tests := []struct {
 			minitraderStatus []MinitraderStatus
@@ -104,7 +105,7 @@ func TestMinitradersAmount(t *testing.T) {
 		}
 
 		for i, test := range tests {
-			minitrader1.Status, minitrader2.Status, minitrader3.Status = test.minitraderStatus[0], test.minitraderStatus[1], test.minitraderStatus[2]
+			minitrader1.status, minitrader2.status, minitrader3.status = test.minitraderStatus[0], test.minitraderStatus[1], test.minitraderStatus[2]
 			minitraderPool.updateMinitradersVolatileValues(0)
 
 			if math.Abs(minitrader1.volatileInvestmentPercentage-test.expectedResult[0]) > toleranceError {
@@ -119,3 +120,230 @@ func TestMinitradersAmount(t *testing.T) {
 		}
 	})
 }
+
+func TestMinitraderEffectWithBroker(t *testing.T) {
+	broker := &_TestBroker{Confirmation: PositionOrderConfirmationResponse{Status: "OPEN"}}
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitraderPool, err := NewMinitraderPool(broker, minitrader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minitrader.broker = minitraderPool.Broker
+	minitrader.status = RUNNING
+	minitrader.volatileAmountAvailable = 1000
+
+	if err := minitrader.Effect(context.Background(), BUY, 19.5); err != nil {
+		t.Fatal(err)
+	}
+	if minitrader.Status() != HOLDING {
+		t.Errorf("expected status %s, got %s", HOLDING, minitrader.Status())
+	}
+	if len(broker.WorkingOrders) != 1 || broker.WorkingOrders[0].Level != 19.5 || broker.WorkingOrders[0].Size != 1000 {
+		t.Errorf("unexpected working orders: %+v", broker.WorkingOrders)
+	}
+	if minitrader.activeDealReference != "o_test" || minitrader.payedPrice != 19.5 {
+		t.Errorf("unexpected deal state: %s %f", minitrader.activeDealReference, minitrader.payedPrice)
+	}
+	if math.Abs(broker.WorkingOrders[0].StopLevel-18.525) > 1e-9 || math.Abs(broker.WorkingOrders[0].ProfitLevel-19.5975) > 1e-9 {
+		t.Errorf("stop loss and take profit should be attached: %+v", broker.WorkingOrders[0])
+	}
+}
+
+func TestMinitraderWorkingOrderEntryExit(t *testing.T) {
+	paper := NewPaperBroker(nil, 1000, "USD")
+	paper.UpdatePrice("USDMXN", 20.5, 20.5)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 1, MINUTE_15, GPTStrategy)
+	minitrader.broker = paper
+	minitrader.events = NewEventBus()
+	subscription := minitrader.events.Subscribe(10, EVENT_POSITION_CLOSED)
+	defer subscription.Unsubscribe()
+	minitrader.status = RUNNING
+	minitrader.volatileAmountAvailable = 10
+
+	// the entry is still pending when the stop loss is hit, so it is deleted
+	if err := minitrader.Effect(context.Background(), BUY, 20); err != nil {
+		t.Fatal(err)
+	}
+	if minitrader.Status() != HOLDING || minitrader.activeDealID == "" {
+		t.Fatalf("unexpected minitrader state %s %s", minitrader.Status(), minitrader.activeDealID)
+	}
+	if err := minitrader.Effect(context.Background(), NONE, 18.9); err != nil {
+		t.Fatal(err)
+	}
+	workingOrders, _ := paper.GetAllWorkingOrders(context.Background())
+	if minitrader.Status() != RUNNING || len(workingOrders.WorkingOrders) != 0 {
+		t.Errorf("pending entry should be deleted, status %s, %d working orders", minitrader.Status(), len(workingOrders.WorkingOrders))
+	}
+
+	// a filled entry keeps its take profit on the broker; the minitrader only closes what is left open
+	if err := minitrader.Effect(context.Background(), BUY, 20); err != nil {
+		t.Fatal(err)
+	}
+	paper.UpdatePrice("USDMXN", 20, 20)
+	positions, _ := paper.GetPositions(context.Background())
+	if len(positions.Positions) != 1 || math.Abs(positions.Positions[0].Position.ProfitLevel-20.2) > 1e-9 {
+		t.Fatalf("filled entry should carry its take profit: %+v", positions.Positions)
+	}
+	paper.UpdatePrice("USDMXN", 20.3, 20.3)
+	if err := minitrader.Effect(context.Background(), NONE, 20.3); err != nil {
+		t.Fatal(err)
+	}
+	account, _ := paper.GetPreferredAccount(context.Background())
+	if minitrader.Status() != RUNNING || math.Abs(account.Balance.Balance-1003) > 1e-9 {
+		t.Errorf("expected take profit at 20.3, status %s, balance %f", minitrader.Status(), account.Balance.Balance)
+	}
+	// the round trip closed by the broker is still published, estimated at the price it was noticed at
+	if len(subscription.Events) != 1 {
+		t.Fatalf("expected a single position closed, got %d", len(subscription.Events))
+	}
+	if closed := (<-subscription.Events).(PositionClosed); !closed.ClosedByBroker || closed.Reason != "TAKE_PROFIT" || math.Abs(closed.ProfitLoss-3) > 1e-9 {
+		t.Errorf("unexpected position closed %+v", closed)
+	}
+}
+
+func TestMinitraderPoolFansOutTimeframes(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	capClient.CreateNewSession(context.Background())
+	minitrader15m := NewMinitrader("USDMXN", 50, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader2h := NewMinitrader("USDMXN", 50, 5, 0.5, "HOUR_2", GPTStrategy)
+	pool, err := NewMinitraderPool(capClient, minitrader15m, minitrader2h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.epicTimeframeMinitraderMap) != 1 || pool.epicBaseTimeframe["USDMXN"] != MINUTE_15 {
+		t.Fatalf("expected a single MINUTE_15 fetch for USDMXN, got %v", pool.epicBaseTimeframe)
+	}
+	go pool.UpdateMinitradersData(context.Background(), time.Minute)
+
+	tests := []struct {
+		minitrader     *Minitrader
+		expectedLength int
+		expectedStep   int64
+	}{
+		{minitrader15m, 200, 15 * 60},
+		{minitrader2h, 62, 2 * 60 * 60}, // 500 emulated bars of 15 minutes, without the incomplete first one
+	}
+	for i, test := range tests {
+		select {
+		case candles := <-test.minitrader.candlesChannel:
+			if len(candles) < test.expectedLength || len(candles) > test.expectedLength+1 {
+				t.Errorf("Test case %d: expected about %d candles, got %d", i, test.expectedLength, len(candles))
+			}
+			for j := 1; j < len(candles); j++ {
+				if candles[j].Timestamp-candles[j-1].Timestamp != test.expectedStep || candles[j].Timestamp%test.expectedStep != 0 {
+					t.Fatalf("Test case %d: unexpected bar %d at %d", i, j, candles[j].Timestamp)
+				}
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Test case %d: candles not received", i)
+		}
+	}
+}
+
+func TestMinitraderPoolResampleError(t *testing.T) {
+	minitrader15m := NewMinitrader("USDMXN", 50, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader2h := NewMinitrader("USDMXN", 50, 5, 0.5, "HOUR_2", GPTStrategy)
+	pool, err := NewMinitraderPool(&_TestBroker{}, minitrader15m, minitrader2h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unsorted candles can not be resampled
+	candles := Candles{{Timestamp: 7200}, {Timestamp: 900}}
+	if resampled := pool.minitraderCandles(minitrader2h, MINUTE_15, candles); resampled != nil || minitrader2h.Status() != ERROR_ON_UPDATE_CANDLES_DATA {
+		t.Errorf("expected the HOUR_2 minitrader to skip the update in %s, got %d candles in %s", ERROR_ON_UPDATE_CANDLES_DATA, len(resampled), minitrader2h.Status())
+	}
+	if resampled := pool.minitraderCandles(minitrader15m, MINUTE_15, candles); len(resampled) != 2 || minitrader15m.Status() != NEW {
+		t.Errorf("expected the MINUTE_15 minitrader to get the candles as they are, got %d candles in %s", len(resampled), minitrader15m.Status())
+	}
+}
+
+func TestMinitraderPoolShutdown(t *testing.T) {
+	feed := &_TestBroker{Account: AccountResponse{Preferred: true}}
+	feed.Prices = PricesResponse{Prices: GenerateCapitalPrices(time.Now(), MINUTE_15, 200, 19.5, 0.01)}
+
+	tests := []struct {
+		options               ShutdownOptions
+		expectedWorkingOrders int
+		expectedPositions     int
+	}{
+		{ShutdownOptions{}, 2, 2},
+		{ShutdownOptions{CancelWorkingOrders: true}, 1, 2},
+		{ShutdownOptions{CancelWorkingOrders: true, ClosePositions: true}, 1, 1}, // EURUSD is not traded by the pool
+	}
+	for i, test := range tests {
+		paper := NewPaperBroker(feed, 10000, "USD")
+		paper.UpdatePrice("USDMXN", 19.5, 19.51)
+		paper.UpdatePrice("EURUSD", 1.1, 1.1001)
+		// the USDMXN trades are sells, so the minitrader does not restore them on start
+		paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: SELL, Type: LIMIT, Level: 30, Size: 1})
+		paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "EURUSD", Direction: BUY, Type: LIMIT, Level: 0.5, Size: 1})
+		paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: SELL, Size: 1})
+		paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "EURUSD", Direction: BUY, Size: 1})
+
+		pool, _ := NewMinitraderPool(paper, NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, func(candles Candles) (Signal, float64) { return NONE, 0 }))
+		pool.SetShutdownOptions(test.options)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+		err := pool.Start(ctx)
+		cancel()
+		if err != nil {
+			t.Errorf("Test case %d: unexpected error %v", i, err)
+		}
+
+		workingOrders, _ := paper.GetAllWorkingOrders(context.Background())
+		positions, _ := paper.GetPositions(context.Background())
+		if len(workingOrders.WorkingOrders) != test.expectedWorkingOrders || len(positions.Positions) != test.expectedPositions {
+			t.Errorf("Test case %d: expected %d working orders and %d positions, got %d and %d", i, test.expectedWorkingOrders, test.expectedPositions, len(workingOrders.WorkingOrders), len(positions.Positions))
+		}
+	}
+}
+
+func TestMinitraderEffectCancelled(t *testing.T) {
+	broker := &_TestFailingBroker{}
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader.broker = broker
+	minitrader.status = RUNNING
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	start := time.Now()
+	if err := minitrader.Effect(ctx, BUY, 19.5); err == nil {
+		t.Errorf("expected the order to fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected retries to stop with the context, took %s", elapsed)
+	}
+}
+
+func TestMinitraderAmountConfirmationFailed(t *testing.T) {
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+
+	broker := &_TestUnconfirmedBroker{}
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader.broker = broker
+	minitrader.activeDealReference = "o_test"
+
+	amount, err := minitrader.getAmountFromPositionOrderConfirmation(context.Background())
+	if err == nil || amount != 0 || broker.tries != 3 {
+		t.Errorf("expected the error after 3 tries, got %f, %v after %d tries", amount, err, broker.tries)
+	}
+}
+
+func TestMinitraderPoolKeepAlive(t *testing.T) {
+	emulator := _TestCapitalEmulator(t)
+	capClient, _ := emulator.NewClient()
+	pool, _ := NewMinitraderPool(capClient, NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	pool.AuthenticateSession(ctx, time.Millisecond*20)
+
+	// one login, then pings
+	if sessions := _TestEmulatorSessions(emulator); sessions != 1 {
+		t.Errorf("expected a single session, got %d", sessions)
+	}
+	if !capClient.hasSession() {
+		t.Errorf("expected the session to be created")
+	}
+}
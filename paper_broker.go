@@ -0,0 +1,796 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PaperBroker is a simulated Broker. It keeps a virtual account and fills working orders locally
+// whenever bid/ask prices cross their level, so a MinitraderPool can run without sending orders to
+// Capital.com. Prices come either from a Feed broker (real market data) or from UpdatePrice calls.
+type PaperBroker struct {
+	Feed     Broker
+	Currency string
+
+	mutex         sync.Mutex
+	sequence      int
+	deposit       float64
+	realized      float64
+	quotes        map[string]BidAskPrice
+	workingOrders []*paperWorkingOrder
+	positions     []*paperPosition
+	confirmations map[string]*PositionOrderConfirmationResponse
+}
+
+var _ Broker = (*PaperBroker)(nil)
+
+type paperWorkingOrder struct {
+	dealID        string
+	dealReference string
+	epic          string
+	direction     Signal
+	orderType     OrderType
+	level         float64
+	size          float64
+	createdAt     time.Time
+	goodTill      time.Time // zero for good till cancelled orders
+
+	// protective exits attached to the position opened by the order
+	guaranteedStop bool
+	trailingStop   bool
+	stopLevel      float64
+	stopDistance   float64
+	profitLevel    float64
+	profitDistance float64
+}
+
+type paperPosition struct {
+	dealID        string
+	dealReference string
+	epic          string
+	direction     Signal
+	level         float64
+	size          float64
+	createdAt     time.Time
+
+	// protective exits handled by the broker
+	guaranteedStop bool
+	trailingStop   bool
+	stopLevel      float64
+	stopDistance   float64
+	profitLevel    float64
+}
+
+type PaperBrokerNoPriceFeed struct{}
+
+func (err *PaperBrokerNoPriceFeed) Error() string {
+	return "Paper broker has no price feed; Set `Feed` or replay prices with `UpdatePrice()`"
+}
+
+func NewPaperBroker(feed Broker, balance float64, currency string) *PaperBroker {
+	return &PaperBroker{
+		Feed:          feed,
+		Currency:      currency,
+		deposit:       balance,
+		quotes:        make(map[string]BidAskPrice),
+		confirmations: make(map[string]*PositionOrderConfirmationResponse),
+	}
+}
+
+// UpdatePrice sets the current bid/ask of an epic and fills every working order crossed by it.
+func (paper *PaperBroker) UpdatePrice(epic string, bid float64, ask float64) {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	quote := BidAskPrice{Bid: bid, Ask: ask}
+	paper.quotes[epic] = quote
+	paper.expireWorkingOrders()
+
+	pending := paper.workingOrders[:0]
+	for _, order := range paper.workingOrders {
+		if order.epic != epic {
+			pending = append(pending, order)
+			continue
+		}
+		fillPrice, crossed := order.crossedBy(quote)
+		if !crossed {
+			pending = append(pending, order)
+			continue
+		}
+		paper.fill(order, fillPrice)
+	}
+	paper.workingOrders = pending
+
+	// broker side stop losses and take profits
+	open := paper.positions[:0]
+	for _, position := range paper.positions {
+		if position.epic != epic {
+			open = append(open, position)
+			continue
+		}
+		exitPrice, triggered := position.protectionTriggeredBy(quote)
+		if !triggered {
+			open = append(open, position)
+			continue
+		}
+		paper.realized += position.profitLoss(exitPrice)
+	}
+	paper.positions = open
+}
+
+func (paper *PaperBroker) CreateNewSession(ctx context.Context) (NewSessionResponse, http.Header, error) {
+	if paper.Feed != nil {
+		return paper.Feed.CreateNewSession(ctx)
+	}
+	account := paper.account()
+	session := NewSessionResponse{
+		AccountType:      "CFD",
+		CurrencyIsoCode:  paper.Currency,
+		CurrentAccountId: account.AccountID,
+	}
+	session.AccountInfo.Balance = account.Balance.Balance
+	session.AccountInfo.Deposit = account.Balance.Deposit
+	session.AccountInfo.ProfitLoss = account.Balance.ProfitLoss
+	session.AccountInfo.Available = account.Balance.Available
+	return session, http.Header{}, nil
+}
+
+func (paper *PaperBroker) GetAllAccounts(ctx context.Context) (AccountsResponse, error) {
+	return AccountsResponse{Accounts: []AccountResponse{paper.account()}}, nil
+}
+
+func (paper *PaperBroker) GetPreferredAccount(ctx context.Context) (AccountResponse, error) {
+	return paper.account(), nil
+}
+
+func (paper *PaperBroker) GetMarketsDetails(ctx context.Context, epics []string) (MarketsDetailsResponse, error) {
+	if paper.Feed != nil {
+		marketsDetailsResponse, err := paper.Feed.GetMarketsDetails(ctx, epics)
+		if err != nil {
+			return marketsDetailsResponse, err
+		}
+		for _, detail := range marketsDetailsResponse.MarketDetails {
+			paper.UpdatePrice(detail.Instrument.Epic, detail.Snapshot.Bid, detail.Snapshot.Offer)
+		}
+		return marketsDetailsResponse, nil
+	}
+
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+	marketsDetailsResponse := MarketsDetailsResponse{}
+	for _, epic := range epics {
+		quote, ok := paper.quotes[epic]
+		if !ok {
+			continue
+		}
+		detail := MarketDetails{}
+		detail.Instrument.Epic = epic
+		detail.Instrument.Currency = paper.Currency
+		detail.Snapshot.MarketStatus = string(TRADEABLE)
+		detail.Snapshot.Bid = quote.Bid
+		detail.Snapshot.Offer = quote.Ask
+		marketsDetailsResponse.MarketDetails = append(marketsDetailsResponse.MarketDetails, detail)
+	}
+	return marketsDetailsResponse, nil
+}
+
+func (paper *PaperBroker) GetHistoricalPrices(ctx context.Context, epic string, resolution Timeframe, numberOfCandles int) (PricesResponse, error) {
+	if paper.Feed == nil {
+		return PricesResponse{}, &PaperBrokerNoPriceFeed{}
+	}
+	pricesResponse, err := paper.Feed.GetHistoricalPrices(ctx, epic, resolution, numberOfCandles)
+	if err != nil {
+		return pricesResponse, err
+	}
+	if len(pricesResponse.Prices) != 0 {
+		last := pricesResponse.Prices[len(pricesResponse.Prices)-1]
+		paper.UpdatePrice(epic, last.ClosePrice.Bid, last.ClosePrice.Ask)
+	}
+	return pricesResponse, nil
+}
+
+func (paper *PaperBroker) CreateWorkingOrder(ctx context.Context, workingOrder CreateWorkingOrderBody) (WorkingOrderResponse, error) {
+	if workingOrder.Direction != BUY && workingOrder.Direction != SELL {
+		return WorkingOrderResponse{}, fmt.Errorf("Invalid Working Order Direction: %s", workingOrder.Direction)
+	}
+	if workingOrder.Type != LIMIT && workingOrder.Type != STOP {
+		return WorkingOrderResponse{}, fmt.Errorf("Invalid Working Order Type: %s", workingOrder.Type)
+	}
+	if workingOrder.Level <= 0 || workingOrder.Size <= 0 {
+		return WorkingOrderResponse{}, errors.New("Working Order Level and Size must be greater than zero")
+	}
+	goodTill, err := parseGoodTillDate(workingOrder.GoodTillDate)
+	if err != nil {
+		return WorkingOrderResponse{}, err
+	}
+
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	order := &paperWorkingOrder{
+		dealID:         paper.nextID(),
+		dealReference:  "o_" + paper.nextID(),
+		epic:           workingOrder.Epic,
+		direction:      workingOrder.Direction,
+		orderType:      workingOrder.Type,
+		level:          workingOrder.Level,
+		size:           workingOrder.Size,
+		createdAt:      time.Now().UTC(),
+		goodTill:       goodTill,
+		guaranteedStop: workingOrder.GuaranteedStop,
+		trailingStop:   workingOrder.TrailingStop,
+		stopLevel:      workingOrder.StopLevel,
+		stopDistance:   workingOrder.StopDistance,
+		profitLevel:    workingOrder.ProfitLevel,
+		profitDistance: workingOrder.ProfitDistance,
+	}
+	confirmation := &PositionOrderConfirmationResponse{
+		Date:           order.createdAt.Format("2006-01-02T15:04:05.000"),
+		Status:         string(OPEN),
+		DealStatus:     string(ACCEPTED),
+		Epic:           order.epic,
+		DealRef:        order.dealReference,
+		DealID:         order.dealID,
+		Level:          order.level,
+		Size:           order.size,
+		Direction:      string(order.direction),
+		GuaranteedStop: order.guaranteedStop,
+		TrailingStop:   order.trailingStop,
+	}
+	paper.confirmations[order.dealReference] = confirmation
+
+	// orders that increase exposure need enough available funds
+	if paper.increasesExposure(order.epic, order.direction) && order.size*order.level > paper.available() {
+		confirmation.Status = string(REJECTED)
+		confirmation.DealStatus = string(REJECTED)
+		confirmation.Reason = "INSUFFICIENT_FUNDS"
+		return WorkingOrderResponse{DealReference: order.dealReference}, nil
+	}
+
+	// an order that is already crossed by the current quote is filled right away
+	if quote, ok := paper.quotes[order.epic]; ok {
+		if fillPrice, crossed := order.crossedBy(quote); crossed {
+			paper.fill(order, fillPrice)
+			return WorkingOrderResponse{DealReference: order.dealReference}, nil
+		}
+	}
+	paper.workingOrders = append(paper.workingOrders, order)
+
+	return WorkingOrderResponse{DealReference: order.dealReference}, nil
+}
+
+// UpdateWorkingOrder amends a pending working order. Zero values keep the current level and expiry, while
+// the protective exits are replaced. Both the deal id and the deal reference are accepted.
+func (paper *PaperBroker) UpdateWorkingOrder(ctx context.Context, dealId string, update UpdateWorkingOrderBody) (WorkingOrderResponse, error) {
+	goodTill, err := parseGoodTillDate(update.GoodTillDate)
+	if err != nil {
+		return WorkingOrderResponse{}, err
+	}
+
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	paper.expireWorkingOrders()
+	for _, order := range paper.workingOrders {
+		if order.dealID != dealId && order.dealReference != dealId {
+			continue
+		}
+		if update.Level > 0 {
+			order.level = update.Level
+		}
+		if !goodTill.IsZero() {
+			order.goodTill = goodTill
+		}
+		order.guaranteedStop = update.GuaranteedStop
+		order.trailingStop = update.TrailingStop
+		order.stopLevel = update.StopLevel
+		order.stopDistance = update.StopDistance
+		order.profitLevel = update.ProfitLevel
+		order.profitDistance = update.ProfitDistance
+
+		dealReference := "o_" + paper.nextID()
+		paper.confirmations[dealReference] = &PositionOrderConfirmationResponse{
+			Date:           time.Now().UTC().Format("2006-01-02T15:04:05.000"),
+			Status:         string(OPEN),
+			DealStatus:     string(ACCEPTED),
+			Epic:           order.epic,
+			DealRef:        dealReference,
+			DealID:         order.dealID,
+			AffectedDeals:  []AffectedDeal{{ID: order.dealID, Status: "AMENDED"}},
+			Level:          order.level,
+			Size:           order.size,
+			Direction:      string(order.direction),
+			GuaranteedStop: order.guaranteedStop,
+			TrailingStop:   order.trailingStop,
+		}
+
+		// the new level may already be crossed by the current quote
+		if quote, ok := paper.quotes[order.epic]; ok {
+			if fillPrice, crossed := order.crossedBy(quote); crossed {
+				paper.removeWorkingOrder(order)
+				paper.fill(order, fillPrice)
+			}
+		}
+		return WorkingOrderResponse{DealReference: dealReference}, nil
+	}
+	return WorkingOrderResponse{}, fmt.Errorf("Working Order Not Found: %s: %w", dealId, ErrNotFound)
+}
+
+func (paper *PaperBroker) GetAllWorkingOrders(ctx context.Context) (WorkingOrdersResponse, error) {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	paper.expireWorkingOrders()
+	workingOrdersResponse := WorkingOrdersResponse{}
+	for _, order := range paper.workingOrders {
+		workingOrder := WorkingOrder{}
+		workingOrder.WorkingOrderData = WorkingOrderData{
+			DealID:         order.dealID,
+			Direction:      string(order.direction),
+			Epic:           order.epic,
+			OrderSize:      order.size,
+			OrderLevel:     order.level,
+			TimeInForce:    "GOOD_TILL_CANCELLED",
+			CreatedDate:    order.createdAt.Format("2006-01-02T15:04:05.000"),
+			CreatedDateUTC: order.createdAt.Format("2006-01-02T15:04:05.000"),
+			GuaranteedStop: order.guaranteedStop,
+			TrailingStop:   order.trailingStop,
+			OrderType:      string(order.orderType),
+			StopLevel:      order.stopLevel,
+			StopDistance:   order.stopDistance,
+			ProfitLevel:    order.profitLevel,
+			ProfitDistance: order.profitDistance,
+			CurrencyCode:   paper.Currency,
+		}
+		if !order.goodTill.IsZero() {
+			workingOrder.WorkingOrderData.TimeInForce = "GOOD_TILL_DATE"
+			workingOrder.WorkingOrderData.GoodTillDate = order.goodTill.Format("2006-01-02T15:04:05")
+			workingOrder.WorkingOrderData.GoodTillDateUTC = order.goodTill.Format("2006-01-02T15:04:05")
+		}
+		workingOrder.MarketData.Epic = order.epic
+		workingOrder.MarketData.MarketStatus = string(TRADEABLE)
+		workingOrder.MarketData.Bid = paper.quotes[order.epic].Bid
+		workingOrder.MarketData.Offer = paper.quotes[order.epic].Ask
+		workingOrdersResponse.WorkingOrders = append(workingOrdersResponse.WorkingOrders, workingOrder)
+	}
+	return workingOrdersResponse, nil
+}
+
+// DeleteWorkingOrder cancels a pending working order. Both the deal id and the deal reference are accepted.
+func (paper *PaperBroker) DeleteWorkingOrder(ctx context.Context, dealReference string) (WorkingOrderResponse, error) {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	paper.expireWorkingOrders()
+	for _, order := range paper.workingOrders {
+		if order.dealID != dealReference && order.dealReference != dealReference {
+			continue
+		}
+		paper.removeWorkingOrder(order)
+		if confirmation, ok := paper.confirmations[order.dealReference]; ok {
+			confirmation.Status = string(DELETED)
+		}
+		return WorkingOrderResponse{DealReference: order.dealReference}, nil
+	}
+	return WorkingOrderResponse{}, fmt.Errorf("Working Order Not Found: %s: %w", dealReference, ErrNotFound)
+}
+
+func (paper *PaperBroker) GetPositions(ctx context.Context) (PositionsResponse, error) {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	positionsResponse := PositionsResponse{}
+	for _, position := range paper.positions {
+		positionsResponse.Positions = append(positionsResponse.Positions, paper.positionResponse(position))
+	}
+	return positionsResponse, nil
+}
+
+func (paper *PaperBroker) GetPosition(ctx context.Context, dealId string) (PositionResponse, error) {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	for _, position := range paper.positions {
+		if position.dealID == dealId {
+			return paper.positionResponse(position), nil
+		}
+	}
+	return PositionResponse{}, fmt.Errorf("Position Not Found: %s: %w", dealId, ErrNotFound)
+}
+
+// CreatePosition opens a position at the current quote; BUY positions open at the ask and SELL positions at the bid.
+func (paper *PaperBroker) CreatePosition(ctx context.Context, position CreatePositionBody) (DealReferenceResponse, error) {
+	if position.Direction != BUY && position.Direction != SELL {
+		return DealReferenceResponse{}, fmt.Errorf("Invalid Position Direction: %s", position.Direction)
+	}
+	if position.Size <= 0 {
+		return DealReferenceResponse{}, errors.New("Position Size must be greater than zero")
+	}
+
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	quote, ok := paper.quotes[position.Epic]
+	if !ok {
+		return DealReferenceResponse{}, fmt.Errorf("No Price Available For Epic: %s", position.Epic)
+	}
+	price := quote.Ask
+	if position.Direction == SELL {
+		price = quote.Bid
+	}
+
+	order := &paperWorkingOrder{
+		dealID:         paper.nextID(),
+		dealReference:  "o_" + paper.nextID(),
+		epic:           position.Epic,
+		direction:      position.Direction,
+		level:          price,
+		size:           position.Size,
+		createdAt:      time.Now().UTC(),
+		guaranteedStop: position.GuaranteedStop,
+		trailingStop:   position.TrailingStop,
+		stopLevel:      position.StopLevel,
+		stopDistance:   position.StopDistance,
+		profitLevel:    position.ProfitLevel,
+		profitDistance: position.ProfitDistance,
+	}
+	confirmation := &PositionOrderConfirmationResponse{
+		Date:           order.createdAt.Format("2006-01-02T15:04:05.000"),
+		Status:         string(OPEN),
+		DealStatus:     string(ACCEPTED),
+		Epic:           position.Epic,
+		DealRef:        order.dealReference,
+		DealID:         order.dealID,
+		Level:          price,
+		Size:           position.Size,
+		Direction:      string(position.Direction),
+		GuaranteedStop: position.GuaranteedStop,
+		TrailingStop:   position.TrailingStop,
+	}
+	paper.confirmations[order.dealReference] = confirmation
+
+	if paper.increasesExposure(position.Epic, position.Direction) && position.Size*price > paper.available() {
+		confirmation.Status = string(REJECTED)
+		confirmation.DealStatus = string(REJECTED)
+		confirmation.Reason = "INSUFFICIENT_FUNDS"
+		return DealReferenceResponse{DealReference: order.dealReference}, nil
+	}
+
+	if opened := paper.fill(order, price); opened != nil {
+		confirmation.DealID = opened.dealID
+	}
+	return DealReferenceResponse{DealReference: order.dealReference}, nil
+}
+
+// UpdatePosition replaces the protective exits of a position. Distances are relative to the current exit price.
+func (paper *PaperBroker) UpdatePosition(ctx context.Context, dealId string, update UpdatePositionBody) (DealReferenceResponse, error) {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	for _, position := range paper.positions {
+		if position.dealID != dealId {
+			continue
+		}
+		quote := paper.quotes[position.epic]
+		price := quote.Bid
+		if position.direction == SELL {
+			price = quote.Ask
+		}
+		position.protect(update.GuaranteedStop, update.TrailingStop, update.StopLevel, update.StopDistance, update.ProfitLevel, update.ProfitDistance, price)
+
+		dealReference := "p_" + paper.nextID()
+		paper.confirmations[dealReference] = &PositionOrderConfirmationResponse{
+			Date:           time.Now().UTC().Format("2006-01-02T15:04:05.000"),
+			Status:         string(OPEN),
+			DealStatus:     string(ACCEPTED),
+			Epic:           position.epic,
+			DealRef:        dealReference,
+			DealID:         position.dealID,
+			AffectedDeals:  []AffectedDeal{{ID: position.dealID, Status: "AMENDED"}},
+			Level:          position.level,
+			Size:           position.size,
+			Direction:      string(position.direction),
+			GuaranteedStop: position.guaranteedStop,
+			TrailingStop:   position.trailingStop,
+		}
+		return DealReferenceResponse{DealReference: dealReference}, nil
+	}
+	return DealReferenceResponse{}, fmt.Errorf("Position Not Found: %s: %w", dealId, ErrNotFound)
+}
+
+// ClosePosition closes a whole position at the current quote.
+func (paper *PaperBroker) ClosePosition(ctx context.Context, dealId string) (DealReferenceResponse, error) {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	for i, position := range paper.positions {
+		if position.dealID != dealId {
+			continue
+		}
+		quote := paper.quotes[position.epic]
+		price := quote.Bid
+		if position.direction == SELL {
+			price = quote.Ask
+		}
+		paper.realized += position.profitLoss(price)
+		paper.positions = append(paper.positions[:i], paper.positions[i+1:]...)
+
+		dealReference := "p_" + paper.nextID()
+		paper.confirmations[dealReference] = &PositionOrderConfirmationResponse{
+			Date:          time.Now().UTC().Format("2006-01-02T15:04:05.000"),
+			Status:        string(POSITION_CLOSED),
+			DealStatus:    string(ACCEPTED),
+			Epic:          position.epic,
+			DealRef:       dealReference,
+			DealID:        position.dealID,
+			AffectedDeals: []AffectedDeal{{ID: position.dealID, Status: "FULLY_CLOSED"}},
+			Level:         price,
+			Size:          position.size,
+			Direction:     string(position.direction),
+		}
+		return DealReferenceResponse{DealReference: dealReference}, nil
+	}
+	return DealReferenceResponse{}, fmt.Errorf("Position Not Found: %s: %w", dealId, ErrNotFound)
+}
+
+func (paper *PaperBroker) GetPositionOrderConfirmation(ctx context.Context, dealReference string) (PositionOrderConfirmationResponse, error) {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	confirmation, ok := paper.confirmations[dealReference]
+	if !ok {
+		return PositionOrderConfirmationResponse{}, fmt.Errorf("Confirmation Not Found: %s: %w", dealReference, ErrNotFound)
+	}
+	return *confirmation, nil
+}
+
+// crossedBy reports whether the quote reaches the order level, and the price it would be filled at.
+// BUY orders trade at the ask and SELL orders at the bid.
+func (order *paperWorkingOrder) crossedBy(quote BidAskPrice) (float64, bool) {
+	switch {
+	case order.direction == BUY && order.orderType == LIMIT && quote.Ask > 0 && quote.Ask <= order.level:
+		return quote.Ask, true
+	case order.direction == BUY && order.orderType == STOP && quote.Ask >= order.level:
+		return quote.Ask, true
+	case order.direction == SELL && order.orderType == LIMIT && quote.Bid >= order.level:
+		return quote.Bid, true
+	case order.direction == SELL && order.orderType == STOP && quote.Bid > 0 && quote.Bid <= order.level:
+		return quote.Bid, true
+	}
+	return 0, false
+}
+
+// fill turns a working order into position changes; opposite positions on the same epic are
+// closed first (netting) and any remaining size opens a new position, which is returned with the
+// protective exits of the order. Distances are relative to the order level. Must hold the mutex.
+func (paper *PaperBroker) fill(order *paperWorkingOrder, price float64) *paperPosition {
+	confirmation := paper.confirmations[order.dealReference]
+	confirmation.Level = price
+
+	remaining := order.size
+	open := paper.positions[:0]
+	for _, position := range paper.positions {
+		if remaining == 0 || position.epic != order.epic || position.direction == order.direction {
+			open = append(open, position)
+			continue
+		}
+		closedSize := position.size
+		if closedSize > remaining {
+			closedSize = remaining
+		}
+		paper.realized += position.profitLoss(price) * closedSize / position.size
+		position.size -= closedSize
+		remaining -= closedSize
+
+		status := "FULLY_CLOSED"
+		if position.size > 0 {
+			status = "PARTIALLY_CLOSED"
+			open = append(open, position)
+		}
+		confirmation.AffectedDeals = append(confirmation.AffectedDeals, AffectedDeal{ID: position.dealID, Status: status})
+	}
+	paper.positions = open
+
+	if remaining > 0 {
+		position := &paperPosition{
+			dealID:        paper.nextID(),
+			dealReference: order.dealReference,
+			epic:          order.epic,
+			direction:     order.direction,
+			level:         price,
+			size:          remaining,
+			createdAt:     time.Now().UTC(),
+		}
+		position.protect(order.guaranteedStop, order.trailingStop, order.stopLevel, order.stopDistance, order.profitLevel, order.profitDistance, order.level)
+		paper.positions = append(paper.positions, position)
+		confirmation.AffectedDeals = append(confirmation.AffectedDeals, AffectedDeal{ID: position.dealID, Status: "OPENED"})
+		return position
+	}
+	return nil
+}
+
+// protect sets the protective exits of a position. Distances are converted to levels from price.
+func (position *paperPosition) protect(guaranteedStop bool, trailingStop bool, stopLevel float64, stopDistance float64, profitLevel float64, profitDistance float64, price float64) {
+	sign := 1.0
+	if position.direction == SELL {
+		sign = -1.0
+	}
+	if stopLevel == 0 && stopDistance > 0 {
+		stopLevel = price - sign*stopDistance
+	}
+	if profitLevel == 0 && profitDistance > 0 {
+		profitLevel = price + sign*profitDistance
+	}
+	if trailingStop && stopDistance == 0 && stopLevel != 0 {
+		stopDistance = sign * (price - stopLevel)
+	}
+	position.guaranteedStop = guaranteedStop
+	position.trailingStop = trailingStop
+	position.stopLevel = stopLevel
+	position.stopDistance = stopDistance
+	position.profitLevel = profitLevel
+}
+
+// protectionTriggeredBy moves trailing stops with the quote and reports whether the stop loss or the take
+// profit of the position is reached, with the exit price. Guaranteed stops exit exactly at their level.
+func (position *paperPosition) protectionTriggeredBy(quote BidAskPrice) (float64, bool) {
+	if position.direction == BUY {
+		if position.trailingStop && position.stopDistance > 0 && quote.Bid-position.stopDistance > position.stopLevel {
+			position.stopLevel = quote.Bid - position.stopDistance
+		}
+		if position.stopLevel > 0 && quote.Bid <= position.stopLevel {
+			if position.guaranteedStop {
+				return position.stopLevel, true
+			}
+			return quote.Bid, true
+		}
+		if position.profitLevel > 0 && quote.Bid >= position.profitLevel {
+			return quote.Bid, true
+		}
+		return 0, false
+	}
+
+	if position.trailingStop && position.stopDistance > 0 && (position.stopLevel == 0 || quote.Ask+position.stopDistance < position.stopLevel) {
+		position.stopLevel = quote.Ask + position.stopDistance
+	}
+	if position.stopLevel > 0 && quote.Ask >= position.stopLevel {
+		if position.guaranteedStop {
+			return position.stopLevel, true
+		}
+		return quote.Ask, true
+	}
+	if position.profitLevel > 0 && quote.Ask <= position.profitLevel {
+		return quote.Ask, true
+	}
+	return 0, false
+}
+
+// expireWorkingOrders deletes the pending orders whose good till date has passed. Must hold the mutex.
+func (paper *PaperBroker) expireWorkingOrders() {
+	now := time.Now().UTC()
+	pending := paper.workingOrders[:0]
+	for _, order := range paper.workingOrders {
+		if order.goodTill.IsZero() || now.Before(order.goodTill) {
+			pending = append(pending, order)
+			continue
+		}
+		if confirmation, ok := paper.confirmations[order.dealReference]; ok {
+			confirmation.Status = string(DELETED)
+		}
+	}
+	paper.workingOrders = pending
+}
+
+// Must hold the mutex.
+func (paper *PaperBroker) removeWorkingOrder(order *paperWorkingOrder) {
+	for i := range paper.workingOrders {
+		if paper.workingOrders[i] == order {
+			paper.workingOrders = append(paper.workingOrders[:i], paper.workingOrders[i+1:]...)
+			return
+		}
+	}
+}
+
+// parseGoodTillDate parses the UTC expiry of a working order; an empty date means good till cancelled.
+func parseGoodTillDate(goodTillDate string) (time.Time, error) {
+	if goodTillDate == "" {
+		return time.Time{}, nil
+	}
+	goodTill, err := time.Parse("2006-01-02T15:04:05", goodTillDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid Working Order Good Till Date: %s", goodTillDate)
+	}
+	return goodTill, nil
+}
+
+func (position *paperPosition) profitLoss(price float64) float64 {
+	if position.direction == BUY {
+		return (price - position.level) * position.size
+	}
+	return (position.level - price) * position.size
+}
+
+// increasesExposure reports whether an order on epic in direction would open (rather than close) exposure.
+// Must hold the mutex.
+func (paper *PaperBroker) increasesExposure(epic string, direction Signal) bool {
+	for _, position := range paper.positions {
+		if position.epic == epic && position.direction != direction {
+			return false
+		}
+	}
+	return true
+}
+
+// available is the cash not tied up in open positions. Must hold the mutex.
+func (paper *PaperBroker) available() float64 {
+	available := paper.deposit + paper.realized
+	for _, position := range paper.positions {
+		available -= position.level * position.size
+	}
+	return available
+}
+
+func (paper *PaperBroker) account() AccountResponse {
+	paper.mutex.Lock()
+	defer paper.mutex.Unlock()
+
+	var unrealized float64
+	for _, position := range paper.positions {
+		quote := paper.quotes[position.epic]
+		price := quote.Bid
+		if position.direction == SELL {
+			price = quote.Ask
+		}
+		unrealized += position.profitLoss(price)
+	}
+
+	account := AccountResponse{
+		AccountID:   "PAPER",
+		AccountName: "Paper Trading",
+		Status:      "ENABLED",
+		AccountType: "CFD",
+		Preferred:   true,
+		Currency:    paper.Currency,
+	}
+	account.Balance.Balance = paper.deposit + paper.realized + unrealized
+	account.Balance.Deposit = paper.deposit
+	account.Balance.ProfitLoss = unrealized
+	account.Balance.Available = paper.available()
+	return account
+}
+
+// Must hold the mutex.
+func (paper *PaperBroker) positionResponse(position *paperPosition) PositionResponse {
+	positionResponse := PositionResponse{}
+	positionResponse.Position = PositionData{
+		ContractSize:   1,
+		CreatedDate:    position.createdAt.Format("2006-01-02T15:04:05.000"),
+		CreatedDateUTC: position.createdAt.Format("2006-01-02T15:04:05.000"),
+		DealID:         position.dealID,
+		DealReference:  position.dealReference,
+		Size:           position.size,
+		Direction:      string(position.direction),
+		Level:          position.level,
+		Currency:       paper.Currency,
+		GuaranteedStop: position.guaranteedStop,
+		TrailingStop:   position.trailingStop,
+		StopLevel:      position.stopLevel,
+		StopDistance:   position.stopDistance,
+		ProfitLevel:    position.profitLevel,
+	}
+	positionResponse.Market.Epic = position.epic
+	positionResponse.Market.MarketStatus = string(TRADEABLE)
+	positionResponse.Market.Bid = paper.quotes[position.epic].Bid
+	positionResponse.Market.Offer = paper.quotes[position.epic].Ask
+	return positionResponse
+}
+
+// Must hold the mutex.
+func (paper *PaperBroker) nextID() string {
+	paper.sequence++
+	return fmt.Sprintf("paper-%08d", paper.sequence)
+}
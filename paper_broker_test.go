@@ -0,0 +1,315 @@
+package gominitrader
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPaperBrokerFills(t *testing.T) {
+	tests := []struct {
+		direction     Signal
+		orderType     OrderType
+		level         float64
+		bid, ask      float64
+		expectedFill  bool
+		expectedLevel float64
+	}{
+		{BUY, LIMIT, 100, 99.5, 100.5, false, 0},
+		{BUY, LIMIT, 100, 99, 99.8, true, 99.8},
+		{BUY, STOP, 100, 99, 99.5, false, 0},
+		{BUY, STOP, 100, 100, 100.2, true, 100.2},
+		{SELL, LIMIT, 100, 99.9, 100.1, false, 0},
+		{SELL, LIMIT, 100, 100.3, 100.5, true, 100.3},
+		{SELL, STOP, 100, 100.1, 100.3, false, 0},
+		{SELL, STOP, 100, 99.7, 99.9, true, 99.7},
+	}
+
+	for i, test := range tests {
+		paper := NewPaperBroker(nil, 10000, "USD")
+		// start on the side of the level the order is waiting from
+		if (test.direction == BUY) == (test.orderType == LIMIT) {
+			paper.UpdatePrice("EURUSD", 110, 110.1)
+		} else {
+			paper.UpdatePrice("EURUSD", 90, 90.1)
+		}
+
+		orderResponse, err := paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "EURUSD", Direction: test.direction, Type: test.orderType, Level: test.level, Size: 1})
+		if err != nil {
+			t.Fatalf("Test case %d: %v", i, err)
+		}
+		workingOrders, _ := paper.GetAllWorkingOrders(context.Background())
+		if len(workingOrders.WorkingOrders) != 1 {
+			t.Fatalf("Test case %d: order should be pending before prices cross, got %d working orders", i, len(workingOrders.WorkingOrders))
+		}
+
+		paper.UpdatePrice("EURUSD", test.bid, test.ask)
+		positions, _ := paper.GetPositions(context.Background())
+		if filled := len(positions.Positions) == 1; filled != test.expectedFill {
+			t.Errorf("Test case %d: expected filled=%t, got %t", i, test.expectedFill, filled)
+			continue
+		}
+		if !test.expectedFill {
+			continue
+		}
+		if positions.Positions[0].Position.Level != test.expectedLevel {
+			t.Errorf("Test case %d: expected fill level %f, got %f", i, test.expectedLevel, positions.Positions[0].Position.Level)
+		}
+		confirmation, err := paper.GetPositionOrderConfirmation(context.Background(), orderResponse.DealReference)
+		if err != nil {
+			t.Fatalf("Test case %d: %v", i, err)
+		}
+		if len(confirmation.AffectedDeals) != 1 || confirmation.AffectedDeals[0].Status != "OPENED" {
+			t.Errorf("Test case %d: unexpected affected deals %+v", i, confirmation.AffectedDeals)
+		}
+	}
+}
+
+func TestPaperBrokerRoundTrip(t *testing.T) {
+	toleranceError := 1e-9
+	paper := NewPaperBroker(nil, 1000, "USD")
+	paper.UpdatePrice("USDMXN", 20, 20)
+
+	buy, _ := paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 20, Size: 10})
+	account, _ := paper.GetPreferredAccount(context.Background())
+	if math.Abs(account.Balance.Available-800) > toleranceError {
+		t.Errorf("expected 800 available after buying, got %f", account.Balance.Available)
+	}
+
+	sell, _ := paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: SELL, Type: LIMIT, Level: 21, Size: 10})
+	paper.UpdatePrice("USDMXN", 21, 21.01)
+
+	positions, _ := paper.GetPositions(context.Background())
+	if len(positions.Positions) != 0 {
+		t.Errorf("sell should have closed the buy position, got %+v", positions.Positions)
+	}
+	account, _ = paper.GetPreferredAccount(context.Background())
+	if math.Abs(account.Balance.Balance-1010) > toleranceError || math.Abs(account.Balance.Available-1010) > toleranceError {
+		t.Errorf("expected 1010 balance and available after round trip, got %+v", account.Balance)
+	}
+
+	confirmation, _ := paper.GetPositionOrderConfirmation(context.Background(), sell.DealReference)
+	if len(confirmation.AffectedDeals) != 1 || confirmation.AffectedDeals[0].Status != "FULLY_CLOSED" {
+		t.Errorf("unexpected sell affected deals %+v", confirmation.AffectedDeals)
+	}
+	confirmation, _ = paper.GetPositionOrderConfirmation(context.Background(), buy.DealReference)
+	if confirmation.Status != string(OPEN) || confirmation.DealStatus != string(ACCEPTED) {
+		t.Errorf("unexpected buy confirmation %+v", confirmation)
+	}
+}
+
+func TestPaperBrokerDeleteAndReject(t *testing.T) {
+	paper := NewPaperBroker(nil, 100, "USD")
+	paper.UpdatePrice("USDMXN", 20, 20.01)
+
+	order, _ := paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19, Size: 1})
+	if _, err := paper.DeleteWorkingOrder(context.Background(), order.DealReference); err != nil {
+		t.Fatal(err)
+	}
+	confirmation, _ := paper.GetPositionOrderConfirmation(context.Background(), order.DealReference)
+	if confirmation.Status != string(DELETED) {
+		t.Errorf("expected DELETED confirmation, got %s", confirmation.Status)
+	}
+	if _, err := paper.DeleteWorkingOrder(context.Background(), order.DealReference); err == nil {
+		t.Error("deleting an unknown working order should fail")
+	}
+
+	order, _ = paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19, Size: 1000})
+	confirmation, _ = paper.GetPositionOrderConfirmation(context.Background(), order.DealReference)
+	if confirmation.DealStatus != string(REJECTED) || confirmation.Reason != "INSUFFICIENT_FUNDS" {
+		t.Errorf("expected rejected confirmation, got %+v", confirmation)
+	}
+}
+
+func TestPaperBrokerNotFound(t *testing.T) {
+	paper := NewPaperBroker(nil, 100, "USD")
+	paper.UpdatePrice("USDMXN", 20, 20.01)
+	order, _ := paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "USDMXN", Direction: BUY, Type: LIMIT, Level: 19, Size: 1})
+	workingOrders, _ := paper.GetAllWorkingOrders(context.Background())
+	dealID := workingOrders.WorkingOrders[0].WorkingOrderData.DealID
+	paper.UpdatePrice("USDMXN", 18.99, 19)
+	positions, _ := paper.GetPositions(context.Background())
+	paper.ClosePosition(context.Background(), positions.Positions[0].Position.DealID)
+
+	tests := []func() error{
+		// the order filled before it could be deleted
+		func() error { _, err := paper.DeleteWorkingOrder(context.Background(), dealID); return err },
+		func() error {
+			_, err := paper.UpdateWorkingOrder(context.Background(), dealID, UpdateWorkingOrderBody{Level: 18})
+			return err
+		},
+		// the position it opened was closed already
+		func() error {
+			_, err := paper.ClosePosition(context.Background(), positions.Positions[0].Position.DealID)
+			return err
+		},
+		func() error {
+			_, err := paper.GetPosition(context.Background(), positions.Positions[0].Position.DealID)
+			return err
+		},
+		func() error {
+			_, err := paper.GetPositionOrderConfirmation(context.Background(), "unknown")
+			return err
+		},
+	}
+	for i, test := range tests {
+		if err := test(); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Test case %d: expected %v, got %v", i, ErrNotFound, err)
+		}
+	}
+
+	// a minitrader deleting the filled order moves on, as it does with Capital.com
+	minitrader := NewMinitrader("USDMXN", 100, 5, 1, MINUTE_15, GPTStrategy)
+	minitrader.broker = paper
+	minitrader.setTrade(order.DealReference, dealID, 19, 1)
+	minitrader.transition(HOLDING, "test order")
+	if err := minitrader.deleteOrder(context.Background(), dealID); err != nil || minitrader.Status() != RUNNING {
+		t.Errorf("expected the minitrader to be RUNNING, got %s: %v", minitrader.Status(), err)
+	}
+}
+
+func TestPaperBrokerWithMinitrader(t *testing.T) {
+	paper := NewPaperBroker(nil, 1000, "USD")
+	paper.UpdatePrice("USDMXN", 19.5, 19.5)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 0.5, MINUTE_15, GPTStrategy)
+	minitrader.broker = paper
+	minitrader.status = RUNNING
+	minitrader.volatileAmountAvailable = 10
+
+	if err := minitrader.Effect(context.Background(), BUY, 19.5); err != nil {
+		t.Fatal(err)
+	}
+	if minitrader.Status() != HOLDING {
+		t.Errorf("expected status %s, got %s", HOLDING, minitrader.Status())
+	}
+	positions, _ := paper.GetPositions(context.Background())
+	if len(positions.Positions) != 1 {
+		t.Errorf("expected one paper position, got %d", len(positions.Positions))
+	}
+}
+
+func TestPaperBrokerPositionProtection(t *testing.T) {
+	tests := []struct {
+		position      CreatePositionBody
+		quotes        []float64 // bid; ask is bid + 0.1
+		expectedOpen  bool
+		expectedFinal float64 // balance after the quotes
+	}{
+		// stop loss at 95, exits at the bid
+		{CreatePositionBody{Epic: "X", Direction: BUY, Size: 1, StopLevel: 95}, []float64{97, 94}, false, 1000 - 6.1},
+		// guaranteed stop exits exactly at its level
+		{CreatePositionBody{Epic: "X", Direction: BUY, Size: 1, StopLevel: 95, GuaranteedStop: true}, []float64{97, 90}, false, 1000 - 5.1},
+		// take profit
+		{CreatePositionBody{Epic: "X", Direction: BUY, Size: 1, ProfitDistance: 5}, []float64{104, 105.2}, false, 1000 + 5.1},
+		// trailing stop follows the price up and exits at 107
+		{CreatePositionBody{Epic: "X", Direction: BUY, Size: 1, StopDistance: 3, TrailingStop: true}, []float64{105, 110, 107}, false, 1000 + 6.9},
+		// sell position stop loss above the price
+		{CreatePositionBody{Epic: "X", Direction: SELL, Size: 1, StopDistance: 2}, []float64{101, 101.95}, false, 1000 - 2.05},
+		// untouched levels keep the position open
+		{CreatePositionBody{Epic: "X", Direction: BUY, Size: 1, StopLevel: 90, ProfitLevel: 110}, []float64{95, 105}, true, 0},
+	}
+
+	for i, test := range tests {
+		paper := NewPaperBroker(nil, 1000, "USD")
+		paper.UpdatePrice("X", 100, 100.1)
+		if _, err := paper.CreatePosition(context.Background(), test.position); err != nil {
+			t.Fatalf("Test case %d: %v", i, err)
+		}
+		for _, bid := range test.quotes {
+			paper.UpdatePrice("X", bid, bid+0.1)
+		}
+
+		positions, _ := paper.GetPositions(context.Background())
+		if open := len(positions.Positions) == 1; open != test.expectedOpen {
+			t.Errorf("Test case %d: expected open=%t, got %t", i, test.expectedOpen, open)
+			continue
+		}
+		if test.expectedOpen {
+			continue
+		}
+		account, _ := paper.GetPreferredAccount(context.Background())
+		if math.Abs(account.Balance.Balance-test.expectedFinal) > 1e-9 {
+			t.Errorf("Test case %d: expected balance %f, got %f", i, test.expectedFinal, account.Balance.Balance)
+		}
+	}
+}
+
+func TestMinitraderMarketEntry(t *testing.T) {
+	paper := NewPaperBroker(nil, 1000, "USD")
+	paper.UpdatePrice("USDMXN", 20, 20)
+	minitrader := NewMinitrader("USDMXN", 100, 5, 1, MINUTE_15, GPTStrategy)
+	minitrader.EntryType = MARKET_ENTRY
+	minitrader.broker = paper
+	minitrader.status = RUNNING
+	minitrader.volatileAmountAvailable = 10
+
+	if err := minitrader.Effect(context.Background(), BUY, 20); err != nil {
+		t.Fatal(err)
+	}
+	if minitrader.Status() != HOLDING || minitrader.activeDealID == "" || minitrader.payedPrice != 20 {
+		t.Fatalf("unexpected minitrader state %s %s %f", minitrader.Status(), minitrader.activeDealID, minitrader.payedPrice)
+	}
+	position, err := paper.GetPosition(context.Background(), minitrader.activeDealID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(position.Position.StopLevel-19) > 1e-9 || math.Abs(position.Position.ProfitLevel-20.2) > 1e-9 {
+		t.Errorf("protective levels not attached: %+v", position.Position)
+	}
+
+	// the broker stops the position out before the minitrader sees the price
+	paper.UpdatePrice("USDMXN", 18.9, 18.9)
+	if err := minitrader.Effect(context.Background(), NONE, 18.9); err != nil {
+		t.Fatal(err)
+	}
+	if minitrader.Status() != RUNNING || minitrader.activeDealID != "" {
+		t.Errorf("minitrader should be running again, got %s", minitrader.Status())
+	}
+}
+
+func TestPaperBrokerWorkingOrderProtectionAndExpiry(t *testing.T) {
+	paper := NewPaperBroker(nil, 1000, "USD")
+	paper.UpdatePrice("X", 101, 101.1)
+
+	// the stop and profit distances are relative to the order level
+	order, err := paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "X", Direction: BUY, Type: LIMIT, Level: 100, Size: 1, StopDistance: 2, ProfitDistance: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workingOrders, _ := paper.GetAllWorkingOrders(context.Background())
+	if len(workingOrders.WorkingOrders) != 1 || workingOrders.WorkingOrders[0].WorkingOrderData.StopDistance != 2 {
+		t.Fatalf("unexpected working orders: %+v", workingOrders.WorkingOrders)
+	}
+
+	// amending the level fills the order right away
+	if _, err := paper.UpdateWorkingOrder(context.Background(), order.DealReference, UpdateWorkingOrderBody{Level: 101.5, StopDistance: 2, ProfitDistance: 3}); err != nil {
+		t.Fatal(err)
+	}
+	positions, _ := paper.GetPositions(context.Background())
+	if len(positions.Positions) != 1 {
+		t.Fatalf("amended order should be filled, got %d positions", len(positions.Positions))
+	}
+	position := positions.Positions[0].Position
+	if math.Abs(position.StopLevel-99.5) > 1e-9 || math.Abs(position.ProfitLevel-104.5) > 1e-9 {
+		t.Errorf("protective exits not carried to the position: %+v", position)
+	}
+	paper.UpdatePrice("X", 99.4, 99.5)
+	if positions, _ = paper.GetPositions(context.Background()); len(positions.Positions) != 0 {
+		t.Errorf("attached stop loss should close the position")
+	}
+
+	// expired orders are deleted
+	expired, _ := paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "X", Direction: BUY, Type: LIMIT, Level: 90, Size: 1, GoodTillDate: time.Now().UTC().Add(-time.Minute).Format("2006-01-02T15:04:05")})
+	paper.UpdatePrice("X", 89, 89.1)
+	if positions, _ = paper.GetPositions(context.Background()); len(positions.Positions) != 0 {
+		t.Errorf("expired order should not be filled")
+	}
+	if confirmation, _ := paper.GetPositionOrderConfirmation(context.Background(), expired.DealReference); confirmation.Status != string(DELETED) {
+		t.Errorf("expected expired order to be %s, got %s", DELETED, confirmation.Status)
+	}
+	if _, err := paper.CreateWorkingOrder(context.Background(), CreateWorkingOrderBody{Epic: "X", Direction: BUY, Type: LIMIT, Level: 90, Size: 1, GoodTillDate: "tomorrow"}); err == nil {
+		t.Error("invalid good till date should be rejected")
+	}
+}
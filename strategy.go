@@ -1,5 +1,11 @@
 package gominitrader
 
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
 type Strategy func(candles Candles) (Signal, float64)
 
 type Signal string
@@ -9,3 +15,83 @@ const (
 	SELL Signal = "SELL"
 	NONE Signal = "NONE"
 )
+
+// StatefulStrategy is a strategy updated bar by bar instead of recomputing everything from the full
+// window on every tick. Minitraders and the Backtester call OnCandle once per candle in time order; a
+// candle with the same timestamp as the previous one is an update of that bar (e.g. a streamed bar still
+// in progress) and replaces it. Signals before WarmUp candles have been seen are ignored.
+type StatefulStrategy interface {
+	Name() string
+	Parameters() []StrategyParameter
+	WarmUp() int
+	OnCandle(candle Candle) (Signal, float64)
+	Reset()
+}
+
+// StrategyParameter describes a tunable strategy parameter, its current value and the range it can take.
+type StrategyParameter struct {
+	Name  string
+	Value float64
+	Min   float64
+	Max   float64
+	Step  float64
+}
+
+// FuncStrategy adapts a plain Strategy function to a StatefulStrategy. It keeps the last `Window`
+// candles and calls the function with them on every bar once the window is full.
+type FuncStrategy struct {
+	Strategy Strategy
+	Window   int
+
+	name    string
+	candles Candles
+}
+
+var _ StatefulStrategy = (*FuncStrategy)(nil)
+
+func NewFuncStrategy(strategy Strategy, window int) *FuncStrategy {
+	name := runtime.FuncForPC(reflect.ValueOf(strategy).Pointer()).Name()
+	return &FuncStrategy{
+		Strategy: strategy,
+		Window:   window,
+		name:     name[strings.LastIndex(name, ".")+1:],
+	}
+}
+
+// Name is the name of the adapted function, e.g. "GPTStrategy".
+func (strategy *FuncStrategy) Name() string {
+	return strategy.name
+}
+
+func (strategy *FuncStrategy) Parameters() []StrategyParameter {
+	return nil
+}
+
+func (strategy *FuncStrategy) WarmUp() int {
+	return strategy.Window
+}
+
+// OnCandle adds the candle to the window and evaluates the function once the window is full. Candles
+// older than the last one are ignored. Until the function is evaluated, the price is the last close.
+func (strategy *FuncStrategy) OnCandle(candle Candle) (Signal, float64) {
+	last := len(strategy.candles) - 1
+	switch {
+	case last >= 0 && candle.Timestamp == strategy.candles[last].Timestamp:
+		strategy.candles[last] = candle
+	case last >= 0 && candle.Timestamp < strategy.candles[last].Timestamp:
+		return NONE, strategy.candles[last].Close.Bid
+	default:
+		strategy.candles = append(strategy.candles, candle)
+		if len(strategy.candles) > strategy.Window {
+			strategy.candles = strategy.candles[len(strategy.candles)-strategy.Window:]
+		}
+	}
+	if len(strategy.candles) < strategy.Window {
+		return NONE, candle.Close.Bid
+	}
+	return strategy.Strategy(strategy.candles)
+}
+
+func (strategy *FuncStrategy) Reset() {
+	strategy.candles = nil
+}
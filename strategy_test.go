@@ -0,0 +1,155 @@
+package gominitrader
+
+import (
+	"context"
+	"testing"
+)
+
+// _TestRecordingStrategy is a StatefulStrategy that records the candles it is fed and buys once warmed up.
+type _TestRecordingStrategy struct {
+	warmUp int
+	resets int
+	seen   []Candle
+}
+
+func (strategy *_TestRecordingStrategy) Name() string { return "Recording" }
+
+func (strategy *_TestRecordingStrategy) Parameters() []StrategyParameter {
+	return []StrategyParameter{{Name: "warmUp", Value: float64(strategy.warmUp), Min: 1, Max: 10, Step: 1}}
+}
+
+func (strategy *_TestRecordingStrategy) WarmUp() int { return strategy.warmUp }
+
+func (strategy *_TestRecordingStrategy) OnCandle(candle Candle) (Signal, float64) {
+	strategy.seen = append(strategy.seen, candle)
+	return BUY, candle.Close.Bid
+}
+
+func (strategy *_TestRecordingStrategy) Reset() {
+	strategy.resets++
+	strategy.seen = nil
+}
+
+func TestFuncStrategy(t *testing.T) {
+	calls := 0
+	strategy := NewFuncStrategy(func(candles Candles) (Signal, float64) {
+		calls++
+		if len(candles) != 3 {
+			t.Errorf("expected window of 3 candles, got %d", len(candles))
+		}
+		return BUY, candles[len(candles)-1].Close.Bid
+	}, 3)
+
+	tests := []struct {
+		candle         Candle
+		expectedSignal Signal
+		expectedPrice  float64
+	}{
+		{_TestCandles(1)[0], NONE, 1}, // warming up; the price is the last close
+		{_TestCandles(1, 2)[1], NONE, 2},
+		{_TestCandles(1, 2, 3)[2], BUY, 3},
+		{_TestCandles(1, 2, 3.5)[2], BUY, 3.5}, // update of the last bar
+		{_TestCandles(1, 2)[1], NONE, 3.5},     // older bars are ignored
+		{_TestCandles(1, 2, 3, 4)[3], BUY, 4},
+	}
+	for i, test := range tests {
+		signal, price := strategy.OnCandle(test.candle)
+		if signal != test.expectedSignal || price != test.expectedPrice {
+			t.Errorf("Test case %d: expected %s %f, got %s %f", i, test.expectedSignal, test.expectedPrice, signal, price)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+
+	strategy.Reset()
+	if signal, _ := strategy.OnCandle(_TestCandles(1, 2, 3, 4, 5)[4]); signal != NONE {
+		t.Errorf("strategy should warm up again after Reset, got %s", signal)
+	}
+	if NewFuncStrategy(GPTStrategy, 200).Name() != "GPTStrategy" {
+		t.Errorf("unexpected name %s", NewFuncStrategy(GPTStrategy, 200).Name())
+	}
+}
+
+func TestMinitraderWarmUpHoldsPosition(t *testing.T) {
+	paper := NewPaperBroker(nil, 10000, "USD")
+	paper.UpdatePrice("USDMXN", 20, 20)
+	response, _ := paper.CreatePosition(context.Background(), CreatePositionBody{Epic: "USDMXN", Direction: BUY, Size: 1})
+	minitrader := NewMinitrader("USDMXN", 100, 5, 1, MINUTE, GPTStrategy)
+	minitrader.broker = paper
+	minitrader.setTrade(response.DealReference, "", 20, 1)
+	minitrader.transition(HOLDING, "test position")
+
+	// far fewer candles than the window of the strategy, e.g. warming up from a short candle store
+	signal, price := minitrader.onCandles(_TestCandles(20, 20.1, 20.05))
+	if signal != NONE || price != 20.05 {
+		t.Errorf("expected no signal at the last close, got %s at %f", signal, price)
+	}
+	if err := minitrader.Effect(context.Background(), signal, price); err != nil {
+		t.Fatal(err)
+	}
+	if positions, _ := paper.GetPositions(context.Background()); minitrader.Status() != HOLDING || len(positions.Positions) != 1 {
+		t.Errorf("expected the position to be held while warming up, got %s", minitrader.Status())
+	}
+}
+
+func TestMinitraderEvaluate(t *testing.T) {
+	strategy := &_TestRecordingStrategy{warmUp: 1}
+	minitrader := NewStatefulMinitrader("USDMXN", 100, 5, 1, MINUTE, strategy)
+
+	tests := []struct {
+		candles        Candles
+		expectedResets int
+		expectedSeen   int // candles seen since the last reset
+	}{
+		{_TestCandles(1, 2, 3), 1, 3},
+		{_TestCandles(1, 2, 3.5), 1, 4},            // the bar in progress is fed again
+		{_TestCandles(1, 2, 3.5, 4)[1:], 1, 6},     // a new bar; the updated one is fed again as well
+		{_TestCandles(1, 2, 3, 4, 5, 6)[5:], 2, 1}, // a gap resets the strategy
+	}
+	for i, test := range tests {
+		signal, price := minitrader.evaluate(test.candles)
+		last := test.candles[len(test.candles)-1]
+		if signal != BUY || price != last.Close.Bid {
+			t.Errorf("Test case %d: unexpected signal %s %f", i, signal, price)
+		}
+		if strategy.resets != test.expectedResets || len(strategy.seen) != test.expectedSeen {
+			t.Errorf("Test case %d: expected %d resets and %d candles seen, got %d and %d", i, test.expectedResets, test.expectedSeen, strategy.resets, len(strategy.seen))
+		}
+	}
+}
+
+func TestGPTStrategies(t *testing.T) {
+	flat := make([]float64, 60)
+	for i := range flat {
+		flat[i] = 20 + 0.01*float64(i%2)
+	}
+	crash := append(append([]float64{}, flat...), 19.5, 19, 18.5)
+	rally := append(append([]float64{}, flat...), 20.5, 21, 21.5)
+	uptrendDip, downtrendSpike := make([]float64, 60), make([]float64, 60)
+	for i := range uptrendDip {
+		uptrendDip[i] = 20 + 0.1*float64(i)
+		downtrendSpike[i] = 20 - 0.1*float64(i)
+	}
+	uptrendDip[59], downtrendSpike[59] = 24, 16
+
+	tests := []struct {
+		strategy       Strategy
+		closes         []float64
+		expectedSignal Signal
+	}{
+		{GPTStrategy, flat, NONE},
+		{GPTStrategy, crash, BUY},
+		{GPTStrategy, rally, SELL},
+		{GPTStrategy, crash[:10], NONE}, // not enough candles to warm up
+		{GPTShortTermStrategy, uptrendDip, BUY},
+		{GPTShortTermStrategy, downtrendSpike, SELL},
+		{GPTShortTermStrategy, uptrendDip[:30], NONE},
+	}
+	for i, test := range tests {
+		signal, price := test.strategy(_TestCandles(test.closes...))
+		if signal != test.expectedSignal || price != test.closes[len(test.closes)-1] {
+			t.Errorf("Test case %d: expected %s at %f, got %s at %f", i, test.expectedSignal, test.closes[len(test.closes)-1], signal, price)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	gominitrader "github.com/menesesghz/go-minitrader"
+)
+
+const botHelp = `/status - status of every minitrader
+/positions - open positions of the pool epics
+/balance - balance of the preferred account
+/pause <epic> - stop entering new trades on epic
+/resume <epic> - trade epic again, recovering from error statuses
+/flatten <epic> - pause epic, delete its working orders and close its positions
+/stop - stop the pool`
+
+// Bot monitors and controls a MinitraderPool from Telegram. Commands are only taken from the allowed
+// chats, which are also pushed the positions opened and closed and the minitraders failing.
+type Bot struct {
+	Client         *Client
+	Pool           *gominitrader.MinitraderPool
+	AllowedChatIDs []int64
+	PollTimeout    time.Duration // long polling timeout of getUpdates
+	RetryTime      time.Duration // wait after a failed getUpdates
+}
+
+func NewBot(client *Client, pool *gominitrader.MinitraderPool, allowedChatIDs ...int64) *Bot {
+	return &Bot{
+		Client:         client,
+		Pool:           pool,
+		AllowedChatIDs: allowedChatIDs,
+		PollTimeout:    time.Second * 30,
+		RetryTime:      time.Second * 5,
+	}
+}
+
+// Run answers commands and pushes notifications until ctx is done.
+func (bot *Bot) Run(ctx context.Context) error {
+	subscription := bot.Pool.Subscribe(100, gominitrader.EVENT_POSITION_OPENED, gominitrader.EVENT_POSITION_CLOSED, gominitrader.EVENT_STATUS_CHANGED,
+		gominitrader.EVENT_TRADE_RESTORED, gominitrader.EVENT_ORPHAN_TRADE_FOUND)
+	defer subscription.Unsubscribe()
+	go bot.push(ctx, subscription)
+
+	var offset int64
+	for {
+		updates, err := bot.Client.GetUpdates(ctx, offset, bot.PollTimeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			gominitrader.Logger().Warn("unable to get telegram updates", "error", err)
+			if sleep(ctx, bot.RetryTime) != nil {
+				return nil
+			}
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil || update.Message.Text == "" {
+				continue
+			}
+			if !bot.allowed(update.Message.Chat.ID) {
+				gominitrader.Logger().Warn("telegram command ignored; chat not allowed", "chat_id", update.Message.Chat.ID)
+				continue
+			}
+			reply := bot.Command(ctx, update.Message.Text)
+			if err := bot.Client.SendMessage(ctx, update.Message.Chat.ID, reply); err != nil {
+				gominitrader.Logger().Warn("unable to send telegram reply", "error", err)
+			}
+		}
+	}
+}
+
+// Command runs a command and returns the reply.
+func (bot *Bot) Command(ctx context.Context, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return botHelp
+	}
+	command := strings.SplitN(fields[0], "@", 2)[0] // commands in groups are sent as /command@botname
+	args := fields[1:]
+
+	switch command {
+	case "/status":
+		return bot.status()
+	case "/positions":
+		return bot.positions(ctx)
+	case "/balance":
+		return bot.balance(ctx)
+	case "/pause", "/resume", "/flatten":
+		if len(args) != 1 {
+			return fmt.Sprintf("Usage: %s <epic>", command)
+		}
+		return bot.control(ctx, command, strings.ToUpper(args[0]))
+	case "/stop":
+		bot.Pool.Stop()
+		return "Stopping the pool"
+	}
+	return botHelp
+}
+
+func (bot *Bot) status() string {
+	lines := []string{}
+	for _, minitrader := range bot.Pool.ListMinitraders() {
+		line := fmt.Sprintf("%s %s: %s (%s)", minitrader.Epic, minitrader.Timeframe, minitrader.Status(), minitrader.MarketStatus())
+		if minitrader.Paused() {
+			line += " paused"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (bot *Bot) positions(ctx context.Context) string {
+	positionsResponse, err := bot.Pool.Broker.GetPositions(ctx)
+	if err != nil {
+		return fmt.Sprintf("Unable to get positions: %s", err)
+	}
+	epics := map[string]bool{}
+	for _, minitrader := range bot.Pool.ListMinitraders() {
+		epics[minitrader.Epic] = true
+	}
+	lines := []string{}
+	for _, position := range positionsResponse.Positions {
+		if !epics[position.Market.Epic] {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %g @ %g (%s)", position.Market.Epic, position.Position.Direction, position.Position.Size, position.Position.Level, position.Position.DealID))
+	}
+	if len(lines) == 0 {
+		return "No open positions"
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (bot *Bot) balance(ctx context.Context) string {
+	account, err := bot.Pool.Broker.GetPreferredAccount(ctx)
+	if err != nil {
+		return fmt.Sprintf("Unable to get balance: %s", err)
+	}
+	return fmt.Sprintf("Balance: %.2f %s\nAvailable: %.2f\nP&L: %.2f", account.Balance.Balance, account.Currency, account.Balance.Available, account.Balance.ProfitLoss)
+}
+
+func (bot *Bot) control(ctx context.Context, command string, epic string) string {
+	var err error
+	var done string
+	switch command {
+	case "/pause":
+		err, done = bot.Pool.Pause(epic), "paused"
+	case "/resume":
+		err, done = bot.Pool.Resume(epic), "resumed"
+	case "/flatten":
+		err, done = bot.Pool.Flatten(ctx, epic), "flattened"
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s %s", epic, done)
+}
+
+// push sends the notifications of the subscribed events to every allowed chat.
+func (bot *Bot) push(ctx context.Context, subscription *gominitrader.Subscription) {
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+			text, notify := notification(event)
+			if !notify {
+				continue
+			}
+			for _, chatID := range bot.AllowedChatIDs {
+				if err := bot.Client.SendMessage(ctx, chatID, text); err != nil {
+					gominitrader.Logger().Warn("unable to send telegram notification", "chat_id", chatID, "error", err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// notification returns the message pushed for event: fills, stop outs and other exits, trades restored or
+// left orphan on startup, and minitraders moving to an error status.
+func notification(event gominitrader.Event) (string, bool) {
+	info := event.Info()
+	switch event := event.(type) {
+	case gominitrader.PositionOpened:
+		return fmt.Sprintf("%s %s: filled %g @ %g (%s)", info.Epic, info.Timeframe, event.Size, event.Level, event.DealID), true
+	case gominitrader.PositionClosed:
+		switch event.Reason {
+		case "STOP_LOSS":
+			return fmt.Sprintf("%s %s: stopped out @ %g (%s)", info.Epic, info.Timeframe, event.Level, event.DealID), true
+		case "TAKE_PROFIT":
+			return fmt.Sprintf("%s %s: took profit @ %g (%s)", info.Epic, info.Timeframe, event.Level, event.DealID), true
+		}
+		return fmt.Sprintf("%s %s: closed @ %g (%s) - %s", info.Epic, info.Timeframe, event.Level, event.DealID, event.Reason), true
+	case gominitrader.TradeRestored:
+		return fmt.Sprintf("%s %s: restored %s %g @ %g (%s)", info.Epic, info.Timeframe, tradeKind(event.WorkingOrder), event.Size, event.Level, event.DealID), true
+	case gominitrader.OrphanTradeFound:
+		return fmt.Sprintf("%s: orphan %s %s %g @ %g (%s) - %s", info.Epic, tradeKind(event.WorkingOrder), event.Direction, event.Size, event.Level, event.DealID, event.Reason), true
+	case gominitrader.StatusChanged:
+		if event.Transition.To.IsError() {
+			return fmt.Sprintf("%s %s: %s - %s", info.Epic, info.Timeframe, event.Transition.To, event.Transition.Reason), true
+		}
+	}
+	return "", false
+}
+
+func (bot *Bot) allowed(chatID int64) bool {
+	for _, allowedChatID := range bot.AllowedChatIDs {
+		if chatID == allowedChatID {
+			return true
+		}
+	}
+	return false
+}
+
+// sleep waits for duration, returning early with the error of ctx if it is done first.
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func tradeKind(workingOrder bool) string {
+	if workingOrder {
+		return "working order"
+	}
+	return "position"
+}
@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	gominitrader "github.com/menesesghz/go-minitrader"
+)
+
+func _TestBot(t *testing.T) (*Bot, *Emulator, *gominitrader.PaperBroker) {
+	paper := gominitrader.NewPaperBroker(nil, 10000, "USD")
+	paper.UpdatePrice("USDMXN", 19.5, 19.5)
+	paper.UpdatePrice("EURUSD", 1.1, 1.1)
+	paper.CreatePosition(context.Background(), gominitrader.CreatePositionBody{Epic: "USDMXN", Direction: gominitrader.BUY, Size: 10})
+	paper.CreatePosition(context.Background(), gominitrader.CreatePositionBody{Epic: "EURUSD", Direction: gominitrader.BUY, Size: 10})
+	pool, err := gominitrader.NewMinitraderPool(paper, gominitrader.NewMinitrader("USDMXN", 100, 5, 0.5, gominitrader.MINUTE_15, gominitrader.GPTStrategy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emulator := NewEmulator("123:token")
+	t.Cleanup(emulator.Close)
+	bot := NewBot(emulator.NewClient(), pool, 1)
+	bot.PollTimeout = time.Second
+	return bot, emulator, paper
+}
+
+func TestBotCommands(t *testing.T) {
+	bot, emulator, paper := _TestBot(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bot.Run(ctx)
+
+	tests := []struct {
+		text             string
+		expectedContains string
+	}{
+		{"/status", "USDMXN MINUTE_15: NEW"},
+		{"/positions", "USDMXN BUY 10 @ 19.5"},
+		{"/balance", "Available: 9794.00"},
+		{"/pause usdmxn", "USDMXN paused"},
+		{"/status@minitrader_bot", "paused"},
+		{"/pause EURUSD", "No Minitrader Trades EURUSD"},
+		{"/resume", "Usage: /resume <epic>"},
+		{"/flatten USDMXN", "USDMXN flattened"},
+		{"/positions", "No open positions"},
+		{"/help", "/flatten <epic>"},
+	}
+	for i, test := range tests {
+		emulator.SendMessage(1, test.text)
+		sent := emulator.WaitSent(1, i+1, time.Second*2)
+		if len(sent) != i+1 {
+			t.Fatalf("Test case %d: expected a reply to %s, got %v", i, test.text, sent)
+		}
+		if !strings.Contains(sent[i], test.expectedContains) {
+			t.Errorf("Test case %d: expected %q in the reply to %s, got %q", i, test.expectedContains, test.text, sent[i])
+		}
+	}
+
+	// the position of an epic the pool does not trade is left open
+	positions, _ := paper.GetPositions(context.Background())
+	if len(positions.Positions) != 1 || positions.Positions[0].Market.Epic != "EURUSD" {
+		t.Errorf("expected only the EURUSD position to be left, got %+v", positions.Positions)
+	}
+}
+
+func TestBotAllowList(t *testing.T) {
+	bot, emulator, _ := _TestBot(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bot.Run(ctx)
+
+	emulator.SendMessage(2, "/flatten USDMXN")
+	emulator.SendMessage(1, "/status")
+	if sent := emulator.WaitSent(1, 1, time.Second*2); len(sent) != 1 {
+		t.Fatalf("expected a reply to the allowed chat, got %v", sent)
+	}
+	if sent := emulator.Sent(2); len(sent) != 0 {
+		t.Errorf("expected no reply to a chat not allowed, got %v", sent)
+	}
+	if bot.Pool.Minitraders[0].Paused() {
+		t.Errorf("expected the command of a chat not allowed to be ignored")
+	}
+}
+
+func TestBotNotifications(t *testing.T) {
+	info := gominitrader.EventInfo{Time: time.Now(), Epic: "USDMXN", Timeframe: gominitrader.MINUTE_15}
+	tests := []struct {
+		event          gominitrader.Event
+		expectedNotify bool
+		expectedText   string
+	}{
+		{gominitrader.PositionOpened{EventInfo: info, DealID: "p_1", Level: 19.5, Size: 10}, true, "USDMXN MINUTE_15: filled 10 @ 19.5 (p_1)"},
+		{gominitrader.PositionClosed{EventInfo: info, DealID: "p_1", Level: 18.5, Reason: "STOP_LOSS"}, true, "USDMXN MINUTE_15: stopped out @ 18.5 (p_1)"},
+		{gominitrader.PositionClosed{EventInfo: info, DealID: "p_1", Level: 19.6, Reason: "TAKE_PROFIT"}, true, "USDMXN MINUTE_15: took profit @ 19.6 (p_1)"},
+		{gominitrader.StatusChanged{EventInfo: info, Transition: gominitrader.Transition{From: gominitrader.RUNNING, To: gominitrader.ERROR_ON_MAKING_ORDER, Reason: "entry failed"}}, true, "USDMXN MINUTE_15: ERROR_ON_MAKING_ORDER - entry failed"},
+		{gominitrader.StatusChanged{EventInfo: info, Transition: gominitrader.Transition{From: gominitrader.RUNNING, To: gominitrader.HOLDING}}, false, ""},
+		{gominitrader.TradeRestored{EventInfo: info, DealID: "p_1", Level: 19.5, Size: 10}, true, "USDMXN MINUTE_15: restored position 10 @ 19.5 (p_1)"},
+		{gominitrader.OrphanTradeFound{EventInfo: gominitrader.EventInfo{Epic: "USDMXN"}, DealID: "o_2", WorkingOrder: true, Direction: "SELL", Level: 20, Size: 1, Reason: "minitraders only enter buy trades"}, true, "USDMXN: orphan working order SELL 1 @ 20 (o_2) - minitraders only enter buy trades"},
+		{gominitrader.OrderSubmitted{EventInfo: info}, false, ""},
+	}
+	for i, test := range tests {
+		text, notify := notification(test.event)
+		if notify != test.expectedNotify || text != test.expectedText {
+			t.Errorf("Test case %d: expected %t %q, got %t %q", i, test.expectedNotify, test.expectedText, notify, text)
+		}
+	}
+
+	// notifications are pushed to every allowed chat
+	bot, emulator, _ := _TestBot(t)
+	bot.AllowedChatIDs = []int64{1, 2}
+	bus := gominitrader.NewEventBus()
+	subscription := bus.Subscribe(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bot.push(ctx, subscription)
+	bus.Publish(tests[1].event)
+	for _, chatID := range bot.AllowedChatIDs {
+		if sent := emulator.WaitSent(chatID, 1, time.Second*2); len(sent) != 1 || sent[0] != tests[1].expectedText {
+			t.Errorf("expected chat %d to be notified, got %v", chatID, sent)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	gominitrader "github.com/menesesghz/go-minitrader"
+)
+
+const TELEGRAM_API_URL = "https://api.telegram.org"
+
+// Client is a minimal Telegram Bot API client: it long polls updates and sends messages. Point BaseURL at
+// an Emulator to test without Telegram.
+type Client struct {
+	Token      string
+	BaseURL    string
+	HttpClient *http.Client
+}
+
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	From      *User  `json:"from,omitempty"`
+	Chat      Chat   `json:"chat"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text,omitempty"`
+}
+
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+type Chat struct {
+	ID int64 `json:"id"`
+}
+
+type GetUpdatesBody struct {
+	Offset         int64    `json:"offset,omitempty"`
+	Timeout        int      `json:"timeout,omitempty"` // seconds
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
+}
+
+type SendMessageBody struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// apiResponse is the envelope of every Bot API response.
+type apiResponse struct {
+	Ok          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	ErrorCode   int             `json:"error_code,omitempty"`
+	Description string          `json:"description,omitempty"`
+}
+
+// APIError is a Bot API response that is not ok.
+type APIError struct {
+	Method      string
+	ErrorCode   int
+	Description string
+}
+
+func (apiError *APIError) Error() string {
+	return fmt.Sprintf("Telegram %s Failed [%d] - %s", apiError.Method, apiError.ErrorCode, apiError.Description)
+}
+
+func NewClient(token string) *Client {
+	client := &Client{Token: token, BaseURL: TELEGRAM_API_URL, HttpClient: &http.Client{}}
+	gominitrader.RedactSecret(fmt.Sprintf("%p TELEGRAM_TOKEN", client), token)
+	return client
+}
+
+// GetUpdates returns the messages received from offset on, waiting up to timeout for one to arrive.
+func (client *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	updates := []Update{}
+	body := GetUpdatesBody{Offset: offset, Timeout: int(timeout / time.Second), AllowedUpdates: []string{"message"}}
+	if err := client.call(ctx, "getUpdates", body, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SendMessage sends text to a chat.
+func (client *Client) SendMessage(ctx context.Context, chatID int64, text string) error {
+	return client.call(ctx, "sendMessage", SendMessageBody{ChatID: chatID, Text: text}, &Message{})
+}
+
+func (client *Client) call(ctx context.Context, method string, body interface{}, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/bot%s/%s", client.BaseURL, client.Token, method)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.HttpClient.Do(request)
+	if err != nil {
+		// the request URL carries the bot token; keep it out of the error
+		var urlError *url.Error
+		if errors.As(err, &urlError) {
+			err = urlError.Err
+		}
+		return fmt.Errorf("Telegram %s Request Failed: %w", method, err)
+	}
+	defer response.Body.Close()
+
+	envelope := apiResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return &APIError{Method: method, ErrorCode: response.StatusCode, Description: err.Error()}
+	}
+	if !envelope.Ok {
+		return &APIError{Method: method, ErrorCode: envelope.ErrorCode, Description: envelope.Description}
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
@@ -0,0 +1,150 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Emulator is an in-process fake of the Telegram Bot API built on `httptest`. Tests send it messages as
+// if they were typed in a chat, and read back what the bot sent. Use `NewClient()` to point a client at it.
+type Emulator struct {
+	Server *httptest.Server
+	URL    string
+
+	token string
+
+	mutex    sync.Mutex
+	updates  []Update
+	sent     []SendMessageBody
+	received chan struct{} // closed and replaced on every new update, to wake long polls
+}
+
+func NewEmulator(token string) *Emulator {
+	emulator := &Emulator{token: token, received: make(chan struct{})}
+	emulator.Server = httptest.NewServer(http.HandlerFunc(emulator.handle))
+	emulator.URL = emulator.Server.URL
+	return emulator
+}
+
+// NewClient returns a client talking to the emulator.
+func (emulator *Emulator) NewClient() *Client {
+	client := NewClient(emulator.token)
+	client.BaseURL = emulator.URL
+	return client
+}
+
+func (emulator *Emulator) Close() {
+	emulator.Server.Close()
+}
+
+// SendMessage queues text as a message typed in chatID.
+func (emulator *Emulator) SendMessage(chatID int64, text string) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	updateID := int64(len(emulator.updates) + 1)
+	emulator.updates = append(emulator.updates, Update{
+		UpdateID: updateID,
+		Message:  &Message{MessageID: updateID, From: &User{ID: chatID}, Chat: Chat{ID: chatID}, Date: time.Now().Unix(), Text: text},
+	})
+	close(emulator.received)
+	emulator.received = make(chan struct{})
+}
+
+// Sent returns the messages the bot sent to chatID.
+func (emulator *Emulator) Sent(chatID int64) []string {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	texts := []string{}
+	for _, message := range emulator.sent {
+		if message.ChatID == chatID {
+			texts = append(texts, message.Text)
+		}
+	}
+	return texts
+}
+
+// WaitSent waits up to timeout for the bot to have sent count messages to chatID, and returns them.
+func (emulator *Emulator) WaitSent(chatID int64, count int, timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	for {
+		sent := emulator.Sent(chatID)
+		if len(sent) >= count || time.Now().After(deadline) {
+			return sent
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func (emulator *Emulator) handle(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/bot"+emulator.token+"/")
+	if path == r.URL.Path {
+		writeEmulatorResponse(w, apiResponse{ErrorCode: http.StatusUnauthorized, Description: "Unauthorized"})
+		return
+	}
+	switch path {
+	case "getUpdates":
+		body := GetUpdatesBody{}
+		json.NewDecoder(r.Body).Decode(&body)
+		emulator.handleGetUpdates(w, r, body)
+	case "sendMessage":
+		body := SendMessageBody{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ChatID == 0 {
+			writeEmulatorResponse(w, apiResponse{ErrorCode: http.StatusBadRequest, Description: "Bad Request: chat not found"})
+			return
+		}
+		emulator.mutex.Lock()
+		emulator.sent = append(emulator.sent, body)
+		emulator.mutex.Unlock()
+		writeEmulatorResult(w, Message{Chat: Chat{ID: body.ChatID}, Date: time.Now().Unix(), Text: body.Text})
+	default:
+		writeEmulatorResponse(w, apiResponse{ErrorCode: http.StatusNotFound, Description: "Not Found"})
+	}
+}
+
+// handleGetUpdates returns the updates from the offset on, long polling for up to the timeout when there
+// are none.
+func (emulator *Emulator) handleGetUpdates(w http.ResponseWriter, r *http.Request, body GetUpdatesBody) {
+	timer := time.NewTimer(time.Duration(body.Timeout) * time.Second)
+	defer timer.Stop()
+	for {
+		emulator.mutex.Lock()
+		updates := []Update{}
+		for _, update := range emulator.updates {
+			if update.UpdateID >= body.Offset {
+				updates = append(updates, update)
+			}
+		}
+		received := emulator.received
+		emulator.mutex.Unlock()
+
+		if len(updates) != 0 || body.Timeout == 0 {
+			writeEmulatorResult(w, updates)
+			return
+		}
+		select {
+		case <-received:
+		case <-timer.C:
+			writeEmulatorResult(w, updates)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEmulatorResult(w http.ResponseWriter, result interface{}) {
+	encoded, _ := json.Marshal(result)
+	writeEmulatorResponse(w, apiResponse{Ok: true, Result: encoded})
+}
+
+func writeEmulatorResponse(w http.ResponseWriter, response apiResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Ok {
+		w.WriteHeader(response.ErrorCode)
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,145 @@
+package gominitrader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeframeUnits are the units of custom timeframes, from the largest; "HOUR_2" is two hours.
+var timeframeUnits = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"WEEK", time.Hour * 24 * 7},
+	{"DAY", time.Hour * 24},
+	{"HOUR", time.Hour},
+	{"MINUTE", time.Minute},
+}
+
+// nativeTimeframes are the resolutions served by Capital.com, from the largest.
+var nativeTimeframes = []Timeframe{WEEK, DAY, HOUR_4, HOUR, MINUTE_30, MINUTE_15, MINUTE_5, MINUTE}
+
+// NewTimeframe returns the timeframe of a duration, e.g. "MINUTE_3" or "HOUR_12". Native durations give
+// Capital.com timeframes.
+func NewTimeframe(duration time.Duration) (Timeframe, error) {
+	for _, native := range nativeTimeframes {
+		if nativeDuration, _ := native.Duration(); nativeDuration == duration {
+			return native, nil
+		}
+	}
+	if duration > time.Hour*24 && duration%(time.Hour*24) != 0 {
+		return "", fmt.Errorf("Invalid Timeframe Duration: %s; Must Be A Whole Number Of Days When Longer Than A Day", duration)
+	}
+	for _, unit := range timeframeUnits {
+		if duration > 0 && duration%unit.duration == 0 {
+			return Timeframe(fmt.Sprintf("%s_%d", unit.name, duration/unit.duration)), nil
+		}
+	}
+	return "", fmt.Errorf("Invalid Timeframe Duration: %s; Must Be A Whole Number Of Minutes", duration)
+}
+
+// Duration returns the length of a bar of the timeframe. Besides Capital.com resolutions, custom
+// timeframes such as "MINUTE_3", "HOUR_2" or "HOUR_12" are supported; timeframes longer than a day
+// must be whole days, so "HOUR_36" is invalid.
+func (timeframe Timeframe) Duration() (time.Duration, error) {
+	if minutes, ok := TimeframeMinuteMap[timeframe]; ok {
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	parts := strings.SplitN(string(timeframe), "_", 2)
+	for _, unit := range timeframeUnits {
+		if parts[0] != unit.name {
+			continue
+		}
+		n := 1
+		if len(parts) == 2 {
+			var err error
+			if n, err = strconv.Atoi(parts[1]); err != nil || n < 1 {
+				break
+			}
+		}
+		duration := time.Duration(n) * unit.duration
+		if duration > time.Hour*24 && duration%(time.Hour*24) != 0 {
+			// bars longer than a day must start at midnight, see bucketStart
+			break
+		}
+		return duration, nil
+	}
+	return 0, fmt.Errorf("Invalid Timeframe: %s", timeframe)
+}
+
+// Native reports whether Capital.com serves prices for the timeframe.
+func (timeframe Timeframe) Native() bool {
+	_, ok := TimeframeMinuteMap[timeframe]
+	return ok
+}
+
+// baseTimeframe returns the largest native timeframe every one of timeframes can be resampled from. Bars
+// are aligned to midnight in location while native bars are aligned to UTC, so the offsets of location,
+// in winter and summer, must be multiples of the base as well as the frame durations.
+func baseTimeframe(location *time.Location, timeframes ...Timeframe) (Timeframe, error) {
+	durations := []time.Duration{}
+	for _, timeframe := range timeframes {
+		duration, err := timeframe.Duration()
+		if err != nil {
+			return "", err
+		}
+		durations = append(durations, duration)
+	}
+	durations = append(durations, locationOffsets(location)...)
+
+	for _, base := range nativeTimeframes {
+		baseDuration, _ := base.Duration()
+		divides := true
+		for _, duration := range durations {
+			if duration%baseDuration != 0 || (base == WEEK && duration != 0 && duration != baseDuration) {
+				divides = false
+			}
+		}
+		if divides {
+			return base, nil
+		}
+	}
+	return MINUTE, nil
+}
+
+// locationOffsets returns the offsets from UTC of location in January and in July of the current year.
+func locationOffsets(location *time.Location) []time.Duration {
+	if location == nil {
+		return nil
+	}
+	offsets := []time.Duration{}
+	for _, month := range []time.Month{time.January, time.July} {
+		_, offset := time.Date(time.Now().Year(), month, 1, 0, 0, 0, 0, location).Zone()
+		offsets = append(offsets, time.Duration(offset)*time.Second)
+	}
+	return offsets
+}
+
+// bucketStart returns the unix timestamp of the start of the bar of length duration containing timestamp.
+// Bars of a day or longer are whole days starting at midnight in location, and weeks start on Monday;
+// shorter bars are counted from midnight as well.
+func bucketStart(timestamp int64, duration time.Duration, location *time.Location) int64 {
+	day := time.Hour * 24
+	week := day * 7
+	t := time.Unix(timestamp, 0).In(location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+
+	if duration%day != 0 {
+		elapsed := t.Sub(midnight)
+		return midnight.Add(elapsed - elapsed%duration).Unix()
+	}
+
+	// whole days, counted on the calendar of the location
+	days := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+	if duration%week == 0 {
+		days -= 4 // 1970-01-05 is the first Monday
+	}
+	n := int64(duration / day)
+	startDays := days - ((days%n)+n)%n
+	if duration%week == 0 {
+		startDays += 4
+	}
+	return time.Date(1970, 1, 1+int(startDays), 0, 0, 0, 0, location).Unix()
+}